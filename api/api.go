@@ -1,17 +1,37 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
 	"time"
 
 	"assetmanager/pkg/network"
+	"assetmanager/pkg/store"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AssetResult represents the structure of the assets.json file
+// storePath is the inventory database the handlers read from
+var storePath = "assets.db"
+
+// sharedStore, when set, is used instead of opening storePath per request
+var sharedStore *store.Store
+
+// SetStorePath sets the inventory database the handlers read from. The
+// database is opened read-only for each request so another process can
+// keep writing to it.
+func SetStorePath(path string) {
+	storePath = path
+}
+
+// SetStore makes the handlers read from an inventory store that is already
+// open in this process
+func SetStore(inventory *store.Store) {
+	sharedStore = inventory
+}
+
+// AssetResult represents the current asset inventory and the last scan summary
 type AssetResult struct {
 	Timestamp   string          `json:"timestamp"`
 	TotalHosts  int             `json:"total_hosts"`
@@ -27,6 +47,9 @@ type GetAssetsResponse struct {
 	Message     string       `json:"message,omitempty"`
 	Data        *AssetResult `json:"data,omitempty"`
 	AssetsCount int          `json:"assets_count"`
+	TotalCount  int          `json:"total_count"`
+	MatchCount  int          `json:"match_count"`
+	NextCursor  string       `json:"next_cursor,omitempty"`
 	HasAssets   bool         `json:"has_assets"`
 	Timestamp   string       `json:"response_timestamp"`
 }
@@ -37,18 +60,31 @@ func HandleHome(c *gin.Context) {
 		"version": "1.0.0",
 		"endpoints": []string{
 			"GET /assets - Get all discovered assets",
+			"GET /assets/:id - Get an asset by IP, MAC or hostname",
+			"GET /assets/:id/ports - Get the services found on an asset",
+			"GET /assets/:id/ports/:port/screenshot - Get the screenshot of a web service",
+			"GET /assets/:id/history - Get the change timeline of an asset",
+			"GET /scans - Get recorded scan runs",
+			"GET /scans/diff - Compare the assets of two scan runs",
+			"POST /scans - Start an on-demand scan",
+			"GET /scans/:id - Get an on-demand scan job, or a recorded scan run by numeric ID",
+			"DELETE /scans/:id - Cancel an on-demand scan",
+			"GET /scheduler - Get the scan scheduler state",
+			"GET /port-profiles - Get the named port profiles",
+			"GET /certificates/expiring - Get the TLS certificates expiring within N days",
+			"GET /findings - Get ARP spoofing and address conflict findings",
 		},
 	})
 }
 
-// GetAssets handles the /getAssets endpoint
+// GetAssets handles the /assets and /getAssets endpoints. See ParseAssetQuery
+// for the supported filter, sort and pagination parameters.
 func GetAssets(c *gin.Context) {
-	// Read the assets.json file
-	data, err := os.ReadFile("assets.json")
+	query, err := ParseAssetQuery(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, GetAssetsResponse{
+		c.JSON(http.StatusBadRequest, GetAssetsResponse{
 			Success:     false,
-			Message:     "Failed to read assets file: " + err.Error(),
+			Message:     "Invalid query: " + err.Error(),
 			AssetsCount: 0,
 			HasAssets:   false,
 			Timestamp:   time.Now().Format("2006-01-02 15:04:05"),
@@ -56,12 +92,11 @@ func GetAssets(c *gin.Context) {
 		return
 	}
 
-	// Parse the JSON data
-	var assetResult AssetResult
-	if err := json.Unmarshal(data, &assetResult); err != nil {
+	assetResult, err := loadInventory()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, GetAssetsResponse{
 			Success:     false,
-			Message:     "Failed to parse assets data: " + err.Error(),
+			Message:     "Failed to read asset inventory: " + err.Error(),
 			AssetsCount: 0,
 			HasAssets:   false,
 			Timestamp:   time.Now().Format("2006-01-02 15:04:05"),
@@ -74,6 +109,10 @@ func GetAssets(c *gin.Context) {
 		assetResult.Assets = []network.Asset{}
 	}
 
+	totalCount := len(assetResult.Assets)
+	page, matchCount, nextCursor := query.Apply(assetResult.Assets)
+	assetResult.Assets = page
+
 	// Determine if we have assets and get count
 	assetsCount := len(assetResult.Assets)
 	hasAssets := assetsCount > 0
@@ -81,7 +120,9 @@ func GetAssets(c *gin.Context) {
 	// Prepare response message based on assets availability
 	var message string
 	if !hasAssets {
-		if assetResult.TotalHosts == 0 {
+		if totalCount > 0 {
+			message = "No assets match the query."
+		} else if assetResult.TotalHosts == 0 {
 			message = "No assets have been discovered yet. Run asset discovery scan to populate data."
 		} else {
 			message = "Asset scan completed but no active hosts found."
@@ -94,9 +135,67 @@ func GetAssets(c *gin.Context) {
 	c.JSON(http.StatusOK, GetAssetsResponse{
 		Success:     true,
 		Message:     message,
-		Data:        &assetResult,
+		Data:        assetResult,
 		AssetsCount: assetsCount,
+		TotalCount:  totalCount,
+		MatchCount:  matchCount,
+		NextCursor:  nextCursor,
 		HasAssets:   hasAssets,
 		Timestamp:   time.Now().Format("2006-01-02 15:04:05"),
 	})
 }
+
+// errNoInventory is returned by withStore before the first scan has created the database
+var errNoInventory = errors.New("no scan has been recorded yet")
+
+// withStore runs fn against the shared store, or opens the inventory
+// database read-only for the duration of fn
+func withStore(fn func(inventory *store.Store) error) error {
+	if sharedStore != nil {
+		return fn(sharedStore)
+	}
+
+	if _, err := os.Stat(storePath); errors.Is(err, os.ErrNotExist) {
+		return errNoInventory
+	}
+
+	inventory, err := store.OpenReadOnly(storePath)
+	if err != nil {
+		return err
+	}
+	defer inventory.Close()
+
+	return fn(inventory)
+}
+
+// loadInventory reads the asset inventory and last scan summary from the store.
+// A missing database means no scan has completed yet and yields an empty result.
+func loadInventory() (*AssetResult, error) {
+	result := &AssetResult{}
+
+	err := withStore(func(inventory *store.Store) error {
+		assets, err := inventory.GetAssets()
+		if err != nil {
+			return err
+		}
+		result.Assets = assets
+
+		latest, ok, err := inventory.GetLatestScan()
+		if err != nil {
+			return err
+		}
+		if ok {
+			result.Timestamp = latest.Timestamp
+			result.TotalHosts = latest.TotalHosts
+			result.ScanTime = latest.ScanTime
+			result.LocalNet = latest.LocalNet
+			result.FileTargets = latest.FileTargets
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errNoInventory) {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"assetmanager/pkg/network"
+	"assetmanager/pkg/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// useTestStore serves the handlers from a fresh inventory holding one scan
+// run per asset list, recorded an hour apart
+func useTestStore(t *testing.T, scans ...[]network.Asset) *store.Store {
+	t.Helper()
+	inventory, err := store.Open(filepath.Join(t.TempDir(), "assets.db"))
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	SetStore(inventory)
+	t.Cleanup(func() {
+		SetStore(nil)
+		inventory.Close()
+	})
+
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	for _, assets := range scans {
+		for i := range assets {
+			assets[i].FirstSeen, assets[i].LastSeen = at, at
+		}
+		run := store.ScanRun{StartedAt: at, CompletedAt: at, PortScan: true}
+		if _, err := inventory.RecordScan(run, assets); err != nil {
+			t.Fatalf("RecordScan: %v", err)
+		}
+		at = at.Add(time.Hour)
+	}
+	return inventory
+}
+
+// serve sends a request through the API router and decodes the JSON
+// response into out, when given
+func serve(t *testing.T, method, path, body string, out any) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	NewRouter().ServeHTTP(w, req)
+
+	if out != nil && strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decoding %s: %v", method, path, w.Body, err)
+		}
+	}
+	return w
+}
+
+func tcpPort(port int) network.PortScanResult {
+	return network.PortScanResult{Port: port, Protocol: "tcp", State: network.PortOpen}
+}
+
+func TestHealth(t *testing.T) {
+	if w := serve(t, http.MethodGet, "/health", "", nil); w.Code != http.StatusOK {
+		t.Errorf("GET /health = %d, want 200", w.Code)
+	}
+}
+
+func TestGetAssetsWithoutInventory(t *testing.T) {
+	previous := storePath
+	SetStorePath(filepath.Join(t.TempDir(), "missing.db"))
+	t.Cleanup(func() { SetStorePath(previous) })
+
+	var resp GetAssetsResponse
+	if w := serve(t, http.MethodGet, "/api/v1/assets", "", &resp); w.Code != http.StatusOK {
+		t.Fatalf("GET /assets = %d: %s", w.Code, w.Body)
+	}
+	if !resp.Success || resp.HasAssets || resp.AssetsCount != 0 {
+		t.Errorf("GET /assets before any scan = %+v, want an empty success", resp)
+	}
+}
+
+func TestGetAssets(t *testing.T) {
+	useTestStore(t, []network.Asset{
+		{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"},
+		{IP: "10.0.0.2", MAC: "aa:bb:cc:00:00:02"},
+	})
+
+	var resp GetAssetsResponse
+	if w := serve(t, http.MethodGet, "/api/v1/assets", "", &resp); w.Code != http.StatusOK {
+		t.Fatalf("GET /assets = %d: %s", w.Code, w.Body)
+	}
+	if resp.AssetsCount != 2 || !resp.HasAssets || resp.Data == nil || resp.Data.Assets[0].IP != "10.0.0.1" {
+		t.Errorf("GET /assets = %+v, want both stored assets", resp)
+	}
+}
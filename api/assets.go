@@ -0,0 +1,157 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"assetmanager/pkg/network"
+	"assetmanager/pkg/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes returned in the error body of failed requests
+const (
+	ErrCodeAssetNotFound  = "asset_not_found"
+	ErrCodeAmbiguousAsset = "ambiguous_asset"
+	ErrCodeInventory      = "inventory_unavailable"
+)
+
+// APIError is the structured error body of a failed request
+type APIError struct {
+	Code       string   `json:"code"`
+	Message    string   `json:"message"`
+	Ref        string   `json:"ref,omitempty"`
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// GetAssetResponse represents a single asset with its ports and banners
+type GetAssetResponse struct {
+	Success   bool           `json:"success"`
+	Message   string         `json:"message,omitempty"`
+	Data      *network.Asset `json:"data,omitempty"`
+	Error     *APIError      `json:"error,omitempty"`
+	Timestamp string         `json:"response_timestamp"`
+}
+
+// GetAssetPortsResponse represents the service list of a single asset
+type GetAssetPortsResponse struct {
+	Success    bool                     `json:"success"`
+	Message    string                   `json:"message,omitempty"`
+	AssetID    string                   `json:"asset_id,omitempty"`
+	Ports      []network.PortScanResult `json:"ports"`
+	PortsCount int                      `json:"ports_count"`
+	Error      *APIError                `json:"error,omitempty"`
+	Timestamp  string                   `json:"response_timestamp"`
+}
+
+// GetAsset handles GET /assets/:id. The asset is resolved by IP address,
+// MAC address or hostname.
+func GetAsset(c *gin.Context) {
+	asset, status, apiErr := resolveAsset(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(status, GetAssetResponse{
+			Success:   false,
+			Message:   apiErr.Message,
+			Error:     apiErr,
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	if asset.OpenPorts == nil {
+		asset.OpenPorts = []network.PortScanResult{}
+	}
+
+	c.JSON(http.StatusOK, GetAssetResponse{
+		Success:   true,
+		Message:   "Asset retrieved successfully.",
+		Data:      asset,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// GetAssetPorts handles GET /assets/:id/ports and lists the services found
+// on an asset, ordered by protocol and port
+func GetAssetPorts(c *gin.Context) {
+	asset, status, apiErr := resolveAsset(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(status, GetAssetPortsResponse{
+			Success:   false,
+			Message:   apiErr.Message,
+			Ports:     []network.PortScanResult{},
+			Error:     apiErr,
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	ports := make([]network.PortScanResult, len(asset.OpenPorts))
+	copy(ports, asset.OpenPorts)
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Protocol != ports[j].Protocol {
+			return ports[i].Protocol < ports[j].Protocol
+		}
+		return ports[i].Port < ports[j].Port
+	})
+
+	message := "Asset ports retrieved successfully."
+	if len(ports) == 0 {
+		message = "No open ports have been found on this asset."
+	}
+
+	c.JSON(http.StatusOK, GetAssetPortsResponse{
+		Success:    true,
+		Message:    message,
+		AssetID:    asset.AssetID(),
+		Ports:      ports,
+		PortsCount: len(ports),
+		Timestamp:  time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// resolveAsset looks up the asset a path reference points to. On failure it
+// returns the HTTP status and error body to respond with: 404 when nothing
+// matches and 409 when a MAC address or hostname matches several assets.
+func resolveAsset(ref string) (*network.Asset, int, *APIError) {
+	ref = strings.TrimSpace(ref)
+
+	var matches []network.Asset
+	err := withStore(func(inventory *store.Store) error {
+		var err error
+		matches, err = inventory.FindAssets(ref)
+		return err
+	})
+	if err != nil && !errors.Is(err, errNoInventory) {
+		return nil, http.StatusInternalServerError, &APIError{
+			Code:    ErrCodeInventory,
+			Message: "Failed to read asset inventory: " + err.Error(),
+			Ref:     ref,
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, http.StatusNotFound, &APIError{
+			Code:    ErrCodeAssetNotFound,
+			Message: "No asset matches " + ref + ".",
+			Ref:     ref,
+		}
+	case 1:
+		return &matches[0], http.StatusOK, nil
+	}
+
+	candidates := make([]string, 0, len(matches))
+	for _, match := range matches {
+		candidates = append(candidates, match.AssetID())
+	}
+	return nil, http.StatusConflict, &APIError{
+		Code:       ErrCodeAmbiguousAsset,
+		Message:    ref + " matches more than one asset; use one of the candidate IDs.",
+		Ref:        ref,
+		Candidates: candidates,
+	}
+}
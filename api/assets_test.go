@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"assetmanager/pkg/network"
+)
+
+func TestGetAsset(t *testing.T) {
+	useTestStore(t, []network.Asset{
+		{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01", Hostname: "router.local", OpenPorts: []network.PortScanResult{tcpPort(443), tcpPort(22)}},
+		{IP: "10.0.0.2", MAC: "aa:bb:cc:00:00:02", Hostname: "printer.local"},
+		{IP: "10.0.0.3", MAC: "aa:bb:cc:00:00:03", Hostname: "printer.local"},
+	})
+
+	for _, ref := range []string{"10.0.0.1", "AA-BB-CC-00-00-01", "router.local"} {
+		var got GetAssetResponse
+		w := serve(t, http.MethodGet, "/api/v1/assets/"+ref, "", &got)
+		if w.Code != http.StatusOK || got.Data == nil || got.Data.AssetID() != "aa:bb:cc:00:00:01" {
+			t.Errorf("GET /assets/%s = %d %s", ref, w.Code, w.Body)
+		}
+	}
+
+	var missing GetAssetResponse
+	if w := serve(t, http.MethodGet, "/api/v1/assets/10.9.9.9", "", &missing); w.Code != http.StatusNotFound || missing.Error == nil || missing.Error.Code != ErrCodeAssetNotFound {
+		t.Errorf("GET /assets/10.9.9.9 = %d %s", w.Code, w.Body)
+	}
+
+	var ambiguous GetAssetResponse
+	w := serve(t, http.MethodGet, "/api/v1/assets/printer.local", "", &ambiguous)
+	if w.Code != http.StatusConflict || ambiguous.Error == nil || ambiguous.Error.Code != ErrCodeAmbiguousAsset || len(ambiguous.Error.Candidates) != 2 {
+		t.Errorf("GET /assets/printer.local = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestGetAssetPorts(t *testing.T) {
+	udp := network.PortScanResult{Port: 161, Protocol: "udp", State: network.PortOpen}
+	useTestStore(t, []network.Asset{
+		{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01", OpenPorts: []network.PortScanResult{udp, tcpPort(443), tcpPort(22)}},
+		{IP: "10.0.0.2", MAC: "aa:bb:cc:00:00:02"},
+	})
+
+	var got GetAssetPortsResponse
+	w := serve(t, http.MethodGet, "/api/v1/assets/10.0.0.1/ports", "", &got)
+	if w.Code != http.StatusOK || got.PortsCount != 3 || got.AssetID != "aa:bb:cc:00:00:01" {
+		t.Fatalf("GET /assets/10.0.0.1/ports = %d %s", w.Code, w.Body)
+	}
+	want := []int{22, 443, 161}
+	for i, port := range got.Ports {
+		if port.Port != want[i] {
+			t.Errorf("port %d is %d, want ports ordered by protocol then number: %v", i, port.Port, want)
+		}
+	}
+
+	var empty GetAssetPortsResponse
+	if w := serve(t, http.MethodGet, "/api/v1/assets/10.0.0.2/ports", "", &empty); w.Code != http.StatusOK || empty.Ports == nil || empty.PortsCount != 0 {
+		t.Errorf("GET /assets/10.0.0.2/ports = %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"assetmanager/pkg/network"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExpiringCertificate is a certificate served by an asset's port together
+// with where it was found
+type ExpiringCertificate struct {
+	AssetID    string                  `json:"asset_id"`
+	IP         string                  `json:"ip"`
+	Hostname   string                  `json:"hostname,omitempty"`
+	Port       int                     `json:"port"`
+	ChainIndex int                     `json:"chain_index"`
+	DaysLeft   int                     `json:"days_left"`
+	Expired    bool                    `json:"expired"`
+	Cert       network.CertificateInfo `json:"certificate"`
+}
+
+// GetExpiringCertificatesResponse represents the certificates expiring
+// within a number of days
+type GetExpiringCertificatesResponse struct {
+	Success           bool                  `json:"success"`
+	Message           string                `json:"message,omitempty"`
+	Days              int                   `json:"days"`
+	Certificates      []ExpiringCertificate `json:"certificates"`
+	CertificatesCount int                   `json:"certificates_count"`
+	Timestamp         string                `json:"response_timestamp"`
+}
+
+// GetExpiringCertificates handles GET /certificates/expiring. It lists every
+// certificate in the served chains of the inventory that expires within
+// ?days=N (default 30), including those already expired, soonest first.
+func GetExpiringCertificates(c *gin.Context) {
+	days := 30
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, GetExpiringCertificatesResponse{
+				Success:      false,
+				Message:      "Invalid days: " + value,
+				Certificates: []ExpiringCertificate{},
+				Timestamp:    time.Now().Format("2006-01-02 15:04:05"),
+			})
+			return
+		}
+		days = parsed
+	}
+
+	assetResult, err := loadInventory()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GetExpiringCertificatesResponse{
+			Success:      false,
+			Message:      "Failed to read asset inventory: " + err.Error(),
+			Days:         days,
+			Certificates: []ExpiringCertificate{},
+			Timestamp:    time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	now := time.Now()
+	deadline := now.Add(time.Duration(days) * 24 * time.Hour)
+
+	certificates := []ExpiringCertificate{}
+	for _, asset := range assetResult.Assets {
+		for _, port := range asset.OpenPorts {
+			if port.TLS == nil {
+				continue
+			}
+			for i, cert := range port.TLS.Certificates {
+				if cert.NotAfter.After(deadline) {
+					continue
+				}
+				certificates = append(certificates, ExpiringCertificate{
+					AssetID:    asset.AssetID(),
+					IP:         asset.IP,
+					Hostname:   asset.Hostname,
+					Port:       port.Port,
+					ChainIndex: i,
+					DaysLeft:   int(cert.NotAfter.Sub(now).Hours() / 24),
+					Expired:    now.After(cert.NotAfter),
+					Cert:       cert,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(certificates, func(i, j int) bool {
+		return certificates[i].Cert.NotAfter.Before(certificates[j].Cert.NotAfter)
+	})
+
+	message := "Expiring certificates retrieved successfully."
+	if len(certificates) == 0 {
+		message = "No certificates expire within " + strconv.Itoa(days) + " days."
+	}
+
+	c.JSON(http.StatusOK, GetExpiringCertificatesResponse{
+		Success:           true,
+		Message:           message,
+		Days:              days,
+		Certificates:      certificates,
+		CertificatesCount: len(certificates),
+		Timestamp:         time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"assetmanager/pkg/network"
+)
+
+func TestGetExpiringCertificates(t *testing.T) {
+	now := time.Now()
+	port := func(number int, notAfter ...time.Time) network.PortScanResult {
+		result := tcpPort(number)
+		result.TLS = &network.TLSInfo{}
+		for _, expiry := range notAfter {
+			result.TLS.Certificates = append(result.TLS.Certificates, network.CertificateInfo{NotAfter: expiry})
+		}
+		return result
+	}
+	useTestStore(t, []network.Asset{
+		{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01", OpenPorts: []network.PortScanResult{
+			port(443, now.Add(20*24*time.Hour), now.Add(400*24*time.Hour)),
+			port(8443, now.Add(-24*time.Hour)),
+			tcpPort(80),
+		}},
+	})
+
+	var got GetExpiringCertificatesResponse
+	w := serve(t, http.MethodGet, "/api/v1/certificates/expiring", "", &got)
+	if w.Code != http.StatusOK || got.Days != 30 || got.CertificatesCount != 2 {
+		t.Fatalf("GET /certificates/expiring = %d %s", w.Code, w.Body)
+	}
+	if first := got.Certificates[0]; first.Port != 8443 || !first.Expired {
+		t.Errorf("first certificate = %+v, want the expired one on 8443", first)
+	}
+	if second := got.Certificates[1]; second.Port != 443 || second.ChainIndex != 0 || second.Expired {
+		t.Errorf("second certificate = %+v, want the leaf on 443", second)
+	}
+
+	if w := serve(t, http.MethodGet, "/api/v1/certificates/expiring?days=500", "", &got); w.Code != http.StatusOK || got.CertificatesCount != 3 {
+		t.Errorf("GET /certificates/expiring?days=500 = %d, %d certificates; want 3", w.Code, got.CertificatesCount)
+	}
+	if w := serve(t, http.MethodGet, "/api/v1/certificates/expiring?days=soon", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("GET /certificates/expiring?days=soon = %d, want 400", w.Code)
+	}
+}
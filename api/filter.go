@@ -0,0 +1,291 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"assetmanager/pkg/network"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AssetQuery holds the filters, sort order and page requested on /assets
+type AssetQuery struct {
+	CIDR           *net.IPNet
+	Vendor         string
+	Hostname       string
+	Port           int
+	Protocol       network.ScanType
+	Service        string
+	ARPResponse    *bool
+	Online         *bool
+	LastSeenAfter  time.Time
+	LastSeenBefore time.Time
+	SortField      string
+	SortDesc       bool
+	Limit          int
+	Cursor         *assetCursor
+}
+
+// assetCursor marks the last asset of a page: its sort key and asset ID
+type assetCursor struct {
+	Key string `json:"k"`
+	ID  string `json:"id"`
+}
+
+// sortKeys maps each sort field to a function returning a key that orders
+// lexicographically the same way the field should be ordered
+var sortKeys = map[string]func(a *network.Asset) string{
+	"ip":         func(a *network.Asset) string { return ipSortKey(a.IP) },
+	"mac":        func(a *network.Asset) string { return strings.ToLower(a.MAC) },
+	"vendor":     func(a *network.Asset) string { return strings.ToLower(a.Vendor) },
+	"hostname":   func(a *network.Asset) string { return strings.ToLower(a.Hostname) },
+	"last_seen":  func(a *network.Asset) string { return timeSortKey(a.LastSeen) },
+	"first_seen": func(a *network.Asset) string { return timeSortKey(a.FirstSeen) },
+	"seen_count": func(a *network.Asset) string { return fmt.Sprintf("%010d", a.SeenCount) },
+	"open_ports": func(a *network.Asset) string { return fmt.Sprintf("%010d", len(a.OpenPorts)) },
+}
+
+// ParseAssetQuery reads the /assets query parameters:
+//
+//	cidr=10.0.0.0/8           assets whose IP is inside the CIDR
+//	vendor=cisco              vendor contains the text (case-insensitive)
+//	hostname=web              hostname contains the text (case-insensitive)
+//	port=22                   has the port open (with protocol, on that protocol)
+//	protocol=udp              has an open port on the protocol
+//	service=ssh               has an open port running the service
+//	arp_response=true         answered (or did not answer) ARP
+//	online=true               seen (or not seen) by the latest scan
+//	seen_within=24h           last seen within the duration
+//	last_seen_after=RFC3339   last seen at or after the time
+//	last_seen_before=RFC3339  last seen at or before the time
+//	sort=-last_seen           sort field, "-" prefix for descending
+//	limit=100                 page size
+//	cursor=...                next_cursor of the previous page
+func ParseAssetQuery(c *gin.Context) (*AssetQuery, error) {
+	q := &AssetQuery{SortField: "ip"}
+
+	if value := c.Query("cidr"); value != "" {
+		_, cidr, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q", value)
+		}
+		q.CIDR = cidr
+	}
+
+	q.Vendor = strings.ToLower(c.Query("vendor"))
+	q.Hostname = strings.ToLower(c.Query("hostname"))
+	q.Service = strings.ToLower(c.Query("service"))
+
+	if value := c.Query("port"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %q", value)
+		}
+		q.Port = port
+	}
+
+	if value := strings.ToLower(c.Query("protocol")); value != "" {
+		q.Protocol = network.ScanType(value)
+		if q.Protocol != network.ScanTCP && q.Protocol != network.ScanUDP {
+			return nil, fmt.Errorf("invalid protocol %q (expected tcp or udp)", value)
+		}
+	}
+
+	for name, target := range map[string]**bool{"arp_response": &q.ARPResponse, "online": &q.Online} {
+		if value := c.Query(name); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", name, value)
+			}
+			*target = &b
+		}
+	}
+
+	if value := c.Query("seen_within"); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid seen_within %q", value)
+		}
+		q.LastSeenAfter = time.Now().Add(-window)
+	}
+	if value := c.Query("last_seen_after"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid last_seen_after %q (expected RFC 3339)", value)
+		}
+		// Combined with seen_within, the narrower window wins
+		if t.After(q.LastSeenAfter) {
+			q.LastSeenAfter = t
+		}
+	}
+	if value := c.Query("last_seen_before"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid last_seen_before %q (expected RFC 3339)", value)
+		}
+		q.LastSeenBefore = t
+	}
+
+	if value := c.Query("sort"); value != "" {
+		q.SortDesc = strings.HasPrefix(value, "-")
+		q.SortField = strings.TrimPrefix(value, "-")
+		if _, ok := sortKeys[q.SortField]; !ok {
+			return nil, fmt.Errorf("invalid sort field %q", q.SortField)
+		}
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("invalid limit %q", value)
+		}
+		q.Limit = limit
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		var cursor assetCursor
+		if err := json.Unmarshal(data, &cursor); err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		q.Cursor = &cursor
+	}
+
+	return q, nil
+}
+
+// Apply filters and sorts assets, returning one page of them, the number of
+// assets that matched the filters, and the cursor of the next page ("" on
+// the last page)
+func (q *AssetQuery) Apply(assets []network.Asset) ([]network.Asset, int, string) {
+	keyOf := sortKeys[q.SortField]
+
+	type keyedAsset struct {
+		key   string
+		id    string
+		asset network.Asset
+	}
+
+	var matched []keyedAsset
+	for i := range assets {
+		if q.matches(&assets[i]) {
+			matched = append(matched, keyedAsset{keyOf(&assets[i]), assets[i].AssetID(), assets[i]})
+		}
+	}
+
+	less := func(aKey, aID, bKey, bID string) bool {
+		if aKey == bKey {
+			aKey, bKey = aID, bID
+		}
+		if q.SortDesc {
+			return aKey > bKey
+		}
+		return aKey < bKey
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return less(matched[i].key, matched[i].id, matched[j].key, matched[j].id)
+	})
+
+	start := 0
+	if q.Cursor != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			return less(q.Cursor.Key, q.Cursor.ID, matched[i].key, matched[i].id)
+		})
+	}
+
+	end := len(matched)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+
+	page := make([]network.Asset, 0, end-start)
+	for _, item := range matched[start:end] {
+		page = append(page, item.asset)
+	}
+
+	next := ""
+	if end < len(matched) {
+		last := matched[end-1]
+		data, _ := json.Marshal(assetCursor{Key: last.key, ID: last.id})
+		next = base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	return page, len(matched), next
+}
+
+// matches reports whether an asset passes every filter of the query
+func (q *AssetQuery) matches(a *network.Asset) bool {
+	if q.CIDR != nil {
+		ip := net.ParseIP(a.IP)
+		if ip == nil || !q.CIDR.Contains(ip) {
+			return false
+		}
+	}
+	if q.Vendor != "" && !strings.Contains(strings.ToLower(a.Vendor), q.Vendor) {
+		return false
+	}
+	if q.Hostname != "" && !strings.Contains(strings.ToLower(a.Hostname), q.Hostname) {
+		return false
+	}
+	if q.ARPResponse != nil && a.ARPResponse != *q.ARPResponse {
+		return false
+	}
+	if q.Online != nil && a.Online != *q.Online {
+		return false
+	}
+	if !q.LastSeenAfter.IsZero() && a.LastSeen.Before(q.LastSeenAfter) {
+		return false
+	}
+	if !q.LastSeenBefore.IsZero() && a.LastSeen.After(q.LastSeenBefore) {
+		return false
+	}
+
+	if q.Port != 0 || q.Protocol != "" || q.Service != "" {
+		found := false
+		for _, port := range a.OpenPorts {
+			if port.State != network.PortOpen {
+				continue
+			}
+			if q.Port != 0 && port.Port != q.Port {
+				continue
+			}
+			if q.Protocol != "" && port.Protocol != q.Protocol {
+				continue
+			}
+			if q.Service != "" && strings.ToLower(port.Service) != q.Service {
+				continue
+			}
+			found = true
+			break
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ipSortKey orders IP addresses numerically, with unparsable values last
+func ipSortKey(value string) string {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return "~" + value
+	}
+	return fmt.Sprintf("%x", []byte(ip.To16()))
+}
+
+// timeSortKey orders timestamps chronologically
+func timeSortKey(t time.Time) string {
+	return t.UTC().Format("20060102150405.000000000")
+}
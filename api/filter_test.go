@@ -0,0 +1,141 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"assetmanager/pkg/network"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseQuery parses the /assets query string into an AssetQuery
+func parseQuery(t *testing.T, query string) (*AssetQuery, error) {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/assets?"+query, nil)
+	return ParseAssetQuery(c)
+}
+
+func filterAssets() []network.Asset {
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	return []network.Asset{
+		{IP: "10.0.0.10", MAC: "aa:bb:cc:00:00:10", Vendor: "Cisco Systems", Hostname: "switch", ARPResponse: true, Online: true, LastSeen: at,
+			OpenPorts: []network.PortScanResult{{Port: 22, Protocol: "tcp", State: network.PortOpen, Service: "SSH"}}},
+		{IP: "10.0.0.9", MAC: "aa:bb:cc:00:00:09", Vendor: "Acme", Hostname: "web-1", ARPResponse: true, Online: false, LastSeen: at.Add(-48 * time.Hour),
+			OpenPorts: []network.PortScanResult{{Port: 80, Protocol: "tcp", State: network.PortOpen, Service: "HTTP"}, {Port: 161, Protocol: "udp", State: network.PortOpen}}},
+		{IP: "203.0.113.5", Hostname: "www.example.com", Online: true, LastSeen: at.Add(time.Hour),
+			OpenPorts: []network.PortScanResult{{Port: 443, Protocol: "tcp", State: network.PortOpen, Service: "HTTPS"}, {Port: 22, Protocol: "tcp", State: network.PortFiltered}}},
+	}
+}
+
+func TestAssetQueryFilters(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"10.0.0.9", "10.0.0.10", "203.0.113.5"}},
+		{"cidr=10.0.0.0/8", []string{"10.0.0.9", "10.0.0.10"}},
+		{"vendor=CISCO", []string{"10.0.0.10"}},
+		{"hostname=web", []string{"10.0.0.9"}},
+		{"port=22", []string{"10.0.0.10"}},
+		{"protocol=udp", []string{"10.0.0.9"}},
+		{"port=161&protocol=tcp", nil},
+		{"service=https", []string{"203.0.113.5"}},
+		{"arp_response=false", []string{"203.0.113.5"}},
+		{"online=false", []string{"10.0.0.9"}},
+		{"last_seen_after=2025-07-01T00:00:00Z", []string{"10.0.0.10", "203.0.113.5"}},
+		{"last_seen_before=2025-07-01T10:00:00Z", []string{"10.0.0.9", "10.0.0.10"}},
+		{"sort=-ip", []string{"203.0.113.5", "10.0.0.10", "10.0.0.9"}},
+		{"sort=last_seen", []string{"10.0.0.9", "10.0.0.10", "203.0.113.5"}},
+		{"sort=-open_ports&online=true", []string{"203.0.113.5", "10.0.0.10"}},
+	}
+	for _, tt := range tests {
+		q, err := parseQuery(t, tt.query)
+		if err != nil {
+			t.Fatalf("ParseAssetQuery(%q): %v", tt.query, err)
+		}
+		page, matched, next := q.Apply(filterAssets())
+		if matched != len(tt.want) || len(page) != len(tt.want) || next != "" {
+			t.Errorf("%q: %d of %d matched, next %q; want %v", tt.query, len(page), matched, next, tt.want)
+			continue
+		}
+		for i, asset := range page {
+			if asset.IP != tt.want[i] {
+				t.Errorf("%q: asset %d is %s, want %s", tt.query, i, asset.IP, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestAssetQueryErrors(t *testing.T) {
+	for _, query := range []string{
+		"cidr=10.0.0.0/33",
+		"port=0",
+		"port=http",
+		"protocol=icmp",
+		"online=maybe",
+		"seen_within=-1h",
+		"last_seen_after=yesterday",
+		"sort=color",
+		"limit=0",
+		"cursor=!!",
+	} {
+		if _, err := parseQuery(t, query); err == nil {
+			t.Errorf("ParseAssetQuery(%q) succeeded, want an error", query)
+		}
+	}
+}
+
+func TestAssetQueryPagination(t *testing.T) {
+	assets := filterAssets()
+	var seen []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(assets) {
+			t.Fatal("pagination did not end")
+		}
+		q, err := parseQuery(t, "sort=-ip&limit=2&cursor="+cursor)
+		if err != nil {
+			t.Fatalf("ParseAssetQuery: %v", err)
+		}
+		page, matched, next := q.Apply(assets)
+		if matched != len(assets) {
+			t.Errorf("page %d: %d matched, want %d", pages, matched, len(assets))
+		}
+		for _, asset := range page {
+			seen = append(seen, asset.IP)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	want := []string{"203.0.113.5", "10.0.0.10", "10.0.0.9"}
+	if len(seen) != len(want) {
+		t.Fatalf("pages held %v, want %v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("pages held %v, want %v", seen, want)
+			break
+		}
+	}
+}
+
+func TestGetAssetsQuery(t *testing.T) {
+	useTestStore(t, filterAssets())
+
+	var got GetAssetsResponse
+	w := serve(t, http.MethodGet, "/api/v1/assets?cidr=10.0.0.0/8&limit=1", "", &got)
+	if w.Code != http.StatusOK || got.AssetsCount != 1 || got.MatchCount != 2 || got.TotalCount != 3 || got.NextCursor == "" {
+		t.Errorf("GET /assets?cidr=10.0.0.0/8&limit=1 = %d %s", w.Code, w.Body)
+	}
+
+	if w := serve(t, http.MethodGet, "/api/v1/assets?sort=color", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("GET /assets?sort=color = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"assetmanager/pkg/network"
+	"assetmanager/pkg/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFindingsResponse represents the network findings raised by scans
+type GetFindingsResponse struct {
+	Success       bool                  `json:"success"`
+	Message       string                `json:"message,omitempty"`
+	Findings      []store.StoredFinding `json:"findings"`
+	FindingsCount int                   `json:"findings_count"`
+	Timestamp     string                `json:"response_timestamp"`
+}
+
+// GetFindings handles GET /findings and lists the network findings raised
+// by scans, such as ARP spoofing and address conflicts, newest first.
+// ?since=DATE, ?type=, ?severity= and ?limit=N narrow the list.
+func GetFindings(c *gin.Context) {
+	fail := func(status int, message string) {
+		c.JSON(status, GetFindingsResponse{
+			Success:   false,
+			Message:   message,
+			Findings:  []store.StoredFinding{},
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	query := store.FindingQuery{
+		Type:     c.Query("type"),
+		Severity: network.Severity(c.Query("severity")),
+	}
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			fail(http.StatusBadRequest, "Invalid limit: "+value)
+			return
+		}
+		query.Limit = parsed
+	}
+	if value := c.Query("since"); value != "" {
+		since, err := parseFindingsSince(value)
+		if err != nil {
+			fail(http.StatusBadRequest, "Invalid since: "+value)
+			return
+		}
+		query.Since = since
+	}
+
+	findings := []store.StoredFinding{}
+	err := withStore(func(inventory *store.Store) error {
+		var err error
+		findings, err = inventory.GetFindings(query)
+		return err
+	})
+	if err != nil && !errors.Is(err, errNoInventory) {
+		fail(http.StatusInternalServerError, "Failed to read findings: "+err.Error())
+		return
+	}
+
+	message := "Findings retrieved successfully."
+	if len(findings) == 0 {
+		message = "No findings match."
+	}
+
+	c.JSON(http.StatusOK, GetFindingsResponse{
+		Success:       true,
+		Message:       message,
+		Findings:      findings,
+		FindingsCount: len(findings),
+		Timestamp:     time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// parseFindingsSince parses an RFC 3339 time, "2006-01-02 15:04:05" or a
+// date, meaning the start of that day, in local time
+func parseFindingsSince(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
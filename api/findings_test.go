@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"assetmanager/pkg/network"
+	"assetmanager/pkg/store"
+)
+
+func TestGetFindings(t *testing.T) {
+	var empty GetFindingsResponse
+	if w := serve(t, http.MethodGet, "/api/v1/findings", "", &empty); w.Code != http.StatusOK || !empty.Success || empty.Findings == nil {
+		t.Errorf("GET /findings without an inventory = %d %s, want an empty list", w.Code, w.Body)
+	}
+
+	inventory := useTestStore(t)
+	first := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	for i, findingType := range []string{network.FindingIPConflict, network.FindingGatewayMACChanged} {
+		run := store.ScanRun{ID: uint64(i + 1), CompletedAt: first.Add(time.Duration(i) * 72 * time.Hour)}
+		finding := network.NetworkFinding{Finding: network.Finding{Type: findingType, Severity: network.SeverityHigh}}
+		if _, err := inventory.RecordFindings(run, []network.NetworkFinding{finding}); err != nil {
+			t.Fatalf("RecordFindings: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		count int
+	}{
+		{"", 2},
+		{"?type=ip_conflict", 1},
+		{"?severity=medium", 0},
+		{"?limit=1", 1},
+		{"?since=2025-07-03", 1},
+		{"?since=2025-07-01T00:00:00Z", 2},
+		{"?since=2025-07-05%2012:00:00", 0},
+	}
+	for _, tt := range tests {
+		var got GetFindingsResponse
+		if w := serve(t, http.MethodGet, "/api/v1/findings"+tt.query, "", &got); w.Code != http.StatusOK || got.FindingsCount != tt.count || len(got.Findings) != tt.count {
+			t.Errorf("GET /findings%s = %d with %d findings, want %d", tt.query, w.Code, got.FindingsCount, tt.count)
+		}
+	}
+
+	for _, query := range []string{"?limit=-1", "?limit=ten", "?since=yesterday"} {
+		var got GetFindingsResponse
+		if w := serve(t, http.MethodGet, "/api/v1/findings"+query, "", &got); w.Code != http.StatusBadRequest || got.Success {
+			t.Errorf("GET /findings%s = %d, want 400", query, w.Code)
+		}
+	}
+}
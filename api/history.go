@@ -0,0 +1,170 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"assetmanager/pkg/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAssetHistoryResponse represents the change timeline of a single asset
+type GetAssetHistoryResponse struct {
+	Success      bool                `json:"success"`
+	Message      string              `json:"message,omitempty"`
+	AssetID      string              `json:"asset_id,omitempty"`
+	History      []store.AssetChange `json:"history"`
+	ChangesCount int                 `json:"changes_count"`
+	Error        *APIError           `json:"error,omitempty"`
+	Timestamp    string              `json:"response_timestamp"`
+}
+
+// GetScansResponse represents the list of recorded scan runs
+type GetScansResponse struct {
+	Success    bool            `json:"success"`
+	Message    string          `json:"message,omitempty"`
+	Scans      []store.ScanRun `json:"scans"`
+	ScansCount int             `json:"scans_count"`
+	Timestamp  string          `json:"response_timestamp"`
+}
+
+// GetAssetHistory handles the /assets/:id/history endpoint. The asset is
+// resolved the same way as on /assets/:id.
+func GetAssetHistory(c *gin.Context) {
+	asset, status, apiErr := resolveAsset(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(status, GetAssetHistoryResponse{
+			Success:   false,
+			Message:   apiErr.Message,
+			History:   []store.AssetChange{},
+			Error:     apiErr,
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	assetID := asset.AssetID()
+	history := []store.AssetChange{}
+	err := withStore(func(inventory *store.Store) error {
+		var err error
+		history, err = inventory.GetAssetHistory(assetID)
+		return err
+	})
+	if err != nil && !errors.Is(err, errNoInventory) {
+		c.JSON(http.StatusInternalServerError, GetAssetHistoryResponse{
+			Success:   false,
+			Message:   "Failed to read asset history: " + err.Error(),
+			AssetID:   assetID,
+			History:   []store.AssetChange{},
+			Error:     &APIError{Code: ErrCodeInventory, Message: "Failed to read asset history: " + err.Error(), Ref: assetID},
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, GetAssetHistoryResponse{
+		Success:      true,
+		Message:      "Asset history retrieved successfully.",
+		AssetID:      assetID,
+		History:      history,
+		ChangesCount: len(history),
+		Timestamp:    time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// GetScans handles the /scans endpoint. An optional ?limit=N returns only the
+// N most recent runs.
+func GetScans(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, GetScansResponse{
+				Success:   false,
+				Message:   "Invalid limit: " + value,
+				Scans:     []store.ScanRun{},
+				Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	scans := []store.ScanRun{}
+	err := withStore(func(inventory *store.Store) error {
+		var err error
+		scans, err = inventory.GetScans(limit)
+		return err
+	})
+	if err != nil && !errors.Is(err, errNoInventory) {
+		c.JSON(http.StatusInternalServerError, GetScansResponse{
+			Success:   false,
+			Message:   "Failed to read scan history: " + err.Error(),
+			Scans:     []store.ScanRun{},
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	message := "Scans retrieved successfully."
+	if len(scans) == 0 {
+		message = "No scans have been recorded yet."
+	}
+
+	c.JSON(http.StatusOK, GetScansResponse{
+		Success:    true,
+		Message:    message,
+		Scans:      scans,
+		ScansCount: len(scans),
+		Timestamp:  time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// GetScanDiffResponse represents the difference between two scan runs
+type GetScanDiffResponse struct {
+	Success   bool            `json:"success"`
+	Message   string          `json:"message,omitempty"`
+	Data      *store.ScanDiff `json:"data,omitempty"`
+	Timestamp string          `json:"response_timestamp"`
+}
+
+// GetScanDiff handles the /scans/diff endpoint. The runs to compare are picked
+// with ?from=ID or ?since=DATE and an optional ?to=ID (default: latest scan);
+// ?format=text returns a plain-text report instead of JSON.
+func GetScanDiff(c *gin.Context) {
+	var diff *store.ScanDiff
+	err := withStore(func(inventory *store.Store) error {
+		fromID, toID, err := inventory.ResolveScanRange(c.Query("from"), c.Query("to"), c.Query("since"))
+		if err != nil {
+			return err
+		}
+		diff, err = inventory.DiffScans(fromID, toID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, GetScanDiffResponse{
+			Success:   false,
+			Message:   "Failed to compare scans: " + err.Error(),
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	if c.Query("format") == "text" {
+		var report strings.Builder
+		diff.WriteText(&report)
+		c.String(http.StatusOK, report.String())
+		return
+	}
+
+	c.JSON(http.StatusOK, GetScanDiffResponse{
+		Success:   true,
+		Message:   "Scan diff computed successfully.",
+		Data:      diff,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
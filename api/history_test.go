@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"assetmanager/pkg/network"
+	"assetmanager/pkg/store"
+)
+
+func TestGetScans(t *testing.T) {
+	host := network.Asset{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"}
+	useTestStore(t, []network.Asset{host}, []network.Asset{host}, []network.Asset{host})
+
+	var resp GetScansResponse
+	if w := serve(t, http.MethodGet, "/api/v1/scans?limit=2", "", &resp); w.Code != http.StatusOK {
+		t.Fatalf("GET /scans = %d: %s", w.Code, w.Body)
+	}
+	if resp.ScansCount != 2 || resp.Scans[0].ID != 3 || resp.Scans[1].ID != 2 {
+		t.Errorf("GET /scans?limit=2 = %+v, want scans 3 and 2", resp.Scans)
+	}
+
+	if w := serve(t, http.MethodGet, "/api/v1/scans?limit=-1", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("GET /scans?limit=-1 = %d, want 400", w.Code)
+	}
+}
+
+func TestGetAssetHistory(t *testing.T) {
+	useTestStore(t,
+		[]network.Asset{{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"}},
+		[]network.Asset{{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01", OpenPorts: []network.PortScanResult{tcpPort(22)}}},
+	)
+
+	var resp GetAssetHistoryResponse
+	if w := serve(t, http.MethodGet, "/api/v1/assets/10.0.0.1/history", "", &resp); w.Code != http.StatusOK {
+		t.Fatalf("GET history = %d: %s", w.Code, w.Body)
+	}
+	if resp.AssetID != "aa:bb:cc:00:00:01" || resp.ChangesCount != 2 {
+		t.Fatalf("history of %s = %+v, want 2 changes", resp.AssetID, resp.History)
+	}
+	if resp.History[0].Type != store.ChangeDiscovered || resp.History[1].Type != store.ChangePortOpened {
+		t.Errorf("history = %+v, want discovered then port_opened", resp.History)
+	}
+
+	if w := serve(t, http.MethodGet, "/api/v1/assets/10.9.9.9/history", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("history of an unknown asset = %d, want 404", w.Code)
+	}
+}
+
+func TestGetScanDiff(t *testing.T) {
+	useTestStore(t,
+		[]network.Asset{{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"}},
+		[]network.Asset{{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"}, {IP: "10.0.0.2", MAC: "aa:bb:cc:00:00:02"}},
+	)
+
+	var resp GetScanDiffResponse
+	if w := serve(t, http.MethodGet, "/api/v1/scans/diff", "", &resp); w.Code != http.StatusOK {
+		t.Fatalf("GET /scans/diff = %d: %s", w.Code, w.Body)
+	}
+	if resp.Data.FromScan.ID != 1 || resp.Data.ToScan.ID != 2 || len(resp.Data.NewAssets) != 1 {
+		t.Errorf("diff = %+v, want scan 1 to 2 with one new asset", resp.Data)
+	}
+
+	w := serve(t, http.MethodGet, "/api/v1/scans/diff?from=1&to=2&format=text", "", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "+ 10.0.0.2") {
+		t.Errorf("text diff = %d:\n%s", w.Code, w.Body)
+	}
+
+	if w := serve(t, http.MethodGet, "/api/v1/scans/diff?from=1&since=2025-07-01", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("diff with from and since = %d, want 400", w.Code)
+	}
+}
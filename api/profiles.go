@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"assetmanager/pkg/network"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PortProfileSummary describes a named port profile
+type PortProfileSummary struct {
+	Name     string `json:"name"`
+	TCPCount int    `json:"tcp_count"`
+	UDPCount int    `json:"udp_count"`
+}
+
+// GetPortProfilesResponse represents the list of named port profiles
+type GetPortProfilesResponse struct {
+	Success   bool                 `json:"success"`
+	Message   string               `json:"message,omitempty"`
+	Profiles  []PortProfileSummary `json:"profiles"`
+	Timestamp string               `json:"response_timestamp"`
+}
+
+// GetPortProfiles handles GET /port-profiles and lists the profiles a scan
+// request can select by name
+func GetPortProfiles(c *gin.Context) {
+	var profiles []PortProfileSummary
+	for _, name := range network.PortProfileNames() {
+		profile, err := network.ParsePortProfile(name)
+		if err != nil {
+			continue
+		}
+		profiles = append(profiles, PortProfileSummary{
+			Name:     name,
+			TCPCount: len(profile.TCP),
+			UDPCount: len(profile.UDP),
+		})
+	}
+
+	c.JSON(http.StatusOK, GetPortProfilesResponse{
+		Success:   true,
+		Message:   "Port profiles retrieved successfully.",
+		Profiles:  profiles,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
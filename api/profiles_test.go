@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetPortProfiles(t *testing.T) {
+	var got GetPortProfilesResponse
+	if w := serve(t, http.MethodGet, "/api/v1/port-profiles", "", &got); w.Code != http.StatusOK {
+		t.Fatalf("GET /port-profiles = %d", w.Code)
+	}
+
+	counts := make(map[string]int)
+	for _, profile := range got.Profiles {
+		counts[profile.Name] = profile.TCPCount
+	}
+	if counts["top-100"] != 100 || counts["top-1000"] != 1000 || counts["all"] != 65535 {
+		t.Errorf("TCP port counts = %v", counts)
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewRouter creates the gin router with CORS and every API route registered
+func NewRouter() *gin.Engine {
+	// Create Gin router
+	r := gin.Default()
+
+	// Enable CORS middleware
+	r.Use(func(c *gin.Context) {
+		c.Header("Access-Control-Allow-Origin", "*")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	})
+
+	// API routes
+	v1 := r.Group("/api/v1")
+	{
+		v1.GET("/", HandleHome)
+		v1.GET("/assets", GetAssets)
+		v1.GET("/getAssets", GetAssets) // Alternative endpoint name
+		v1.GET("/assets/:id", GetAsset)
+		v1.GET("/assets/:id/ports", GetAssetPorts)
+		v1.GET("/assets/:id/ports/:port/screenshot", GetAssetPortScreenshot)
+		v1.GET("/assets/:id/history", GetAssetHistory)
+		v1.GET("/scans", GetScans)
+		v1.GET("/scans/diff", GetScanDiff)
+		v1.POST("/scans", StartScan)
+		v1.GET("/scans/:id", GetScanJob)
+		v1.DELETE("/scans/:id", CancelScanJob)
+		v1.GET("/scheduler", GetSchedulerStatus)
+		v1.GET("/port-profiles", GetPortProfiles)
+		v1.GET("/certificates/expiring", GetExpiringCertificates)
+		v1.GET("/findings", GetFindings)
+	}
+
+	// Health check endpoint
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  "healthy",
+			"service": "asset-management-api",
+		})
+	})
+
+	return r
+}
+
+// LogEndpoints logs the routes served by NewRouter
+func LogEndpoints() {
+	log.Println("Available endpoints:")
+	log.Println("  GET /api/v1/assets - Get all discovered assets")
+	log.Println("  GET /api/v1/getAssets - Get all discovered assets (alternative)")
+	log.Println("  GET /api/v1/assets/:id - Get an asset by IP, MAC or hostname")
+	log.Println("  GET /api/v1/assets/:id/ports - Get the services found on an asset")
+	log.Println("  GET /api/v1/assets/:id/ports/:port/screenshot - Get the screenshot of a web service")
+	log.Println("  GET /api/v1/assets/:id/history - Get the change timeline of an asset")
+	log.Println("  GET /api/v1/scans - Get recorded scan runs")
+	log.Println("  GET /api/v1/scans/diff - Compare the assets of two scan runs")
+	log.Println("  POST /api/v1/scans - Start an on-demand scan")
+	log.Println("  GET /api/v1/scans/:id - Get an on-demand scan job, or a recorded scan run by numeric ID")
+	log.Println("  DELETE /api/v1/scans/:id - Cancel an on-demand scan")
+	log.Println("  GET /api/v1/scheduler - Get the scan scheduler state")
+	log.Println("  GET /api/v1/port-profiles - Get the named port profiles")
+	log.Println("  GET /api/v1/certificates/expiring - Get the TLS certificates expiring within N days")
+	log.Println("  GET /api/v1/findings - Get ARP spoofing and address conflict findings")
+	log.Println("  GET /health - Health check")
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"assetmanager/pkg/jobs"
+	"assetmanager/pkg/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jobManager runs the on-demand scans started through the API
+var jobManager *jobs.Manager
+
+// SetJobManager sets the manager used to run on-demand scans
+func SetJobManager(manager *jobs.Manager) {
+	jobManager = manager
+}
+
+// ScanJobResponse represents the state of an on-demand scan job
+type ScanJobResponse struct {
+	Success   bool      `json:"success"`
+	Message   string    `json:"message,omitempty"`
+	Data      *jobs.Job `json:"data,omitempty"`
+	Timestamp string    `json:"response_timestamp"`
+}
+
+// GetScanRunResponse represents a recorded scan run
+type GetScanRunResponse struct {
+	Success   bool           `json:"success"`
+	Message   string         `json:"message,omitempty"`
+	Data      *store.ScanRun `json:"data,omitempty"`
+	Timestamp string         `json:"response_timestamp"`
+}
+
+// StartScan handles POST /scans. The body is a jobs.ScanRequest; the scan runs
+// in the background and the job is returned with 202 Accepted, or refused with
+// 429 Too Many Requests while the job queue is full.
+func StartScan(c *gin.Context) {
+	if jobManager == nil {
+		c.JSON(http.StatusServiceUnavailable, ScanJobResponse{
+			Success:   false,
+			Message:   "On-demand scanning is not enabled on this server.",
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	var req jobs.ScanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ScanJobResponse{
+			Success:   false,
+			Message:   "Invalid scan request: " + err.Error(),
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	job, err := jobManager.Start(req)
+	if errors.Is(err, jobs.ErrBusy) {
+		c.JSON(http.StatusTooManyRequests, ScanJobResponse{
+			Success:   false,
+			Message:   "Scan refused: " + err.Error() + ", try again later.",
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ScanJobResponse{
+			Success:   false,
+			Message:   "Invalid scan request: " + err.Error(),
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, ScanJobResponse{
+		Success:   true,
+		Message:   "Scan started.",
+		Data:      job,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// GetScanJob handles GET /scans/:id and reports a job's status, progress and
+// results. A numeric ID that is not a job is the ID of a recorded scan run, as
+// listed by GET /scans, and returns that run.
+func GetScanJob(c *gin.Context) {
+	if runID, err := strconv.ParseUint(c.Param("id"), 10, 64); err == nil && !isJob(c.Param("id")) {
+		getScanRun(c, runID)
+		return
+	}
+
+	job, ok := lookupJob(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, ScanJobResponse{
+		Success:   true,
+		Message:   "Scan job is " + string(job.Status) + ".",
+		Data:      job,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// CancelScanJob handles DELETE /scans/:id and cancels a queued or running
+// job. Recorded scan runs cannot be cancelled.
+func CancelScanJob(c *gin.Context) {
+	if _, err := strconv.ParseUint(c.Param("id"), 10, 64); err == nil && !isJob(c.Param("id")) {
+		c.JSON(http.StatusBadRequest, ScanJobResponse{
+			Success:   false,
+			Message:   "Scan " + c.Param("id") + " is a recorded scan run, not an on-demand scan job, and cannot be cancelled.",
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	if _, ok := lookupJob(c); !ok {
+		return
+	}
+
+	// The job may have been pruned since it was looked up
+	job, ok := jobManager.Cancel(c.Param("id"))
+	if !ok {
+		jobNotFound(c)
+		return
+	}
+
+	message := "Scan cancellation requested."
+	if job.CompletedAt != nil {
+		message = "Scan job already finished."
+	}
+
+	c.JSON(http.StatusOK, ScanJobResponse{
+		Success:   true,
+		Message:   message,
+		Data:      job,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// lookupJob resolves the :id parameter to a job, writing a 404 if there is none
+func lookupJob(c *gin.Context) (*jobs.Job, bool) {
+	id := c.Param("id")
+
+	var job *jobs.Job
+	ok := false
+	if jobManager != nil {
+		job, ok = jobManager.Get(id)
+	}
+	if !ok {
+		jobNotFound(c)
+		return nil, false
+	}
+
+	return job, true
+}
+
+// jobNotFound writes the 404 for an :id parameter that is not a job
+func jobNotFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, ScanJobResponse{
+		Success:   false,
+		Message:   "Scan job not found: " + c.Param("id"),
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// isJob reports whether id is the ID of an on-demand scan job
+func isJob(id string) bool {
+	if jobManager == nil {
+		return false
+	}
+	_, ok := jobManager.Get(id)
+	return ok
+}
+
+// getScanRun writes the recorded scan run with the given ID, or a 404
+func getScanRun(c *gin.Context, id uint64) {
+	var run *store.ScanRun
+	found := false
+	err := withStore(func(inventory *store.Store) error {
+		var err error
+		run, found, err = inventory.GetScan(id)
+		return err
+	})
+	if err != nil && !errors.Is(err, errNoInventory) {
+		c.JSON(http.StatusInternalServerError, GetScanRunResponse{
+			Success:   false,
+			Message:   "Failed to read scan: " + err.Error(),
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, GetScanRunResponse{
+			Success:   false,
+			Message:   "Scan not found: " + c.Param("id"),
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, GetScanRunResponse{
+		Success:   true,
+		Message:   "Scan run retrieved successfully.",
+		Data:      run,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"assetmanager/pkg/jobs"
+	"assetmanager/pkg/network"
+)
+
+// useTestJobs serves on-demand scans from a manager whose ARP sweeps fail
+// at once, on an interface that does not exist
+func useTestJobs(t *testing.T) {
+	t.Helper()
+	SetJobManager(jobs.NewManager(jobs.Options{Interface: "test-none0", MaxRunning: 1, MaxQueued: 1}))
+	t.Cleanup(func() { SetJobManager(nil) })
+}
+
+func TestScanJobs(t *testing.T) {
+	useTestJobs(t)
+
+	var started ScanJobResponse
+	w := serve(t, http.MethodPost, "/api/v1/scans", `{"cidrs": ["10.0.0.1"], "modes": ["arp"]}`, &started)
+	if w.Code != http.StatusAccepted || started.Data == nil || started.Data.ID == "" {
+		t.Fatalf("POST /scans = %d %s", w.Code, w.Body)
+	}
+
+	var got ScanJobResponse
+	if w := serve(t, http.MethodGet, "/api/v1/scans/"+started.Data.ID, "", &got); w.Code != http.StatusOK || got.Data.ID != started.Data.ID {
+		t.Errorf("GET /scans/%s = %d %s", started.Data.ID, w.Code, w.Body)
+	}
+	if w := serve(t, http.MethodDelete, "/api/v1/scans/"+started.Data.ID, "", nil); w.Code != http.StatusOK {
+		t.Errorf("DELETE /scans/%s = %d %s", started.Data.ID, w.Code, w.Body)
+	}
+
+	if w := serve(t, http.MethodPost, "/api/v1/scans", `{"cidrs": ["10.0.0.0/33"]}`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /scans with an invalid CIDR = %d, want 400", w.Code)
+	}
+	if w := serve(t, http.MethodGet, "/api/v1/scans/0123abcd", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET /scans/ of an unknown job = %d, want 404", w.Code)
+	}
+}
+
+func TestGetScanRun(t *testing.T) {
+	useTestStore(t,
+		[]network.Asset{{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"}},
+		[]network.Asset{{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"}, {IP: "10.0.0.2", MAC: "aa:bb:cc:00:00:02"}},
+	)
+	useTestJobs(t)
+
+	var got GetScanRunResponse
+	w := serve(t, http.MethodGet, "/api/v1/scans/2", "", &got)
+	if w.Code != http.StatusOK || got.Data == nil || got.Data.ID != 2 || got.Data.NewAssets != 1 {
+		t.Fatalf("GET /scans/2 = %d %s", w.Code, w.Body)
+	}
+
+	if w := serve(t, http.MethodGet, "/api/v1/scans/14", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET /scans/14 = %d, want 404", w.Code)
+	}
+	if w := serve(t, http.MethodDelete, "/api/v1/scans/2", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("DELETE /scans/2 = %d, want 400", w.Code)
+	}
+}
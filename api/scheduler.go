@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"assetmanager/pkg/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scanScheduler is the scheduler running in this process, if any
+var scanScheduler *scheduler.Scheduler
+
+// SetScheduler exposes the state of a scheduler running in this process
+func SetScheduler(s *scheduler.Scheduler) {
+	scanScheduler = s
+}
+
+// SchedulerResponse represents the state of the scan scheduler
+type SchedulerResponse struct {
+	Success   bool              `json:"success"`
+	Message   string            `json:"message,omitempty"`
+	Data      *scheduler.Status `json:"data,omitempty"`
+	Timestamp string            `json:"response_timestamp"`
+}
+
+// GetSchedulerStatus handles the /scheduler endpoint
+func GetSchedulerStatus(c *gin.Context) {
+	if scanScheduler == nil {
+		c.JSON(http.StatusNotFound, SchedulerResponse{
+			Success:   false,
+			Message:   "No scan scheduler is running in this process. Start the API with \"assetmanager serve\".",
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	status := scanScheduler.Status()
+	c.JSON(http.StatusOK, SchedulerResponse{
+		Success:   true,
+		Message:   "Scheduler is " + string(status.State) + ".",
+		Data:      &status,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
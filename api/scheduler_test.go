@@ -0,0 +1,24 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"assetmanager/pkg/scheduler"
+)
+
+func TestGetSchedulerStatus(t *testing.T) {
+	if w := serve(t, http.MethodGet, "/api/v1/scheduler", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET /scheduler without a scheduler = %d, want 404", w.Code)
+	}
+
+	SetScheduler(scheduler.New(time.Hour, func(ctx context.Context) error { return nil }))
+	t.Cleanup(func() { SetScheduler(nil) })
+
+	var got SchedulerResponse
+	if w := serve(t, http.MethodGet, "/api/v1/scheduler", "", &got); w.Code != http.StatusOK || got.Data.State != scheduler.StateIdle || got.Data.Interval != "1h0m0s" {
+		t.Errorf("GET /scheduler = %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"assetmanager/pkg/network"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes returned when a screenshot cannot be served
+const (
+	ErrCodeInvalidPort        = "invalid_port"
+	ErrCodePortNotFound       = "port_not_found"
+	ErrCodeScreenshotNotFound = "screenshot_not_found"
+)
+
+// screenshotDir is where the scanners store screenshots
+var screenshotDir = "screenshots"
+
+// SetScreenshotDir sets the directory screenshots are served from
+func SetScreenshotDir(dir string) {
+	screenshotDir = dir
+}
+
+// GetScreenshotErrorResponse is the body of a screenshot request that
+// cannot be served; a successful request returns the PNG image itself
+type GetScreenshotErrorResponse struct {
+	Success   bool      `json:"success"`
+	Message   string    `json:"message"`
+	Error     *APIError `json:"error"`
+	Timestamp string    `json:"response_timestamp"`
+}
+
+// GetAssetPortScreenshot handles GET /assets/:id/ports/:port/screenshot and
+// serves the latest screenshot of the web service on a TCP port of an asset.
+// The image's SHA-256 is sent as its ETag.
+func GetAssetPortScreenshot(c *gin.Context) {
+	fail := func(status int, apiErr *APIError) {
+		c.JSON(status, GetScreenshotErrorResponse{
+			Success:   false,
+			Message:   apiErr.Message,
+			Error:     apiErr,
+			Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	asset, status, apiErr := resolveAsset(c.Param("id"))
+	if apiErr != nil {
+		fail(status, apiErr)
+		return
+	}
+
+	ref := c.Param("port")
+	port, err := strconv.Atoi(ref)
+	if err != nil || port < 1 || port > 65535 {
+		fail(http.StatusBadRequest, &APIError{
+			Code:    ErrCodeInvalidPort,
+			Message: "Invalid port: " + ref,
+			Ref:     ref,
+		})
+		return
+	}
+
+	var result *network.PortScanResult
+	for i := range asset.OpenPorts {
+		if asset.OpenPorts[i].Protocol == network.ScanTCP && asset.OpenPorts[i].Port == port {
+			result = &asset.OpenPorts[i]
+			break
+		}
+	}
+	if result == nil {
+		fail(http.StatusNotFound, &APIError{
+			Code:    ErrCodePortNotFound,
+			Message: "TCP port " + ref + " is not open on " + asset.AssetID() + ".",
+			Ref:     ref,
+		})
+		return
+	}
+	if result.Screenshot == nil {
+		fail(http.StatusNotFound, &APIError{
+			Code:    ErrCodeScreenshotNotFound,
+			Message: "No screenshot has been taken of port " + ref + " on " + asset.AssetID() + ".",
+			Ref:     ref,
+		})
+		return
+	}
+
+	path, err := network.ScreenshotPath(screenshotDir, result.Screenshot.SHA256)
+	if err == nil {
+		_, err = os.Stat(path)
+	}
+	if err != nil {
+		message := "Failed to read screenshot: " + err.Error()
+		if errors.Is(err, os.ErrNotExist) {
+			message = "The screenshot of port " + ref + " on " + asset.AssetID() + " is no longer stored."
+		}
+		fail(http.StatusNotFound, &APIError{
+			Code:    ErrCodeScreenshotNotFound,
+			Message: message,
+			Ref:     ref,
+		})
+		return
+	}
+
+	c.Header("ETag", `"`+result.Screenshot.SHA256+`"`)
+	c.Header("Content-Type", "image/png")
+	c.File(path)
+}
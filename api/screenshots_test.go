@@ -0,0 +1,63 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"assetmanager/pkg/network"
+)
+
+func TestGetAssetPortScreenshot(t *testing.T) {
+	dir := t.TempDir()
+	SetScreenshotDir(dir)
+	t.Cleanup(func() { SetScreenshotDir("screenshots") })
+
+	image := []byte("\x89PNG test image")
+	digest := sha256.Sum256(image)
+	sum := hex.EncodeToString(digest[:])
+	path, err := network.ScreenshotPath(dir, sum)
+	if err != nil {
+		t.Fatalf("ScreenshotPath: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, image, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	web := tcpPort(80)
+	web.Screenshot = &network.Screenshot{SHA256: sum}
+	gone := tcpPort(8080)
+	gone.Screenshot = &network.Screenshot{SHA256: hex.EncodeToString(make([]byte, sha256.Size))}
+	useTestStore(t, []network.Asset{
+		{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01", OpenPorts: []network.PortScanResult{web, gone, tcpPort(22)}},
+	})
+
+	w := serve(t, http.MethodGet, "/api/v1/assets/10.0.0.1/ports/80/screenshot", "", nil)
+	if w.Code != http.StatusOK || w.Body.String() != string(image) || w.Header().Get("ETag") != `"`+sum+`"` {
+		t.Errorf("GET screenshot of port 80 = %d, ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/api/v1/assets/10.0.0.1/ports/http/screenshot", http.StatusBadRequest, ErrCodeInvalidPort},
+		{"/api/v1/assets/10.0.0.1/ports/443/screenshot", http.StatusNotFound, ErrCodePortNotFound},
+		{"/api/v1/assets/10.0.0.1/ports/22/screenshot", http.StatusNotFound, ErrCodeScreenshotNotFound},
+		{"/api/v1/assets/10.0.0.1/ports/8080/screenshot", http.StatusNotFound, ErrCodeScreenshotNotFound},
+		{"/api/v1/assets/10.9.9.9/ports/80/screenshot", http.StatusNotFound, ErrCodeAssetNotFound},
+	}
+	for _, tt := range tests {
+		var got GetScreenshotErrorResponse
+		if w := serve(t, http.MethodGet, tt.path, "", &got); w.Code != tt.status || got.Error == nil || got.Error.Code != tt.code {
+			t.Errorf("GET %s = %d %s, want %d %s", tt.path, w.Code, w.Body, tt.status, tt.code)
+		}
+	}
+}
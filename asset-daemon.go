@@ -1,17 +1,26 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"io/ioutil"
+	"errors"
+	"fmt"
 	"log"
-	"net"
+	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"assetmanager/api"
 	"assetmanager/pkg/config"
+	"assetmanager/pkg/jobs"
 	"assetmanager/pkg/network"
+	"assetmanager/pkg/notify"
+	"assetmanager/pkg/scheduler"
+	"assetmanager/pkg/store"
 	"assetmanager/utilities"
 )
 
@@ -21,10 +30,32 @@ type AssetResult struct {
 	ScanTime    string          `json:"scan_time"`
 	LocalNet    string          `json:"local_network"`
 	FileTargets int             `json:"file_targets"`
+	Partial     bool            `json:"partial,omitempty"`
 	Assets      []network.Asset `json:"assets"`
 }
 
 func main() {
+	command := "daemon"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	switch command {
+	case "daemon":
+		run(false)
+	case "serve":
+		run(true)
+	default:
+		fmt.Fprintf(os.Stderr, "Usage: %s [daemon|serve]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "  daemon  run scheduled scans only (default)")
+		fmt.Fprintln(os.Stderr, "  serve   run scheduled scans and the HTTP API in one process")
+		os.Exit(2)
+	}
+}
+
+// run starts the scan scheduler and, when serveAPI is set, the HTTP API
+// sharing the same inventory store. It returns on SIGINT or SIGTERM.
+func run(serveAPI bool) {
 	log.Println("Asset Management Daemon Starting...")
 
 	cfg, err := config.LoadConfig("config.json")
@@ -36,35 +67,98 @@ func main() {
 
 	log.Printf("Service: %s", cfg.Service.Name)
 	log.Printf("Scan Interval: %s", cfg.Service.ScanInterval)
+	if cfg.Network.ScanFileList || cfg.PublicScan.Enabled {
+		if file, err := network.ReadTargetsFromFile(cfg.Files.IPListFile); err != nil {
+			log.Printf("Target file is invalid, its targets will be skipped:\n%v", err)
+		} else {
+			log.Printf("Target file: %d targets, %d exclusions", len(file.Targets), len(file.Exclude))
+		}
+	}
 
-	discovery, err := createAssetDiscovery(cfg)
+	// One auditor serves every scan of the process, so the attempts it
+	// makes against a host count across scans
+	auditor, err := cfg.NewCredentialAuditor()
+	if err != nil {
+		log.Printf("Credential audit disabled: %v", err)
+	}
+
+	discovery, err := createAssetDiscovery(cfg, auditor)
 	if err != nil {
 		log.Fatalf("Failed to create asset discovery: %v", err)
 	}
 	defer discovery.Close()
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	// The API server keeps the inventory open for the life of the process;
+	// the standalone daemon only opens it while saving a scan so that
+	// cmd/server can read it in between.
+	var inventory *store.Store
+	if serveAPI {
+		inventory, err = store.Open(cfg.GetDatabaseFile())
+		if err != nil {
+			log.Fatalf("Failed to open asset inventory: %v", err)
+		}
+		defer inventory.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	ticker := createTicker(cfg.Service.ScanInterval)
-	defer ticker.Stop()
+	if cfg.Network.PassiveListen {
+		go func() {
+			log.Println("Passive listener started")
+			if err := discovery.Listen(ctx); err != nil {
+				log.Printf("Passive listener stopped: %v", err)
+			}
+		}()
+	}
+
+	interval, err := cfg.GetScanInterval()
+	if err != nil {
+		interval = 5 * time.Minute
+	}
+	watcher := cfg.NewARPWatcher()
+	notifier := cfg.NewNotifier()
+	scans := scheduler.New(interval, func(ctx context.Context) error {
+		return performScan(ctx, cfg, discovery, auditor, inventory, watcher, notifier)
+	})
+
+	var server *http.Server
+	if serveAPI {
+		api.SetStore(inventory)
+		api.SetScreenshotDir(cfg.GetScreenshotDir())
+		api.SetScheduler(scans)
+		opts := jobs.OptionsFromConfig(cfg)
+		opts.Credentials = auditor
+		api.SetJobManager(jobs.NewManager(opts))
+
+		server = &http.Server{
+			Addr:    cfg.GetAPIListen(),
+			Handler: api.NewRouter(),
+		}
+		go func() {
+			log.Printf("Starting Asset Management API server on %s", server.Addr)
+			api.LogEndpoints()
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Failed to start server: %v", err)
+			}
+		}()
+	}
 
 	log.Println("Daemon started. Press Ctrl+C to stop.")
 
-	performScan(cfg, discovery)
+	scans.Run(ctx)
 
-	for {
-		select {
-		case <-ticker.C:
-			performScan(cfg, discovery)
-		case <-stop:
-			log.Println("Daemon stopping...")
-			return
+	log.Println("Daemon stopping...")
+	if server != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("API server shutdown failed: %v", err)
 		}
 	}
 }
 
-func createAssetDiscovery(cfg *config.Config) (*network.AssetDiscovery, error) {
+func createAssetDiscovery(cfg *config.Config, auditor *network.CredentialAuditor) (*network.AssetDiscovery, error) {
 	arpTimeout, err := cfg.GetARPTimeout()
 	if err != nil {
 		log.Printf("Invalid ARP timeout, using default: %v", err)
@@ -104,122 +198,306 @@ func createAssetDiscovery(cfg *config.Config) (*network.AssetDiscovery, error) {
 		return nil, err
 	}
 
+	mode := discovery.SetTCPScanMode(network.TCPScanMode(cfg.GetPortScanMode()))
+	log.Printf("TCP port scan mode: %s", mode)
+	discovery.SetServiceDetection(cfg.PortScan.ServiceDetection)
+	discovery.SetTLSInspector(cfg.NewTLSInspector())
+	discovery.SetHTTPEnumerator(cfg.NewHTTPEnumerator())
+	discovery.SetScreenshotter(cfg.NewScreenshotter())
+	discovery.SetCredentialAuditor(auditor)
+
+	profile := portScanProfile(cfg)
+	discovery.SetPorts(profile.TCP, profile.UDP)
+	discovery.SetRandomOrder(cfg.Network.RandomOrder)
+	if err := discovery.SetExclusions(cfg.Network.Exclude); err != nil {
+		log.Printf("Invalid exclusions, nothing is excluded: %v", err)
+	}
+
 	return discovery, nil
 }
 
-func performScan(cfg *config.Config, discovery *network.AssetDiscovery) {
+// portScanProfile returns the configured port profile of discovered hosts
+func portScanProfile(cfg *config.Config) *network.PortProfile {
+	profile, err := network.ParsePortProfile(cfg.GetPortScanProfile())
+	if err != nil {
+		log.Printf("Invalid port scan profile, using default: %v", err)
+		profile = network.DefaultPortProfile()
+	}
+	return profile
+}
+
+// performScan runs one full discovery sweep and records it in the inventory.
+// A nil inventory means the store is opened just for recording the scan.
+// The answers to the ARP sweeps are checked by watcher, when set, and the
+// findings it raises are sent to notifier. Each phase runs under its
+// configured deadline; when ctx is cancelled or a phase runs out of time
+// what was found is still recorded, as a partial scan. The probes of every
+// phase share one rate limiter.
+func performScan(ctx context.Context, cfg *config.Config, discovery *network.AssetDiscovery, auditor *network.CredentialAuditor, inventory *store.Store, watcher *network.ARPWatcher, notifier *notify.Notifier) error {
 	log.Println("Starting asset discovery scan...")
 	startTime := time.Now()
 
 	var allAssets []network.Asset
+	var interrupted []string
 	localCIDR := getLocalNetwork(cfg)
+	deadlines := getPhaseDeadlines(cfg)
+
+	limit, err := cfg.NewRateLimiter()
+	if err != nil {
+		log.Printf("Invalid timing, scan is not paced: %v", err)
+	}
+	discovery.SetRateLimiter(limit)
 
 	// Scan local network using ARP
 	if cfg.Network.ScanLocalNetwork {
-		localAssets := scanLocalNetwork(cfg, discovery)
+		phase, cancel := network.WithDeadline(ctx, deadlines.arp)
+		localAssets, err := scanLocalNetwork(phase, cfg, discovery)
+		cancel()
+		interrupted = noteInterrupted(interrupted, "local_network", err)
 		allAssets = append(allAssets, localAssets...)
 		log.Printf("Local network: found %d assets", len(localAssets))
 	}
 
+	// Find IPv6 hosts on the local link with neighbor discovery
+	if cfg.Network.IPv6Discovery {
+		phase, cancel := network.WithDeadline(ctx, deadlines.ipv6)
+		ipv6Assets, err := discovery.DiscoverIPv6Assets(phase, cfg.PortScan.Enabled)
+		cancel()
+		if err != nil && !network.Interrupted(err) {
+			log.Printf("IPv6 discovery failed: %v", err)
+		}
+		interrupted = noteInterrupted(interrupted, "ipv6", err)
+		allAssets = append(allAssets, ipv6Assets...)
+		log.Printf("Local link (IPv6): found %d assets", len(ipv6Assets))
+	}
+
+	// Record the devices the passive listener heard since the last scan
+	if cfg.Network.PassiveListen {
+		phase, cancel := network.WithDeadline(ctx, deadlines.heardHosts)
+		heardAssets, err := discovery.HeardAssets(phase, cfg.PortScan.Enabled, allAssets)
+		cancel()
+		interrupted = noteInterrupted(interrupted, "heard_hosts", err)
+		allAssets = append(allAssets, heardAssets...)
+		log.Printf("Passive listener: heard %d assets", len(heardAssets))
+	}
+
 	// Scan file targets using ARP (excluding local network)
 	if cfg.Network.ScanFileList {
-		fileAssets := scanFileTargetsExcluding(cfg, discovery, localCIDR)
+		phase, cancel := network.WithDeadline(ctx, deadlines.arp)
+		fileAssets, err := scanFileTargetsExcluding(phase, cfg, discovery, localCIDR)
+		cancel()
+		interrupted = noteInterrupted(interrupted, "file_targets", err)
 		allAssets = append(allAssets, fileAssets...)
 		log.Printf("File targets (ARP): found %d assets", len(fileAssets))
 	}
 
 	// Scan public assets using ping/TCP/UDP
 	if cfg.PublicScan.Enabled {
-		publicAssets := scanPublicAssets(cfg)
+		phase, cancel := network.WithDeadline(ctx, deadlines.publicScan)
+		publicAssets, err := scanPublicAssets(phase, cfg, auditor, limit)
+		cancel()
+		interrupted = noteInterrupted(interrupted, "public", err)
 		allAssets = append(allAssets, publicAssets...)
 		log.Printf("Public assets: found %d assets", len(publicAssets))
 	}
 
-	uniqueAssets := removeDuplicateAssets(allAssets)
+	uniqueAssets := network.DeduplicateAssets(allAssets)
 	log.Printf("After deduplication: %d unique assets (reduced from %d)", len(uniqueAssets), len(allAssets))
 
 	scanDuration := time.Since(startTime)
 
-	result := AssetResult{
+	summary := store.ScanSummary{
 		Timestamp:   time.Now().Format("2006-01-02 15:04:05"),
 		TotalHosts:  len(uniqueAssets),
 		ScanTime:    scanDuration.String(),
 		LocalNet:    localCIDR,
 		FileTargets: countFileTargets(cfg.Files.IPListFile),
-		Assets:      uniqueAssets,
 	}
 
-	saveResult(result, cfg.Files.OutputFile)
+	run := store.ScanRun{
+		StartedAt:   startTime,
+		CompletedAt: time.Now(),
+		PortScan:    cfg.PortScan.Enabled,
+		Partial:     len(interrupted) > 0,
+		Interrupted: interrupted,
+		ScanSummary: summary,
+	}
+
+	claims := discovery.TakeARPClaims()
+	if watcher != nil {
+		if gateway, err := discovery.DefaultGateway(); err == nil {
+			watcher.SetGateway(gateway)
+		}
+	}
+
+	snapshot, findings, persistErr := persistScan(inventory, cfg.GetDatabaseFile(), run, uniqueAssets, watcher, claims)
+	reportFindings(findings, notifier)
+	if persistErr != nil {
+		log.Printf("Failed to update asset inventory: %v", persistErr)
+		snapshot = uniqueAssets
+	}
+
+	saveResult(AssetResult{
+		Timestamp:   summary.Timestamp,
+		TotalHosts:  summary.TotalHosts,
+		ScanTime:    summary.ScanTime,
+		LocalNet:    summary.LocalNet,
+		FileTargets: summary.FileTargets,
+		Partial:     run.Partial,
+		Assets:      snapshot,
+	}, cfg.Files.OutputFile)
+
+	if run.Partial {
+		log.Printf("Scan stopped early: %d unique assets in %v (%s)", len(uniqueAssets), scanDuration, strings.Join(interrupted, ", "))
+		if persistErr == nil {
+			return fmt.Errorf("partial scan: %s", strings.Join(interrupted, ", "))
+		}
+		return persistErr
+	}
 	log.Printf("Scan completed: %d unique assets in %v", len(uniqueAssets), scanDuration)
+
+	return persistErr
+}
+
+// phaseDeadlines are how long each phase of a scan may run
+type phaseDeadlines struct {
+	arp        time.Duration
+	ipv6       time.Duration
+	heardHosts time.Duration
+	publicScan time.Duration
+}
+
+func getPhaseDeadlines(cfg *config.Config) phaseDeadlines {
+	var deadlines phaseDeadlines
+	var err error
+	if deadlines.arp, err = cfg.GetARPDeadline(); err != nil {
+		log.Printf("Invalid ARP deadline, using default: %v", err)
+		deadlines.arp = 15 * time.Minute
+	}
+	if deadlines.ipv6, err = cfg.GetIPv6Deadline(); err != nil {
+		log.Printf("Invalid IPv6 deadline, using default: %v", err)
+		deadlines.ipv6 = 2 * time.Minute
+	}
+	if deadlines.heardHosts, err = cfg.GetHeardHostsDeadline(); err != nil {
+		log.Printf("Invalid heard hosts deadline, using default: %v", err)
+		deadlines.heardHosts = 10 * time.Minute
+	}
+	if deadlines.publicScan, err = cfg.GetPublicScanDeadline(); err != nil {
+		log.Printf("Invalid public scan deadline, using default: %v", err)
+		deadlines.publicScan = time.Hour
+	}
+	return deadlines
+}
+
+// noteInterrupted adds a phase that was cancelled or ran out of time, with
+// the reason, to the interrupted phases of a scan
+func noteInterrupted(interrupted []string, phase string, err error) []string {
+	if !network.Interrupted(err) {
+		return interrupted
+	}
+	return append(interrupted, phase+": "+network.InterruptReason(err))
 }
 
-func scanLocalNetwork(cfg *config.Config, discovery *network.AssetDiscovery) []network.Asset {
+// scanLocalNetwork sweeps the local network. Only an interruption is
+// returned as an error, with the assets found before it.
+func scanLocalNetwork(ctx context.Context, cfg *config.Config, discovery *network.AssetDiscovery) ([]network.Asset, error) {
 	localCIDR := getLocalNetwork(cfg)
 	if localCIDR == "" {
-		return []network.Asset{}
+		return []network.Asset{}, nil
 	}
 
 	log.Printf("Scanning local network: %s", localCIDR)
 
-	assets, err := discovery.DiscoverAssets(localCIDR, cfg.PortScan.Enabled)
-	if err != nil {
+	assets, err := discovery.DiscoverAssets(ctx, localCIDR, cfg.PortScan.Enabled)
+	if err != nil && !network.Interrupted(err) {
 		log.Printf("Local network scan failed: %v", err)
-		return []network.Asset{}
+		return []network.Asset{}, nil
 	}
 
-	return assets
+	return assets, err
 }
 
-func scanFileTargetsExcluding(cfg *config.Config, discovery *network.AssetDiscovery, excludeCIDR string) []network.Asset {
-	cidrs, err := network.ReadCIDRsFromFile(cfg.Files.IPListFile)
+// scanFileTargetsExcluding sweeps the targets in the target file other
+// than excludeCIDR, less the file's exclusions, each with its own port
+// profile and tagging the assets it finds. Only an interruption is returned
+// as an error, with the assets found before it.
+func scanFileTargetsExcluding(ctx context.Context, cfg *config.Config, discovery *network.AssetDiscovery, excludeCIDR string) ([]network.Asset, error) {
+	file, err := network.ReadTargetsFromFile(cfg.Files.IPListFile)
 	if err != nil {
-		log.Printf("Failed to read CIDR file: %v", err)
-		return []network.Asset{}
+		log.Printf("Failed to read target file: %v", err)
+		return []network.Asset{}, nil
+	}
+
+	exclude := append(append([]string{}, cfg.Network.Exclude...), file.Exclude...)
+	if err := discovery.SetExclusions(exclude); err != nil {
+		log.Printf("Invalid exclusions, skipping file targets: %v", err)
+		return []network.Asset{}, nil
 	}
+	defer discovery.SetExclusions(cfg.Network.Exclude)
+	defaults := portScanProfile(cfg)
+	defer discovery.SetPorts(defaults.TCP, defaults.UDP)
 
 	var allAssets []network.Asset
-	for _, cidr := range cidrs {
-		if cidr == excludeCIDR {
-			log.Printf("Skipping %s (already scanned as local network)", cidr)
+	for _, target := range file.Targets {
+		if target.Spec == excludeCIDR {
+			log.Printf("Skipping %s (already scanned as local network)", target.Spec)
 			continue
 		}
 
-		log.Printf("Scanning file target: %s", cidr)
-		assets, err := discovery.DiscoverAssets(cidr, cfg.PortScan.Enabled)
+		specs, err := target.Resolve(ctx)
 		if err != nil {
-			log.Printf("Error scanning CIDR %s: %v", cidr, err)
+			log.Printf("Error scanning %s: %v", target.Spec, err)
 			continue
 		}
-		allAssets = append(allAssets, assets...)
+		profile := defaults
+		if target.Ports != "" {
+			profile, _ = network.ParsePortProfile(target.Ports)
+		}
+		discovery.SetPorts(profile.TCP, profile.UDP)
+
+		for _, spec := range specs {
+			log.Printf("Scanning file target: %s", spec)
+			assets, err := discovery.DiscoverAssets(ctx, spec, cfg.PortScan.Enabled)
+			for i := range assets {
+				assets[i].AddTags(target.Tags...)
+			}
+			allAssets = append(allAssets, assets...)
+			if network.Interrupted(err) {
+				return allAssets, err
+			}
+			if err != nil {
+				log.Printf("Error scanning %s: %v", spec, err)
+			}
+		}
 	}
 
-	return allAssets
+	return allAssets, nil
 }
 
-// scanPublicAssets scans public IP addresses using ping, TCP, and UDP
-func scanPublicAssets(cfg *config.Config) []network.Asset {
-	// Read targets from file
-	targets, err := network.ReadTargetsFromFile(cfg.Files.IPListFile)
+// scanPublicAssets scans the targets in the target file using ping, TCP,
+// and UDP, paced by limit. Targets sharing a port profile are scanned
+// together, and the assets found at a target are tagged with its tags and
+// named after it when it is a hostname. Only an interruption is returned as
+// an error, with the assets found before it.
+func scanPublicAssets(ctx context.Context, cfg *config.Config, auditor *network.CredentialAuditor, limit *network.RateLimiter) ([]network.Asset, error) {
+	file, err := network.ReadTargetsFromFile(cfg.Files.IPListFile)
 	if err != nil {
-		log.Printf("Failed to read targets from file: %v", err)
-		return []network.Asset{}
+		log.Printf("Failed to read target file: %v", err)
+		return []network.Asset{}, nil
 	}
 
-	if len(targets) == 0 {
+	if len(file.Targets) == 0 {
 		log.Println("No public targets found in file")
-		return []network.Asset{}
+		return []network.Asset{}, nil
 	}
 
-	localCIDR := getLocalNetwork(cfg)
-	filteredTargets := filterOutLocalIPs(targets, localCIDR)
-
-	if len(filteredTargets) == 0 {
-		log.Println("No public targets remaining after filtering local IPs")
-		return []network.Asset{}
+	// The local network is swept with ARP, so it is left out along with the
+	// configured exclusions and the file's own
+	exclude := append(append([]string{}, cfg.Network.Exclude...), file.Exclude...)
+	if localCIDR := getLocalNetwork(cfg); localCIDR != "" {
+		exclude = append(exclude, localCIDR)
 	}
 
-	log.Printf("Scanning %d public targets", len(filteredTargets))
-
 	timeout, err := cfg.GetPublicScanTimeout()
 	if err != nil {
 		log.Printf("Invalid public scan timeout, using default: %v", err)
@@ -228,53 +506,151 @@ func scanPublicAssets(cfg *config.Config) []network.Asset {
 
 	scanner := network.NewPublicAssetScanner(timeout, cfg.PublicScan.Workers, 2)
 	defer scanner.Close()
-
-	tcpPorts := cfg.PublicScan.TCPPorts
-	if len(tcpPorts) == 0 {
-		tcpPorts = network.GetCommonTCPPorts()
+	scanner.SetPingEnabled(cfg.PublicScan.PingEnabled)
+	scanner.SetPingCount(cfg.GetPingCount())
+	scanner.SetServiceDetection(cfg.PublicScan.ServiceDetection)
+	scanner.SetTLSInspector(cfg.NewTLSInspector())
+	scanner.SetHTTPEnumerator(cfg.NewHTTPEnumerator())
+	scanner.SetScreenshotter(cfg.NewScreenshotter())
+	scanner.SetCredentialAuditor(auditor)
+	scanner.SetRateLimiter(limit)
+	scanner.SetRandomOrder(cfg.Network.RandomOrder)
+
+	defaults, err := network.ParsePortProfile(cfg.GetPublicScanProfile())
+	if err != nil {
+		log.Printf("Invalid public scan profile, using default: %v", err)
+		defaults = network.DefaultPortProfile()
 	}
 
-	udpPorts := cfg.PublicScan.UDPPorts
-	if len(udpPorts) == 0 {
-		udpPorts = network.GetCommonUDPPorts()
+	// Resolve the targets, grouped by port profile in file order
+	type resolvedTarget struct {
+		network.Target
+		specs     []string
+		addresses *network.AddressSet
 	}
-
-	publicAssets, err := scanner.ScanPublicAssets(filteredTargets, tcpPorts, udpPorts)
-	if err != nil {
-		log.Printf("Public scan failed: %v", err)
-		return []network.Asset{}
+	groups := make(map[string][]resolvedTarget)
+	var profiles []string
+	for _, target := range file.Targets {
+		specs, err := target.Resolve(ctx)
+		if err != nil {
+			log.Printf("Skipping public target %s: %v", target.Spec, err)
+			continue
+		}
+		addresses, _ := network.ParseAddressSet(specs, nil)
+		if _, ok := groups[target.Ports]; !ok {
+			profiles = append(profiles, target.Ports)
+		}
+		groups[target.Ports] = append(groups[target.Ports], resolvedTarget{Target: target, specs: specs, addresses: addresses})
 	}
 
 	var assets []network.Asset
-	for _, publicAsset := range publicAssets {
-		assets = append(assets, publicAsset.ToAsset())
+	for _, ports := range profiles {
+		var specs []string
+		for _, target := range groups[ports] {
+			specs = append(specs, target.specs...)
+		}
+		addresses, err := network.ParseAddressSet(specs, exclude)
+		if err != nil {
+			log.Printf("Invalid public targets or exclusions: %v", err)
+			return assets, nil
+		}
+		if addresses.Size() == 0 {
+			log.Println("No public targets remaining after filtering local IPs")
+			continue
+		}
+
+		profile := defaults
+		if ports != "" {
+			profile, _ = network.ParsePortProfile(ports)
+		}
+
+		publicAssets, err := scanner.ScanPublicAssets(ctx, addresses, profile.TCP, profile.UDP)
+		if err != nil && !network.Interrupted(err) {
+			log.Printf("Public scan failed: %v", err)
+			continue
+		}
+
+		for _, publicAsset := range publicAssets {
+			asset := publicAsset.ToAsset()
+			if addr, perr := netip.ParseAddr(asset.IP); perr == nil {
+				for _, target := range groups[ports] {
+					if !target.addresses.Contains(addr) {
+						continue
+					}
+					asset.AddTags(target.Tags...)
+					if asset.Hostname == "" {
+						asset.Hostname = target.Hostname
+					}
+				}
+			}
+			assets = append(assets, asset)
+		}
+		if err != nil {
+			return assets, err
+		}
 	}
 
-	return assets
+	return assets, nil
 }
 
-func filterOutLocalIPs(targets []string, localCIDR string) []string {
-	if localCIDR == "" {
-		return targets
+// persistScan records the scan run in the inventory store and returns the
+// full inventory, including assets that were not seen by this scan, with
+// the findings watcher raised on the ARP answers compared to the inventory
+// as it was before. Without a shared inventory the database at dbPath is
+// only held open for the duration of the update so a separate API server
+// can read it between scans.
+func persistScan(inventory *store.Store, dbPath string, run store.ScanRun, assets []network.Asset, watcher *network.ARPWatcher, claims network.ARPClaims) ([]network.Asset, []store.StoredFinding, error) {
+	if inventory == nil {
+		var err error
+		inventory, err = store.Open(dbPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer inventory.Close()
 	}
 
-	_, localNet, err := net.ParseCIDR(localCIDR)
+	var raised []network.NetworkFinding
+	if watcher != nil && len(claims) > 0 {
+		previous, err := inventory.GetAssets()
+		if err != nil {
+			return nil, nil, err
+		}
+		raised = watcher.Check(claims, previous)
+	}
+
+	recorded, err := inventory.RecordScan(run, assets)
 	if err != nil {
-		log.Printf("Invalid local CIDR %s: %v", localCIDR, err)
-		return targets
+		return nil, nil, err
 	}
+	log.Printf("Recorded scan #%d: %d new, %d offline, %d changes",
+		recorded.ID, recorded.NewAssets, recorded.OfflineAssets, recorded.Changes)
 
-	var filtered []string
-	for _, target := range targets {
-		ip := net.ParseIP(target)
-		if ip != nil && !localNet.Contains(ip) {
-			filtered = append(filtered, target)
-		}
+	findings, err := inventory.RecordFindings(*recorded, raised)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return filtered
+	snapshot, err := inventory.GetAssets()
+	return snapshot, findings, err
 }
 
+// reportFindings logs the findings a scan raised and sends them to the
+// notifier, when one is configured
+func reportFindings(findings []store.StoredFinding, notifier *notify.Notifier) {
+	for _, finding := range findings {
+		log.Printf("Finding #%d (%s, %s): %s", finding.ID, finding.Severity, finding.Type, finding.Detail)
+	}
+	if notifier == nil {
+		return
+	}
+	if err := notifier.NotifyFindings(findings); err != nil {
+		log.Printf("Failed to send findings: %v", err)
+	}
+}
+
+// saveResult exports the inventory snapshot as JSON for file-based consumers.
+// The file is written to a temporary name and renamed into place so readers
+// never see a partially written file.
 func saveResult(result AssetResult, outputFile string) {
 	data, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
@@ -282,12 +658,18 @@ func saveResult(result AssetResult, outputFile string) {
 		return
 	}
 
-	err = ioutil.WriteFile(outputFile, data, 0644)
-	if err != nil {
+	tmpFile := outputFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
 		log.Printf("File write failed: %v", err)
 		return
 	}
 
+	if err := os.Rename(tmpFile, outputFile); err != nil {
+		log.Printf("File write failed: %v", err)
+		os.Remove(tmpFile)
+		return
+	}
+
 	log.Printf("Results saved to: %s", outputFile)
 }
 
@@ -301,19 +683,11 @@ func getLocalNetwork(cfg *config.Config) string {
 }
 
 func countFileTargets(filename string) int {
-	targets, err := network.ReadCIDRsFromFile(filename)
+	file, err := network.ReadTargetsFromFile(filename)
 	if err != nil {
 		return 0
 	}
-	return len(targets)
-}
-
-func createTicker(interval string) *time.Ticker {
-	duration, err := time.ParseDuration(interval)
-	if err != nil {
-		duration = 5 * time.Minute
-	}
-	return time.NewTicker(duration)
+	return len(file.Targets)
 }
 
 func saveDefaultConfig() {
@@ -325,65 +699,3 @@ func saveDefaultConfig() {
 		log.Println("Default config.json created")
 	}
 }
-
-func removeDuplicateAssets(assets []network.Asset) []network.Asset {
-	assetMap := make(map[string]*network.Asset)
-
-	for _, asset := range assets {
-		if existing, ok := assetMap[asset.IP]; ok {
-			if existing.MAC == "" && asset.MAC != "" {
-				existing.MAC = asset.MAC
-			}
-
-			if existing.Vendor == "" && asset.Vendor != "" {
-				existing.Vendor = asset.Vendor
-			}
-
-			if existing.Hostname == "" && asset.Hostname != "" {
-				existing.Hostname = asset.Hostname
-			}
-
-			if len(asset.OpenPorts) > 0 {
-				existing.OpenPorts = mergePortResults(existing.OpenPorts, asset.OpenPorts)
-			}
-
-			if asset.LastSeen.After(existing.LastSeen) {
-				existing.LastSeen = asset.LastSeen
-			}
-
-			if asset.ARPResponse {
-				existing.ARPResponse = true
-			}
-
-		} else {
-			newAsset := asset
-			assetMap[asset.IP] = &newAsset
-		}
-	}
-
-	var uniqueAssets []network.Asset
-	for _, asset := range assetMap {
-		uniqueAssets = append(uniqueAssets, *asset)
-	}
-
-	return uniqueAssets
-}
-
-func mergePortResults(existing, new []network.PortScanResult) []network.PortScanResult {
-	portMap := make(map[int]network.PortScanResult)
-
-	for _, port := range existing {
-		portMap[port.Port] = port
-	}
-
-	for _, port := range new {
-		portMap[port.Port] = port
-	}
-
-	var merged []network.PortScanResult
-	for _, port := range portMap {
-		merged = append(merged, port)
-	}
-
-	return merged
-}
@@ -0,0 +1,56 @@
+// Command ouiupdate rebuilds the embedded vendor registry from IEEE CSV
+// exports (oui.csv, mam.csv, oui36.csv) downloaded from
+// https://standards-oui.ieee.org. Rebuild the binaries afterwards to pick
+// up the new registry.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"assetmanager/pkg/network"
+)
+
+func main() {
+	out := flag.String("out", "pkg/network/oui_registry.csv", "registry file to write")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-out file] oui.csv [mam.csv] [oui36.csv]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	merged := make(map[string]network.OUIEntry)
+	for _, path := range flag.Args() {
+		entries, err := network.ReadOUIRegistryFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read registry: %v", err)
+		}
+		for _, entry := range entries {
+			merged[string(entry.Registry)+entry.Assignment] = entry
+		}
+		log.Printf("Read %d assignments from %s", len(entries), path)
+	}
+
+	entries := make([]network.OUIEntry, 0, len(merged))
+	for _, entry := range merged {
+		entries = append(entries, entry)
+	}
+
+	var buf bytes.Buffer
+	if err := network.WriteOUIRegistry(&buf, entries); err != nil {
+		log.Fatalf("Failed to encode registry: %v", err)
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+
+	log.Printf("Wrote %d assignments to %s", len(entries), *out)
+}
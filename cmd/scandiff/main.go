@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"assetmanager/pkg/config"
+	"assetmanager/pkg/store"
+)
+
+func main() {
+	cfg, err := config.LoadConfig("config.json")
+	if err != nil {
+		cfg = config.GetDefaultConfig()
+	}
+
+	dbPath := flag.String("db", cfg.GetDatabaseFile(), "inventory database file")
+	from := flag.String("from", "", "scan ID to compare from (default: the scan before -to)")
+	to := flag.String("to", "", "scan ID to compare to (default: latest scan)")
+	since := flag.String("since", "", "compare from the last scan at or before this date or timestamp")
+	format := flag.String("format", "text", "output format: text or json")
+	flag.Parse()
+
+	inventory, err := store.OpenReadOnly(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open inventory: %v", err)
+	}
+	defer inventory.Close()
+
+	fromID, toID, err := inventory.ResolveScanRange(*from, *to, *since)
+	if err != nil {
+		log.Fatalf("Failed to select scans: %v", err)
+	}
+
+	diff, err := inventory.DiffScans(fromID, toID)
+	if err != nil {
+		log.Fatalf("Failed to compare scans: %v", err)
+	}
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(diff)
+	case "text":
+		err = diff.WriteText(os.Stdout)
+	default:
+		log.Fatalf("Unknown format %q (expected text or json)", *format)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
@@ -2,55 +2,38 @@ package main
 
 import (
 	"log"
-	"net/http"
 
 	"assetmanager/api"
-
-	"github.com/gin-gonic/gin"
+	"assetmanager/pkg/config"
+	"assetmanager/pkg/jobs"
 )
 
+// This binary serves the API on its own, reading the inventory written by the
+// daemon. Use "assetmanager serve" to run the scheduler and API in one process.
 func main() {
-	// Create Gin router
-	r := gin.Default()
-
-	// Enable CORS middleware
-	r.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-
-		c.Next()
-	})
-
-	// API routes
-	v1 := r.Group("/api/v1")
-	{
-		v1.GET("/", api.HandleHome)
-		v1.GET("/assets", api.GetAssets)
-		v1.GET("/getAssets", api.GetAssets) // Alternative endpoint name
+	cfg, err := config.LoadConfig("config.json")
+	if err != nil {
+		log.Printf("Config load failed, using defaults: %v", err)
+		cfg = config.GetDefaultConfig()
+	}
+	api.SetStorePath(cfg.GetDatabaseFile())
+	api.SetScreenshotDir(cfg.GetScreenshotDir())
+
+	opts := jobs.OptionsFromConfig(cfg)
+	if auditor, err := cfg.NewCredentialAuditor(); err != nil {
+		log.Printf("Credential audit disabled: %v", err)
+	} else {
+		opts.Credentials = auditor
 	}
+	api.SetJobManager(jobs.NewManager(opts))
 
-	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "healthy",
-			"service": "asset-management-api",
-		})
-	})
+	r := api.NewRouter()
 
 	// Start server
-	log.Println("Starting Asset Management API server on :8080")
-	log.Println("Available endpoints:")
-	log.Println("  GET /api/v1/assets - Get all discovered assets")
-	log.Println("  GET /api/v1/getAssets - Get all discovered assets (alternative)")
-	log.Println("  GET /health - Health check")
+	log.Printf("Starting Asset Management API server on %s", cfg.GetAPIListen())
+	api.LogEndpoints()
 
-	if err := r.Run(":8080"); err != nil {
+	if err := r.Run(cfg.GetAPIListen()); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
 }
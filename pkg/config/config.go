@@ -6,15 +6,27 @@ import (
 	"io/ioutil"
 	"os"
 	"time"
+
+	"assetmanager/pkg/network"
+	"assetmanager/pkg/notify"
 )
 
 type Config struct {
-	Service    ServiceConfig    `json:"service"`
-	Network    NetworkConfig    `json:"network"`
-	ARP        ARPConfig        `json:"arp"`
-	PortScan   PortScanConfig   `json:"port_scan"`
-	PublicScan PublicScanConfig `json:"public_scan"`
-	Files      FileConfig       `json:"files"`
+	Service         ServiceConfig         `json:"service"`
+	Network         NetworkConfig         `json:"network"`
+	ARP             ARPConfig             `json:"arp"`
+	PortScan        PortScanConfig        `json:"port_scan"`
+	PublicScan      PublicScanConfig      `json:"public_scan"`
+	TLS             TLSConfig             `json:"tls"`
+	HTTP            HTTPConfig            `json:"http"`
+	Screenshot      ScreenshotConfig      `json:"screenshot"`
+	CredentialAudit CredentialAuditConfig `json:"credential_audit"`
+	ARPWatch        ARPWatchConfig        `json:"arp_watch"`
+	Notifications   NotificationConfig    `json:"notifications"`
+	Deadlines       DeadlineConfig        `json:"deadlines"`
+	Timing          TimingConfig          `json:"timing"`
+	Files           FileConfig            `json:"files"`
+	API             APIConfig             `json:"api"`
 }
 
 type ServiceConfig struct {
@@ -30,6 +42,13 @@ type NetworkConfig struct {
 	DefaultCIDR      string `json:"default_cidr"`
 	ScanLocalNetwork bool   `json:"scan_local_network"`
 	ScanFileList     bool   `json:"scan_file_list"`
+	IPv6Discovery    bool   `json:"ipv6_discovery"`
+	PassiveListen    bool   `json:"passive_listen"`
+	// Exclude lists the addresses, prefixes and ranges no scan probes
+	Exclude []string `json:"exclude"`
+	// RandomOrder probes the addresses of each target in random order
+	// rather than address order
+	RandomOrder bool `json:"random_order"`
 }
 
 type ARPConfig struct {
@@ -40,23 +59,113 @@ type ARPConfig struct {
 }
 
 type PortScanConfig struct {
+	Enabled          bool   `json:"enabled"`
+	Timeout          string `json:"timeout"`
+	Workers          int    `json:"workers"`
+	Mode             string `json:"mode"`
+	Profile          string `json:"profile"`
+	ServiceDetection bool   `json:"service_detection"`
+}
+
+type PublicScanConfig struct {
+	Enabled          bool   `json:"enabled"`
+	Timeout          string `json:"timeout"`
+	Workers          int    `json:"workers"`
+	Profile          string `json:"profile"`
+	TCPPorts         []int  `json:"tcp_ports,omitempty"`
+	UDPPorts         []int  `json:"udp_ports,omitempty"`
+	PingEnabled      bool   `json:"ping_enabled"`
+	PingCount        int    `json:"ping_count"`
+	ServiceDetection bool   `json:"service_detection"`
+}
+
+type TLSConfig struct {
+	Enabled           bool   `json:"enabled"`
+	Timeout           string `json:"timeout"`
+	ExpiryWarningDays int    `json:"expiry_warning_days"`
+}
+
+type HTTPConfig struct {
 	Enabled bool   `json:"enabled"`
 	Timeout string `json:"timeout"`
-	Workers int    `json:"workers"`
 }
 
-type PublicScanConfig struct {
+type ScreenshotConfig struct {
 	Enabled     bool   `json:"enabled"`
-	Timeout     string `json:"timeout"`
+	Directory   string `json:"directory"`
 	Workers     int    `json:"workers"`
-	TCPPorts    []int  `json:"tcp_ports"`
-	UDPPorts    []int  `json:"udp_ports"`
-	PingEnabled bool   `json:"ping_enabled"`
+	Timeout     string `json:"timeout"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	BrowserPath string `json:"browser_path,omitempty"`
+}
+
+// CredentialAuditConfig configures the default-credential audit. Only
+// assets inside AllowedCIDRs are audited; with none listed nothing is.
+type CredentialAuditConfig struct {
+	Enabled        bool     `json:"enabled"`
+	CredentialFile string   `json:"credential_file"`
+	AllowedCIDRs   []string `json:"allowed_cidrs"`
+	Timeout        string   `json:"timeout"`
+	Delay          string   `json:"delay"`
+	MaxPerUser     int      `json:"max_attempts_per_user"`
+	ResetWindow    string   `json:"reset_window"`
+	Workers        int      `json:"workers"`
+}
+
+// ARPWatchConfig configures ARP spoofing and address conflict detection.
+// Routers, by MAC or IP address, may answer for many addresses.
+type ARPWatchConfig struct {
+	Enabled      bool     `json:"enabled"`
+	Routers      []string `json:"routers"`
+	MaxIPsPerMAC int      `json:"max_ips_per_mac"`
+}
+
+// NotificationConfig configures where findings are sent. With no webhook
+// URL they are only logged.
+type NotificationConfig struct {
+	WebhookURL string `json:"webhook_url"`
+	Timeout    string `json:"timeout"`
+}
+
+// DeadlineConfig bounds how long each scan phase may run in total. A phase
+// that runs out of time stops with what it has found so far. "0" leaves a
+// phase unbounded.
+type DeadlineConfig struct {
+	ARP        string `json:"arp"`
+	IPv6       string `json:"ipv6"`
+	PortScan   string `json:"port_scan"`
+	HeardHosts string `json:"heard_hosts"`
+	PublicScan string `json:"public_scan"`
+}
+
+// TimingConfig paces the probes of each scan. Template is a preset from T0
+// (paranoid) to T5 (insane); a rate set here overrides the template's.
+// Rates are probes a second, across the scan and to any one /24 or /64.
+type TimingConfig struct {
+	Enabled    bool    `json:"enabled"`
+	Template   string  `json:"template"`
+	Rate       float64 `json:"packets_per_second"`
+	SubnetRate float64 `json:"subnet_packets_per_second"`
+	Adaptive   bool    `json:"adaptive"`
 }
 
 type FileConfig struct {
-	IPListFile string `json:"ip_list_file"`
-	OutputFile string `json:"output_file"`
+	IPListFile   string `json:"ip_list_file"`
+	OutputFile   string `json:"output_file"`
+	DatabaseFile string `json:"database_file"`
+}
+
+// APIConfig configures the REST API. On-demand scans beyond
+// max_running_scans wait in a queue of up to max_queued_scans, and are
+// refused beyond that. A finished scan is kept for scan_retention, and at
+// most max_retained_scans of them are kept.
+type APIConfig struct {
+	Listen           string `json:"listen"`
+	MaxRunningScans  int    `json:"max_running_scans"`
+	MaxQueuedScans   int    `json:"max_queued_scans"`
+	ScanRetention    string `json:"scan_retention"`
+	MaxRetainedScans int    `json:"max_retained_scans"`
 }
 
 func LoadConfig(configPath string) (*Config, error) {
@@ -119,6 +228,39 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	switch c.PortScan.Mode {
+	case "", "connect", "syn":
+	default:
+		return fmt.Errorf("invalid port scan mode %q (expected connect or syn)", c.PortScan.Mode)
+	}
+
+	if _, err := network.ParsePortProfile(c.GetPortScanProfile()); err != nil {
+		return fmt.Errorf("invalid port scan profile: %v", err)
+	}
+	if _, err := network.ParsePortProfile(c.GetPublicScanProfile()); err != nil {
+		return fmt.Errorf("invalid public scan profile: %v", err)
+	}
+
+	if c.TLS.Timeout != "" {
+		if _, err := time.ParseDuration(c.TLS.Timeout); err != nil {
+			return fmt.Errorf("invalid TLS timeout: %v", err)
+		}
+	}
+
+	if c.TLS.ExpiryWarningDays < 0 {
+		return fmt.Errorf("invalid TLS expiry_warning_days: %d", c.TLS.ExpiryWarningDays)
+	}
+
+	if c.HTTP.Timeout != "" {
+		if _, err := time.ParseDuration(c.HTTP.Timeout); err != nil {
+			return fmt.Errorf("invalid HTTP timeout: %v", err)
+		}
+	}
+
+	if c.PublicScan.PingCount < 0 {
+		return fmt.Errorf("invalid public scan ping_count: %d", c.PublicScan.PingCount)
+	}
+
 	return nil
 }
 
@@ -150,6 +292,31 @@ func (c *Config) GetPortScanTimeout() (time.Duration, error) {
 	return time.ParseDuration(c.PortScan.Timeout)
 }
 
+func (c *Config) GetPortScanMode() string {
+	if c.PortScan.Mode == "" {
+		return "connect"
+	}
+	return c.PortScan.Mode
+}
+
+func (c *Config) GetPortScanProfile() string {
+	if c.PortScan.Profile == "" {
+		return network.ProfileDefault
+	}
+	return c.PortScan.Profile
+}
+
+// The older tcp_ports and udp_ports lists still apply when no profile is set
+func (c *Config) GetPublicScanProfile() string {
+	if c.PublicScan.Profile == "" && (len(c.PublicScan.TCPPorts) > 0 || len(c.PublicScan.UDPPorts) > 0) {
+		return network.PortSpec(c.PublicScan.TCPPorts, c.PublicScan.UDPPorts)
+	}
+	if c.PublicScan.Profile == "" {
+		return network.ProfileDefault
+	}
+	return c.PublicScan.Profile
+}
+
 func (c *Config) GetPublicScanTimeout() (time.Duration, error) {
 	if c.PublicScan.Timeout == "" {
 		return 5 * time.Second, nil
@@ -157,6 +324,294 @@ func (c *Config) GetPublicScanTimeout() (time.Duration, error) {
 	return time.ParseDuration(c.PublicScan.Timeout)
 }
 
+func (c *Config) GetMaxRunningScans() int {
+	if c.API.MaxRunningScans == 0 {
+		return 2
+	}
+	return c.API.MaxRunningScans
+}
+
+func (c *Config) GetMaxQueuedScans() int {
+	if c.API.MaxQueuedScans == 0 {
+		return 8
+	}
+	return c.API.MaxQueuedScans
+}
+
+func (c *Config) GetScanRetention() (time.Duration, error) {
+	if c.API.ScanRetention == "" {
+		return 24 * time.Hour, nil
+	}
+	return time.ParseDuration(c.API.ScanRetention)
+}
+
+func (c *Config) GetMaxRetainedScans() int {
+	if c.API.MaxRetainedScans == 0 {
+		return 100
+	}
+	return c.API.MaxRetainedScans
+}
+
+func (c *Config) GetPingCount() int {
+	if c.PublicScan.PingCount == 0 {
+		return 2
+	}
+	return c.PublicScan.PingCount
+}
+
+func (c *Config) GetTLSTimeout() (time.Duration, error) {
+	if c.TLS.Timeout == "" {
+		return 5 * time.Second, nil
+	}
+	return time.ParseDuration(c.TLS.Timeout)
+}
+
+func (c *Config) GetTLSExpiryWarning() time.Duration {
+	if c.TLS.ExpiryWarningDays == 0 {
+		return 30 * 24 * time.Hour
+	}
+	return time.Duration(c.TLS.ExpiryWarningDays) * 24 * time.Hour
+}
+
+// NewTLSInspector returns the TLS inspector for the scanners, or nil when
+// TLS inspection is disabled
+func (c *Config) NewTLSInspector() *network.TLSInspector {
+	if !c.TLS.Enabled {
+		return nil
+	}
+	timeout, err := c.GetTLSTimeout()
+	if err != nil {
+		timeout = 5 * time.Second
+	}
+	return network.NewTLSInspector(timeout, c.GetTLSExpiryWarning())
+}
+
+func (c *Config) GetHTTPTimeout() (time.Duration, error) {
+	if c.HTTP.Timeout == "" {
+		return 5 * time.Second, nil
+	}
+	return time.ParseDuration(c.HTTP.Timeout)
+}
+
+// NewHTTPEnumerator returns the HTTP enumerator for the scanners, or nil
+// when HTTP enumeration is disabled
+func (c *Config) NewHTTPEnumerator() *network.HTTPEnumerator {
+	if !c.HTTP.Enabled {
+		return nil
+	}
+	timeout, err := c.GetHTTPTimeout()
+	if err != nil {
+		timeout = 5 * time.Second
+	}
+	return network.NewHTTPEnumerator(timeout)
+}
+
+func (c *Config) GetScreenshotDir() string {
+	if c.Screenshot.Directory == "" {
+		return "screenshots"
+	}
+	return c.Screenshot.Directory
+}
+
+func (c *Config) GetScreenshotTimeout() (time.Duration, error) {
+	if c.Screenshot.Timeout == "" {
+		return 15 * time.Second, nil
+	}
+	return time.ParseDuration(c.Screenshot.Timeout)
+}
+
+// NewScreenshotter returns the screenshotter for the scanners, or nil when
+// screenshots are disabled
+func (c *Config) NewScreenshotter() *network.Screenshotter {
+	if !c.Screenshot.Enabled {
+		return nil
+	}
+	timeout, err := c.GetScreenshotTimeout()
+	if err != nil {
+		timeout = 15 * time.Second
+	}
+	screenshotter := network.NewScreenshotter(c.GetScreenshotDir(), c.Screenshot.Workers, timeout, c.Screenshot.Width, c.Screenshot.Height)
+	screenshotter.SetBrowserPath(c.Screenshot.BrowserPath)
+	return screenshotter
+}
+
+func (c *Config) GetCredentialFile() string {
+	if c.CredentialAudit.CredentialFile == "" {
+		return "credentials.txt"
+	}
+	return c.CredentialAudit.CredentialFile
+}
+
+func (c *Config) GetCredentialAuditTimeout() (time.Duration, error) {
+	if c.CredentialAudit.Timeout == "" {
+		return 5 * time.Second, nil
+	}
+	return time.ParseDuration(c.CredentialAudit.Timeout)
+}
+
+func (c *Config) GetCredentialAuditDelay() (time.Duration, error) {
+	if c.CredentialAudit.Delay == "" {
+		return 2 * time.Second, nil
+	}
+	return time.ParseDuration(c.CredentialAudit.Delay)
+}
+
+func (c *Config) GetCredentialAuditResetWindow() (time.Duration, error) {
+	if c.CredentialAudit.ResetWindow == "" {
+		return 24 * time.Hour, nil
+	}
+	return time.ParseDuration(c.CredentialAudit.ResetWindow)
+}
+
+// NewCredentialAuditor returns the default-credential auditor for the
+// scanners, or nil when the audit is disabled. An error is returned when
+// the audit is enabled but its credential file or allow-list is unusable.
+func (c *Config) NewCredentialAuditor() (*network.CredentialAuditor, error) {
+	if !c.CredentialAudit.Enabled {
+		return nil, nil
+	}
+	if len(c.CredentialAudit.AllowedCIDRs) == 0 {
+		return nil, fmt.Errorf("credential audit enabled without allowed_cidrs")
+	}
+
+	credentials, err := network.LoadCredentialFile(c.GetCredentialFile())
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := c.GetCredentialAuditTimeout()
+	if err != nil {
+		timeout = 5 * time.Second
+	}
+	delay, err := c.GetCredentialAuditDelay()
+	if err != nil {
+		delay = 2 * time.Second
+	}
+	window, err := c.GetCredentialAuditResetWindow()
+	if err != nil {
+		window = 24 * time.Hour
+	}
+
+	auditor, err := network.NewCredentialAuditor(credentials, c.CredentialAudit.AllowedCIDRs, timeout)
+	if err != nil {
+		return nil, err
+	}
+	auditor.SetRateLimit(delay, c.CredentialAudit.MaxPerUser)
+	auditor.SetResetWindow(window)
+	auditor.SetConcurrency(c.CredentialAudit.Workers)
+	return auditor, nil
+}
+
+// NewARPWatcher returns the watcher checking ARP sweeps for spoofing and
+// address conflicts, or nil when it is disabled
+func (c *Config) NewARPWatcher() *network.ARPWatcher {
+	if !c.ARPWatch.Enabled {
+		return nil
+	}
+	return network.NewARPWatcher(c.ARPWatch.Routers, c.ARPWatch.MaxIPsPerMAC)
+}
+
+func (c *Config) GetNotificationTimeout() (time.Duration, error) {
+	if c.Notifications.Timeout == "" {
+		return 10 * time.Second, nil
+	}
+	return time.ParseDuration(c.Notifications.Timeout)
+}
+
+// NewNotifier returns the webhook notifier for findings, or nil when no
+// webhook is configured
+func (c *Config) NewNotifier() *notify.Notifier {
+	if c.Notifications.WebhookURL == "" {
+		return nil
+	}
+	timeout, err := c.GetNotificationTimeout()
+	if err != nil {
+		timeout = 10 * time.Second
+	}
+	return notify.NewWebhook(c.Notifications.WebhookURL, timeout)
+}
+
+func (c *Config) GetARPDeadline() (time.Duration, error) {
+	if c.Deadlines.ARP == "" {
+		return 15 * time.Minute, nil
+	}
+	return time.ParseDuration(c.Deadlines.ARP)
+}
+
+func (c *Config) GetIPv6Deadline() (time.Duration, error) {
+	if c.Deadlines.IPv6 == "" {
+		return 2 * time.Minute, nil
+	}
+	return time.ParseDuration(c.Deadlines.IPv6)
+}
+
+func (c *Config) GetPortScanDeadline() (time.Duration, error) {
+	if c.Deadlines.PortScan == "" {
+		return 30 * time.Minute, nil
+	}
+	return time.ParseDuration(c.Deadlines.PortScan)
+}
+
+func (c *Config) GetHeardHostsDeadline() (time.Duration, error) {
+	if c.Deadlines.HeardHosts == "" {
+		return 10 * time.Minute, nil
+	}
+	return time.ParseDuration(c.Deadlines.HeardHosts)
+}
+
+func (c *Config) GetPublicScanDeadline() (time.Duration, error) {
+	if c.Deadlines.PublicScan == "" {
+		return time.Hour, nil
+	}
+	return time.ParseDuration(c.Deadlines.PublicScan)
+}
+
+// GetTiming returns the template's timing with the configured overrides
+func (c *Config) GetTiming() (network.Timing, error) {
+	template := c.Timing.Template
+	if template == "" {
+		template = "T3"
+	}
+	timing, err := network.TimingTemplate(template)
+	if err != nil {
+		return network.Timing{}, err
+	}
+	if c.Timing.Rate > 0 {
+		timing.Rate = c.Timing.Rate
+	}
+	if c.Timing.SubnetRate > 0 {
+		timing.SubnetRate = c.Timing.SubnetRate
+	}
+	timing.Adaptive = timing.Adaptive && c.Timing.Adaptive
+	return timing, nil
+}
+
+// NewRateLimiter returns a limiter pacing the probes of one scan, or nil
+// when scans are not paced
+func (c *Config) NewRateLimiter() (*network.RateLimiter, error) {
+	if !c.Timing.Enabled {
+		return nil, nil
+	}
+	timing, err := c.GetTiming()
+	if err != nil {
+		return nil, err
+	}
+	return network.NewRateLimiter(timing), nil
+}
+
+func (c *Config) GetDatabaseFile() string {
+	if c.Files.DatabaseFile == "" {
+		return "assets.db"
+	}
+	return c.Files.DatabaseFile
+}
+
+func (c *Config) GetAPIListen() string {
+	if c.API.Listen == "" {
+		return ":8080"
+	}
+	return c.API.Listen
+}
+
 func GetDefaultConfig() *Config {
 	return &Config{
 		Service: ServiceConfig{
@@ -171,6 +626,10 @@ func GetDefaultConfig() *Config {
 			DefaultCIDR:      "192.168.1.0/24",
 			ScanLocalNetwork: true,
 			ScanFileList:     true,
+			IPv6Discovery:    true,
+			PassiveListen:    false,
+			Exclude:          []string{},
+			RandomOrder:      false,
 		},
 		ARP: ARPConfig{
 			Enabled:   true,
@@ -179,21 +638,81 @@ func GetDefaultConfig() *Config {
 			RateLimit: "100ms",
 		},
 		PortScan: PortScanConfig{
-			Enabled: false,
-			Timeout: "2s",
-			Workers: 20,
+			Enabled:          false,
+			Timeout:          "2s",
+			Workers:          20,
+			Mode:             "connect",
+			Profile:          network.ProfileDefault,
+			ServiceDetection: true,
 		},
 		PublicScan: PublicScanConfig{
-			Enabled:     true,
-			Timeout:     "5s",
-			Workers:     10,
-			TCPPorts:    []int{22, 23, 53, 80, 443, 993, 995, 3389, 5432, 3306},
-			UDPPorts:    []int{53, 123, 161, 514},
-			PingEnabled: true,
+			Enabled:          true,
+			Timeout:          "5s",
+			Workers:          10,
+			Profile:          network.ProfileDefault,
+			PingEnabled:      true,
+			PingCount:        2,
+			ServiceDetection: true,
+		},
+		TLS: TLSConfig{
+			Enabled:           true,
+			Timeout:           "5s",
+			ExpiryWarningDays: 30,
+		},
+		HTTP: HTTPConfig{
+			Enabled: true,
+			Timeout: "5s",
+		},
+		Screenshot: ScreenshotConfig{
+			Enabled:   false,
+			Directory: "screenshots",
+			Workers:   4,
+			Timeout:   "15s",
+			Width:     1280,
+			Height:    800,
+		},
+		CredentialAudit: CredentialAuditConfig{
+			Enabled:        false,
+			CredentialFile: "credentials.txt",
+			AllowedCIDRs:   []string{},
+			Timeout:        "5s",
+			Delay:          "2s",
+			MaxPerUser:     3,
+			ResetWindow:    "24h",
+			Workers:        4,
+		},
+		ARPWatch: ARPWatchConfig{
+			Enabled:      true,
+			Routers:      []string{},
+			MaxIPsPerMAC: 3,
+		},
+		Notifications: NotificationConfig{
+			WebhookURL: "",
+			Timeout:    "10s",
+		},
+		Deadlines: DeadlineConfig{
+			ARP:        "15m",
+			IPv6:       "2m",
+			PortScan:   "30m",
+			HeardHosts: "10m",
+			PublicScan: "1h",
+		},
+		Timing: TimingConfig{
+			Enabled:  true,
+			Template: "T3",
+			Adaptive: true,
 		},
 		Files: FileConfig{
-			IPListFile: "list.txt",
-			OutputFile: "assets.json",
+			IPListFile:   "list.txt",
+			OutputFile:   "assets.json",
+			DatabaseFile: "assets.db",
+		},
+		API: APIConfig{
+			Listen:           ":8080",
+			MaxRunningScans:  2,
+			MaxQueuedScans:   8,
+			ScanRetention:    "24h",
+			MaxRetainedScans: 100,
 		},
 	}
 }
@@ -0,0 +1,620 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"assetmanager/pkg/network"
+)
+
+// ScanMode selects a scan phase run by a job
+type ScanMode string
+
+const (
+	// ModeARP discovers hosts on a directly attached network with ARP
+	ModeARP ScanMode = "arp"
+	// ModePort scans the ports of discovered hosts, or of every target when
+	// no discovery mode is requested
+	ModePort ScanMode = "port"
+	// ModePublic discovers remote hosts with ping and TCP/UDP probes
+	ModePublic ScanMode = "public"
+)
+
+// Status is the lifecycle state of a job
+type Status string
+
+const (
+	// StatusQueued indicates the job has not started yet
+	StatusQueued Status = "queued"
+	// StatusRunning indicates the job is scanning
+	StatusRunning Status = "running"
+	// StatusCompleted indicates every step of the job finished
+	StatusCompleted Status = "completed"
+	// StatusFailed indicates the job stopped on an error
+	StatusFailed Status = "failed"
+	// StatusCancelled indicates the job was cancelled; its results are partial
+	StatusCancelled Status = "cancelled"
+	// StatusPartial indicates the job finished but some steps ran out of
+	// time; its results are partial
+	StatusPartial Status = "partial"
+)
+
+// ScanRequest describes an on-demand scan
+type ScanRequest struct {
+	CIDRs    []string   `json:"cidrs"`
+	Ports    []int      `json:"ports,omitempty"`
+	UDPPorts []int      `json:"udp_ports,omitempty"`
+	Profile  string     `json:"profile,omitempty"`
+	Modes    []ScanMode `json:"modes"`
+	Exclude  []string   `json:"exclude,omitempty"`
+}
+
+// Progress reports how far a job has got
+type Progress struct {
+	Phase     string `json:"phase"`
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+}
+
+// Job is an on-demand scan and its results
+type Job struct {
+	ID          string          `json:"id"`
+	Status      Status          `json:"status"`
+	Request     ScanRequest     `json:"request"`
+	Progress    Progress        `json:"progress"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Interrupted []string        `json:"interrupted,omitempty"`
+	AssetsCount int             `json:"assets_count"`
+	Assets      []network.Asset `json:"assets"`
+}
+
+// Options configures the scanners created for each job
+type Options struct {
+	Interface     string
+	ARPTimeout    time.Duration
+	PortTimeout   time.Duration
+	PublicTimeout time.Duration
+	Workers       int
+	PublicWorkers int
+	RateLimit     time.Duration
+	PingEnabled   bool
+	PingCount     int
+	PortScanMode  network.TCPScanMode
+	PortProfile   string
+	PublicProfile string
+	// Exclude lists the addresses, prefixes and ranges no job probes, on
+	// top of those excluded by each request
+	Exclude []string
+	// RandomOrder probes the addresses of each target in random order
+	RandomOrder bool
+	// ARPDeadline, PortDeadline and PublicDeadline bound each step of a
+	// job by its mode; zero leaves a step unbounded
+	ARPDeadline    time.Duration
+	PortDeadline   time.Duration
+	PublicDeadline time.Duration
+	// ServiceProbes and PublicServiceProbes turn on active service
+	// detection for the port and public scans
+	ServiceProbes       bool
+	PublicServiceProbes bool
+	// TLS inspects the TLS configuration of open ports; nil turns it off
+	TLS *network.TLSInspector
+	// HTTP enumerates the web services among open ports; nil turns it off
+	HTTP *network.HTTPEnumerator
+	// Screenshots captures the web services HTTP enumeration finds; nil
+	// turns it off
+	Screenshots *network.Screenshotter
+	// Credentials audits allow-listed assets for default credentials; nil
+	// turns it off. Its attempt counts carry over from one scan to the next.
+	Credentials *network.CredentialAuditor
+	// Timing paces the probes of each job, which gets a rate limiter of its
+	// own; nil leaves jobs unpaced
+	Timing *network.Timing
+	// MaxRunning bounds the jobs run at once and MaxQueued the jobs waiting
+	// for one of them to finish; Start refuses more with ErrBusy. A zero
+	// MaxRunning leaves both unbounded.
+	MaxRunning int
+	MaxQueued  int
+	// Retention is how long a finished job and its results are kept, and
+	// MaxFinished how many finished jobs are kept at most; zero keeps them
+	Retention   time.Duration
+	MaxFinished int
+}
+
+// ErrBusy is returned by Start when as many jobs as allowed are already
+// running or queued
+var ErrBusy = errors.New("too many scans are running or queued")
+
+// Manager runs scan jobs in the background and keeps their results
+type Manager struct {
+	opts    Options
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	cancels map[string]context.CancelFunc
+	// slots holds a token for each running job when their number is bounded
+	slots chan struct{}
+	// step runs one step of a job; it is runStep but for tests
+	step func(ctx context.Context, req ScanRequest, s step, limit *network.RateLimiter) ([]network.Asset, error)
+}
+
+// NewManager creates a job manager
+func NewManager(opts Options) *Manager {
+	m := &Manager{
+		opts:    opts,
+		jobs:    make(map[string]*Job),
+		cancels: make(map[string]context.CancelFunc),
+	}
+	if opts.MaxRunning > 0 {
+		m.slots = make(chan struct{}, opts.MaxRunning)
+	}
+	m.step = m.runStep
+	return m
+}
+
+// Validate normalizes a scan request and checks it for errors. Bare IP
+// addresses are accepted as single-host CIDRs, ranges such as
+// 10.0.0.5-10.0.0.80 are accepted as they are, and the modes default to an
+// ARP sweep with port scanning.
+func (r *ScanRequest) Validate() error {
+	if len(r.CIDRs) == 0 {
+		return fmt.Errorf("at least one CIDR is required")
+	}
+	for i, cidr := range r.CIDRs {
+		cidr = strings.TrimSpace(cidr)
+		if ip := net.ParseIP(cidr); ip != nil {
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		if _, err := network.ParseAddressSet([]string{cidr}, nil); err != nil {
+			return fmt.Errorf("invalid CIDR %q", r.CIDRs[i])
+		}
+		r.CIDRs[i] = cidr
+	}
+	if _, err := network.ParseAddressSet(nil, r.Exclude); err != nil {
+		return fmt.Errorf("invalid exclusion: %w", err)
+	}
+
+	for _, port := range append(append([]int{}, r.Ports...), r.UDPPorts...) {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+	if r.Profile != "" {
+		if len(r.Ports) > 0 || len(r.UDPPorts) > 0 {
+			return fmt.Errorf("profile and explicit ports are mutually exclusive")
+		}
+		if _, err := network.ParsePortProfile(r.Profile); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Modes) == 0 {
+		r.Modes = []ScanMode{ModeARP, ModePort}
+	}
+	for _, mode := range r.Modes {
+		switch mode {
+		case ModeARP, ModePort, ModePublic:
+		default:
+			return fmt.Errorf("unknown scan mode %q", mode)
+		}
+	}
+
+	return nil
+}
+
+func (r *ScanRequest) hasMode(mode ScanMode) bool {
+	for _, m := range r.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// ports returns the TCP and UDP ports a job scans: the request's explicit
+// ports or profile, or else the configured profile for the scan mode
+func (m *Manager) ports(req ScanRequest, mode ScanMode) ([]int, []int) {
+	if len(req.Ports) > 0 || len(req.UDPPorts) > 0 {
+		return req.Ports, req.UDPPorts
+	}
+
+	spec := req.Profile
+	if spec == "" {
+		spec = m.opts.PortProfile
+		if mode == ModePublic {
+			spec = m.opts.PublicProfile
+		}
+	}
+	profile, err := network.ParsePortProfile(spec)
+	if err != nil {
+		profile = network.DefaultPortProfile()
+	}
+	return profile.TCP, profile.UDP
+}
+
+// Start validates the request and runs it as a new background job. The job
+// waits in the queue while MaxRunning jobs are running, and is refused with
+// ErrBusy when the queue is full too.
+func (m *Manager) Start(req ScanRequest) (*Job, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		ID:        id,
+		Status:    StatusQueued,
+		Request:   req,
+		CreatedAt: time.Now(),
+		Assets:    []network.Asset{},
+	}
+
+	m.mu.Lock()
+	m.prune(time.Now())
+	if m.opts.MaxRunning > 0 && len(m.cancels) >= m.opts.MaxRunning+m.opts.MaxQueued {
+		m.mu.Unlock()
+		return nil, ErrBusy
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.jobs[id] = job
+	m.cancels[id] = cancel
+	snapshot := *job
+	m.mu.Unlock()
+
+	go m.run(ctx, id)
+
+	return &snapshot, nil
+}
+
+// Get returns a snapshot of a job. Finished jobs past their retention are
+// gone.
+func (m *Manager) Get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune(time.Now())
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *job
+	return &snapshot, true
+}
+
+// Cancel stops a queued or running job. Cancelling a finished job is a no-op.
+func (m *Manager) Cancel(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+	}
+	snapshot := *job
+	return &snapshot, true
+}
+
+// prune drops the finished jobs older than the retention, then the oldest
+// finished jobs beyond MaxFinished. It is called with the manager lock held.
+func (m *Manager) prune(now time.Time) {
+	var finished []*Job
+	for id, job := range m.jobs {
+		if job.CompletedAt == nil {
+			continue
+		}
+		if m.opts.Retention > 0 && now.Sub(*job.CompletedAt) > m.opts.Retention {
+			delete(m.jobs, id)
+			continue
+		}
+		finished = append(finished, job)
+	}
+
+	if m.opts.MaxFinished <= 0 || len(finished) <= m.opts.MaxFinished {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].CompletedAt.Before(*finished[j].CompletedAt) })
+	for _, job := range finished[:len(finished)-m.opts.MaxFinished] {
+		delete(m.jobs, job.ID)
+	}
+}
+
+// update applies fn to a job under the manager lock
+func (m *Manager) update(id string, fn func(job *Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job, ok := m.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+// run executes a job, one CIDR and mode at a time, checking for
+// cancellation between each step. A cancelled step, or one that runs out of
+// time, still contributes what it found. A job cancelled while it waits for
+// a slot to run in finishes without starting.
+func (m *Manager) run(ctx context.Context, id string) {
+	defer func() {
+		m.mu.Lock()
+		if cancel, ok := m.cancels[id]; ok {
+			cancel()
+			delete(m.cancels, id)
+		}
+		m.prune(time.Now())
+		m.mu.Unlock()
+	}()
+
+	if m.slots != nil {
+		select {
+		case m.slots <- struct{}{}:
+			defer func() { <-m.slots }()
+		case <-ctx.Done():
+			done := time.Now()
+			m.update(id, func(job *Job) {
+				job.Status = StatusCancelled
+				job.CompletedAt = &done
+			})
+			return
+		}
+	}
+
+	job, _ := m.Get(id)
+	req := job.Request
+
+	steps := m.plan(req)
+	now := time.Now()
+	m.update(id, func(job *Job) {
+		job.Status = StatusRunning
+		job.StartedAt = &now
+		job.Progress.Total = len(steps)
+	})
+
+	var limit *network.RateLimiter
+	if m.opts.Timing != nil {
+		limit = network.NewRateLimiter(*m.opts.Timing)
+	}
+
+	var assets []network.Asset
+	var interrupted []string
+	var runErr error
+	for i, s := range steps {
+		if ctx.Err() != nil {
+			break
+		}
+		m.update(id, func(job *Job) {
+			job.Progress.Phase = fmt.Sprintf("%s %s", s.mode, s.cidr)
+		})
+
+		found, err := m.step(ctx, req, s, limit)
+		assets = append(assets, found...)
+		if network.Interrupted(err) {
+			if ctx.Err() != nil {
+				break
+			}
+			interrupted = append(interrupted, fmt.Sprintf("%s %s: %s", s.mode, s.cidr, network.InterruptReason(err)))
+		} else if err != nil {
+			runErr = err
+			break
+		}
+
+		m.update(id, func(job *Job) {
+			job.Progress.Completed = i + 1
+		})
+	}
+
+	assets = network.DeduplicateAssets(assets)
+	done := time.Now()
+	m.update(id, func(job *Job) {
+		job.Assets = assets
+		job.AssetsCount = len(assets)
+		job.CompletedAt = &done
+		job.Progress.Phase = ""
+		job.Interrupted = interrupted
+		switch {
+		case runErr != nil:
+			job.Status = StatusFailed
+			job.Error = runErr.Error()
+		case ctx.Err() != nil:
+			job.Status = StatusCancelled
+		case len(interrupted) > 0:
+			job.Status = StatusPartial
+		default:
+			job.Status = StatusCompleted
+		}
+	})
+
+	log.Printf("Scan job %s finished: %d assets", id, len(assets))
+}
+
+// step is one unit of work in a job
+type step struct {
+	mode ScanMode
+	cidr string
+}
+
+// plan lists the steps of a job. Port scanning is folded into the ARP and
+// public steps when either is requested, and only runs on its own otherwise.
+func (m *Manager) plan(req ScanRequest) []step {
+	var modes []ScanMode
+	if req.hasMode(ModeARP) {
+		modes = append(modes, ModeARP)
+	}
+	if req.hasMode(ModePublic) {
+		modes = append(modes, ModePublic)
+	}
+	if len(modes) == 0 && req.hasMode(ModePort) {
+		modes = append(modes, ModePort)
+	}
+
+	var steps []step
+	for _, cidr := range req.CIDRs {
+		for _, mode := range modes {
+			steps = append(steps, step{mode: mode, cidr: cidr})
+		}
+	}
+	return steps
+}
+
+// exclusions returns the addresses a job skips: those configured and those
+// the request lists
+func (m *Manager) exclusions(req ScanRequest) []string {
+	return append(append([]string{}, m.opts.Exclude...), req.Exclude...)
+}
+
+// runStep scans a single CIDR in a single mode, within the deadline for
+// the mode, paced by the job's rate limiter
+func (m *Manager) runStep(ctx context.Context, req ScanRequest, s step, limit *network.RateLimiter) ([]network.Asset, error) {
+	scanPorts := req.hasMode(ModePort)
+
+	ctx, cancel := network.WithDeadline(ctx, m.deadline(s.mode))
+	defer cancel()
+
+	switch s.mode {
+	case ModeARP:
+		discovery, err := network.NewAssetDiscovery(m.opts.Interface, m.opts.ARPTimeout, m.opts.PortTimeout, m.opts.Workers, m.opts.RateLimit)
+		if err != nil {
+			return nil, err
+		}
+		defer discovery.Close()
+		discovery.SetTCPScanMode(m.opts.PortScanMode)
+		discovery.SetServiceDetection(m.opts.ServiceProbes)
+		discovery.SetTLSInspector(m.opts.TLS)
+		discovery.SetHTTPEnumerator(m.opts.HTTP)
+		discovery.SetScreenshotter(m.opts.Screenshots)
+		discovery.SetCredentialAuditor(m.opts.Credentials)
+		discovery.SetRateLimiter(limit)
+		discovery.SetRandomOrder(m.opts.RandomOrder)
+		if err := discovery.SetExclusions(m.exclusions(req)); err != nil {
+			return nil, err
+		}
+		discovery.SetPorts(m.ports(req, ModeARP))
+
+		return discovery.DiscoverAssets(ctx, s.cidr, scanPorts)
+
+	case ModePublic:
+		targets, err := network.ParseAddressSet([]string{s.cidr}, m.exclusions(req))
+		if err != nil {
+			return nil, err
+		}
+
+		var tcpPorts, udpPorts []int
+		if scanPorts {
+			tcpPorts, udpPorts = m.ports(req, ModePublic)
+		}
+
+		scanner := network.NewPublicAssetScanner(m.opts.PublicTimeout, m.opts.PublicWorkers, 2)
+		defer scanner.Close()
+		scanner.SetPingEnabled(m.opts.PingEnabled)
+		scanner.SetPingCount(m.opts.PingCount)
+		scanner.SetServiceDetection(m.opts.PublicServiceProbes)
+		scanner.SetTLSInspector(m.opts.TLS)
+		scanner.SetHTTPEnumerator(m.opts.HTTP)
+		scanner.SetScreenshotter(m.opts.Screenshots)
+		scanner.SetCredentialAuditor(m.opts.Credentials)
+		scanner.SetRateLimiter(limit)
+		scanner.SetRandomOrder(m.opts.RandomOrder)
+
+		publicAssets, err := scanner.ScanPublicAssets(ctx, targets, tcpPorts, udpPorts)
+		if err != nil && !network.Interrupted(err) {
+			return nil, err
+		}
+		var assets []network.Asset
+		for _, publicAsset := range publicAssets {
+			assets = append(assets, publicAsset.ToAsset())
+		}
+		return assets, err
+
+	case ModePort:
+		return m.scanPortsOnly(ctx, req, s.cidr, limit)
+	}
+
+	return nil, fmt.Errorf("unknown scan mode %q", s.mode)
+}
+
+// deadline returns how long a step in a mode may run
+func (m *Manager) deadline(mode ScanMode) time.Duration {
+	switch mode {
+	case ModeARP:
+		return m.opts.ARPDeadline
+	case ModePublic:
+		return m.opts.PublicDeadline
+	}
+	return m.opts.PortDeadline
+}
+
+// scanPortsOnly port scans every address of a CIDR, less the exclusions,
+// and reports the hosts that have at least one open port. The addresses
+// are produced as they are scanned, so a prefix of any size may be given.
+// When ctx is done the hosts found so far are returned with ctx's error.
+func (m *Manager) scanPortsOnly(ctx context.Context, req ScanRequest, cidr string, limit *network.RateLimiter) ([]network.Asset, error) {
+	targets, err := network.ParseAddressSet([]string{cidr}, m.exclusions(req))
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := network.NewPortScanner(m.opts.PortTimeout, m.opts.Workers, 2)
+	defer scanner.Close()
+	scanner.SetTCPScanMode(m.opts.PortScanMode)
+	scanner.SetServiceDetection(m.opts.ServiceProbes)
+	scanner.SetTLSInspector(m.opts.TLS)
+	scanner.SetHTTPEnumerator(m.opts.HTTP)
+	scanner.SetScreenshotter(m.opts.Screenshots)
+	scanner.SetCredentialAuditor(m.opts.Credentials)
+	scanner.SetRateLimiter(limit)
+	tcpPorts, udpPorts := m.ports(req, ModePort)
+
+	var assets []network.Asset
+	for addr := range targets.All(m.opts.RandomOrder) {
+		if ctx.Err() != nil {
+			break
+		}
+		ip := addr.String()
+
+		results, err := scanner.ScanHostPorts(ctx, ip, tcpPorts, udpPorts)
+		if err != nil && !network.Interrupted(err) {
+			continue
+		}
+
+		asset := network.Asset{IP: ip}
+		for _, result := range results {
+			if result.State == network.PortOpen {
+				asset.OpenPorts = append(asset.OpenPorts, result)
+			}
+		}
+		if len(asset.OpenPorts) > 0 {
+			now := time.Now()
+			asset.FirstSeen, asset.LastSeen = now, now
+			assets = append(assets, asset)
+		}
+	}
+
+	return assets, ctx.Err()
+}
+
+// newJobID returns a random job identifier
+func newJobID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
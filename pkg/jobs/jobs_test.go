@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"assetmanager/pkg/network"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     ScanRequest
+		cidrs   []string
+		wantErr bool
+	}{
+		{name: "bare addresses", req: ScanRequest{CIDRs: []string{"10.0.0.1", " 2001:db8::1 "}}, cidrs: []string{"10.0.0.1/32", "2001:db8::1/128"}},
+		{name: "range", req: ScanRequest{CIDRs: []string{"10.0.0.5-10.0.0.80"}}, cidrs: []string{"10.0.0.5-10.0.0.80"}},
+		{name: "no targets", req: ScanRequest{}, wantErr: true},
+		{name: "bad target", req: ScanRequest{CIDRs: []string{"10.0.0.0/33"}}, wantErr: true},
+		{name: "bad exclusion", req: ScanRequest{CIDRs: []string{"10.0.0.0/24"}, Exclude: []string{"nope"}}, wantErr: true},
+		{name: "bad port", req: ScanRequest{CIDRs: []string{"10.0.0.1"}, Ports: []int{0}}, wantErr: true},
+		{name: "profile and ports", req: ScanRequest{CIDRs: []string{"10.0.0.1"}, Ports: []int{22}, Profile: "web"}, wantErr: true},
+		{name: "unknown profile", req: ScanRequest{CIDRs: []string{"10.0.0.1"}, Profile: "bogus"}, wantErr: true},
+		{name: "unknown mode", req: ScanRequest{CIDRs: []string{"10.0.0.1"}, Modes: []ScanMode{"icmp"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Validate succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			for i, cidr := range tt.cidrs {
+				if tt.req.CIDRs[i] != cidr {
+					t.Errorf("CIDRs[%d] = %q, want %q", i, tt.req.CIDRs[i], cidr)
+				}
+			}
+			if len(tt.req.Modes) != 2 || tt.req.Modes[0] != ModeARP || tt.req.Modes[1] != ModePort {
+				t.Errorf("default modes = %v, want [arp port]", tt.req.Modes)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	m := NewManager(Options{})
+	steps := m.plan(ScanRequest{CIDRs: []string{"10.0.0.0/24", "10.0.1.0/24"}, Modes: []ScanMode{ModePort, ModeARP}})
+	if len(steps) != 2 || steps[0].mode != ModeARP || steps[1].cidr != "10.0.1.0/24" {
+		t.Errorf("plan folds port scanning into arp: got %v", steps)
+	}
+
+	steps = m.plan(ScanRequest{CIDRs: []string{"10.0.0.0/24"}, Modes: []ScanMode{ModePort}})
+	if len(steps) != 1 || steps[0].mode != ModePort {
+		t.Errorf("port-only plan = %v", steps)
+	}
+}
+
+// blockingManager returns a manager whose steps block until their job is
+// cancelled or release is closed
+func blockingManager(opts Options, release <-chan struct{}) *Manager {
+	m := NewManager(opts)
+	m.step = func(ctx context.Context, req ScanRequest, s step, limit *network.RateLimiter) ([]network.Asset, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-release:
+			return []network.Asset{{IP: "10.0.0.1"}}, nil
+		}
+	}
+	return m
+}
+
+// waitFor polls a job until it reaches the status
+func waitFor(t *testing.T, m *Manager, id string, status Status) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, ok := m.Get(id); ok && job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _ := m.Get(id)
+	t.Fatalf("job %s did not reach %s: %+v", id, status, job)
+	return nil
+}
+
+func TestManagerLimits(t *testing.T) {
+	release := make(chan struct{})
+	m := blockingManager(Options{MaxRunning: 1, MaxQueued: 1}, release)
+	req := ScanRequest{CIDRs: []string{"10.0.0.1"}, Modes: []ScanMode{ModePort}}
+
+	running, err := m.Start(req)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitFor(t, m, running.ID, StatusRunning)
+
+	queued, err := m.Start(req)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, err := m.Start(req); !errors.Is(err, ErrBusy) {
+		t.Fatalf("third Start = %v, want ErrBusy", err)
+	}
+
+	// The queued job stays queued behind the running one, and is cancelled
+	// without ever running
+	time.Sleep(20 * time.Millisecond)
+	if job, _ := m.Get(queued.ID); job.Status != StatusQueued {
+		t.Fatalf("second job is %s, want queued", job.Status)
+	}
+	m.Cancel(queued.ID)
+	if job := waitFor(t, m, queued.ID, StatusCancelled); job.StartedAt != nil {
+		t.Error("job cancelled while queued has a start time")
+	}
+
+	close(release)
+	if job := waitFor(t, m, running.ID, StatusCompleted); job.AssetsCount != 1 {
+		t.Errorf("completed job has %d assets, want 1", job.AssetsCount)
+	}
+
+	if _, err := m.Start(req); err != nil {
+		t.Errorf("Start after the queue drained: %v", err)
+	}
+}
+
+func TestManagerPrune(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	m := blockingManager(Options{MaxFinished: 2}, release)
+	req := ScanRequest{CIDRs: []string{"10.0.0.1"}, Modes: []ScanMode{ModePort}}
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		job, err := m.Start(req)
+		if err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		waitFor(t, m, job.ID, StatusCompleted)
+		ids = append(ids, job.ID)
+	}
+	if _, ok := m.Get(ids[0]); ok {
+		t.Error("oldest finished job kept beyond MaxFinished")
+	}
+	if _, ok := m.Get(ids[2]); !ok {
+		t.Error("latest finished job pruned")
+	}
+
+	m.opts.Retention = time.Minute
+	m.mu.Lock()
+	old := time.Now().Add(-2 * time.Minute)
+	m.jobs[ids[1]].CompletedAt = &old
+	m.mu.Unlock()
+	if _, ok := m.Get(ids[1]); ok {
+		t.Error("finished job kept past its retention")
+	}
+	if _, ok := m.Get(ids[2]); !ok {
+		t.Error("finished job within its retention pruned")
+	}
+}
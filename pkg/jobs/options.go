@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"assetmanager/pkg/config"
+	"assetmanager/pkg/network"
+	"assetmanager/utilities"
+)
+
+// OptionsFromConfig builds the scanner settings for on-demand scans from the
+// config. Credentials is left to the caller, which shares one auditor with
+// the other scans of the process.
+func OptionsFromConfig(cfg *config.Config) Options {
+	opts := Options{
+		Interface:     cfg.Network.Interface,
+		Workers:       cfg.ARP.Workers,
+		PublicWorkers: cfg.PublicScan.Workers,
+		PingEnabled:   cfg.PublicScan.PingEnabled,
+		PingCount:     cfg.GetPingCount(),
+		PortScanMode:  network.TCPScanMode(cfg.GetPortScanMode()),
+		PortProfile:   cfg.GetPortScanProfile(),
+		PublicProfile: cfg.GetPublicScanProfile(),
+		MaxRunning:    cfg.GetMaxRunningScans(),
+		MaxQueued:     cfg.GetMaxQueuedScans(),
+		MaxFinished:   cfg.GetMaxRetainedScans(),
+		Exclude:       cfg.Network.Exclude,
+		RandomOrder:   cfg.Network.RandomOrder,
+
+		ServiceProbes:       cfg.PortScan.ServiceDetection,
+		PublicServiceProbes: cfg.PublicScan.ServiceDetection,
+		TLS:                 cfg.NewTLSInspector(),
+		HTTP:                cfg.NewHTTPEnumerator(),
+		Screenshots:         cfg.NewScreenshotter(),
+	}
+
+	if cfg.Timing.Enabled {
+		if timing, err := cfg.GetTiming(); err != nil {
+			log.Printf("Invalid timing, on-demand scans will not be paced: %v", err)
+		} else {
+			opts.Timing = &timing
+		}
+	}
+
+	if opts.Interface == "auto" {
+		if iface, err := utilities.GetMainNetworkInterface(); err == nil {
+			opts.Interface = iface.Name
+		} else {
+			log.Printf("Failed to get main network interface, ARP scans will fail: %v", err)
+		}
+	}
+
+	var err error
+	if opts.ARPTimeout, err = cfg.GetARPTimeout(); err != nil {
+		opts.ARPTimeout = 2 * time.Second
+	}
+	if opts.PortTimeout, err = cfg.GetPortScanTimeout(); err != nil {
+		opts.PortTimeout = 2 * time.Second
+	}
+	if opts.PublicTimeout, err = cfg.GetPublicScanTimeout(); err != nil {
+		opts.PublicTimeout = 5 * time.Second
+	}
+	if opts.RateLimit, err = cfg.GetARPRateLimit(); err != nil {
+		opts.RateLimit = 100 * time.Millisecond
+	}
+	if opts.ARPDeadline, err = cfg.GetARPDeadline(); err != nil {
+		opts.ARPDeadline = 15 * time.Minute
+	}
+	if opts.PortDeadline, err = cfg.GetPortScanDeadline(); err != nil {
+		opts.PortDeadline = 30 * time.Minute
+	}
+	if opts.PublicDeadline, err = cfg.GetPublicScanDeadline(); err != nil {
+		opts.PublicDeadline = time.Hour
+	}
+	if opts.Retention, err = cfg.GetScanRetention(); err != nil {
+		opts.Retention = 24 * time.Hour
+	}
+
+	return opts
+}
@@ -0,0 +1,364 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"math"
+	"math/bits"
+	"math/rand"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// AddressSet is a set of addresses built from prefixes, ranges and single
+// addresses, less exclusions. Only the ranges are stored, so a set of any
+// size takes little memory and its addresses are produced as they are
+// scanned.
+type AddressSet struct {
+	ranges []addressRange // sorted and disjoint
+	ends   []uint64       // addresses up to the end of each range, saturated
+}
+
+// addressRange runs from first to last inclusive, within one family
+type addressRange struct {
+	first, last netip.Addr
+}
+
+// ParseAddressSet builds the set of the include targets less the exclude
+// targets. A target is a single address, a prefix such as 10.0.0.0/16 or
+// 2001:db8::/120, or a range such as 10.0.0.5-10.0.0.80. The network and
+// broadcast addresses of an IPv4 prefix, and the subnet-router address of
+// an IPv6 one, are left out of the include targets but not the exclude
+// targets.
+func ParseAddressSet(include, exclude []string) (*AddressSet, error) {
+	var included, excluded []addressRange
+	for _, target := range include {
+		r, err := parseAddressRange(target, true)
+		if err != nil {
+			return nil, err
+		}
+		included = append(included, r)
+	}
+	for _, target := range exclude {
+		r, err := parseAddressRange(target, false)
+		if err != nil {
+			return nil, err
+		}
+		excluded = append(excluded, r)
+	}
+
+	set := &AddressSet{ranges: subtractRanges(mergeRanges(included), mergeRanges(excluded))}
+	var total uint64
+	for _, r := range set.ranges {
+		total = saturatingAdd(total, r.size())
+		set.ends = append(set.ends, total)
+	}
+	return set, nil
+}
+
+// parseAddressRange parses one target. host leaves out the addresses of a
+// prefix that are not hosts.
+func parseAddressRange(target string, host bool) (addressRange, error) {
+	target = strings.TrimSpace(target)
+
+	if strings.Contains(target, "/") {
+		prefix, err := netip.ParsePrefix(target)
+		if err != nil {
+			return addressRange{}, fmt.Errorf("invalid target %q: %w", target, err)
+		}
+		prefix = prefix.Masked()
+		first := prefix.Addr().Unmap()
+		hostBits := prefix.Addr().BitLen() - prefix.Bits()
+		last := lastAddress(first, hostBits)
+		if host && first.Is4() && hostBits >= 2 {
+			first, last = first.Next(), last.Prev()
+		} else if host && first.Is6() && hostBits >= 2 {
+			first = first.Next()
+		}
+		return addressRange{first: first, last: last}, nil
+	}
+
+	if from, to, ok := strings.Cut(target, "-"); ok {
+		first, err := netip.ParseAddr(strings.TrimSpace(from))
+		if err != nil {
+			return addressRange{}, fmt.Errorf("invalid target %q: %w", target, err)
+		}
+		last, err := netip.ParseAddr(strings.TrimSpace(to))
+		if err != nil {
+			return addressRange{}, fmt.Errorf("invalid target %q: %w", target, err)
+		}
+		first, last = first.Unmap(), last.Unmap()
+		if first.Zone() != "" || last.Zone() != "" {
+			return addressRange{}, fmt.Errorf("invalid target %q: zones are not supported", target)
+		}
+		if first.Is4() != last.Is4() {
+			return addressRange{}, fmt.Errorf("invalid target %q: range ends differ in family", target)
+		}
+		if last.Less(first) {
+			return addressRange{}, fmt.Errorf("invalid target %q: range ends before it starts", target)
+		}
+		return addressRange{first: first, last: last}, nil
+	}
+
+	addr, err := netip.ParseAddr(target)
+	if err != nil {
+		return addressRange{}, fmt.Errorf("invalid target %q: %w", target, err)
+	}
+	if addr.Zone() != "" {
+		return addressRange{}, fmt.Errorf("invalid target %q: zones are not supported", target)
+	}
+	addr = addr.Unmap()
+	return addressRange{first: addr, last: addr}, nil
+}
+
+// lastAddress returns the last address of the prefix starting at first
+// with hostBits host bits
+func lastAddress(first netip.Addr, hostBits int) netip.Addr {
+	if first.Is4() {
+		a := first.As4()
+		for i := 3; i >= 0 && hostBits > 0; i-- {
+			n := min(hostBits, 8)
+			a[i] |= byte(1<<n - 1)
+			hostBits -= n
+		}
+		return netip.AddrFrom4(a)
+	}
+	a := first.As16()
+	for i := 15; i >= 0 && hostBits > 0; i-- {
+		n := min(hostBits, 8)
+		a[i] |= byte(1<<n - 1)
+		hostBits -= n
+	}
+	return netip.AddrFrom16(a)
+}
+
+// mergeRanges sorts ranges and joins those that overlap or touch
+func mergeRanges(ranges []addressRange) []addressRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].first.Less(ranges[j].first) })
+
+	var merged []addressRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			prev := &merged[n-1]
+			if next := prev.last.Next(); r.first.Is4() == prev.last.Is4() && (!next.IsValid() || !next.Less(r.first)) {
+				if prev.last.Less(r.last) {
+					prev.last = r.last
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// subtractRanges removes the excluded ranges from the included ones. Both
+// are sorted and disjoint, and so is the result.
+func subtractRanges(included, excluded []addressRange) []addressRange {
+	var result []addressRange
+	for _, r := range included {
+		for _, x := range excluded {
+			if x.last.Less(r.first) || r.last.Less(x.first) {
+				continue
+			}
+			if r.first.Less(x.first) {
+				result = append(result, addressRange{first: r.first, last: x.first.Prev()})
+			}
+			if !x.last.Less(r.last) {
+				r.first = netip.Addr{}
+				break
+			}
+			r.first = x.last.Next()
+		}
+		if r.first.IsValid() {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// size returns the number of addresses in a range, saturated
+func (r addressRange) size() uint64 {
+	hi, lo := addrDiff(r.last, r.first)
+	if hi > 0 || lo == math.MaxUint64 {
+		return math.MaxUint64
+	}
+	return lo + 1
+}
+
+// addrDiff returns a - b as a 128-bit number
+func addrDiff(a, b netip.Addr) (hi, lo uint64) {
+	ahi, alo := addrUint128(a)
+	bhi, blo := addrUint128(b)
+	lo, borrow := bits.Sub64(alo, blo, 0)
+	hi, _ = bits.Sub64(ahi, bhi, borrow)
+	return hi, lo
+}
+
+// addrUint128 returns an address as a 128-bit number
+func addrUint128(a netip.Addr) (hi, lo uint64) {
+	b := a.As16()
+	for i := 0; i < 8; i++ {
+		hi = hi<<8 | uint64(b[i])
+		lo = lo<<8 | uint64(b[i+8])
+	}
+	return hi, lo
+}
+
+// addrAdd returns the address n after a
+func addrAdd(a netip.Addr, n uint64) netip.Addr {
+	hi, lo := addrUint128(a)
+	lo, carry := bits.Add64(lo, n, 0)
+	hi += carry
+	var b [16]byte
+	for i := 7; i >= 0; i-- {
+		b[i], b[i+8] = byte(hi), byte(lo)
+		hi >>= 8
+		lo >>= 8
+	}
+	addr := netip.AddrFrom16(b)
+	if a.Is4() {
+		return addr.Unmap()
+	}
+	return addr
+}
+
+func saturatingAdd(a, b uint64) uint64 {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 {
+		return math.MaxUint64
+	}
+	return sum
+}
+
+// Size returns the number of addresses in the set. Sets too large to count
+// report math.MaxUint64.
+func (s *AddressSet) Size() uint64 {
+	if len(s.ends) == 0 {
+		return 0
+	}
+	return s.ends[len(s.ends)-1]
+}
+
+// Contains reports whether an address is in the set
+func (s *AddressSet) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	i := sort.Search(len(s.ranges), func(i int) bool { return !s.ranges[i].last.Less(addr) })
+	return i < len(s.ranges) && !addr.Less(s.ranges[i].first)
+}
+
+// IPv4Only reports whether every address of the set is an IPv4 address
+func (s *AddressSet) IPv4Only() bool {
+	for _, r := range s.ranges {
+		if !r.first.Is4() {
+			return false
+		}
+	}
+	return true
+}
+
+// All returns the addresses of the set, in address order or shuffled. The
+// shuffled order is a random permutation computed as it goes, so it takes
+// no more memory than the sorted one. Sets too large to count are walked
+// in order.
+func (s *AddressSet) All(random bool) iter.Seq[netip.Addr] {
+	return func(yield func(netip.Addr) bool) {
+		if size := s.Size(); random && size > 1 && size < math.MaxUint64 {
+			perm := newPermutation(size)
+			for i := uint64(0); i < size; i++ {
+				if !yield(s.at(perm.at(i))) {
+					return
+				}
+			}
+			return
+		}
+
+		for _, r := range s.ranges {
+			for addr := r.first; ; addr = addr.Next() {
+				if !yield(addr) {
+					return
+				}
+				if addr == r.last {
+					break
+				}
+			}
+		}
+	}
+}
+
+// at returns the address at an index of the set
+func (s *AddressSet) at(i uint64) netip.Addr {
+	n := sort.Search(len(s.ends), func(n int) bool { return s.ends[n] > i })
+	var start uint64
+	if n > 0 {
+		start = s.ends[n-1]
+	}
+	return addrAdd(s.ranges[n].first, i-start)
+}
+
+// Stream sends the addresses of the set on a channel, in address order or
+// shuffled, for workers to take from. The channel is closed once every
+// address is sent or ctx is done.
+func (s *AddressSet) Stream(ctx context.Context, random bool) <-chan string {
+	addrs := make(chan string)
+	go func() {
+		defer close(addrs)
+		for addr := range s.All(random) {
+			select {
+			case addrs <- addr.String():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return addrs
+}
+
+// permutation is a random permutation of [0, n), a Feistel network over
+// the next power of four at or above n, walked until it lands below n
+type permutation struct {
+	n    uint64
+	half uint
+	mask uint64
+	keys [4]uint64
+}
+
+func newPermutation(n uint64) permutation {
+	half := uint(max((bits.Len64(n-1)+1)/2, 1))
+	p := permutation{n: n, half: half, mask: 1<<half - 1}
+	for i := range p.keys {
+		p.keys[i] = rand.Uint64()
+	}
+	return p
+}
+
+// at returns the i'th element of the permutation
+func (p permutation) at(i uint64) uint64 {
+	for {
+		i = p.encrypt(i)
+		if i < p.n {
+			return i
+		}
+	}
+}
+
+// encrypt permutes the values of 2*half bits
+func (p permutation) encrypt(x uint64) uint64 {
+	l, r := x>>p.half, x&p.mask
+	for _, key := range p.keys {
+		l, r = r, l^(mix64(r^key)&p.mask)
+	}
+	return l<<p.half | r
+}
+
+// mix64 is the splitmix64 finalizer
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,178 @@
+package network
+
+import (
+	"context"
+	"math"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+// collect returns the addresses of a set as strings
+func collect(set *AddressSet, random bool) []string {
+	var addrs []string
+	for addr := range set.All(random) {
+		addrs = append(addrs, addr.String())
+	}
+	return addrs
+}
+
+func TestParseAddressSet(t *testing.T) {
+	tests := []struct {
+		include, exclude []string
+		want             []string
+	}{
+		{[]string{"10.0.0.0/30"}, nil, []string{"10.0.0.1", "10.0.0.2"}},
+		{[]string{"10.0.0.0/31"}, nil, []string{"10.0.0.0", "10.0.0.1"}},
+		{[]string{" 10.0.0.7/32 "}, nil, []string{"10.0.0.7"}},
+		{[]string{"10.0.0.9-10.0.0.11", "10.0.0.5"}, nil, []string{"10.0.0.5", "10.0.0.9", "10.0.0.10", "10.0.0.11"}},
+		{[]string{"10.0.0.1-10.0.0.4", "10.0.0.3-10.0.0.6", "10.0.0.7"}, nil, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6", "10.0.0.7"}},
+		{[]string{"10.0.0.0/29"}, []string{"10.0.0.3", "10.0.0.5-10.0.0.9"}, []string{"10.0.0.1", "10.0.0.2", "10.0.0.4"}},
+		{[]string{"10.0.0.0/30"}, []string{"10.0.0.0/24"}, nil},
+		{[]string{"2001:db8::/126"}, nil, []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"}},
+		{[]string{"::ffff:10.0.0.1", "2001:db8::1"}, nil, []string{"10.0.0.1", "2001:db8::1"}},
+		{[]string{"255.255.255.254-255.255.255.255", "::"}, nil, []string{"255.255.255.254", "255.255.255.255", "::"}},
+	}
+	for _, tt := range tests {
+		set, err := ParseAddressSet(tt.include, tt.exclude)
+		if err != nil {
+			t.Errorf("ParseAddressSet(%v, %v): %v", tt.include, tt.exclude, err)
+			continue
+		}
+		if got := collect(set, false); !slices.Equal(got, tt.want) {
+			t.Errorf("ParseAddressSet(%v, %v) = %v, want %v", tt.include, tt.exclude, got, tt.want)
+		}
+		if set.Size() != uint64(len(tt.want)) {
+			t.Errorf("ParseAddressSet(%v, %v) has size %d, want %d", tt.include, tt.exclude, set.Size(), len(tt.want))
+		}
+	}
+
+	for _, target := range []string{"10.0.0.0/33", "10.0.0.300", "10.0.0.9-10.0.0.1", "10.0.0.1-2001:db8::1", "fe80::1%eth0", "host.example.com", "10.0.0.1-"} {
+		if _, err := ParseAddressSet([]string{target}, nil); err == nil {
+			t.Errorf("ParseAddressSet(%q) succeeded, want an error", target)
+		}
+	}
+	if _, err := ParseAddressSet([]string{"10.0.0.0/24"}, []string{"bogus"}); err == nil {
+		t.Error("an invalid exclusion was accepted")
+	}
+}
+
+func TestAddressSetSize(t *testing.T) {
+	tests := []struct {
+		targets []string
+		size    uint64
+	}{
+		{nil, 0},
+		{[]string{"10.0.0.0/8"}, 1<<24 - 2},
+		{[]string{"2001:db8::/64"}, math.MaxUint64},
+		{[]string{"::/0"}, math.MaxUint64},
+		{[]string{"2001:db8::/65", "10.0.0.0/8"}, 1<<63 - 1 + 1<<24 - 2},
+		{[]string{"2001:db8::/65", "2001:db8:1::/65", "2001:db8:2::/65"}, math.MaxUint64},
+	}
+	for _, tt := range tests {
+		set, err := ParseAddressSet(tt.targets, nil)
+		if err != nil {
+			t.Fatalf("ParseAddressSet(%v): %v", tt.targets, err)
+		}
+		if got := set.Size(); got != tt.size {
+			t.Errorf("Size of %v = %d, want %d", tt.targets, got, tt.size)
+		}
+	}
+}
+
+func TestAddressSetContains(t *testing.T) {
+	set, err := ParseAddressSet([]string{"10.0.0.0/24", "2001:db8::/120"}, []string{"10.0.0.128/25"})
+	if err != nil {
+		t.Fatalf("ParseAddressSet: %v", err)
+	}
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.0.0.1", true},
+		{"::ffff:10.0.0.127", true},
+		{"10.0.0.0", false},
+		{"10.0.0.128", false},
+		{"10.0.1.1", false},
+		{"2001:db8::ff", true},
+		{"2001:db8::1%eth0", true},
+		{"2001:db8::100", false},
+	}
+	for _, tt := range tests {
+		if got := set.Contains(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+	if set.IPv4Only() {
+		t.Error("a set with IPv6 addresses is IPv4 only")
+	}
+}
+
+func TestAddressSetRandomOrder(t *testing.T) {
+	for _, targets := range [][]string{
+		{"10.0.0.1"},
+		{"10.0.0.1-10.0.0.2"},
+		{"10.0.0.1-10.0.0.3"},
+		{"10.0.0.0/24"},
+		{"10.0.0.0/22", "10.0.8.5-10.0.8.9", "2001:db8::/121"},
+	} {
+		set, err := ParseAddressSet(targets, []string{"10.0.1.0/25"})
+		if err != nil {
+			t.Fatalf("ParseAddressSet(%v): %v", targets, err)
+		}
+		sorted := collect(set, false)
+		shuffled := collect(set, true)
+		if len(shuffled) != len(sorted) {
+			t.Errorf("%v: shuffled %d addresses, want %d", targets, len(shuffled), len(sorted))
+			continue
+		}
+
+		// Every address comes once, and only addresses of the set
+		seen := make(map[string]bool)
+		for _, addr := range shuffled {
+			if seen[addr] || !set.Contains(netip.MustParseAddr(addr)) {
+				t.Errorf("%v: %s repeated or outside the set", targets, addr)
+			}
+			seen[addr] = true
+		}
+		if len(sorted) > 100 && slices.Equal(shuffled, sorted) {
+			t.Errorf("%v: shuffled order is the sorted order", targets)
+		}
+	}
+}
+
+func TestPermutation(t *testing.T) {
+	for _, n := range []uint64{2, 3, 5, 64, 1000} {
+		perm := newPermutation(n)
+		seen := make([]bool, n)
+		for i := uint64(0); i < n; i++ {
+			v := perm.at(i)
+			if v >= n || seen[v] {
+				t.Fatalf("permutation of %d maps %d to %d, out of range or repeated", n, i, v)
+			}
+			seen[v] = true
+		}
+	}
+}
+
+func TestAddressSetStream(t *testing.T) {
+	set, err := ParseAddressSet([]string{"10.0.0.0/24"}, nil)
+	if err != nil {
+		t.Fatalf("ParseAddressSet: %v", err)
+	}
+
+	var streamed []string
+	for addr := range set.Stream(context.Background(), false) {
+		streamed = append(streamed, addr)
+	}
+	if len(streamed) != 254 || streamed[0] != "10.0.0.1" {
+		t.Errorf("streamed %d addresses starting at %v", len(streamed), streamed[:1])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	addrs := set.Stream(ctx, true)
+	<-addrs
+	cancel()
+	for range addrs {
+	}
+}
@@ -1,26 +1,40 @@
 package network
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/netip"
 	"time"
 
 	"github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
 )
 
+// arpClient is the ARP socket of a scanner, an *arp.Client outside tests
+type arpClient interface {
+	Close() error
+	SetDeadline(t time.Time) error
+	SetReadDeadline(t time.Time) error
+	Resolve(ip netip.Addr) (net.HardwareAddr, error)
+	Request(ip netip.Addr) error
+	Read() (*arp.Packet, *ethernet.Frame, error)
+}
+
 // ARPScanner represents an ARP scanner
 type ARPScanner struct {
 	iface   *net.Interface
-	client  *arp.Client
+	client  arpClient
 	timeout time.Duration
 }
 
 // ARPResult represents the result of an ARP scan
 type ARPResult struct {
-	IP     string `json:"ip"`
-	MAC    string `json:"mac"`
-	Vendor string `json:"vendor"`
+	IP                  string        `json:"ip"`
+	MAC                 string        `json:"mac"`
+	Vendor              string        `json:"vendor"`
+	LocallyAdministered bool          `json:"locally_administered,omitempty"`
+	Latency             time.Duration `json:"latency,omitempty"` // from the request answered to its reply
 }
 
 // NewARPScanner creates a new ARP scanner for the given interface
@@ -60,7 +74,7 @@ func (s *ARPScanner) ScanIP(ip string) (*ARPResult, error) {
 
 	netIP, err := netip.ParseAddr(ip)
 	if err != nil {
-		fmt.Errorf(err.Error())
+		return nil, fmt.Errorf("invalid IP address %s: %w", ip, err)
 	}
 	mac, err := s.client.Resolve(netIP)
 	if err != nil {
@@ -68,23 +82,29 @@ func (s *ARPScanner) ScanIP(ip string) (*ARPResult, error) {
 	}
 
 	result := &ARPResult{
-		IP:     ip,
-		MAC:    mac.String(),
-		Vendor: lookupVendor(mac),
+		IP:                  ip,
+		MAC:                 mac.String(),
+		Vendor:              lookupVendor(mac),
+		LocallyAdministered: IsLocallyAdministered(mac),
 	}
 
 	return result, nil
 }
 
-func (s *ARPScanner) ScanNetwork(cidr string) ([]ARPResult, error) {
-	ips, err := CIDRToIPRange(cidr)
+// ScanNetwork asks for every address of a network in turn. When ctx is done
+// the hosts found so far are returned with ctx's error.
+func (s *ARPScanner) ScanNetwork(ctx context.Context, cidr string) ([]ARPResult, error) {
+	targets, err := ParseAddressSet([]string{cidr}, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse CIDR: %w", err)
 	}
 
 	var results []ARPResult
-	for _, ip := range ips {
-		result, err := s.ScanIP(ip)
+	for addr := range targets.All(false) {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		result, err := s.ScanIP(addr.String())
 		if err == nil {
 			results = append(results, *result)
 		}
@@ -92,10 +112,3 @@ func (s *ARPScanner) ScanNetwork(cidr string) ([]ARPResult, error) {
 
 	return results, nil
 }
-
-// lookupVendor returns the vendor name for a MAC address
-func lookupVendor(mac net.HardwareAddr) string {
-	// TODO: Implement vendor lookup using an OUI database
-	// For now, return an empty string
-	return ""
-}
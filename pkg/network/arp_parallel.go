@@ -1,21 +1,56 @@
 package network
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"iter"
+	"log"
+	"net"
 	"net/netip"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/mdlayher/arp"
+)
+
+const (
+	// arpRetries is how many times an unanswered request is sent again
+	arpRetries = 2
+
+	// maxARPRate bounds the requests sent a second when no rate limit is set
+	maxARPRate = 1000
 )
 
-// ParallelARPScanner extends the ARPScanner with parallel scanning capabilities
+// ParallelARPScanner sweeps networks with ARP from the scanner's one raw
+// socket. A sender paces the requests and retransmits the unanswered ones
+// while a single receiver matches every reply to the address it answers,
+// so thousands of requests can be outstanding at once and the latency of
+// each answer is measured from the request it answers.
 type ParallelARPScanner struct {
 	*ARPScanner
-	workers    int
-	rateLimit  time.Duration // Time to wait between scans per worker
-	scanResult chan *ARPResult
+	interval time.Duration // between two requests
+	retries  int
+	limit    *RateLimiter
+	random   bool
+
+	// sweeping lets one sweep at a time read the socket
+	sweeping sync.Mutex
+}
+
+// arpProbe is an address a sweep is waiting to hear from
+type arpProbe struct {
+	addr     netip.Addr
+	sent     time.Time
+	attempts int
+	answered bool
 }
 
-// NewParallelARPScanner creates a new parallel ARP scanner
+// NewParallelARPScanner creates a new parallel ARP scanner. Requests are
+// sent workers at a time per rateLimit: 10 workers with a 50ms rate limit
+// send 200 requests a second. Without a rate limit up to maxARPRate
+// requests are sent a second.
 func NewParallelARPScanner(interfaceName string, timeout time.Duration, workers int, rateLimit time.Duration) (*ParallelARPScanner, error) {
 	baseScanner, err := NewARPScanner(interfaceName, timeout)
 	if err != nil {
@@ -27,139 +62,236 @@ func NewParallelARPScanner(interfaceName string, timeout time.Duration, workers
 		workers = 10
 	}
 
+	interval := rateLimit / time.Duration(workers)
+	if interval < time.Second/maxARPRate {
+		interval = time.Second / maxARPRate
+	}
+
 	return &ParallelARPScanner{
 		ARPScanner: baseScanner,
-		workers:    workers,
-		rateLimit:  rateLimit,
-		scanResult: make(chan *ARPResult),
+		interval:   interval,
+		retries:    arpRetries,
 	}, nil
 }
 
-// ScanNetworkParallel performs ARP scanning in parallel using multiple goroutines
-func (s *ParallelARPScanner) ScanNetworkParallel(cidr string) ([]ARPResult, error) {
-	ips, err := CIDRToIPRange(cidr)
+// SetRateLimiter sets the limiter pacing the requests along with the rest
+// of the scan, on top of the scanner's own rate. nil leaves only the
+// scanner's rate.
+func (s *ParallelARPScanner) SetRateLimiter(limit *RateLimiter) {
+	s.limit = limit
+}
+
+// SetRandomOrder sends the requests of a sweep in random order rather than
+// address order
+func (s *ParallelARPScanner) SetRandomOrder(random bool) {
+	s.random = random
+}
+
+// ScanNetworkParallel sweeps a network with ARP and returns the hosts that
+// answered, in address order. When ctx is done no further requests are
+// sent, and the hosts found so far are returned with ctx's error.
+func (s *ParallelARPScanner) ScanNetworkParallel(ctx context.Context, cidr string) ([]ARPResult, error) {
+	targets, err := ParseAddressSet([]string{cidr}, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse CIDR: %w", err)
 	}
+	results, _, err := s.sweep(ctx, targets)
+	return results, err
+}
 
-	var results []ARPResult
-	var wg sync.WaitGroup
-	ipChan := make(chan string, len(ips))
-	resultChan := make(chan ARPResult, len(ips))
-	errChan := make(chan error, 1)
-	doneChan := make(chan struct{})
-
-	// Start worker goroutines
-	for i := 0; i < s.workers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			for ip := range ipChan {
-				// Rate limiting per worker
-				if s.rateLimit > 0 {
-					time.Sleep(s.rateLimit)
-				}
+// sweep asks for every address of a set. Along with the hosts that
+// answered it returns every reply seen while it ran, so a second device
+// answering for an address is noticed. Only the requests outstanding are
+// tracked, so the size of the set does not matter.
+func (s *ParallelARPScanner) sweep(ctx context.Context, targets *AddressSet) ([]ARPResult, ARPClaims, error) {
+	if !targets.IPv4Only() {
+		return nil, nil, fmt.Errorf("ARP sweeps IPv4 addresses only")
+	}
 
-				// Create a new client for each worker to avoid race conditions
-				clientTimeout := s.timeout
-				client, err := NewARPScanner(s.ARPScanner.iface.Name, clientTimeout)
-				if err != nil {
-					select {
-					case errChan <- fmt.Errorf("worker %d failed to create ARP client: %w", workerID, err):
-					default:
-					}
+	s.sweeping.Lock()
+	defer s.sweeping.Unlock()
+
+	var mu sync.Mutex
+	probes := make(map[netip.Addr]*arpProbe)
+	found := make(map[netip.Addr]ARPResult)
+	claims := make(ARPClaims)
+
+	if err := s.client.SetReadDeadline(time.Time{}); err != nil {
+		return nil, nil, fmt.Errorf("failed to set deadline: %w", err)
+	}
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for {
+			packet, _, err := s.client.Read()
+			if err != nil {
+				// Malformed packets fail to parse; only socket errors end the receiver
+				if _, ok := err.(net.Error); ok {
 					return
 				}
+				continue
+			}
+			if packet.Operation != arp.OperationReply || !bytes.Equal(packet.TargetHardwareAddr, s.iface.HardwareAddr) {
+				continue
+			}
+			ip := packet.SenderIP
+			if !ip.Is4() || ip.IsUnspecified() {
+				continue
+			}
 
-				// Perform the scan
-				result, err := s.scanIPWithRetry(client, ip, 2) // 2 retries
-				if err == nil && result != nil {
-					resultChan <- *result
+			now := time.Now()
+			mac := packet.SenderHardwareAddr
+			mu.Lock()
+			claims.add(ip.String(), mac.String())
+			if probe, ok := probes[ip]; ok && !probe.answered {
+				probe.answered = true
+				delete(probes, ip)
+				s.limit.Observe(now.Sub(probe.sent), false)
+				found[ip] = ARPResult{
+					IP:                  ip.String(),
+					MAC:                 mac.String(),
+					Vendor:              lookupVendor(mac),
+					LocallyAdministered: IsLocallyAdministered(mac),
+					Latency:             now.Sub(probe.sent),
 				}
-
-				// Close the client
-				client.Close()
 			}
-		}(i)
-	}
-
-	// Result collector goroutine
-	go func() {
-		for result := range resultChan {
-			results = append(results, result)
+			mu.Unlock()
 		}
-		close(doneChan)
 	}()
 
-	// Send IPs to workers
-	for _, ip := range ips {
-		select {
-		case ipChan <- ip:
-		case err := <-errChan:
-			close(ipChan)
-			return nil, err
-		}
+	sendErr := s.send(ctx, targets, probes, &mu)
+
+	// Late answers to the last requests are still collected
+	if sendErr == nil {
+		sleep(ctx, arpWatchGrace)
 	}
-	close(ipChan)
+	s.client.SetReadDeadline(time.Now())
+	<-received
 
-	// Wait for all workers to finish
-	wg.Wait()
-	close(resultChan)
-	<-doneChan
+	if sendErr != nil && !Interrupted(sendErr) {
+		return nil, nil, sendErr
+	}
 
-	return results, nil
+	results := make([]ARPResult, 0, len(found))
+	for _, result := range found {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return netip.MustParseAddr(results[i].IP).Less(netip.MustParseAddr(results[j].IP))
+	})
+	return results, claims, ctx.Err()
 }
 
-// scanIPWithRetry attempts to scan an IP with retries
-func (s *ParallelARPScanner) scanIPWithRetry(client *ARPScanner, ip string, retries int) (*ARPResult, error) {
-	var lastErr error
-	for i := 0; i <= retries; i++ {
-		// Parse the IP address
-		netIP, err := netip.ParseAddr(ip)
-		if err != nil {
-			return nil, fmt.Errorf("invalid IP address %s: %w", ip, err)
+// send requests every address once, one request per interval, and sends
+// the unanswered requests again once they time out. It returns when every
+// address has answered or run out of attempts and its last request timed
+// out, or with ctx's error when ctx is done first.
+func (s *ParallelARPScanner) send(ctx context.Context, targets *AddressSet, probes map[netip.Addr]*arpProbe, mu *sync.Mutex) error {
+	answered := func(probe *arpProbe) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return probe.answered
+	}
+
+	next := time.Now()
+	request := func(probe *arpProbe) error {
+		now := time.Now()
+		if next.Before(now) {
+			next = now
+		}
+		if !sleep(ctx, next.Sub(now)) {
+			return ctx.Err()
+		}
+		next = next.Add(s.interval)
+		if !s.limit.Wait(ctx, probe.addr.String()) {
+			return ctx.Err()
 		}
 
-		// Set deadline
-		err = client.client.SetDeadline(time.Now().Add(client.timeout))
-		if err != nil {
-			lastErr = fmt.Errorf("failed to set deadline: %w", err)
-			continue
+		mu.Lock()
+		probe.sent = time.Now()
+		probe.attempts++
+		mu.Unlock()
+		if err := s.client.Request(probe.addr); err != nil {
+			return fmt.Errorf("failed to send ARP request for %s: %w", probe.addr, err)
 		}
+		return nil
+	}
 
-		// Send ARP request
-		mac, err := client.client.Resolve(netIP)
-		if err != nil {
-			lastErr = fmt.Errorf("ARP request failed for IP %s: %w", ip, err)
-			continue
+	// waiting holds the requests in the order they were sent, so the first
+	// is always the next to time out
+	var waiting []*arpProbe
+	pull, stop := iter.Pull(targets.All(s.random))
+	defer stop()
+	addr, more := pull()
+	for more || len(waiting) > 0 {
+		if len(waiting) > 0 {
+			probe := waiting[0]
+			if answered(probe) {
+				waiting = waiting[1:]
+				continue
+			}
+			due := probe.sent.Add(s.timeout)
+			if !more || !time.Now().Before(due) {
+				if !sleep(ctx, time.Until(due)) {
+					return ctx.Err()
+				}
+				waiting = waiting[1:]
+				if answered(probe) {
+					continue
+				}
+				s.limit.Observe(0, true)
+				if probe.attempts > s.retries {
+					mu.Lock()
+					delete(probes, probe.addr)
+					mu.Unlock()
+					continue
+				}
+				if err := request(probe); err != nil {
+					return err
+				}
+				waiting = append(waiting, probe)
+				continue
+			}
 		}
 
-		// Success
-		return &ARPResult{
-			IP:     ip,
-			MAC:    mac.String(),
-			Vendor: lookupVendor(mac),
-		}, nil
+		probe := &arpProbe{addr: addr}
+		addr, more = pull()
+		mu.Lock()
+		probes[probe.addr] = probe
+		mu.Unlock()
+		if err := request(probe); err != nil {
+			return err
+		}
+		waiting = append(waiting, probe)
 	}
-	return nil, lastErr
+	return nil
 }
 
-// ScanCIDRFiles scans multiple CIDR ranges from a file
-func (s *ParallelARPScanner) ScanCIDRFiles(filePath string) ([]ARPResult, error) {
-	// Read the CIDR ranges from the file
-	cidrs, err := ReadCIDRsFromFile(filePath)
+// ScanCIDRFiles sweeps the targets of a target file, less its exclusions,
+// stopping with the hosts found so far when ctx is done
+func (s *ParallelARPScanner) ScanCIDRFiles(ctx context.Context, filePath string) ([]ARPResult, error) {
+	file, err := ReadTargetsFromFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CIDR file: %w", err)
+		return nil, fmt.Errorf("failed to read target file: %w", err)
 	}
 
 	var allResults []ARPResult
-	for _, cidr := range cidrs {
-		results, err := s.ScanNetworkParallel(cidr)
+	for _, target := range file.Targets {
+		specs, err := target.Resolve(ctx)
+		if err == nil {
+			var targets *AddressSet
+			if targets, err = ParseAddressSet(specs, file.Exclude); err == nil {
+				var results []ARPResult
+				results, _, err = s.sweep(ctx, targets)
+				allResults = append(allResults, results...)
+			}
+		}
+		if Interrupted(err) {
+			return allResults, err
+		}
 		if err != nil {
-			fmt.Printf("Error scanning CIDR %s: %v\n", cidr, err)
-			continue
+			log.Printf("Error scanning %s: %v", target.Spec, err)
 		}
-		allResults = append(allResults, results...)
 	}
 
 	return allResults, nil
@@ -0,0 +1,182 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mdlayher/arp"
+	"github.com/mdlayher/ethernet"
+)
+
+// fakeARPTimeout is the error of a read past its deadline
+type fakeARPTimeout struct{}
+
+func (fakeARPTimeout) Error() string   { return "i/o timeout" }
+func (fakeARPTimeout) Timeout() bool   { return true }
+func (fakeARPTimeout) Temporary() bool { return true }
+
+// fakeARPClient answers requests for the addresses in hosts from their MAC
+// addresses. Each address in extra is also answered from a second MAC, and
+// the first request for an address in drop goes unanswered.
+type fakeARPClient struct {
+	ours  net.HardwareAddr
+	hosts map[netip.Addr]net.HardwareAddr
+	extra map[netip.Addr]net.HardwareAddr
+	drop  map[netip.Addr]bool
+
+	mu       sync.Mutex
+	requests map[netip.Addr]int
+	replies  chan *arp.Packet
+	expired  chan struct{}
+}
+
+func newFakeARPClient(ours net.HardwareAddr) *fakeARPClient {
+	return &fakeARPClient{
+		ours:     ours,
+		hosts:    make(map[netip.Addr]net.HardwareAddr),
+		extra:    make(map[netip.Addr]net.HardwareAddr),
+		drop:     make(map[netip.Addr]bool),
+		requests: make(map[netip.Addr]int),
+		replies:  make(chan *arp.Packet, 1024),
+		expired:  make(chan struct{}),
+	}
+}
+
+func (c *fakeARPClient) reply(ip netip.Addr, mac net.HardwareAddr) {
+	c.replies <- &arp.Packet{Operation: arp.OperationReply, SenderHardwareAddr: mac, SenderIP: ip, TargetHardwareAddr: c.ours}
+}
+
+func (c *fakeARPClient) Request(ip netip.Addr) error {
+	c.mu.Lock()
+	c.requests[ip]++
+	first := c.requests[ip] == 1
+	c.mu.Unlock()
+
+	if first && c.drop[ip] {
+		return nil
+	}
+	if mac, ok := c.hosts[ip]; ok {
+		c.reply(ip, mac)
+	}
+	if mac, ok := c.extra[ip]; ok {
+		c.reply(ip, mac)
+	}
+	return nil
+}
+
+func (c *fakeARPClient) Read() (*arp.Packet, *ethernet.Frame, error) {
+	c.mu.Lock()
+	expired := c.expired
+	c.mu.Unlock()
+	select {
+	case packet := <-c.replies:
+		return packet, nil, nil
+	case <-expired:
+		return nil, nil, fakeARPTimeout{}
+	}
+}
+
+func (c *fakeARPClient) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.expired:
+		if t.IsZero() {
+			c.expired = make(chan struct{})
+		}
+	default:
+		if !t.IsZero() && !t.After(time.Now()) {
+			close(c.expired)
+		}
+	}
+	return nil
+}
+
+func (c *fakeARPClient) SetDeadline(t time.Time) error { return c.SetReadDeadline(t) }
+func (c *fakeARPClient) Close() error                  { return nil }
+
+func (c *fakeARPClient) Resolve(ip netip.Addr) (net.HardwareAddr, error) {
+	if mac, ok := c.hosts[ip]; ok {
+		return mac, nil
+	}
+	return nil, fakeARPTimeout{}
+}
+
+// newFakeARPScanner sweeps through a fake client with short timeouts
+func newFakeARPScanner() (*ParallelARPScanner, *fakeARPClient) {
+	ours, _ := net.ParseMAC("02:00:00:00:00:ff")
+	client := newFakeARPClient(ours)
+	scanner := &ParallelARPScanner{
+		ARPScanner: &ARPScanner{iface: &net.Interface{Name: "test0", HardwareAddr: ours}, client: client, timeout: 20 * time.Millisecond},
+		interval:   time.Millisecond,
+		retries:    arpRetries,
+	}
+	return scanner, client
+}
+
+func TestARPSweep(t *testing.T) {
+	scanner, client := newFakeARPScanner()
+	first, _ := net.ParseMAC("aa:bb:cc:00:00:01")
+	second, _ := net.ParseMAC("02:bb:cc:00:00:02")
+	spoofer, _ := net.ParseMAC("aa:bb:cc:00:00:66")
+	client.hosts[netip.MustParseAddr("10.0.0.9")] = second
+	client.hosts[netip.MustParseAddr("10.0.0.2")] = first
+	client.extra[netip.MustParseAddr("10.0.0.2")] = spoofer
+	client.drop[netip.MustParseAddr("10.0.0.9")] = true
+
+	targets, err := ParseAddressSet([]string{"10.0.0.0/28"}, []string{"10.0.0.15"})
+	if err != nil {
+		t.Fatalf("ParseAddressSet: %v", err)
+	}
+	results, claims, err := scanner.sweep(context.Background(), targets)
+	if err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+
+	if len(results) != 2 || results[0].IP != "10.0.0.2" || results[1].IP != "10.0.0.9" {
+		t.Fatalf("results = %+v, want 10.0.0.2 and 10.0.0.9 in address order", results)
+	}
+	if results[0].MAC != first.String() || !results[1].LocallyAdministered {
+		t.Errorf("results = %+v, want the first answer's MAC kept", results)
+	}
+	if got := claims["10.0.0.2"]; len(got) != 2 {
+		t.Errorf("claims for 10.0.0.2 = %v, want both MAC addresses", got)
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if n := client.requests[netip.MustParseAddr("10.0.0.9")]; n != 2 {
+		t.Errorf("10.0.0.9 requested %d times, want once more after the first went unanswered", n)
+	}
+	if n := client.requests[netip.MustParseAddr("10.0.0.3")]; n != 1+arpRetries {
+		t.Errorf("silent address requested %d times, want %d", n, 1+arpRetries)
+	}
+	if n := client.requests[netip.MustParseAddr("10.0.0.15")]; n != 0 {
+		t.Errorf("excluded address requested %d times", n)
+	}
+}
+
+func TestARPSweepCancelled(t *testing.T) {
+	scanner, client := newFakeARPScanner()
+	client.hosts[netip.MustParseAddr("10.0.0.1")], _ = net.ParseMAC("aa:bb:cc:00:00:01")
+	scanner.interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	results, err := scanner.ScanNetworkParallel(ctx, "10.0.0.0/16")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("sweep ended with %v, want its deadline exceeded", err)
+	}
+	if len(results) != 1 || results[0].IP != "10.0.0.1" {
+		t.Errorf("results = %+v, want the host found before the deadline", results)
+	}
+
+	if _, err := scanner.ScanNetworkParallel(context.Background(), "2001:db8::/120"); err == nil {
+		t.Error("ARP sweep of an IPv6 prefix succeeded")
+	}
+}
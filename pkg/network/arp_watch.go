@@ -0,0 +1,273 @@
+package network
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// arpWatchGrace is how long replies are still collected after a sweep
+const arpWatchGrace = 250 * time.Millisecond
+
+// Types of the findings reported by ARP watching
+const (
+	FindingIPConflict        = "ip_conflict"
+	FindingMACClaimsManyIPs  = "mac_claims_many_ips"
+	FindingIPMACChanged      = "ip_mac_changed"
+	FindingGatewayMACChanged = "gateway_mac_changed"
+)
+
+// NetworkFinding is a finding about the local network rather than one
+// service: the addresses and MAC addresses it concerns are recorded with it
+type NetworkFinding struct {
+	Finding
+	IPs  []string `json:"ips"`
+	MACs []string `json:"macs"`
+}
+
+// ARPClaims records which MAC addresses answered for each IP address during
+// a sweep
+type ARPClaims map[string][]string
+
+// add records a MAC address answering for an IP address
+func (c ARPClaims) add(ip, mac string) {
+	for _, known := range c[ip] {
+		if known == mac {
+			return
+		}
+	}
+	c[ip] = append(c[ip], mac)
+}
+
+// Merge adds the answers recorded in other
+func (c ARPClaims) Merge(other ARPClaims) {
+	for ip, macs := range other {
+		for _, mac := range macs {
+			c.add(ip, mac)
+		}
+	}
+}
+
+// ARPWatcher looks for signs of ARP spoofing and address conflicts in the
+// answers to a sweep, comparing them with where each address was before.
+// Routers may answer for many addresses (proxy ARP) and are not reported
+// for it.
+type ARPWatcher struct {
+	routers      map[string]bool
+	maxIPsPerMAC int
+	gateway      string
+}
+
+// NewARPWatcher creates a watcher. routers lists the MAC or IP addresses of
+// routers; a router given by IP is recognised by the MAC address it had
+// before the sweep, so a spoofer taking its address is still reported. A
+// MAC address answering for more than maxIPsPerMAC addresses is reported.
+func NewARPWatcher(routers []string, maxIPsPerMAC int) *ARPWatcher {
+	if maxIPsPerMAC <= 0 {
+		maxIPsPerMAC = 3
+	}
+	w := &ARPWatcher{routers: make(map[string]bool), maxIPsPerMAC: maxIPsPerMAC}
+	for _, router := range routers {
+		w.routers[normalizeRouter(router)] = true
+	}
+	return w
+}
+
+// SetGateway sets the default gateway, whose MAC address changing is
+// reported with high severity. It is also treated as a router.
+func (w *ARPWatcher) SetGateway(ip string) {
+	w.gateway = ip
+}
+
+// normalizeRouter puts a router's MAC address in canonical form
+func normalizeRouter(router string) string {
+	router = strings.TrimSpace(router)
+	if mac, err := net.ParseMAC(router); err == nil {
+		return mac.String()
+	}
+	return router
+}
+
+// Check reports the findings in the answers to a sweep. previous is the
+// inventory before the sweep; the online hosts with a MAC address in it
+// give the MAC address each address was last seen with.
+func (w *ARPWatcher) Check(claims ARPClaims, previous []Asset) []NetworkFinding {
+	owners := previousOwners(previous)
+
+	routerMACs := make(map[string]bool)
+	for router := range w.routers {
+		if mac, ok := owners[router]; ok {
+			routerMACs[mac] = true
+		} else {
+			routerMACs[router] = true
+		}
+	}
+	if mac, ok := owners[w.gateway]; ok {
+		routerMACs[mac] = true
+	}
+
+	ips := make([]string, 0, len(claims))
+	for ip := range claims {
+		ips = append(ips, ip)
+	}
+	sortAddresses(ips)
+
+	var findings []NetworkFinding
+	claimedBy := make(map[string][]string)
+	for _, ip := range ips {
+		macs := append([]string(nil), claims[ip]...)
+		sort.Strings(macs)
+		for _, mac := range macs {
+			claimedBy[mac] = append(claimedBy[mac], ip)
+		}
+
+		if len(macs) > 1 {
+			findings = append(findings, NetworkFinding{
+				Finding: Finding{
+					Type:     FindingIPConflict,
+					Severity: SeverityHigh,
+					Detail:   fmt.Sprintf("%d MAC addresses answered for %s in one sweep: %s", len(macs), ip, strings.Join(macs, ", ")),
+				},
+				IPs:  []string{ip},
+				MACs: macs,
+			})
+			continue
+		}
+
+		before, ok := owners[ip]
+		if !ok || before == macs[0] {
+			continue
+		}
+		if ip == w.gateway {
+			findings = append(findings, NetworkFinding{
+				Finding: Finding{
+					Type:     FindingGatewayMACChanged,
+					Severity: SeverityHigh,
+					Detail:   fmt.Sprintf("The default gateway %s answered from %s instead of %s", ip, macs[0], before),
+				},
+				IPs:  []string{ip},
+				MACs: []string{before, macs[0]},
+			})
+			continue
+		}
+		// A device that answered from a new address has been given a new
+		// lease; its old address being reused is expected
+		if movedTo(claims, before, ip) {
+			continue
+		}
+		findings = append(findings, NetworkFinding{
+			Finding: Finding{
+				Type:     FindingIPMACChanged,
+				Severity: SeverityMedium,
+				Detail:   fmt.Sprintf("%s answered from %s instead of %s", ip, macs[0], before),
+			},
+			IPs:  []string{ip},
+			MACs: []string{before, macs[0]},
+		})
+	}
+
+	macs := make([]string, 0, len(claimedBy))
+	for mac := range claimedBy {
+		macs = append(macs, mac)
+	}
+	sort.Strings(macs)
+	for _, mac := range macs {
+		claimed := claimedBy[mac]
+		if len(claimed) <= w.maxIPsPerMAC || routerMACs[mac] {
+			continue
+		}
+		findings = append(findings, NetworkFinding{
+			Finding: Finding{
+				Type:     FindingMACClaimsManyIPs,
+				Severity: SeverityHigh,
+				Detail:   fmt.Sprintf("%s answered for %d addresses: %s", mac, len(claimed), strings.Join(claimed, ", ")),
+			},
+			IPs:  claimed,
+			MACs: []string{mac},
+		})
+	}
+
+	return findings
+}
+
+// previousOwners maps each IPv4 address in an inventory to the MAC address
+// of the online host at it, the most recently seen one winning
+func previousOwners(assets []Asset) map[string]string {
+	owners := make(map[string]string)
+	seen := make(map[string]Asset)
+	for _, asset := range assets {
+		if !asset.Online || asset.MAC == "" || net.ParseIP(asset.IP).To4() == nil {
+			continue
+		}
+		if last, ok := seen[asset.IP]; ok && !asset.LastSeen.After(last.LastSeen) {
+			continue
+		}
+		seen[asset.IP] = asset
+		owners[asset.IP] = asset.AssetID()
+	}
+	return owners
+}
+
+// movedTo reports whether mac answered for an address other than ip
+func movedTo(claims ARPClaims, mac, ip string) bool {
+	for other, macs := range claims {
+		if other == ip {
+			continue
+		}
+		for _, claimed := range macs {
+			if claimed == mac {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sortAddresses sorts IP addresses numerically
+func sortAddresses(ips []string) {
+	sort.Slice(ips, func(i, j int) bool {
+		a, errA := netip.ParseAddr(ips[i])
+		b, errB := netip.ParseAddr(ips[j])
+		if errA != nil || errB != nil {
+			return ips[i] < ips[j]
+		}
+		return a.Less(b)
+	})
+}
+
+// DefaultGateway returns the IPv4 default gateway routed through an
+// interface, read from the kernel routing table
+func DefaultGateway(interfaceName string) (string, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", fmt.Errorf("failed to read routing table: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] != interfaceName || fields[1] != "00000000" {
+			continue
+		}
+		gateway, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil || gateway == 0 {
+			continue
+		}
+		// The table prints addresses as host-order integers
+		var ip [4]byte
+		binary.NativeEndian.PutUint32(ip[:], uint32(gateway))
+		return netip.AddrFrom4(ip).String(), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read routing table: %w", err)
+	}
+	return "", fmt.Errorf("no default gateway on %s", interfaceName)
+}
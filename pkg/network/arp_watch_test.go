@@ -0,0 +1,115 @@
+package network
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestARPWatcherCheck(t *testing.T) {
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	previous := []Asset{
+		{IP: "10.0.0.1", MAC: "aa:00:00:00:00:01", Online: true, LastSeen: at},
+		{IP: "10.0.0.10", MAC: "aa:00:00:00:00:10", Online: true, LastSeen: at},
+		{IP: "10.0.0.11", MAC: "aa:00:00:00:00:11", Online: true, LastSeen: at},
+		{IP: "10.0.0.12", MAC: "aa:00:00:00:00:12", Online: true, LastSeen: at},
+		// Offline hosts say nothing about who holds an address now
+		{IP: "10.0.0.13", MAC: "aa:00:00:00:00:13", LastSeen: at},
+	}
+
+	tests := []struct {
+		name    string
+		routers []string
+		claims  ARPClaims
+		want    []string
+	}{
+		{
+			name:   "unchanged",
+			claims: ARPClaims{"10.0.0.1": {"aa:00:00:00:00:01"}, "10.0.0.10": {"aa:00:00:00:00:10"}},
+		},
+		{
+			name:   "two answers for one address",
+			claims: ARPClaims{"10.0.0.10": {"aa:00:00:00:00:10", "bb:00:00:00:00:01"}},
+			want:   []string{FindingIPConflict},
+		},
+		{
+			name:   "address answered from another MAC",
+			claims: ARPClaims{"10.0.0.10": {"bb:00:00:00:00:01"}, "10.0.0.13": {"bb:00:00:00:00:02"}},
+			want:   []string{FindingIPMACChanged},
+		},
+		{
+			name:   "device moved to a new lease",
+			claims: ARPClaims{"10.0.0.10": {"aa:00:00:00:00:11"}, "10.0.0.20": {"aa:00:00:00:00:10"}},
+		},
+		{
+			name:   "gateway answered from another MAC",
+			claims: ARPClaims{"10.0.0.1": {"bb:00:00:00:00:01"}},
+			want:   []string{FindingGatewayMACChanged},
+		},
+		{
+			name:   "one MAC answering for many addresses",
+			claims: ARPClaims{"10.0.0.10": {"bb:00:00:00:00:01"}, "10.0.0.11": {"bb:00:00:00:00:01"}, "10.0.0.12": {"bb:00:00:00:00:01"}, "10.0.0.30": {"bb:00:00:00:00:01"}},
+			want:   []string{FindingIPMACChanged, FindingIPMACChanged, FindingIPMACChanged, FindingMACClaimsManyIPs},
+		},
+		{
+			name:    "proxy ARP from a router given by MAC",
+			routers: []string{"CC-00-00-00-00-01"},
+			claims:  ARPClaims{"10.0.0.30": {"cc:00:00:00:00:01"}, "10.0.0.31": {"cc:00:00:00:00:01"}, "10.0.0.32": {"cc:00:00:00:00:01"}, "10.0.0.33": {"cc:00:00:00:00:01"}},
+		},
+		{
+			name:   "proxy ARP from the gateway",
+			claims: ARPClaims{"10.0.0.30": {"aa:00:00:00:00:01"}, "10.0.0.31": {"aa:00:00:00:00:01"}, "10.0.0.32": {"aa:00:00:00:00:01"}, "10.0.0.33": {"aa:00:00:00:00:01"}},
+		},
+	}
+	for _, tt := range tests {
+		watcher := NewARPWatcher(tt.routers, 3)
+		watcher.SetGateway("10.0.0.1")
+		var got []string
+		for _, finding := range watcher.Check(tt.claims, previous) {
+			got = append(got, finding.Type)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: findings %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestARPWatcherRouterByIP(t *testing.T) {
+	previous := []Asset{{IP: "10.0.0.254", MAC: "aa:00:00:00:00:fe", Online: true}}
+	claims := ARPClaims{}
+	for _, ip := range []string{"10.0.0.30", "10.0.0.31", "10.0.0.32", "10.0.0.33"} {
+		claims.add(ip, "aa:00:00:00:00:fe")
+	}
+
+	watcher := NewARPWatcher([]string{"10.0.0.254"}, 3)
+	if findings := watcher.Check(claims, previous); len(findings) != 0 {
+		t.Errorf("router answering for many addresses reported: %+v", findings)
+	}
+
+	// Another MAC answering for the router's addresses is still reported
+	spoofed := ARPClaims{}
+	spoofed.Merge(claims)
+	for ip := range spoofed {
+		spoofed[ip] = []string{"bb:00:00:00:00:01"}
+	}
+	findings := watcher.Check(spoofed, previous)
+	if len(findings) != 1 || findings[0].Type != FindingMACClaimsManyIPs || !slices.Equal(findings[0].IPs, []string{"10.0.0.30", "10.0.0.31", "10.0.0.32", "10.0.0.33"}) {
+		t.Errorf("findings = %+v, want the spoofing MAC reported with its addresses in order", findings)
+	}
+}
+
+func TestARPClaimsMerge(t *testing.T) {
+	claims := ARPClaims{"10.0.0.1": {"aa:00:00:00:00:01"}}
+	claims.Merge(ARPClaims{"10.0.0.1": {"aa:00:00:00:00:01", "bb:00:00:00:00:01"}, "10.0.0.2": {"aa:00:00:00:00:02"}})
+	if !slices.Equal(claims["10.0.0.1"], []string{"aa:00:00:00:00:01", "bb:00:00:00:00:01"}) || len(claims["10.0.0.2"]) != 1 {
+		t.Errorf("merged claims = %v", claims)
+	}
+}
+
+func TestSortAddresses(t *testing.T) {
+	ips := []string{"10.0.0.10", "10.0.0.9", "9.0.0.1", "10.0.0.100"}
+	sortAddresses(ips)
+	if want := []string{"9.0.0.1", "10.0.0.9", "10.0.0.10", "10.0.0.100"}; !slices.Equal(ips, want) {
+		t.Errorf("sorted = %v, want %v", ips, want)
+	}
+}
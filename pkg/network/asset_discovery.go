@@ -1,36 +1,89 @@
 package network
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net"
+	"net/netip"
+	"strings"
 	"sync"
 	"time"
 )
 
 // Asset represents a discovered network asset
 type Asset struct {
-	IP          string           `json:"ip"`
-	MAC         string           `json:"mac"`
-	Vendor      string           `json:"vendor"`
-	OpenPorts   []PortScanResult `json:"open_ports,omitempty"`
-	LastSeen    time.Time        `json:"last_seen"`
-	FirstSeen   time.Time        `json:"first_seen"`
-	Hostname    string           `json:"hostname,omitempty"`
-	ARPResponse bool             `json:"arp_response"`
+	IP                  string           `json:"ip"`
+	MAC                 string           `json:"mac"`
+	Vendor              string           `json:"vendor"`
+	OpenPorts           []PortScanResult `json:"open_ports,omitempty"`
+	LastSeen            time.Time        `json:"last_seen"`
+	FirstSeen           time.Time        `json:"first_seen"`
+	Hostname            string           `json:"hostname,omitempty"`
+	ARPResponse         bool             `json:"arp_response"`
+	SeenCount           int              `json:"seen_count"`
+	Online              bool             `json:"online"`
+	LocallyAdministered bool             `json:"locally_administered,omitempty"`
+	Addresses           []AssetAddress   `json:"addresses,omitempty"`
+	ResponseTime        time.Duration    `json:"response_time,omitempty"`
+	Tags                []string         `json:"tags,omitempty"`
 }
 
-// AssetID returns a unique identifier for the asset
+// AssetAddress is an IP address an asset has been seen at. IPv6 addresses
+// carry their scope: link-local, unique-local or global.
+type AssetAddress struct {
+	IP        string    `json:"ip"`
+	Scope     string    `json:"scope,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// AssetID returns a stable identifier for the asset. Hosts with a known MAC
+// address are identified by it, so a device keeps its identity when its IP
+// address changes. Other assets are identified by IP address, qualified by
+// hostname when one is known ("www.example.com@203.0.113.10").
 func (a *Asset) AssetID() string {
+	if a.MAC != "" {
+		if mac, err := net.ParseMAC(a.MAC); err == nil {
+			return mac.String()
+		}
+		return strings.ToLower(a.MAC)
+	}
+	if hostname := normalizeHostname(a.Hostname); hostname != "" {
+		return hostname + "@" + a.IP
+	}
 	return a.IP
 }
 
+// AddressIDs returns the asset IDs an asset without a MAC address would have
+// at its current IP: qualified by hostname when known, and bare. A stored
+// asset found by either may be the same device seen before its MAC address
+// or hostname was learned.
+func (a *Asset) AddressIDs() []string {
+	if hostname := normalizeHostname(a.Hostname); hostname != "" {
+		return []string{hostname + "@" + a.IP, a.IP}
+	}
+	return []string{a.IP}
+}
+
+// normalizeHostname lower-cases a hostname and drops the trailing root dot
+func normalizeHostname(hostname string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(hostname)), ".")
+}
+
 // AssetDiscovery represents an asset discovery service
 type AssetDiscovery struct {
 	arpScanner   *ParallelARPScanner
+	ndpScanner   *NDPScanner
 	portScanner  *PortScanner
 	assets       map[string]*Asset
+	heard        map[string]bool
+	claims       ARPClaims
 	mu           sync.RWMutex
 	scanInterval time.Duration
+	tcpPorts     []int
+	udpPorts     []int
+	exclude      []string
 }
 
 // NewAssetDiscovery creates a new asset discovery service
@@ -41,19 +94,29 @@ func NewAssetDiscovery(interfaceName string, arpTimeout, portTimeout time.Durati
 		return nil, fmt.Errorf("failed to create ARP scanner: %w", err)
 	}
 
+	ndpScanner, err := NewNDPScanner(interfaceName, arpTimeout)
+	if err != nil {
+		arpScanner.Close()
+		return nil, fmt.Errorf("failed to create NDP scanner: %w", err)
+	}
+
 	// Create port scanner
 	portScanner := NewPortScanner(portTimeout, workers, 2)
 
 	return &AssetDiscovery{
 		arpScanner:   arpScanner,
+		ndpScanner:   ndpScanner,
 		portScanner:  portScanner,
 		assets:       make(map[string]*Asset),
+		heard:        make(map[string]bool),
+		claims:       make(ARPClaims),
 		scanInterval: 10 * time.Minute, // Default scan interval
 	}, nil
 }
 
 // Close closes the asset discovery service
 func (d *AssetDiscovery) Close() error {
+	d.portScanner.Close()
 	return d.arpScanner.Close()
 }
 
@@ -62,13 +125,95 @@ func (d *AssetDiscovery) SetScanInterval(interval time.Duration) {
 	d.scanInterval = interval
 }
 
-// DiscoverAssets discovers assets on the network
-func (d *AssetDiscovery) DiscoverAssets(cidr string, scanPorts bool) ([]Asset, error) {
-	// Step 1: Perform ARP scan to discover devices
-	arpResults, err := d.arpScanner.ScanNetworkParallel(cidr)
+// SetPorts sets the ports scanned on discovered hosts. With no ports set the
+// port scanner's common ports are used.
+func (d *AssetDiscovery) SetPorts(tcpPorts, udpPorts []int) {
+	d.tcpPorts = tcpPorts
+	d.udpPorts = udpPorts
+}
+
+// SetExclusions sets the addresses, prefixes and ranges that sweeps skip
+func (d *AssetDiscovery) SetExclusions(exclude []string) error {
+	if _, err := ParseAddressSet(nil, exclude); err != nil {
+		return err
+	}
+	d.exclude = exclude
+	return nil
+}
+
+// SetRandomOrder sweeps networks in random order rather than address order
+func (d *AssetDiscovery) SetRandomOrder(random bool) {
+	d.arpScanner.SetRandomOrder(random)
+}
+
+// SetTCPScanMode selects connect or SYN scanning for the TCP ports of
+// discovered hosts and returns the mode in effect
+func (d *AssetDiscovery) SetTCPScanMode(mode TCPScanMode) TCPScanMode {
+	return d.portScanner.SetTCPScanMode(mode)
+}
+
+// SetServiceDetection turns active service probing of the open TCP ports of
+// discovered hosts on or off
+func (d *AssetDiscovery) SetServiceDetection(enabled bool) {
+	d.portScanner.SetServiceDetection(enabled)
+}
+
+// SetTLSInspector sets the inspector recording the TLS configuration of the
+// open ports of discovered hosts. nil turns TLS inspection off.
+func (d *AssetDiscovery) SetTLSInspector(inspector *TLSInspector) {
+	d.portScanner.SetTLSInspector(inspector)
+}
+
+// SetHTTPEnumerator sets the enumerator recording what the web services on
+// discovered hosts serve. nil turns HTTP enumeration off.
+func (d *AssetDiscovery) SetHTTPEnumerator(enumerator *HTTPEnumerator) {
+	d.portScanner.SetHTTPEnumerator(enumerator)
+}
+
+// SetScreenshotter sets the screenshotter capturing the web services on
+// discovered hosts. nil turns screenshots off.
+func (d *AssetDiscovery) SetScreenshotter(screenshotter *Screenshotter) {
+	d.portScanner.SetScreenshotter(screenshotter)
+}
+
+// SetCredentialAuditor sets the auditor trying default credentials against
+// allow-listed discovered hosts. nil turns the audit off.
+func (d *AssetDiscovery) SetCredentialAuditor(auditor *CredentialAuditor) {
+	d.portScanner.SetCredentialAuditor(auditor)
+}
+
+// SetRateLimiter sets the limiter pacing the ARP requests and port probes
+// of a scan. nil leaves them paced by the ARP rate alone.
+func (d *AssetDiscovery) SetRateLimiter(limit *RateLimiter) {
+	d.arpScanner.SetRateLimiter(limit)
+	d.portScanner.SetRateLimiter(limit)
+}
+
+// DiscoverAssets discovers assets on the network. IPv4 networks and ranges
+// are swept with ARP, less the exclusions; for an IPv6 prefix the hosts on
+// the local link are found with neighbor discovery and those with an
+// address in the prefix are kept.
+// When ctx is done the sweep and port scans stop, and the hosts found so far
+// are returned with ctx's error.
+func (d *AssetDiscovery) DiscoverAssets(ctx context.Context, cidr string, scanPorts bool) ([]Asset, error) {
+	if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Addr().Is6() {
+		return d.discoverIPv6(ctx, prefix.Masked(), scanPorts)
+	}
+
+	targets, err := ParseAddressSet([]string{cidr}, d.exclude)
 	if err != nil {
+		return nil, fmt.Errorf("failed to parse CIDR: %w", err)
+	}
+
+	// Step 1: Perform ARP scan to discover devices. Every answer is kept,
+	// so conflicting ones are noticed.
+	arpResults, claims, err := d.arpScanner.sweep(ctx, targets)
+	if err != nil && !Interrupted(err) {
 		return nil, fmt.Errorf("ARP scan failed: %w", err)
 	}
+	d.mu.Lock()
+	d.claims.Merge(claims)
+	d.mu.Unlock()
 
 	var assets []Asset
 	var wg sync.WaitGroup
@@ -83,24 +228,24 @@ func (d *AssetDiscovery) DiscoverAssets(cidr string, scanPorts bool) ([]Asset, e
 
 			now := time.Now()
 			asset := Asset{
-				IP:          r.IP,
-				MAC:         r.MAC,
-				Vendor:      r.Vendor,
-				LastSeen:    now,
-				FirstSeen:   now,
-				ARPResponse: true,
+				IP:                  r.IP,
+				MAC:                 r.MAC,
+				Vendor:              r.Vendor,
+				LastSeen:            now,
+				FirstSeen:           now,
+				ARPResponse:         true,
+				LocallyAdministered: r.LocallyAdministered,
+				ResponseTime:        r.Latency,
 			}
 
 			// Step 3: Optionally scan ports
 			if scanPorts {
-				// Scan common ports
-				portResults, err := d.portScanner.ScanHost(r.IP)
-				if err == nil {
-					// Filter for open ports only
-					for _, port := range portResults {
-						if port.State == PortOpen {
-							asset.OpenPorts = append(asset.OpenPorts, port)
-						}
+				// Filter for open ports only; an interrupted scan still
+				// reports the ports it found
+				portResults, _ := d.scanHostPorts(ctx, r.IP)
+				for _, port := range portResults {
+					if port.State == PortOpen {
+						asset.OpenPorts = append(asset.OpenPorts, port)
 					}
 				}
 			}
@@ -128,25 +273,277 @@ func (d *AssetDiscovery) DiscoverAssets(cidr string, scanPorts bool) ([]Asset, e
 		assets = append(assets, asset)
 	}
 
-	return assets, nil
+	return assets, ctx.Err()
 }
 
-// DiscoverAssetsFromFile discovers assets from a file containing CIDR ranges
-func (d *AssetDiscovery) DiscoverAssetsFromFile(filePath string, scanPorts bool) ([]Asset, error) {
-	// Read CIDR ranges from file
-	cidrs, err := ReadCIDRsFromFile(filePath)
+// TakeARPClaims returns the answers seen by the ARP sweeps since the last
+// call, for an ARPWatcher to check
+func (d *AssetDiscovery) TakeARPClaims() ARPClaims {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	claims := d.claims
+	d.claims = make(ARPClaims)
+	return claims
+}
+
+// DefaultGateway returns the IPv4 default gateway of the discovery interface
+func (d *AssetDiscovery) DefaultGateway() (string, error) {
+	return DefaultGateway(d.arpScanner.iface.Name)
+}
+
+// DiscoverIPv6Assets finds the IPv6 hosts on the local link with neighbor
+// discovery and records their link-local and global addresses. Hosts
+// already known by an IPv4 address keep it as their IP; the others are
+// identified, and port scanned, by their preferred IPv6 address. When ctx
+// is done the hosts found so far are returned with ctx's error.
+func (d *AssetDiscovery) DiscoverIPv6Assets(ctx context.Context, scanPorts bool) ([]Asset, error) {
+	return d.discoverIPv6(ctx, netip.Prefix{}, scanPorts)
+}
+
+// discoverIPv6 runs neighbor discovery, keeping the hosts with an address
+// in prefix when it is valid
+func (d *AssetDiscovery) discoverIPv6(ctx context.Context, prefix netip.Prefix, scanPorts bool) ([]Asset, error) {
+	results, err := d.ndpScanner.Scan(ctx)
+	if err != nil && !Interrupted(err) {
+		return nil, fmt.Errorf("NDP scan failed: %w", err)
+	}
+
+	var assets []Asset
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, result := range results {
+		now := time.Now()
+		asset := Asset{
+			MAC:                 result.MAC,
+			Vendor:              result.Vendor,
+			LastSeen:            now,
+			FirstSeen:           now,
+			LocallyAdministered: result.LocallyAdministered,
+		}
+
+		inPrefix := !prefix.IsValid()
+		var preferred netip.Addr
+		for _, address := range result.Addresses {
+			addr, err := netip.ParseAddr(address)
+			if err != nil {
+				continue
+			}
+			if prefix.IsValid() && prefix.Contains(addr) {
+				inPrefix = true
+			}
+			if !preferred.IsValid() || ipv6Preference(addr) > ipv6Preference(preferred) {
+				preferred = addr
+			}
+			asset.Addresses = append(asset.Addresses, AssetAddress{
+				IP:        address,
+				Scope:     IPv6Scope(addr),
+				FirstSeen: now,
+				LastSeen:  now,
+			})
+		}
+		if !inPrefix || !preferred.IsValid() {
+			continue
+		}
+		asset.IP = preferred.String()
+
+		wg.Add(1)
+		go func(asset Asset, target string) {
+			defer wg.Done()
+
+			// Hosts with a known IPv4 address were port scanned at it
+			if scanPorts && !d.hasIPv4(asset.AssetID()) {
+				portResults, _ := d.scanHostPorts(ctx, target)
+				for _, port := range portResults {
+					if port.State == PortOpen {
+						port.IP = asset.IP
+						asset.OpenPorts = append(asset.OpenPorts, port)
+					}
+				}
+			}
+
+			mu.Lock()
+			assets = append(assets, asset)
+			mu.Unlock()
+			d.updateAsset(&asset)
+		}(asset, d.scanAddress(preferred))
+	}
+	wg.Wait()
+
+	return assets, ctx.Err()
+}
+
+// scanAddress is the address to reach a host on the local link at;
+// link-local addresses need the interface as their zone
+func (d *AssetDiscovery) scanAddress(addr netip.Addr) string {
+	if addr.IsLinkLocalUnicast() {
+		return addr.WithZone(d.ndpScanner.iface.Name).String()
+	}
+	return addr.String()
+}
+
+// ipv6Preference ranks the addresses of a host for use as its IP: global
+// before unique-local before link-local
+func ipv6Preference(addr netip.Addr) int {
+	switch IPv6Scope(addr) {
+	case ScopeGlobal:
+		return 2
+	case ScopeUniqueLocal:
+		return 1
+	}
+	return 0
+}
+
+// hasIPv4 reports whether the asset is already known by an IPv4 address
+func (d *AssetDiscovery) hasIPv4(id string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	existing, ok := d.assets[id]
+	return ok && net.ParseIP(existing.IP).To4() != nil
+}
+
+// scanHostPorts port scans one host with the configured ports, or the port
+// scanner's common ports when none are set
+func (d *AssetDiscovery) scanHostPorts(ctx context.Context, ip string) ([]PortScanResult, error) {
+	if len(d.tcpPorts) > 0 || len(d.udpPorts) > 0 {
+		return d.portScanner.ScanHostPorts(ctx, ip, d.tcpPorts, d.udpPorts)
+	}
+	return d.portScanner.ScanHost(ctx, ip)
+}
+
+// Listen watches ARP and DHCP traffic on the discovery interface until ctx is
+// done, recording every device heard the moment it talks. It can run
+// alongside periodic sweeps or instead of them; HeardAssets collects what
+// it has heard for recording with a scan.
+func (d *AssetDiscovery) Listen(ctx context.Context) error {
+	listener, err := NewPassiveListener(d.arpScanner.iface.Name)
+	if err != nil {
+		return err
+	}
+	return listener.Listen(ctx, d.recordSighting)
+}
+
+// recordSighting updates the asset database with a device heard by the
+// passive listener, logging devices that are new or have moved
+func (d *AssetDiscovery) recordSighting(s Sighting) {
+	asset := Asset{
+		IP:                  s.IP,
+		MAC:                 s.MAC,
+		Vendor:              s.Vendor,
+		Hostname:            s.Hostname,
+		LastSeen:            s.Time,
+		FirstSeen:           s.Time,
+		ARPResponse:         s.Source != SightingDHCP,
+		LocallyAdministered: s.LocallyAdministered,
+	}
+	id := asset.AssetID()
+
+	d.mu.RLock()
+	existing, known := d.assets[id]
+	var previousIP string
+	if known {
+		previousIP = existing.IP
+	}
+	d.mu.RUnlock()
+
+	d.updateAsset(&asset)
+
+	d.mu.Lock()
+	d.heard[id] = true
+	d.mu.Unlock()
+
+	switch {
+	case !known:
+		log.Printf("Passive: heard %s at %s (%s)", s.MAC, s.IP, s.Source)
+	case previousIP != s.IP:
+		log.Printf("Passive: %s moved from %s to %s (%s)", s.MAC, previousIP, s.IP, s.Source)
+	}
+}
+
+// HeardAssets returns the assets the passive listener has heard since the
+// last call, as of their latest sighting. Ports are left for a scan to fill
+// in: when scanPorts is set, hosts not among scanned (the assets a sweep has
+// just found and port scanned) are port scanned now, until ctx is done.
+func (d *AssetDiscovery) HeardAssets(ctx context.Context, scanPorts bool, scanned []Asset) ([]Asset, error) {
+	d.mu.Lock()
+	assets := make([]Asset, 0, len(d.heard))
+	for id := range d.heard {
+		if asset, ok := d.assets[id]; ok {
+			heard := *asset
+			heard.OpenPorts = nil
+			assets = append(assets, heard)
+		}
+	}
+	d.heard = make(map[string]bool)
+	d.mu.Unlock()
+
+	if !scanPorts {
+		return assets, nil
+	}
+
+	swept := make(map[string]bool, len(scanned))
+	for _, asset := range scanned {
+		swept[asset.AssetID()] = true
+	}
+
+	var wg sync.WaitGroup
+	for i := range assets {
+		if swept[assets[i].AssetID()] {
+			continue
+		}
+		wg.Add(1)
+		go func(asset *Asset) {
+			defer wg.Done()
+
+			portResults, _ := d.scanHostPorts(ctx, asset.IP)
+			for _, port := range portResults {
+				if port.State == PortOpen {
+					asset.OpenPorts = append(asset.OpenPorts, port)
+				}
+			}
+		}(&assets[i])
+	}
+	wg.Wait()
+
+	return assets, ctx.Err()
+}
+
+// DiscoverAssetsFromFile discovers assets from a target file, stopping with
+// the assets found so far when ctx is done. The file's exclusions are added
+// to the discovery's own for the duration, and the assets of each target
+// are tagged with its tags; targets' own port profiles are not applied.
+func (d *AssetDiscovery) DiscoverAssetsFromFile(ctx context.Context, filePath string, scanPorts bool) ([]Asset, error) {
+	file, err := ReadTargetsFromFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CIDR file: %w", err)
+		return nil, fmt.Errorf("failed to read target file: %w", err)
 	}
 
+	exclude := d.exclude
+	defer func() { d.exclude = exclude }()
+	d.exclude = append(append([]string{}, exclude...), file.Exclude...)
+
 	var allAssets []Asset
-	for _, cidr := range cidrs {
-		assets, err := d.DiscoverAssets(cidr, scanPorts)
+	for _, target := range file.Targets {
+		specs, err := target.Resolve(ctx)
 		if err != nil {
-			fmt.Printf("Error scanning CIDR %s: %v\n", cidr, err)
+			log.Printf("Error scanning %s: %v", target.Spec, err)
 			continue
 		}
-		allAssets = append(allAssets, assets...)
+		for _, spec := range specs {
+			assets, err := d.DiscoverAssets(ctx, spec, scanPorts)
+			for i := range assets {
+				assets[i].AddTags(target.Tags...)
+			}
+			allAssets = append(allAssets, assets...)
+			if Interrupted(err) {
+				return allAssets, err
+			}
+			if err != nil {
+				log.Printf("Error scanning %s: %v", spec, err)
+			}
+		}
 	}
 
 	return allAssets, nil
@@ -157,27 +554,26 @@ func (d *AssetDiscovery) updateAsset(asset *Asset) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	// Check if asset already exists
-	if existing, ok := d.assets[asset.IP]; ok {
-		// Update existing asset
-		existing.LastSeen = asset.LastSeen
-		existing.MAC = asset.MAC
-		existing.Vendor = asset.Vendor
-		existing.ARPResponse = true
-
-		// Only update hostname if it was found
-		if asset.Hostname != "" {
-			existing.Hostname = asset.Hostname
-		}
+	id := asset.AssetID()
+	if existing, ok := d.assets[id]; ok {
+		MergeAsset(existing, *asset)
+		return
+	}
 
-		// Update ports if scan was performed
-		if len(asset.OpenPorts) > 0 {
-			existing.OpenPorts = asset.OpenPorts
+	// A host first seen without a MAC address keeps its record once the
+	// MAC address is learned
+	if asset.MAC != "" {
+		for _, addressID := range asset.AddressIDs() {
+			if existing, ok := d.assets[addressID]; ok && existing.MAC == "" {
+				delete(d.assets, addressID)
+				MergeAsset(existing, *asset)
+				d.assets[id] = existing
+				return
+			}
 		}
-	} else {
-		// Add new asset
-		d.assets[asset.IP] = asset
 	}
+
+	d.assets[id] = asset
 }
 
 // GetAssets returns all discovered assets
@@ -192,16 +588,19 @@ func (d *AssetDiscovery) GetAssets() []Asset {
 	return assets
 }
 
-// GetAssetByIP returns an asset by IP address
+// GetAssetByIP returns the asset currently at an IP address, preferring the
+// most recently seen one when several devices have claimed it
 func (d *AssetDiscovery) GetAssetByIP(ip string) (*Asset, bool) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	asset, ok := d.assets[ip]
-	if !ok {
-		return nil, false
+	var found *Asset
+	for _, asset := range d.assets {
+		if asset.IP == ip && (found == nil || asset.LastSeen.After(found.LastSeen)) {
+			found = asset
+		}
 	}
-	return asset, true
+	return found, found != nil
 }
 
 // lookupHostname tries to resolve an IP address to a hostname
@@ -0,0 +1,222 @@
+package network
+
+import (
+	"net"
+	"slices"
+	"sort"
+	"time"
+)
+
+// DeduplicateAssets merges assets that are the same device, filling in
+// missing details from each duplicate and combining their open ports and
+// addresses. Assets sharing an asset ID are merged, and an asset found
+// without a MAC address (by a port or public scan) is folded into the host
+// that holds its IP address: the one with a MAC address, or else the one
+// whose hostname is known.
+func DeduplicateAssets(assets []Asset) []Asset {
+	owners := addressOwners(assets)
+
+	assetMap := make(map[string]*Asset)
+	var order []string
+
+	for _, asset := range assets {
+		id := asset.AssetID()
+		if asset.MAC == "" {
+			if owner, ok := owners[asset.IP]; ok && owner != "" {
+				id = owner
+			}
+		}
+
+		if existing, ok := assetMap[id]; ok {
+			MergeAsset(existing, asset)
+		} else {
+			newAsset := asset
+			newAsset.Addresses = asset.AddressHistory()
+			assetMap[id] = &newAsset
+			order = append(order, id)
+		}
+	}
+
+	uniqueAssets := make([]Asset, 0, len(order))
+	for _, id := range order {
+		uniqueAssets = append(uniqueAssets, *assetMap[id])
+	}
+
+	return uniqueAssets
+}
+
+// addressOwners maps each IP address to the asset ID that MAC-less results
+// for it belong to. Hosts with a MAC address take precedence, the most
+// recently seen one winning an address conflict; hostname-qualified assets
+// own an address only when a single hostname was seen at it. An ambiguous
+// address maps to "".
+func addressOwners(assets []Asset) map[string]string {
+	owners := make(map[string]string)
+	seen := make(map[string]time.Time)
+
+	for _, asset := range assets {
+		if asset.MAC == "" || asset.IP == "" {
+			continue
+		}
+		if last, ok := seen[asset.IP]; !ok || asset.LastSeen.After(last) {
+			owners[asset.IP] = asset.AssetID()
+			seen[asset.IP] = asset.LastSeen
+		}
+	}
+
+	for _, asset := range assets {
+		if asset.MAC != "" || asset.IP == "" || normalizeHostname(asset.Hostname) == "" {
+			continue
+		}
+		if _, ok := seen[asset.IP]; ok {
+			continue
+		}
+		id := asset.AssetID()
+		if owner, ok := owners[asset.IP]; ok && owner != id {
+			owners[asset.IP] = ""
+			continue
+		}
+		owners[asset.IP] = id
+	}
+
+	return owners
+}
+
+// MergeAsset folds another sighting of the same device into an existing
+// asset. Details from the more recent sighting win, including the current
+// IP address unless it would replace an IPv4 address with an IPv6 one;
+// details only one sighting has are kept; every address either was seen at
+// is recorded.
+func MergeAsset(existing *Asset, asset Asset) {
+	newer := !asset.LastSeen.Before(existing.LastSeen)
+
+	existing.Addresses = MergeAddresses(existing.AddressHistory(), asset.AddressHistory())
+	if asset.IP != "" && (existing.IP == "" || newer && !KeepsIPv4(existing.IP, asset.IP)) {
+		existing.IP = asset.IP
+	}
+
+	if existing.MAC == "" && asset.MAC != "" {
+		existing.MAC = asset.MAC
+		existing.LocallyAdministered = asset.LocallyAdministered
+	}
+
+	if asset.Vendor != "" && (newer || existing.Vendor == "") {
+		existing.Vendor = asset.Vendor
+	}
+
+	if asset.Hostname != "" && (newer || existing.Hostname == "") {
+		existing.Hostname = asset.Hostname
+	}
+
+	if len(asset.OpenPorts) > 0 {
+		existing.OpenPorts = MergePortResults(existing.OpenPorts, asset.OpenPorts)
+	}
+
+	if asset.LastSeen.After(existing.LastSeen) {
+		existing.LastSeen = asset.LastSeen
+	}
+	if !asset.FirstSeen.IsZero() && (existing.FirstSeen.IsZero() || asset.FirstSeen.Before(existing.FirstSeen)) {
+		existing.FirstSeen = asset.FirstSeen
+	}
+
+	if asset.ARPResponse {
+		existing.ARPResponse = true
+	}
+
+	if asset.ResponseTime > 0 && (newer || existing.ResponseTime == 0) {
+		existing.ResponseTime = asset.ResponseTime
+	}
+
+	existing.AddTags(asset.Tags...)
+}
+
+// AddTags adds tags to the asset, keeping its tags sorted and unique
+func (a *Asset) AddTags(tags ...string) {
+	for _, tag := range tags {
+		if i, found := slices.BinarySearch(a.Tags, tag); !found {
+			a.Tags = slices.Insert(a.Tags, i, tag)
+		}
+	}
+}
+
+// KeepsIPv4 reports whether an asset whose IP is current should keep it
+// rather than take candidate: a host known by an IPv4 address keeps it when
+// it is also seen at an IPv6 address, which is recorded among its addresses
+func KeepsIPv4(current, candidate string) bool {
+	currentIP, candidateIP := net.ParseIP(current), net.ParseIP(candidate)
+	return currentIP != nil && currentIP.To4() != nil && candidateIP != nil && candidateIP.To4() == nil
+}
+
+// AddressHistory returns the addresses an asset has been seen at, falling
+// back to its current IP for assets recorded before addresses were tracked
+func (a *Asset) AddressHistory() []AssetAddress {
+	if len(a.Addresses) > 0 || a.IP == "" {
+		return a.Addresses
+	}
+	return []AssetAddress{{IP: a.IP, FirstSeen: a.FirstSeen, LastSeen: a.LastSeen}}
+}
+
+// MergeAddresses combines two address histories, widening the first and last
+// seen times of addresses present in both. The result is ordered by when
+// each address was last seen, most recent first.
+func MergeAddresses(existing, new []AssetAddress) []AssetAddress {
+	addressMap := make(map[string]*AssetAddress)
+	var order []string
+
+	for _, addresses := range [][]AssetAddress{existing, new} {
+		for _, address := range addresses {
+			current, ok := addressMap[address.IP]
+			if !ok {
+				copied := address
+				addressMap[address.IP] = &copied
+				order = append(order, address.IP)
+				continue
+			}
+			if !address.FirstSeen.IsZero() && (current.FirstSeen.IsZero() || address.FirstSeen.Before(current.FirstSeen)) {
+				current.FirstSeen = address.FirstSeen
+			}
+			if address.LastSeen.After(current.LastSeen) {
+				current.LastSeen = address.LastSeen
+			}
+		}
+	}
+
+	merged := make([]AssetAddress, 0, len(order))
+	for _, ip := range order {
+		merged = append(merged, *addressMap[ip])
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].LastSeen.After(merged[j].LastSeen)
+	})
+
+	return merged
+}
+
+// MergePortResults combines two port lists, preferring the newer result for a
+// port/protocol pair seen in both
+func MergePortResults(existing, new []PortScanResult) []PortScanResult {
+	type portKey struct {
+		port     int
+		protocol ScanType
+	}
+
+	portMap := make(map[portKey]PortScanResult)
+	var order []portKey
+
+	for _, ports := range [][]PortScanResult{existing, new} {
+		for _, port := range ports {
+			key := portKey{port.Port, port.Protocol}
+			if _, ok := portMap[key]; !ok {
+				order = append(order, key)
+			}
+			portMap[key] = port
+		}
+	}
+
+	merged := make([]PortScanResult, 0, len(order))
+	for _, key := range order {
+		merged = append(merged, portMap[key])
+	}
+
+	return merged
+}
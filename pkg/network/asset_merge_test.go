@@ -0,0 +1,63 @@
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAssetID(t *testing.T) {
+	tests := []struct {
+		asset Asset
+		want  string
+	}{
+		{Asset{IP: "10.0.0.1", MAC: "AA-BB-CC-00-00-01"}, "aa:bb:cc:00:00:01"},
+		{Asset{IP: "203.0.113.5", Hostname: "WWW.Example.com."}, "www.example.com@203.0.113.5"},
+		{Asset{IP: "203.0.113.5"}, "203.0.113.5"},
+	}
+	for _, tt := range tests {
+		if got := tt.asset.AssetID(); got != tt.want {
+			t.Errorf("AssetID(%+v) = %q, want %q", tt.asset, got, tt.want)
+		}
+	}
+}
+
+func TestDeduplicateAssets(t *testing.T) {
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	assets := DeduplicateAssets([]Asset{
+		{IP: "10.0.0.5", MAC: "aa:bb:cc:00:00:01", Vendor: "Acme", LastSeen: at},
+		{IP: "10.0.0.5", OpenPorts: []PortScanResult{{Port: 22, Protocol: "tcp", State: PortOpen}}, LastSeen: at},
+		{IP: "fe80::1", MAC: "aa:bb:cc:00:00:01", LastSeen: at.Add(time.Minute)},
+		{IP: "203.0.113.5", Hostname: "www.example.com", LastSeen: at},
+		{IP: "203.0.113.5", OpenPorts: []PortScanResult{{Port: 443, Protocol: "tcp", State: PortOpen}}, LastSeen: at},
+	})
+
+	if len(assets) != 2 {
+		t.Fatalf("DeduplicateAssets returned %d assets, want 2: %+v", len(assets), assets)
+	}
+
+	host := assets[0]
+	if host.IP != "10.0.0.5" || len(host.OpenPorts) != 1 || host.Vendor != "Acme" {
+		t.Errorf("MAC host = %+v, want 10.0.0.5 with port 22 folded in", host)
+	}
+	if len(host.Addresses) != 2 || host.Addresses[0].IP != "fe80::1" {
+		t.Errorf("MAC host addresses = %+v, want the IPv6 address recorded and the IPv4 address kept as ip", host.Addresses)
+	}
+
+	if public := assets[1]; public.Hostname != "www.example.com" || len(public.OpenPorts) != 1 {
+		t.Errorf("public host = %+v, want port 443 folded into the named host", public)
+	}
+}
+
+func TestMergeAddresses(t *testing.T) {
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	merged := MergeAddresses(
+		[]AssetAddress{{IP: "10.0.0.5", FirstSeen: at, LastSeen: at}},
+		[]AssetAddress{{IP: "10.0.0.7", FirstSeen: at.Add(time.Hour), LastSeen: at.Add(time.Hour)}, {IP: "10.0.0.5", FirstSeen: at.Add(-time.Hour), LastSeen: at.Add(time.Minute)}},
+	)
+	if len(merged) != 2 || merged[0].IP != "10.0.0.7" {
+		t.Fatalf("MergeAddresses = %+v, want 10.0.0.7 first", merged)
+	}
+	if old := merged[1]; !old.FirstSeen.Equal(at.Add(-time.Hour)) || !old.LastSeen.Equal(at.Add(time.Minute)) {
+		t.Errorf("10.0.0.5 seen %v to %v, want the widest window", old.FirstSeen, old.LastSeen)
+	}
+}
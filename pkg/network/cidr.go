@@ -5,37 +5,6 @@ import (
 	"net"
 )
 
-// CIDRToIPRange converts a CIDR notation to a list of IP addresses
-func CIDRToIPRange(cidr string) ([]string, error) {
-	_, ipnet, err := net.ParseCIDR(cidr)
-	if err != nil {
-		return nil, err
-	}
-
-	var ips []string
-	for ip := ipnet.IP.Mask(ipnet.Mask); ipnet.Contains(ip); incrementIP(ip) {
-		ipCopy := make(net.IP, len(ip))
-		copy(ipCopy, ip)
-		ips = append(ips, ipCopy.String())
-	}
-
-	// The first address is network address and the last is broadcast
-	if len(ips) > 2 {
-		return ips[1 : len(ips)-1], nil
-	}
-	return ips, nil
-}
-
-// incrementIP increments an IP address by 1
-func incrementIP(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
-		}
-	}
-}
-
 // GetLocalNetworkCIDR returns the CIDR of the local network
 func GetLocalNetworkCIDR() (string, error) {
 	interfaces, err := net.Interfaces()
@@ -0,0 +1,59 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WithDeadline bounds a scan phase to d. A zero or negative d leaves the
+// phase bounded only by ctx.
+func WithDeadline(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// Interrupted reports whether a scan error means the scan was cancelled or
+// ran out of time. The results returned with such an error are partial but
+// valid.
+func Interrupted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// InterruptReason describes why an interrupted scan stopped
+func InterruptReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "deadline exceeded"
+	}
+	return "cancelled"
+}
+
+// acquire takes a slot of a semaphore, giving up when ctx is done first
+func acquire(ctx context.Context, sem chan struct{}) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sleep waits for d, returning early with false when ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
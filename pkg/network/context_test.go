@@ -0,0 +1,79 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWithDeadline(t *testing.T) {
+	phase, cancel := WithDeadline(context.Background(), 0)
+	defer cancel()
+	if _, ok := phase.Deadline(); ok {
+		t.Error("a zero deadline bounded the phase")
+	}
+
+	phase, cancel = WithDeadline(context.Background(), time.Millisecond)
+	defer cancel()
+	<-phase.Done()
+	if !errors.Is(phase.Err(), context.DeadlineExceeded) {
+		t.Errorf("phase ended with %v, want its deadline exceeded", phase.Err())
+	}
+}
+
+func TestInterrupted(t *testing.T) {
+	tests := []struct {
+		err         error
+		interrupted bool
+		reason      string
+	}{
+		{context.Canceled, true, "cancelled"},
+		{fmt.Errorf("ARP scan failed: %w", context.DeadlineExceeded), true, "deadline exceeded"},
+		{errors.New("permission denied"), false, ""},
+		{nil, false, ""},
+	}
+	for _, tt := range tests {
+		if got := Interrupted(tt.err); got != tt.interrupted {
+			t.Errorf("Interrupted(%v) = %v, want %v", tt.err, got, tt.interrupted)
+		}
+		if tt.interrupted && InterruptReason(tt.err) != tt.reason {
+			t.Errorf("InterruptReason(%v) = %q, want %q", tt.err, InterruptReason(tt.err), tt.reason)
+		}
+	}
+}
+
+func TestSleep(t *testing.T) {
+	if !sleep(context.Background(), time.Millisecond) {
+		t.Error("sleep without cancellation returned false")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if sleep(ctx, time.Minute) || time.Since(start) > time.Second {
+		t.Error("sleep did not return early once cancelled")
+	}
+	if sleep(ctx, 0) {
+		t.Error("zero sleep after cancellation returned true")
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	sem := make(chan struct{}, 1)
+	if !acquire(context.Background(), sem) {
+		t.Fatal("acquiring a free slot failed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if acquire(ctx, sem) {
+		t.Error("acquired a slot of a full semaphore")
+	}
+
+	<-sem
+	if acquire(ctx, sem) {
+		t.Error("acquired a slot after the context was done")
+	}
+}
@@ -0,0 +1,840 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/textproto"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jlaffaye/ftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// FindingDefaultCredentials is reported on a port that accepts a login from
+// the credential file
+const FindingDefaultCredentials = "default_credentials"
+
+// Credential services a credential file line may be limited to
+const (
+	CredentialSSH    = "ssh"
+	CredentialFTP    = "ftp"
+	CredentialTelnet = "telnet"
+	CredentialHTTP   = "http"
+)
+
+// blankPassword stands for an empty password in a credential file
+const blankPassword = "<blank>"
+
+// maxConnectionErrors is how many attempts in a row may fail to reach a
+// service before its audit is abandoned; a service that starts refusing
+// connections is likely blocking the auditor
+const maxConnectionErrors = 3
+
+var (
+	// errLoginRejected is returned by a checker when the service answered
+	// and refused the credential
+	errLoginRejected = errors.New("login rejected")
+	// errNoLoginForm is returned when a web page has no login form to fill
+	errNoLoginForm = errors.New("no login form")
+)
+
+// telnetFailure matches the replies of telnet services to a refused login
+var telnetFailure = regexp.MustCompile(`(?i)incorrect|invalid|failed|denied|bad password|login:\s*$|username:\s*$`)
+
+// telnetShellPrompt matches the end of a shell prompt
+var telnetShellPrompt = regexp.MustCompile(`[$#>%]\s*$`)
+
+// Credential is a username and password to try against a service. An empty
+// Service means every service.
+type Credential struct {
+	Service  string
+	Username string
+	Password string
+	Line     int
+}
+
+// ParseCredentials reads a credential file. Each line holds a service
+// (ssh, ftp, telnet, http or * for all), a username and a password,
+// separated by whitespace; <blank> stands for an empty password. Blank
+// lines and lines starting with # are ignored.
+func ParseCredentials(r io.Reader) ([]Credential, error) {
+	var credentials []Credential
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected service, username and password, got %d fields", lineNumber, len(fields))
+		}
+
+		service := strings.ToLower(fields[0])
+		switch service {
+		case "*":
+			service = ""
+		case CredentialSSH, CredentialFTP, CredentialTelnet, CredentialHTTP:
+		default:
+			return nil, fmt.Errorf("line %d: unknown service %q", lineNumber, fields[0])
+		}
+
+		password := fields[2]
+		if password == blankPassword {
+			password = ""
+		}
+
+		credentials = append(credentials, Credential{
+			Service:  service,
+			Username: fields[1],
+			Password: password,
+			Line:     lineNumber,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// LoadCredentialFile reads the credential file at path
+func LoadCredentialFile(path string) ([]Credential, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open credential file: %w", err)
+	}
+	defer file.Close()
+
+	credentials, err := ParseCredentials(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse credential file %s: %w", path, err)
+	}
+	return credentials, nil
+}
+
+// CredentialAuditor tries the logins of a credential file against the SSH,
+// FTP, Telnet and web services of assets inside an allow-list of networks.
+// Assets outside it are never contacted. Attempts against a host are made
+// one at a time with a delay between them, and each username is tried at
+// most a few times per host, across all its services and scans, so account
+// lockout policies are not hit. The attempt counts of a host are forgotten
+// once its reset window has passed; until then the ports already audited
+// are not tried again.
+type CredentialAuditor struct {
+	credentials []Credential
+	allowed     []*net.IPNet
+	timeout     time.Duration
+	delay       time.Duration
+	maxPerUser  int
+	resetWindow time.Duration
+	hosts       chan struct{}
+	mu          sync.Mutex
+	ledger      map[string]*hostAttempts
+}
+
+// NewCredentialAuditor creates an auditor for the assets in the allowed
+// networks, given as CIDRs or single addresses
+func NewCredentialAuditor(credentials []Credential, allowed []string, timeout time.Duration) (*CredentialAuditor, error) {
+	auditor := &CredentialAuditor{
+		credentials: credentials,
+		timeout:     timeout,
+		delay:       2 * time.Second,
+		maxPerUser:  3,
+		resetWindow: 24 * time.Hour,
+		hosts:       make(chan struct{}, 4),
+		ledger:      make(map[string]*hostAttempts),
+	}
+
+	for _, entry := range allowed {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allowed network %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			auditor.allowed = append(auditor.allowed, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed network %q: %w", entry, err)
+		}
+		auditor.allowed = append(auditor.allowed, ipNet)
+	}
+
+	return auditor, nil
+}
+
+// SetRateLimit sets the delay between attempts against a host and how many
+// passwords each username is tried with per host
+func (a *CredentialAuditor) SetRateLimit(delay time.Duration, maxPerUser int) {
+	if delay >= 0 {
+		a.delay = delay
+	}
+	if maxPerUser > 0 {
+		a.maxPerUser = maxPerUser
+	}
+}
+
+// SetResetWindow sets how long the attempts made against a host count
+// towards its limits before they are forgotten and the host audited again
+func (a *CredentialAuditor) SetResetWindow(window time.Duration) {
+	if window > 0 {
+		a.resetWindow = window
+	}
+}
+
+// SetConcurrency sets how many hosts are audited at the same time
+func (a *CredentialAuditor) SetConcurrency(hosts int) {
+	if hosts > 0 {
+		a.hosts = make(chan struct{}, hosts)
+	}
+}
+
+// Allowed reports whether an address is inside the audit allow-list
+func (a *CredentialAuditor) Allowed(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range a.allowed {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditTarget is a port being audited
+type auditTarget struct {
+	result   *PortScanResult
+	hostname string
+}
+
+func (t auditTarget) addr() string {
+	return net.JoinHostPort(t.result.IP, strconv.Itoa(t.result.Port))
+}
+
+// credentialCheck tries one credential against a service, giving up when
+// ctx is done. It returns nil when the login is accepted and
+// errLoginRejected when it is refused; any other error means the service
+// could not be asked.
+type credentialCheck func(ctx context.Context, target auditTarget, credential Credential) error
+
+// serviceChecker is how the logins of one service on a port are tried
+type serviceChecker struct {
+	name    string
+	service string
+	check   credentialCheck
+}
+
+// auditPorts tries the credential file against the open ports of a host
+// and records a finding on each port that accepts a login. Ports audited
+// within the reset window are given the findings of that audit instead. No
+// further attempts are made once ctx is done.
+func (a *CredentialAuditor) auditPorts(ctx context.Context, ip string, results []PortScanResult, hostname string) {
+	if !a.Allowed(ip) {
+		return
+	}
+
+	if !acquire(ctx, a.hosts) {
+		return
+	}
+	defer func() { <-a.hosts }()
+
+	// Services of a host often share its accounts, so the attempts per
+	// username are counted across them. Scans auditing the same host take
+	// turns so its attempts stay one at a time.
+	attempts := a.attemptsFor(ip)
+	attempts.mu.Lock()
+	defer attempts.mu.Unlock()
+
+	for i := range results {
+		result := &results[i]
+		if result.Protocol != ScanTCP || result.State != PortOpen {
+			continue
+		}
+		if findings, ok := attempts.ports[result.Port]; ok {
+			result.Findings = append(result.Findings, findings...)
+			continue
+		}
+
+		found := len(result.Findings)
+		target := auditTarget{result: result, hostname: hostname}
+		for _, checker := range a.checkersFor(ctx, target) {
+			a.auditService(ctx, target, checker, attempts)
+		}
+		if ctx.Err() != nil {
+			return // the port is audited again by the next scan
+		}
+		attempts.ports[result.Port] = append([]Finding(nil), result.Findings[found:]...)
+	}
+}
+
+// attemptsFor returns the attempts made against a host in its current
+// reset window, starting a new window when the last one has passed
+func (a *CredentialAuditor) attemptsFor(ip string) *hostAttempts {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for host, attempts := range a.ledger {
+		if now.Sub(attempts.since) >= a.resetWindow {
+			delete(a.ledger, host)
+		}
+	}
+	attempts, ok := a.ledger[ip]
+	if !ok {
+		attempts = &hostAttempts{
+			since:   now,
+			perUser: make(map[string]int),
+			ports:   make(map[int][]Finding),
+		}
+		a.ledger[ip] = attempts
+	}
+	return attempts
+}
+
+// checkersFor picks the checkers for a port by its detected service. Web
+// services are checked with HTTP basic auth when they ask for it, or
+// through their login form when the page has one.
+func (a *CredentialAuditor) checkersFor(ctx context.Context, target auditTarget) []serviceChecker {
+	result := target.result
+	switch {
+	case result.Service == "SSH":
+		return []serviceChecker{{name: "SSH", service: CredentialSSH, check: a.checkSSH}}
+	case result.Service == "FTP":
+		return []serviceChecker{{name: "FTP", service: CredentialFTP, check: a.checkFTP}}
+	case result.Service == "Telnet":
+		return []serviceChecker{{name: "Telnet", service: CredentialTelnet, check: a.checkTelnet}}
+	case result.HTTP == nil:
+		return nil
+	}
+
+	resp, body, err := a.fetch(ctx, a.httpClient(target, nil), http.MethodGet, target.result.HTTP.FinalURL, nil, target, nil)
+	if err != nil {
+		return nil
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		if strings.HasPrefix(strings.ToLower(resp.Header.Get("WWW-Authenticate")), "basic") {
+			return []serviceChecker{{name: "HTTP basic auth", service: CredentialHTTP, check: a.checkHTTPBasic}}
+		}
+		return nil
+	}
+	if findLoginForm(body, resp.Request.URL) != nil {
+		return []serviceChecker{{name: "Web login form", service: CredentialHTTP, check: a.checkWebForm}}
+	}
+	return nil
+}
+
+// hostAttempts counts the login attempts made against a host since its
+// reset window began, and keeps the findings of the ports audited in it.
+// mu is held while the host is audited.
+type hostAttempts struct {
+	mu      sync.Mutex
+	since   time.Time
+	last    time.Time
+	perUser map[string]int
+	ports   map[int][]Finding
+}
+
+// auditService tries the credentials for a service in file order. It stops
+// at the first accepted login, skips usernames that have been tried
+// maxPerUser times on the host, and gives up on a service that stops
+// answering or when ctx is done.
+func (a *CredentialAuditor) auditService(ctx context.Context, target auditTarget, checker serviceChecker, attempts *hostAttempts) {
+	connectionErrors := 0
+
+	for _, credential := range a.credentials {
+		if credential.Service != "" && credential.Service != checker.service {
+			continue
+		}
+		if attempts.perUser[credential.Username] >= a.maxPerUser {
+			continue
+		}
+		if !sleep(ctx, a.delay-time.Since(attempts.last)) {
+			return
+		}
+		attempts.last = time.Now()
+		attempts.perUser[credential.Username]++
+
+		err := checker.check(ctx, target, credential)
+		switch {
+		case err == nil:
+			target.result.Findings = append(target.result.Findings, Finding{
+				Type:     FindingDefaultCredentials,
+				Severity: SeverityHigh,
+				Detail:   describeLogin(checker.name, credential),
+			})
+			return
+		case errors.Is(err, errLoginRejected):
+			connectionErrors = 0
+		case errors.Is(err, errNoLoginForm):
+			return
+		default:
+			connectionErrors++
+			if connectionErrors >= maxConnectionErrors {
+				log.Printf("Credential audit of %s on %s abandoned: %v", checker.name, target.addr(), err)
+				return
+			}
+		}
+	}
+}
+
+// describeLogin is the detail of a default credentials finding. The
+// password is referred to by its credential file line rather than copied
+// into the inventory.
+func describeLogin(name string, credential Credential) string {
+	if credential.Password == "" {
+		return fmt.Sprintf("%s accepts user %q with a blank password (credential file line %d)", name, credential.Username, credential.Line)
+	}
+	return fmt.Sprintf("%s accepts user %q with the password on credential file line %d", name, credential.Username, credential.Line)
+}
+
+// checkSSH tries a password login, answering keyboard-interactive prompts
+// with the password as well
+func (a *CredentialAuditor) checkSSH(ctx context.Context, target auditTarget, credential Credential) error {
+	conn, err := (&net.Dialer{Timeout: a.timeout}).DialContext(ctx, "tcp", target.addr())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * a.timeout))
+
+	config := &ssh.ClientConfig{
+		User: credential.Username,
+		Auth: []ssh.AuthMethod{
+			ssh.Password(credential.Password),
+			ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = credential.Password
+				}
+				return answers, nil
+			}),
+		},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         a.timeout,
+	}
+
+	client, chans, reqs, err := ssh.NewClientConn(conn, target.addr(), config)
+	if err != nil {
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return errLoginRejected
+		}
+		return err
+	}
+	ssh.NewClient(client, chans, reqs).Close()
+	return nil
+}
+
+// checkFTP tries a USER/PASS login
+func (a *CredentialAuditor) checkFTP(ctx context.Context, target auditTarget, credential Credential) error {
+	conn, err := ftp.Dial(target.addr(), ftp.DialWithTimeout(a.timeout), ftp.DialWithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer conn.Quit()
+
+	if err := conn.Login(credential.Username, credential.Password); err != nil {
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			return errLoginRejected
+		}
+		return err
+	}
+	return nil
+}
+
+// checkTelnet answers the login and password prompts and decides by the
+// reply: a shell prompt is a login, another login prompt or an error
+// message is a refusal
+func (a *CredentialAuditor) checkTelnet(ctx context.Context, target auditTarget, credential Credential) error {
+	conn, err := (&net.Dialer{Timeout: a.timeout}).DialContext(ctx, "tcp", target.addr())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	session := &telnetSession{conn: conn, timeout: a.timeout}
+	if _, err := session.readUntil("login:", "username:", "user:", "user name:"); err != nil {
+		return err
+	}
+	if err := session.send(credential.Username); err != nil {
+		return err
+	}
+	if _, err := session.readUntil("password:"); err != nil {
+		return err
+	}
+	if err := session.send(credential.Password); err != nil {
+		return err
+	}
+
+	reply := strings.TrimSpace(session.readFor(a.timeout))
+	if reply == "" || telnetFailure.MatchString(reply) {
+		return errLoginRejected
+	}
+	if telnetShellPrompt.MatchString(reply) {
+		return nil
+	}
+	return errLoginRejected
+}
+
+// telnetSession reads text from a telnet service, refusing every option the
+// server negotiates
+type telnetSession struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+const (
+	telnetIAC  = 255
+	telnetDont = 254
+	telnetDo   = 253
+	telnetWont = 252
+	telnetWill = 251
+	telnetSB   = 250
+	telnetSE   = 240
+)
+
+// read returns the text of the next chunk the server sends with telnet
+// commands stripped
+func (s *telnetSession) read(deadline time.Time) (string, error) {
+	s.conn.SetReadDeadline(deadline)
+	buf := make([]byte, 1024)
+	n, err := s.conn.Read(buf)
+	if n == 0 {
+		return "", err
+	}
+
+	var text, reply []byte
+	data := buf[:n]
+	for i := 0; i < len(data); i++ {
+		if data[i] != telnetIAC || i+1 >= len(data) {
+			text = append(text, data[i])
+			continue
+		}
+		switch command := data[i+1]; command {
+		case telnetDo, telnetDont, telnetWill, telnetWont:
+			if i+2 < len(data) {
+				answer := byte(telnetWont)
+				if command == telnetWill || command == telnetWont {
+					answer = telnetDont
+				}
+				reply = append(reply, telnetIAC, answer, data[i+2])
+			}
+			i += 2
+		case telnetSB:
+			for i += 2; i+1 < len(data) && !(data[i] == telnetIAC && data[i+1] == telnetSE); i++ {
+			}
+			i++
+		default:
+			i++
+		}
+	}
+	if len(reply) > 0 {
+		s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		s.conn.Write(reply)
+	}
+	return string(text), nil
+}
+
+// readUntil reads until the text ends in one of the prompts
+func (s *telnetSession) readUntil(prompts ...string) (string, error) {
+	deadline := time.Now().Add(2 * s.timeout)
+	var text strings.Builder
+	for {
+		chunk, err := s.read(deadline)
+		text.WriteString(chunk)
+		tail := strings.ToLower(strings.TrimSpace(text.String()))
+		for _, prompt := range prompts {
+			if strings.HasSuffix(tail, prompt) {
+				return text.String(), nil
+			}
+		}
+		if err != nil {
+			return text.String(), fmt.Errorf("no %q prompt: %w", prompts[0], err)
+		}
+	}
+}
+
+// readFor collects what the server sends until it falls silent or the
+// duration passes
+func (s *telnetSession) readFor(duration time.Duration) string {
+	deadline := time.Now().Add(duration)
+	var text strings.Builder
+	for time.Now().Before(deadline) {
+		chunk, err := s.read(deadline)
+		text.WriteString(chunk)
+		if err != nil {
+			break
+		}
+	}
+	return text.String()
+}
+
+func (s *telnetSession) send(line string) error {
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	_, err := s.conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+// httpClient builds the client for one web login attempt. Every connection
+// goes to the audited address, whatever host a URL names; the hostname is
+// only sent as the Host header and TLS server name. Redirects are followed
+// while they stay on the host; jar keeps the session cookies a login form
+// may depend on.
+func (a *CredentialAuditor) httpClient(target auditTarget, jar http.CookieJar) *http.Client {
+	dialer := &net.Dialer{Timeout: a.timeout}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			_, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			return dialer.DialContext(ctx, network, net.JoinHostPort(target.result.IP, port))
+		},
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true, ServerName: target.hostname},
+		TLSHandshakeTimeout: a.timeout,
+		DisableKeepAlives:   true,
+		Proxy:               nil,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Jar:       jar,
+		Timeout:   2 * a.timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxHTTPRedirects || !sameHost(req.URL, via[0].URL, target.hostname) {
+				return http.ErrUseLastResponse
+			}
+			if target.hostname != "" {
+				req.Host = target.hostname
+			}
+			return nil
+		},
+	}
+}
+
+// fetch sends a request and reads up to maxHTTPBody of the response, giving
+// up when ctx is done. form is sent as the body of a POST or the query of a
+// GET; basic, when set, is sent as basic auth.
+func (a *CredentialAuditor) fetch(ctx context.Context, client *http.Client, method, target string, form url.Values, audit auditTarget, basic *Credential) (*http.Response, []byte, error) {
+	var body io.Reader
+	if method == http.MethodPost {
+		body = strings.NewReader(form.Encode())
+	} else if form != nil {
+		parsed, err := url.Parse(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		parsed.RawQuery = form.Encode()
+		target = parsed.String()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if audit.hostname != "" {
+		req.Host = audit.hostname
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if basic != nil {
+		req.SetBasicAuth(basic.Username, basic.Password)
+	}
+	req.Header.Set("User-Agent", "assetmanager/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody))
+	if err != nil && len(data) == 0 {
+		return nil, nil, err
+	}
+	return resp, data, nil
+}
+
+// checkHTTPBasic requests the page with basic auth credentials. The login
+// is only taken as accepted when the page is served, or when the reply
+// redirects within the host.
+func (a *CredentialAuditor) checkHTTPBasic(ctx context.Context, target auditTarget, credential Credential) error {
+	resp, _, err := a.fetch(ctx, a.httpClient(target, nil), http.MethodGet, target.result.HTTP.FinalURL, nil, target, &credential)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errLoginRejected
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		// The client stops at a redirect that leaves the host or comes
+		// after too many others
+		if location, err := resp.Location(); err == nil && sameHost(location, resp.Request.URL, target.hostname) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
+
+// loginForm is a web form with a password field
+type loginForm struct {
+	action        *url.URL
+	method        string
+	usernameField string
+	passwordField string
+	fields        url.Values
+}
+
+// FormField is an input element of a web page
+type FormField struct {
+	ID      string
+	Name    string
+	Type    string
+	Value   string
+	Checked bool
+}
+
+// GetFields returns the input elements inside a selection of a page. A
+// field without a type is a text field, as browsers treat it.
+func GetFields(selection *goquery.Selection) []FormField {
+	var fields []FormField
+	selection.Find("input").Each(func(_ int, input *goquery.Selection) {
+		_, checked := input.Attr("checked")
+		fields = append(fields, FormField{
+			ID:      input.AttrOr("id", ""),
+			Name:    input.AttrOr("name", ""),
+			Type:    strings.ToLower(input.AttrOr("type", "text")),
+			Value:   input.AttrOr("value", ""),
+			Checked: checked,
+		})
+	})
+	return fields
+}
+
+// findLoginForm returns the first form of a page that has a password field,
+// with the values of its other fields to submit along with the login
+func findLoginForm(body []byte, page *url.URL) *loginForm {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var found *loginForm
+	doc.Find("form").EachWithBreak(func(_ int, form *goquery.Selection) bool {
+		login := &loginForm{fields: url.Values{}}
+
+		for _, field := range GetFields(form) {
+			if field.Name == "" {
+				continue
+			}
+			switch field.Type {
+			case "password":
+				if login.passwordField == "" {
+					login.passwordField = field.Name
+				}
+			case "text", "email", "tel":
+				if login.usernameField == "" {
+					login.usernameField = field.Name
+				} else {
+					login.fields.Set(field.Name, field.Value)
+				}
+			case "checkbox", "radio":
+				if field.Checked {
+					login.fields.Add(field.Name, field.Value)
+				}
+			case "submit", "hidden":
+				login.fields.Set(field.Name, field.Value)
+			}
+		}
+		if login.passwordField == "" {
+			return true
+		}
+
+		action, err := page.Parse(form.AttrOr("action", ""))
+		if err != nil {
+			return true
+		}
+		login.action = action
+		login.method = http.MethodGet
+		if strings.EqualFold(form.AttrOr("method", ""), http.MethodPost) {
+			login.method = http.MethodPost
+		}
+		found = login
+		return false
+	})
+
+	return found
+}
+
+// checkWebForm loads the login page, fills in its form and submits it. The
+// page is loaded afresh for every attempt so session cookies and
+// anti-forgery tokens are current. The login is taken as accepted when the
+// reply no longer shows a password field.
+func (a *CredentialAuditor) checkWebForm(ctx context.Context, target auditTarget, credential Credential) error {
+	jar, _ := cookiejar.New(nil)
+	client := a.httpClient(target, jar)
+
+	resp, body, err := a.fetch(ctx, client, http.MethodGet, target.result.HTTP.FinalURL, nil, target, nil)
+	if err != nil {
+		return err
+	}
+	form := findLoginForm(body, resp.Request.URL)
+	if form == nil {
+		return errNoLoginForm
+	}
+	if !sameHost(form.action, resp.Request.URL, target.hostname) {
+		return errNoLoginForm // never send the credentials elsewhere
+	}
+
+	values := form.fields
+	if form.usernameField != "" {
+		values.Set(form.usernameField, credential.Username)
+	}
+	values.Set(form.passwordField, credential.Password)
+
+	resp, body, err = a.fetch(ctx, client, form.method, form.action.String(), values, target, nil)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("server error %d", resp.StatusCode)
+	case resp.StatusCode >= 400:
+		return errLoginRejected
+	case findLoginForm(body, resp.Request.URL) != nil:
+		return errLoginRejected
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseCredentials(t *testing.T) {
+	const file = `# service  username  password
+*       admin      admin
+
+SSH     root       toor
+ftp     anonymous  <blank>
+`
+	credentials, err := ParseCredentials(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("ParseCredentials: %v", err)
+	}
+	want := []Credential{
+		{Service: "", Username: "admin", Password: "admin", Line: 2},
+		{Service: CredentialSSH, Username: "root", Password: "toor", Line: 4},
+		{Service: CredentialFTP, Username: "anonymous", Password: "", Line: 5},
+	}
+	if len(credentials) != len(want) {
+		t.Fatalf("parsed %d credentials, want %d", len(credentials), len(want))
+	}
+	for i := range want {
+		if credentials[i] != want[i] {
+			t.Errorf("credential %d = %+v, want %+v", i, credentials[i], want[i])
+		}
+	}
+
+	tests := []struct {
+		file string
+		line string
+	}{
+		{"ssh root", "line 1"},
+		{"# header\nssh root toor extra", "line 2"},
+		{"ssh root toor\n\nrdp admin admin", "line 3"},
+	}
+	for _, tt := range tests {
+		_, err := ParseCredentials(strings.NewReader(tt.file))
+		if err == nil || !strings.Contains(err.Error(), tt.line) {
+			t.Errorf("ParseCredentials(%q) = %v, want an error on %s", tt.file, err, tt.line)
+		}
+	}
+}
+
+func TestNewCredentialAuditor(t *testing.T) {
+	auditor, err := NewCredentialAuditor(nil, []string{"10.0.0.0/24", " 192.168.1.5 ", "2001:db8::1"}, time.Second)
+	if err != nil {
+		t.Fatalf("NewCredentialAuditor: %v", err)
+	}
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"10.0.0.200", true},
+		{"10.0.1.1", false},
+		{"192.168.1.5", true},
+		{"192.168.1.6", false},
+		{"2001:db8::1", true},
+		{"not an address", false},
+	}
+	for _, tt := range tests {
+		if got := auditor.Allowed(tt.ip); got != tt.allowed {
+			t.Errorf("Allowed(%s) = %v, want %v", tt.ip, got, tt.allowed)
+		}
+	}
+
+	for _, allowed := range []string{"10.0.0.0/33", "host.example.com"} {
+		if _, err := NewCredentialAuditor(nil, []string{allowed}, time.Second); err == nil {
+			t.Errorf("allowed network %q accepted", allowed)
+		}
+	}
+}
+
+const testLoginPage = `<html><body>
+<form action="/search"><input name="q"></form>
+<form method="post" action="/session">
+  <input type="hidden" name="csrf" value="token">
+  <input id="user" name="login">
+  <input type="password" name="pass">
+  <input type="checkbox" name="remember" value="yes" checked>
+  <input type="checkbox" name="public" value="yes">
+  <input type="submit" name="go" value="Sign in">
+</form>
+</body></html>`
+
+func TestGetFields(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testLoginPage))
+	if err != nil {
+		t.Fatalf("parsing the page: %v", err)
+	}
+	fields := GetFields(doc.Find("form").Last())
+	if len(fields) != 6 {
+		t.Fatalf("found %d fields, want 6: %+v", len(fields), fields)
+	}
+	if user := fields[1]; user != (FormField{ID: "user", Name: "login", Type: "text"}) {
+		t.Errorf("untyped field = %+v, want a text field", user)
+	}
+	if !fields[3].Checked || fields[4].Checked {
+		t.Errorf("checkboxes = %+v and %+v, want only the first checked", fields[3], fields[4])
+	}
+}
+
+func TestFindLoginForm(t *testing.T) {
+	page, _ := url.Parse("http://10.0.0.1/login")
+	form := findLoginForm([]byte(testLoginPage), page)
+	if form == nil {
+		t.Fatal("no login form found")
+	}
+	if form.method != http.MethodPost || form.action.String() != "http://10.0.0.1/session" {
+		t.Errorf("form submits with %s to %s", form.method, form.action)
+	}
+	if form.usernameField != "login" || form.passwordField != "pass" {
+		t.Errorf("username field %q, password field %q", form.usernameField, form.passwordField)
+	}
+	want := url.Values{"csrf": {"token"}, "remember": {"yes"}, "go": {"Sign in"}}
+	if form.fields.Encode() != want.Encode() {
+		t.Errorf("other fields = %v, want %v", form.fields, want)
+	}
+
+	if findLoginForm([]byte(`<form><input name="q"></form>`), page) != nil {
+		t.Error("found a login form on a page without a password field")
+	}
+}
+
+// testAuditor audits loopback without delays between attempts
+func testAuditor(t *testing.T, credentials string, maxPerUser int) *CredentialAuditor {
+	t.Helper()
+	parsed, err := ParseCredentials(strings.NewReader(credentials))
+	if err != nil {
+		t.Fatalf("ParseCredentials: %v", err)
+	}
+	auditor, err := NewCredentialAuditor(parsed, []string{"127.0.0.1"}, time.Second)
+	if err != nil {
+		t.Fatalf("NewCredentialAuditor: %v", err)
+	}
+	auditor.SetRateLimit(0, maxPerUser)
+	return auditor
+}
+
+// webResult is an open web port of a test server
+func webResult(t *testing.T, server *httptest.Server) PortScanResult {
+	t.Helper()
+	host, port := splitServerURL(t, server.URL)
+	return PortScanResult{IP: host, Port: port, Protocol: ScanTCP, State: PortOpen, Service: "HTTP", HTTP: &HTTPInfo{FinalURL: server.URL + "/"}}
+}
+
+func TestAuditWebForm(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.FormValue("csrf") == "token" && r.FormValue("login") == "admin" && r.FormValue("pass") == "s3cret" {
+			fmt.Fprint(w, "<html><body>Dashboard</body></html>")
+			return
+		}
+		fmt.Fprint(w, testLoginPage)
+	}))
+	defer server.Close()
+
+	auditor := testAuditor(t, "http admin admin\n* admin s3cret\nssh admin other\n", 3)
+	results := []PortScanResult{webResult(t, server)}
+	auditor.auditPorts(context.Background(), "127.0.0.1", results, "")
+
+	findings := results[0].Findings
+	if len(findings) != 1 || findings[0].Type != FindingDefaultCredentials || !strings.Contains(findings[0].Detail, "line 2") {
+		t.Errorf("findings = %+v, want the login on credential file line 2", findings)
+	}
+
+	// Assets outside the allow-list are not contacted
+	results = []PortScanResult{webResult(t, server)}
+	auditor.auditPorts(context.Background(), "10.0.0.1", results, "")
+	if len(results[0].Findings) != 0 {
+		t.Errorf("audited an asset outside the allow-list: %+v", results[0].Findings)
+	}
+}
+
+func TestAuditAttemptsPerHost(t *testing.T) {
+	var mu sync.Mutex
+	tried := make(map[string]int)
+	basic := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, ok := r.BasicAuth(); ok {
+			mu.Lock()
+			tried[user]++
+			mu.Unlock()
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="router"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	first := httptest.NewServer(basic)
+	defer first.Close()
+	second := httptest.NewServer(basic)
+	defer second.Close()
+
+	auditor := testAuditor(t, "* admin one\n* admin two\n* admin three\n* root toor\n", 2)
+	results := []PortScanResult{webResult(t, first), webResult(t, second)}
+	auditor.auditPorts(context.Background(), "127.0.0.1", results, "")
+
+	// The limit holds across both services of the host
+	if tried["admin"] != 2 || tried["root"] != 2 {
+		t.Errorf("attempts per user = %v, want 2 each across both ports", tried)
+	}
+	if len(results[0].Findings)+len(results[1].Findings) != 0 {
+		t.Error("a rejected login was reported")
+	}
+}
+
+func TestAuditAcrossScans(t *testing.T) {
+	var mu sync.Mutex
+	tried := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); ok {
+			mu.Lock()
+			tried++
+			mu.Unlock()
+			if user == "admin" && password == "two" {
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="router"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	auditor := testAuditor(t, "* admin one\n* admin two\n", 3)
+	results := []PortScanResult{webResult(t, server)}
+	auditor.auditPorts(context.Background(), "127.0.0.1", results, "")
+	if tried != 2 || len(results[0].Findings) != 1 {
+		t.Fatalf("first scan made %d attempts with findings %+v, want 2 and the login", tried, results[0].Findings)
+	}
+
+	// Within the reset window the port is not tried again but keeps its
+	// finding
+	results = []PortScanResult{webResult(t, server)}
+	auditor.auditPorts(context.Background(), "127.0.0.1", results, "")
+	if tried != 2 {
+		t.Errorf("second scan made %d more attempts, want none", tried-2)
+	}
+	if len(results[0].Findings) != 1 || results[0].Findings[0].Type != FindingDefaultCredentials {
+		t.Errorf("second scan findings = %+v, want the earlier login", results[0].Findings)
+	}
+
+	// Once the window has passed the host is audited afresh
+	auditor.ledger["127.0.0.1"].since = time.Now().Add(-25 * time.Hour)
+	results = []PortScanResult{webResult(t, server)}
+	auditor.auditPorts(context.Background(), "127.0.0.1", results, "")
+	if tried != 4 || len(results[0].Findings) != 1 {
+		t.Errorf("scan after the window made %d attempts with findings %+v, want 2 more and the login", tried-2, results[0].Findings)
+	}
+}
+
+func TestAuditAttemptsAcrossScans(t *testing.T) {
+	var mu sync.Mutex
+	tried := 0
+	basic := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); ok {
+			mu.Lock()
+			tried++
+			mu.Unlock()
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="router"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	first := httptest.NewServer(basic)
+	defer first.Close()
+	second := httptest.NewServer(basic)
+	defer second.Close()
+
+	// A port opened after the host was audited is tried only with what is
+	// left of each username's attempts
+	auditor := testAuditor(t, "* admin one\n* admin two\n* admin three\n", 2)
+	auditor.auditPorts(context.Background(), "127.0.0.1", []PortScanResult{webResult(t, first)}, "")
+	auditor.auditPorts(context.Background(), "127.0.0.1", []PortScanResult{webResult(t, first), webResult(t, second)}, "")
+	if tried != 2 {
+		t.Errorf("made %d attempts over two scans, want 2", tried)
+	}
+}
+
+func TestAuditDialsTargetAddress(t *testing.T) {
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		if user, password, ok := r.BasicAuth(); ok && user == "admin" && password == "admin" {
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="router"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	// The hostname does not resolve; the requests must still reach the
+	// audited address, naming the hostname only in the Host header
+	auditor := testAuditor(t, "http admin admin\n", 3)
+	result := webResult(t, server)
+	_, port := splitServerURL(t, server.URL)
+	result.HTTP.FinalURL = fmt.Sprintf("http://router.invalid:%d/", port)
+	results := []PortScanResult{result}
+	auditor.auditPorts(context.Background(), "127.0.0.1", results, "router.invalid")
+
+	if len(results[0].Findings) != 1 {
+		t.Errorf("findings = %+v, want the login", results[0].Findings)
+	}
+	if host != "router.invalid" {
+		t.Errorf("Host header = %q, want router.invalid", host)
+	}
+}
+
+func TestCheckHTTPBasic(t *testing.T) {
+	var status int
+	var location string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/home" {
+			return
+		}
+		if location != "" {
+			w.Header().Set("Location", location)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		status   int
+		location string
+		accepted bool
+		rejected bool
+	}{
+		{status: http.StatusOK, accepted: true},
+		{status: http.StatusNoContent, accepted: true},
+		{status: http.StatusFound, location: "/home", accepted: true},
+		{status: http.StatusFound, location: "http://router.invalid/login"},
+		{status: http.StatusUnauthorized, rejected: true},
+		{status: http.StatusForbidden, rejected: true},
+		{status: http.StatusNotFound},
+		{status: http.StatusInternalServerError},
+	}
+
+	auditor := testAuditor(t, "", 3)
+	for _, tt := range tests {
+		status, location = tt.status, tt.location
+		result := webResult(t, server)
+		err := auditor.checkHTTPBasic(context.Background(), auditTarget{result: &result}, Credential{Username: "admin", Password: "admin"})
+		switch {
+		case tt.accepted && err != nil:
+			t.Errorf("status %d to %q: %v, want the login accepted", tt.status, tt.location, err)
+		case tt.rejected && !errors.Is(err, errLoginRejected):
+			t.Errorf("status %d: %v, want the login rejected", tt.status, err)
+		case !tt.accepted && !tt.rejected && (err == nil || errors.Is(err, errLoginRejected)):
+			t.Errorf("status %d to %q: %v, want an error", tt.status, tt.location, err)
+		}
+	}
+}
+
+func TestAuditCancelled(t *testing.T) {
+	requests := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); ok {
+			requests <- struct{}{}
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="router"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	// A login request in flight is abandoned when ctx is done, and the
+	// port is left to be audited by the next scan
+	auditor := testAuditor(t, "* admin one\n* admin two\n", 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-requests
+		cancel()
+	}()
+	start := time.Now()
+	auditor.auditPorts(ctx, "127.0.0.1", []PortScanResult{webResult(t, server)}, "")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled audit took %v", elapsed)
+	}
+	if len(requests) != 0 {
+		t.Errorf("%d more login attempts after cancellation", len(requests))
+	}
+	if _, ok := auditor.ledger["127.0.0.1"].ports[webResult(t, server).Port]; ok {
+		t.Error("an interrupted audit was recorded as done")
+	}
+}
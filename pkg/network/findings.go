@@ -0,0 +1,20 @@
+package network
+
+// Severity ranks how urgently a finding needs attention
+type Severity string
+
+const (
+	// SeverityHigh needs attention now
+	SeverityHigh Severity = "high"
+	// SeverityMedium weakens security and should be planned for
+	SeverityMedium Severity = "medium"
+	// SeverityLow is worth knowing but rarely exploitable on its own
+	SeverityLow Severity = "low"
+)
+
+// Finding is a problem found on an asset or one of its services
+type Finding struct {
+	Type     string   `json:"type"`
+	Severity Severity `json:"severity"`
+	Detail   string   `json:"detail"`
+}
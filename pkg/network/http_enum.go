@@ -0,0 +1,464 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// maxHTTPRedirects bounds the redirect chain followed from /
+	maxHTTPRedirects = 10
+	// maxHTTPBody bounds how much of a page or favicon is read
+	maxHTTPBody = 1 << 20
+)
+
+// httpPorts are the ports enumerated as web services whatever service was
+// detected
+var httpPorts = map[int]bool{
+	80: true, 81: true, 443: true, 591: true, 3000: true, 5000: true,
+	8000: true, 8008: true, 8080: true, 8081: true, 8088: true, 8443: true,
+	8888: true, 9443: true,
+}
+
+// httpServices are detected service names that mark a web service
+var httpServices = map[string]bool{
+	"HTTP": true, "HTTPS": true, "HTTP-Proxy": true,
+}
+
+// HTTPRedirect is one hop of a redirect chain
+type HTTPRedirect struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location"`
+}
+
+// HTTPInfo is what a web service serves at /: the response at the end of
+// the redirect chain, the favicon and the technologies the page reveals
+type HTTPInfo struct {
+	URL           string         `json:"url"`
+	FinalURL      string         `json:"final_url"`
+	StatusCode    int            `json:"status_code"`
+	Title         string         `json:"title,omitempty"`
+	Server        string         `json:"server,omitempty"`
+	ContentType   string         `json:"content_type,omitempty"`
+	ContentLength int            `json:"content_length"`
+	Redirects     []HTTPRedirect `json:"redirects,omitempty"`
+	FaviconURL    string         `json:"favicon_url,omitempty"`
+	FaviconHash   *int32         `json:"favicon_hash,omitempty"`
+	Technologies  []string       `json:"technologies,omitempty"`
+	FetchedAt     time.Time      `json:"fetched_at"`
+}
+
+// techRule recognizes a technology by a response header, a cookie, the
+// generator meta tag, script sources or the page body. A capture group in
+// the pattern is taken as the version.
+type techRule struct {
+	name    string
+	header  string
+	cookie  string
+	meta    bool
+	script  bool
+	body    bool
+	pattern *regexp.Regexp
+}
+
+// techRules are matched against every enumerated page
+var techRules = []techRule{
+	{name: "PHP", header: "X-Powered-By", pattern: regexp.MustCompile(`PHP/?([\d.]+)?`)},
+	{name: "PHP", cookie: "PHPSESSID"},
+	{name: "ASP.NET", header: "X-Powered-By", pattern: regexp.MustCompile(`ASP\.NET`)},
+	{name: "ASP.NET", header: "X-AspNet-Version", pattern: regexp.MustCompile(`([\d.]+)`)},
+	{name: "ASP.NET", cookie: "ASP.NET_SessionId"},
+	{name: "Express", header: "X-Powered-By", pattern: regexp.MustCompile(`^Express$`)},
+	{name: "Next.js", header: "X-Powered-By", pattern: regexp.MustCompile(`Next\.js ?([\d.]+)?`)},
+	{name: "Java", cookie: "JSESSIONID"},
+	{name: "Laravel", cookie: "laravel_session"},
+	{name: "Django", cookie: "csrftoken"},
+	{name: "Ruby on Rails", header: "X-Runtime", pattern: regexp.MustCompile(`^[\d.]+$`)},
+	{name: "Drupal", header: "X-Generator", pattern: regexp.MustCompile(`Drupal ?(\d+)?`)},
+	{name: "Drupal", header: "X-Drupal-Cache", pattern: regexp.MustCompile(`.`)},
+	{name: "Varnish", header: "Via", pattern: regexp.MustCompile(`varnish`)},
+	{name: "Cloudflare", header: "CF-RAY", pattern: regexp.MustCompile(`.`)},
+	{name: "WordPress", meta: true, pattern: regexp.MustCompile(`WordPress ?([\d.]+)?`)},
+	{name: "Joomla", meta: true, pattern: regexp.MustCompile(`Joomla!? ?([\d.]+)?`)},
+	{name: "Drupal", meta: true, pattern: regexp.MustCompile(`Drupal ?(\d+)?`)},
+	{name: "WordPress", body: true, pattern: regexp.MustCompile(`/wp-(?:content|includes)/`)},
+	{name: "Drupal", body: true, pattern: regexp.MustCompile(`/sites/(?:default|all)/(?:files|themes|modules)/`)},
+	{name: "jQuery", script: true, pattern: regexp.MustCompile(`jquery[.-]?([\d.]+\d)?(?:\.min)?\.js`)},
+	{name: "Bootstrap", script: true, pattern: regexp.MustCompile(`bootstrap(?:\.bundle)?(?:\.min)?\.js`)},
+	{name: "React", script: true, pattern: regexp.MustCompile(`react(?:-dom)?(?:\.production)?(?:\.min)?\.js`)},
+	{name: "Angular", body: true, pattern: regexp.MustCompile(`ng-version="([\d.]+)"`)},
+	{name: "Vue.js", script: true, pattern: regexp.MustCompile(`vue(?:\.runtime)?(?:\.global)?(?:\.prod)?(?:\.min)?\.js`)},
+	{name: "Grafana", body: true, pattern: regexp.MustCompile(`<title>Grafana</title>`)},
+	{name: "Jenkins", header: "X-Jenkins", pattern: regexp.MustCompile(`([\d.]+)`)},
+	{name: "GitLab", body: true, pattern: regexp.MustCompile(`content="GitLab"`)},
+}
+
+// HTTPEnumerator fetches / from web services and records what they serve.
+// Redirects are followed as long as they stay on the same host; a redirect
+// elsewhere is recorded but not followed.
+type HTTPEnumerator struct {
+	timeout   time.Duration
+	userAgent string
+}
+
+// NewHTTPEnumerator creates an enumerator whose requests time out after timeout
+func NewHTTPEnumerator(timeout time.Duration) *HTTPEnumerator {
+	return &HTTPEnumerator{timeout: timeout, userAgent: "assetmanager/1.0"}
+}
+
+// Enumerate fetches / from a web service. hostname, when known, is sent as
+// the Host header and TLS server name so virtual hosts answer as they
+// would to a browser.
+func (e *HTTPEnumerator) Enumerate(ctx context.Context, ip string, port int, useTLS bool, hostname string) (*HTTPInfo, error) {
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	start := &url.URL{Scheme: scheme, Host: net.JoinHostPort(ip, strconv.Itoa(port)), Path: "/"}
+
+	info := &HTTPInfo{URL: start.String(), FetchedAt: time.Now()}
+	client := e.client(hostname, info)
+
+	resp, body, err := e.fetch(ctx, client, start, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	info.FinalURL = resp.Request.URL.String()
+	info.StatusCode = resp.StatusCode
+	info.Server = resp.Header.Get("Server")
+	info.ContentType = resp.Header.Get("Content-Type")
+	info.ContentLength = len(body)
+
+	var doc *goquery.Document
+	if strings.Contains(strings.ToLower(info.ContentType), "html") || bytes.Contains(bytes.ToLower(body[:min(len(body), 512)]), []byte("<html")) {
+		doc, _ = goquery.NewDocumentFromReader(bytes.NewReader(body))
+	}
+	if doc != nil {
+		info.Title = strings.Join(strings.Fields(doc.Find("title").First().Text()), " ")
+	}
+
+	info.Technologies = detectTechnologies(resp, doc, body)
+
+	// Redirects followed for the favicon are not part of the page's chain
+	redirects := info.Redirects
+	e.fetchFavicon(ctx, client, resp.Request.URL, doc, hostname, info)
+	info.Redirects = redirects
+
+	return info, nil
+}
+
+// client builds the HTTP client for one enumeration. It records each
+// redirect on info and stops at redirects that leave the host.
+func (e *HTTPEnumerator) client(hostname string, info *HTTPInfo) *http.Client {
+	transport := &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: e.timeout}).DialContext,
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true, ServerName: hostname},
+		TLSHandshakeTimeout: e.timeout,
+		DisableKeepAlives:   true,
+		Proxy:               nil,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   2 * e.timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			previous := via[len(via)-1]
+			hop := HTTPRedirect{URL: previous.URL.String(), Location: req.URL.String()}
+			if req.Response != nil {
+				hop.StatusCode = req.Response.StatusCode
+			}
+			info.Redirects = append(info.Redirects, hop)
+
+			if len(via) >= maxHTTPRedirects {
+				return http.ErrUseLastResponse
+			}
+			if !sameHost(req.URL, via[0].URL, hostname) {
+				return http.ErrUseLastResponse
+			}
+			if hostname != "" {
+				req.Host = hostname
+			}
+			return nil
+		},
+	}
+}
+
+// fetch requests a URL and reads up to maxHTTPBody of the response body
+func (e *HTTPEnumerator) fetch(ctx context.Context, client *http.Client, target *url.URL, hostname string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hostname != "" {
+		req.Host = hostname
+	}
+	req.Header.Set("User-Agent", e.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody))
+	if err != nil && len(body) == 0 {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+// fetchFavicon fetches the icon the page links to, or /favicon.ico, and
+// records its hash when the server returns one
+func (e *HTTPEnumerator) fetchFavicon(ctx context.Context, client *http.Client, page *url.URL, doc *goquery.Document, hostname string, info *HTTPInfo) {
+	icon := &url.URL{Path: "/favicon.ico"}
+	if doc != nil {
+		doc.Find("link[rel]").EachWithBreak(func(_ int, link *goquery.Selection) bool {
+			rel := strings.ToLower(link.AttrOr("rel", ""))
+			href := link.AttrOr("href", "")
+			if href == "" || !strings.Contains(rel, "icon") {
+				return true
+			}
+			if parsed, err := url.Parse(href); err == nil {
+				icon = parsed
+				return false
+			}
+			return true
+		})
+	}
+
+	target := page.ResolveReference(icon)
+	if target.Scheme == "data" || !sameHost(target, page, hostname) {
+		return
+	}
+
+	resp, body, err := e.fetch(ctx, client, target, hostname)
+	if err != nil || resp.StatusCode != http.StatusOK || len(body) == 0 {
+		return
+	}
+	if strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "html") {
+		return // an error page served with 200
+	}
+
+	hash := FaviconHash(body)
+	info.FaviconURL = target.String()
+	info.FaviconHash = &hash
+}
+
+// sameHost reports whether a redirect or link target stays on the
+// enumerated service: the same address, or the hostname it was reached by
+func sameHost(target, origin *url.URL, hostname string) bool {
+	host := target.Hostname()
+	return host == origin.Hostname() || (hostname != "" && strings.EqualFold(host, hostname))
+}
+
+// detectTechnologies matches the tech rules against a response. Versions
+// found by any rule are kept; each technology is listed once, sorted.
+func detectTechnologies(resp *http.Response, doc *goquery.Document, body []byte) []string {
+	found := make(map[string]string)
+	record := func(name string, groups []string) {
+		version := ""
+		if len(groups) > 1 {
+			version = groups[1]
+		}
+		if current, ok := found[name]; !ok || (current == "" && version != "") {
+			found[name] = version
+		}
+	}
+
+	var generators, scripts []string
+	if doc != nil {
+		doc.Find(`meta[name="generator"], meta[name="Generator"]`).Each(func(_ int, meta *goquery.Selection) {
+			generators = append(generators, meta.AttrOr("content", ""))
+		})
+		doc.Find("script[src]").Each(func(_ int, script *goquery.Selection) {
+			scripts = append(scripts, script.AttrOr("src", ""))
+		})
+	}
+
+	for _, rule := range techRules {
+		var candidates []string
+		switch {
+		case rule.header != "":
+			candidates = resp.Header.Values(rule.header)
+		case rule.cookie != "":
+			for _, cookie := range resp.Cookies() {
+				if strings.EqualFold(cookie.Name, rule.cookie) {
+					record(rule.name, nil)
+				}
+			}
+			continue
+		case rule.meta:
+			candidates = generators
+		case rule.script:
+			candidates = scripts
+		case rule.body:
+			candidates = []string{string(body)}
+		}
+
+		for _, candidate := range candidates {
+			if groups := rule.pattern.FindStringSubmatch(candidate); groups != nil {
+				record(rule.name, groups)
+				break
+			}
+		}
+	}
+
+	technologies := make([]string, 0, len(found))
+	for name, version := range found {
+		if version != "" {
+			name += " " + version
+		}
+		technologies = append(technologies, name)
+	}
+	sort.Strings(technologies)
+	return technologies
+}
+
+// FaviconHash returns the MurmurHash3 of a favicon's base64 encoding, with
+// a line break every 76 characters, as a signed 32-bit integer. This is
+// the http.favicon.hash used by Shodan and similar search engines.
+func FaviconHash(data []byte) int32 {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76])
+		b.WriteByte('\n')
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+	b.WriteByte('\n')
+	return int32(murmur3([]byte(b.String()), 0))
+}
+
+// murmur3 is the 32-bit MurmurHash3 of data
+func murmur3(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+
+	h := seed
+	n := len(data) / 4
+	for i := 0; i < n; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	tail := data[n*4:]
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// isHTTPCandidate reports whether a port result should be enumerated as a
+// web service: an open TCP port on a well-known web port, named as a web
+// service, or speaking TLS without another service detected
+func isHTTPCandidate(result *PortScanResult) bool {
+	if result.Protocol != ScanTCP || result.State != PortOpen {
+		return false
+	}
+	if httpPorts[result.Port] || httpServices[result.Service] {
+		return true
+	}
+	return result.TLS != nil && result.Service == "SSL"
+}
+
+// enumerateHTTP enumerates the web services among port results, up to
+// concurrency at a time until ctx is done, and records what it finds on them
+func (e *HTTPEnumerator) enumerateHTTP(ctx context.Context, results []PortScanResult, hostname string, concurrency int) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for i := range results {
+		if !isHTTPCandidate(&results[i]) {
+			continue
+		}
+		if !acquire(ctx, sem) {
+			break
+		}
+		wg.Add(1)
+
+		go func(result *PortScanResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			useTLS := result.TLS != nil || result.Service == "HTTPS"
+			info, err := e.Enumerate(ctx, result.IP, result.Port, useTLS, hostname)
+			if !useTLS && refusedPlainHTTP(info, err) {
+				// A TLS port left uninspected: retry over TLS
+				if tlsInfo, tlsErr := e.Enumerate(ctx, result.IP, result.Port, true, hostname); tlsErr == nil {
+					useTLS, info, err = true, tlsInfo, nil
+				}
+			}
+			if err != nil {
+				return
+			}
+
+			result.HTTP = info
+			switch {
+			case useTLS && (result.Service == "unknown" || result.Service == "SSL"):
+				result.Service = "HTTPS"
+			case !useTLS && result.Service == "unknown":
+				result.Service = "HTTP"
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+}
+
+// refusedPlainHTTP reports a plain HTTP request that a TLS port answered
+// with a TLS alert or with the 400 web servers send for it
+func refusedPlainHTTP(info *HTTPInfo, err error) bool {
+	if err != nil {
+		return strings.Contains(err.Error(), `malformed HTTP response "\x15\x03`)
+	}
+	return info.StatusCode == http.StatusBadRequest
+}
@@ -0,0 +1,142 @@
+package network
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPage = `<!DOCTYPE html>
+<html><head>
+<title>
+  Admin   Console
+</title>
+<meta name="generator" content="WordPress 6.4.2">
+<link rel="shortcut icon" href="/static/icon.png">
+<script src="/js/jquery-3.7.1.min.js"></script>
+</head><body><img src="/wp-content/logo.png"></body></html>`
+
+// testWebServer serves a page behind a same-host redirect, its favicon, and
+// a redirect off the host
+func testWebServer() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx")
+		w.Header().Set("X-Powered-By", "PHP/8.2.1")
+		http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "abc"})
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, testPage)
+	})
+	mux.HandleFunc("/static/icon.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("icon"))
+	})
+	return mux
+}
+
+func TestEnumerate(t *testing.T) {
+	server := httptest.NewServer(testWebServer())
+	defer server.Close()
+	host, port := splitServerURL(t, server.URL)
+
+	info, err := NewHTTPEnumerator(2*time.Second).Enumerate(context.Background(), host, port, false, "")
+	if err != nil {
+		t.Fatalf("Enumerate: %v", err)
+	}
+
+	if info.StatusCode != http.StatusOK || info.Title != "Admin Console" || info.Server != "nginx" {
+		t.Errorf("status %d, title %q, server %q", info.StatusCode, info.Title, info.Server)
+	}
+	if !strings.HasSuffix(info.FinalURL, "/login") || len(info.Redirects) != 1 || info.Redirects[0].StatusCode != http.StatusFound {
+		t.Errorf("final URL %s, redirects %+v; want one 302 to /login", info.FinalURL, info.Redirects)
+	}
+	if info.FaviconHash == nil || *info.FaviconHash != FaviconHash([]byte("icon")) || !strings.HasSuffix(info.FaviconURL, "/static/icon.png") {
+		t.Errorf("favicon %s with hash %v, want the linked icon", info.FaviconURL, info.FaviconHash)
+	}
+
+	want := []string{"PHP 8.2.1", "WordPress 6.4.2", "jQuery 3.7.1"}
+	if !slices.Equal(info.Technologies, want) {
+		t.Errorf("technologies = %v, want %v", info.Technologies, want)
+	}
+}
+
+func TestEnumerateOffHostRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://sso.example.com/auth", http.StatusMovedPermanently)
+	}))
+	defer server.Close()
+	host, port := splitServerURL(t, server.URL)
+
+	info, err := NewHTTPEnumerator(2*time.Second).Enumerate(context.Background(), host, port, false, "")
+	if err != nil {
+		t.Fatalf("Enumerate: %v", err)
+	}
+	if info.StatusCode != http.StatusMovedPermanently || len(info.Redirects) != 1 || info.Redirects[0].Location != "https://sso.example.com/auth" {
+		t.Errorf("status %d, redirects %+v; want the redirect off the host recorded and not followed", info.StatusCode, info.Redirects)
+	}
+}
+
+func TestEnumerateHTTPRetriesOverTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(testWebServer())
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	host, port := splitServerURL(t, server.URL)
+
+	results := []PortScanResult{{IP: host, Port: port, Protocol: ScanTCP, State: PortOpen, Service: "HTTP"}}
+	NewHTTPEnumerator(2*time.Second).enumerateHTTP(context.Background(), results, "", 2)
+
+	if info := results[0].HTTP; info == nil || !strings.HasPrefix(info.URL, "https://") || info.Title != "Admin Console" {
+		t.Errorf("HTTP info = %+v, want the page fetched over TLS", info)
+	}
+}
+
+func TestMurmur3(t *testing.T) {
+	tests := []struct {
+		data string
+		seed uint32
+		want uint32
+	}{
+		{"", 0, 0},
+		{"", 1, 0x514e28b7},
+		{"hello", 0, 0x248bfa47},
+		{"Hello, world!", 1234, 0xfaf6cdb3},
+		{"The quick brown fox jumps over the lazy dog", 0x9747b28c, 0x2fa826cd},
+	}
+	for _, tt := range tests {
+		if got := murmur3([]byte(tt.data), tt.seed); got != tt.want {
+			t.Errorf("murmur3(%q, %d) = %#x, want %#x", tt.data, tt.seed, got, tt.want)
+		}
+	}
+}
+
+func TestIsHTTPCandidate(t *testing.T) {
+	tests := []struct {
+		result PortScanResult
+		want   bool
+	}{
+		{PortScanResult{Port: 8080, Protocol: ScanTCP, State: PortOpen}, true},
+		{PortScanResult{Port: 9999, Protocol: ScanTCP, State: PortOpen, Service: "HTTP-Proxy"}, true},
+		{PortScanResult{Port: 9999, Protocol: ScanTCP, State: PortOpen, Service: "SSL", TLS: &TLSInfo{}}, true},
+		{PortScanResult{Port: 22, Protocol: ScanTCP, State: PortOpen, Service: "SSH"}, false},
+		{PortScanResult{Port: 80, Protocol: ScanTCP, State: PortClosed}, false},
+	}
+	for _, tt := range tests {
+		if got := isHTTPCandidate(&tt.result); got != tt.want {
+			t.Errorf("isHTTPCandidate(%+v) = %v, want %v", tt.result, got, tt.want)
+		}
+	}
+}
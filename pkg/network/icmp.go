@@ -0,0 +1,360 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+
+	// pingInterval spaces the echo requests sent to one host
+	pingInterval = 200 * time.Millisecond
+)
+
+// PingResult holds the echo replies received from a host
+type PingResult struct {
+	IP       string          `json:"ip"`
+	Sent     int             `json:"sent"`
+	Received int             `json:"received"`
+	RTTs     []time.Duration `json:"rtts,omitempty"`
+	MinRTT   time.Duration   `json:"min_rtt"`
+	AvgRTT   time.Duration   `json:"avg_rtt"`
+	MaxRTT   time.Duration   `json:"max_rtt"`
+	TTL      int             `json:"ttl,omitempty"`
+}
+
+// Alive reports whether the host answered at least one echo request
+func (r *PingResult) Alive() bool {
+	return r.Received > 0
+}
+
+// echoReply is a reply matched to an outstanding echo request
+type echoReply struct {
+	received time.Time
+	ttl      int
+}
+
+// echoKey identifies an outstanding echo request
+type echoKey struct {
+	ip  string
+	seq int
+}
+
+// pingSocket is the ICMP socket of one address family
+type pingSocket struct {
+	conn       *icmp.PacketConn
+	privileged bool
+	protocol   int
+}
+
+// Pinger sends ICMP echo requests over one shared socket per address family
+// and matches the replies to their requests by identifier and sequence
+// number. Unprivileged datagram sockets are used where the system allows
+// them, and raw sockets otherwise.
+type Pinger struct {
+	v4      *pingSocket
+	v6      *pingSocket
+	id      int
+	mu      sync.Mutex
+	seq     int
+	pending map[echoKey]chan echoReply
+	done    chan struct{}
+	wg      sync.WaitGroup
+	limit   *RateLimiter
+}
+
+// NewPinger opens the ICMP sockets. It fails only when no IPv4 socket can
+// be opened; without IPv6 support, pinging an IPv6 address returns an error.
+func NewPinger() (*Pinger, error) {
+	p := &Pinger{
+		id:      os.Getpid() & 0xffff,
+		seq:     rand.Intn(0xffff),
+		pending: make(map[echoKey]chan echoReply),
+		done:    make(chan struct{}),
+	}
+
+	var err error
+	p.v4, err = listenICMP("udp4", "ip4:icmp", "0.0.0.0", protocolICMP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ICMP socket: %w", err)
+	}
+	if err := p.v4.conn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true); err != nil {
+		log.Printf("Warning: ICMP replies will not report TTL: %v", err)
+	}
+
+	if p.v6, err = listenICMP("udp6", "ip6:ipv6-icmp", "::", protocolIPv6ICMP); err == nil {
+		if err := p.v6.conn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true); err != nil {
+			log.Printf("Warning: ICMPv6 replies will not report hop limit: %v", err)
+		}
+	} else {
+		p.v6 = nil
+	}
+
+	for _, socket := range []*pingSocket{p.v4, p.v6} {
+		if socket != nil {
+			p.wg.Add(1)
+			go p.receive(socket)
+		}
+	}
+
+	return p, nil
+}
+
+// SetRateLimiter sets the limiter pacing the echo requests along with the
+// rest of a scan. nil leaves them paced by the ping interval alone.
+func (p *Pinger) SetRateLimiter(limit *RateLimiter) {
+	p.limit = limit
+}
+
+// listenICMP opens an unprivileged datagram ICMP socket, falling back to a
+// raw socket when datagram sockets are not permitted
+func listenICMP(dgram, raw, address string, protocol int) (*pingSocket, error) {
+	if conn, err := icmp.ListenPacket(dgram, address); err == nil {
+		return &pingSocket{conn: conn, protocol: protocol}, nil
+	}
+	conn, err := icmp.ListenPacket(raw, address)
+	if err != nil {
+		return nil, err
+	}
+	return &pingSocket{conn: conn, privileged: true, protocol: protocol}, nil
+}
+
+// Close closes the ICMP sockets and waits for the receive loops to stop
+func (p *Pinger) Close() error {
+	close(p.done)
+	var err error
+	for _, socket := range []*pingSocket{p.v4, p.v6} {
+		if socket != nil {
+			if closeErr := socket.conn.Close(); closeErr != nil {
+				err = closeErr
+			}
+		}
+	}
+	p.wg.Wait()
+	return err
+}
+
+// Ping sends count echo requests to an IP address, pingInterval apart, and
+// waits up to timeout for the reply to each. When ctx is done no further
+// requests are sent and the replies not yet received are counted as lost.
+func (p *Pinger) Ping(ctx context.Context, ip string, count int, timeout time.Duration) (*PingResult, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ip)
+	}
+	if count < 1 {
+		count = 1
+	}
+
+	socket := p.v4
+	if addr.To4() == nil {
+		socket = p.v6
+		if socket == nil {
+			return nil, fmt.Errorf("ICMPv6 is not available")
+		}
+	}
+
+	result := &PingResult{IP: ip}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for i := 0; i < count; i++ {
+		if i > 0 && !sleep(ctx, pingInterval) {
+			break
+		}
+		if !p.limit.Wait(ctx, ip) {
+			break
+		}
+
+		key, replies := p.register(addr.String())
+		sent := time.Now()
+		if err := p.send(socket, addr, key.seq); err != nil {
+			p.unregister(key)
+			if result.Sent == 0 {
+				return nil, fmt.Errorf("failed to send echo request to %s: %w", ip, err)
+			}
+			break
+		}
+		result.Sent++
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.unregister(key)
+
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+
+			select {
+			case reply := <-replies:
+				p.limit.Observe(reply.received.Sub(sent), false)
+				mu.Lock()
+				result.RTTs = append(result.RTTs, reply.received.Sub(sent))
+				if reply.ttl > 0 {
+					result.TTL = reply.ttl
+				}
+				mu.Unlock()
+			case <-timer.C:
+				p.limit.Observe(0, true)
+			case <-ctx.Done():
+			case <-p.done:
+			}
+		}()
+	}
+	wg.Wait()
+
+	result.Received = len(result.RTTs)
+	var total time.Duration
+	for i, rtt := range result.RTTs {
+		if i == 0 || rtt < result.MinRTT {
+			result.MinRTT = rtt
+		}
+		if rtt > result.MaxRTT {
+			result.MaxRTT = rtt
+		}
+		total += rtt
+	}
+	if result.Received > 0 {
+		result.AvgRTT = total / time.Duration(result.Received)
+	}
+
+	return result, nil
+}
+
+// register allocates a sequence number for an echo request to ip and
+// returns the channel its reply will be delivered on
+func (p *Pinger) register(ip string) (echoKey, chan echoReply) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		p.seq = (p.seq + 1) & 0xffff
+		key := echoKey{ip: ip, seq: p.seq}
+		if _, busy := p.pending[key]; !busy {
+			replies := make(chan echoReply, 1)
+			p.pending[key] = replies
+			return key, replies
+		}
+	}
+}
+
+func (p *Pinger) unregister(key echoKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, key)
+}
+
+// send writes an echo request to a socket
+func (p *Pinger) send(socket *pingSocket, addr net.IP, seq int) error {
+	var msgType icmp.Type = ipv4.ICMPTypeEcho
+	if socket.protocol == protocolIPv6ICMP {
+		msgType = ipv6.ICMPTypeEchoRequest
+	}
+
+	msg := icmp.Message{
+		Type: msgType,
+		Body: &icmp.Echo{ID: p.id, Seq: seq, Data: []byte("assetmanager-ping")},
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+
+	var dst net.Addr = &net.UDPAddr{IP: addr}
+	if socket.privileged {
+		dst = &net.IPAddr{IP: addr}
+	}
+	_, err = socket.conn.WriteTo(data, dst)
+	return err
+}
+
+// receive reads replies from a socket and hands each one to the request it
+// answers, until the socket is closed
+func (p *Pinger) receive(socket *pingSocket) {
+	defer p.wg.Done()
+
+	buf := make([]byte, 1500)
+	for {
+		var n, ttl int
+		var src net.Addr
+		var err error
+		if socket.protocol == protocolICMP {
+			var cm *ipv4.ControlMessage
+			n, cm, src, err = socket.conn.IPv4PacketConn().ReadFrom(buf)
+			if cm != nil {
+				ttl = cm.TTL
+			}
+		} else {
+			var cm *ipv6.ControlMessage
+			n, cm, src, err = socket.conn.IPv6PacketConn().ReadFrom(buf)
+			if cm != nil {
+				ttl = cm.HopLimit
+			}
+		}
+		received := time.Now()
+
+		if err != nil {
+			select {
+			case <-p.done:
+				return
+			default:
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			log.Printf("ICMP receive error: %v", err)
+			return
+		}
+
+		msg, err := icmp.ParseMessage(socket.protocol, buf[:n])
+		if err != nil {
+			continue
+		}
+		if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		if !ok {
+			continue
+		}
+		// The kernel rewrites the identifier of datagram sockets to the
+		// socket's port, and only delivers that socket's replies to it
+		if socket.privileged && echo.ID != p.id {
+			continue
+		}
+
+		key := echoKey{ip: addrIP(src), seq: echo.Seq}
+		p.mu.Lock()
+		replies, ok := p.pending[key]
+		p.mu.Unlock()
+		if ok {
+			select {
+			case replies <- echoReply{received: received, ttl: ttl}:
+			default: // duplicate reply
+			}
+		}
+	}
+}
+
+// addrIP returns the IP address of a socket address as a string
+func addrIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.String()
+	case *net.IPAddr:
+		return a.IP.String()
+	}
+	return ""
+}
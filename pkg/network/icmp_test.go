@@ -0,0 +1,72 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestPinger opens a pinger, skipping the test where the system allows
+// no ICMP socket
+func newTestPinger(t *testing.T) *Pinger {
+	t.Helper()
+	p, err := NewPinger()
+	if err != nil {
+		t.Skipf("no ICMP socket: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestPingLoopback(t *testing.T) {
+	p := newTestPinger(t)
+
+	result, err := p.Ping(context.Background(), "127.0.0.1", 3, time.Second)
+	if err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if !result.Alive() || result.Sent != 3 || result.Received != 3 {
+		t.Fatalf("ping of loopback = %+v, want 3 of 3 replies", result)
+	}
+	if result.MinRTT > result.AvgRTT || result.AvgRTT > result.MaxRTT {
+		t.Errorf("RTTs out of order: min %v, avg %v, max %v", result.MinRTT, result.AvgRTT, result.MaxRTT)
+	}
+}
+
+func TestPingCancelled(t *testing.T) {
+	p := newTestPinger(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := p.Ping(ctx, "127.0.0.1", 5, time.Second)
+	if err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if result.Sent > 1 {
+		t.Errorf("cancelled ping sent %d requests, want at most 1", result.Sent)
+	}
+}
+
+func TestPingInvalidAddress(t *testing.T) {
+	p := newTestPinger(t)
+	if _, err := p.Ping(context.Background(), "not-an-ip", 1, time.Second); err == nil {
+		t.Error("Ping of an invalid address succeeded")
+	}
+}
+
+func TestAddrIP(t *testing.T) {
+	tests := []struct {
+		addr net.Addr
+		want string
+	}{
+		{&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 7}, "10.0.0.1"},
+		{&net.IPAddr{IP: net.ParseIP("2001:db8::1")}, "2001:db8::1"},
+		{&net.TCPAddr{IP: net.ParseIP("10.0.0.1")}, ""},
+	}
+	for _, tt := range tests {
+		if got := addrIP(tt.addr); got != tt.want {
+			t.Errorf("addrIP(%v) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
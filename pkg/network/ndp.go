@@ -0,0 +1,293 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+// Scopes of the IPv6 addresses recorded on an asset
+const (
+	ScopeLinkLocal   = "link-local"
+	ScopeUniqueLocal = "unique-local"
+	ScopeGlobal      = "global"
+)
+
+// allNodes is the link-local all-nodes multicast group
+var allNodes = netip.MustParseAddr("ff02::1")
+
+// NDPResult is a host found on the local link by IPv6 neighbor discovery
+type NDPResult struct {
+	MAC                 string   `json:"mac"`
+	Vendor              string   `json:"vendor"`
+	LocallyAdministered bool     `json:"locally_administered,omitempty"`
+	Addresses           []string `json:"addresses"`
+}
+
+// NDPScanner finds IPv6 hosts on the local link. It sends an ICMPv6 echo
+// request to the all-nodes group from each of the interface's addresses,
+// so hosts answer from their link-local and global addresses alike, then
+// resolves the MAC address behind every responder with a Neighbor
+// Solicitation. Nothing is enumerated, so the size of the prefix does not
+// matter. The scan needs a raw ICMPv6 socket.
+type NDPScanner struct {
+	iface   *net.Interface
+	timeout time.Duration
+}
+
+// NewNDPScanner creates a neighbor discovery scanner for an interface
+func NewNDPScanner(interfaceName string, timeout time.Duration) (*NDPScanner, error) {
+	iface, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interface %s: %w", interfaceName, err)
+	}
+	return &NDPScanner{iface: iface, timeout: timeout}, nil
+}
+
+// IPv6Scope classifies an IPv6 address as link-local, unique-local or global
+func IPv6Scope(addr netip.Addr) string {
+	switch {
+	case addr.IsLinkLocalUnicast():
+		return ScopeLinkLocal
+	case addr.IsPrivate():
+		return ScopeUniqueLocal
+	}
+	return ScopeGlobal
+}
+
+// Scan discovers the IPv6 hosts on the link, one result per MAC address.
+// When ctx is done it stops listening, and the hosts whose MAC address is
+// already known are returned with ctx's error.
+func (s *NDPScanner) Scan(ctx context.Context) ([]NDPResult, error) {
+	sources, err := s.sourceAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ICMPv6 socket: %w", err)
+	}
+	defer conn.Close()
+
+	pc := conn.IPv6PacketConn()
+	// Neighbor discovery messages are only accepted with a hop limit of 255
+	pc.SetMulticastInterface(s.iface)
+	pc.SetMulticastHopLimit(255)
+	pc.SetHopLimit(255)
+	pc.SetMulticastLoopback(false)
+
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	filter.Accept(ipv6.ICMPTypeEchoReply)
+	filter.Accept(ipv6.ICMPTypeNeighborAdvertisement)
+	if err := pc.SetICMPFilter(&filter); err != nil {
+		return nil, fmt.Errorf("failed to set ICMPv6 filter: %w", err)
+	}
+
+	own := make(map[netip.Addr]bool)
+	for _, source := range sources {
+		own[source] = true
+	}
+
+	id := os.Getpid() & 0xffff
+	for seq, source := range sources {
+		echo := icmp.Message{
+			Type: ipv6.ICMPTypeEchoRequest,
+			Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("assetmanager")},
+		}
+		if err := s.send(pc, echo, source, allNodes); err != nil {
+			return nil, fmt.Errorf("failed to send echo request from %s: %w", source, err)
+		}
+	}
+
+	// Cancelling ctx ends the read in progress
+	stop := context.AfterFunc(ctx, func() { pc.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	responders := make(map[netip.Addr]bool)
+	macs := make(map[netip.Addr]net.HardwareAddr)
+	receive := func(deadline time.Time) {
+		buf := make([]byte, 1500)
+		for ctx.Err() == nil {
+			pc.SetReadDeadline(deadline)
+			n, _, peer, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			ipAddr, ok := peer.(*net.IPAddr)
+			if !ok {
+				continue
+			}
+			from, ok := netip.AddrFromSlice(ipAddr.IP)
+			if !ok || own[from] {
+				continue
+			}
+
+			msg, err := icmp.ParseMessage(protocolIPv6ICMP, buf[:n])
+			if err != nil {
+				continue
+			}
+			switch msg.Type {
+			case ipv6.ICMPTypeEchoReply:
+				if echo, ok := msg.Body.(*icmp.Echo); ok && echo.ID == id {
+					responders[from] = true
+				}
+			case ipv6.ICMPTypeNeighborAdvertisement:
+				if body, ok := msg.Body.(*icmp.RawBody); ok {
+					if target, mac, ok := parseNeighborAdvertisement(body.Data); ok {
+						macs[target] = mac
+						responders[target] = true
+					}
+				}
+			}
+		}
+	}
+	receive(time.Now().Add(s.timeout))
+	if ctx.Err() != nil {
+		return s.collect(responders, macs), ctx.Err()
+	}
+
+	// Resolve the MAC address of every responder; the solicitations are
+	// sent from the link-local address as neighbor discovery requires
+	for addr := range responders {
+		if _, ok := macs[addr]; ok {
+			continue
+		}
+		if err := s.send(pc, neighborSolicitation(addr, s.iface.HardwareAddr), sources[0], solicitedNode(addr)); err != nil {
+			return nil, fmt.Errorf("failed to send neighbor solicitation for %s: %w", addr, err)
+		}
+	}
+	receive(time.Now().Add(s.timeout))
+
+	return s.collect(responders, macs), ctx.Err()
+}
+
+// collect groups the responders by MAC address. Responders whose MAC
+// address could not be resolved are left out.
+func (s *NDPScanner) collect(responders map[netip.Addr]bool, macs map[netip.Addr]net.HardwareAddr) []NDPResult {
+	byMAC := make(map[string]*NDPResult)
+	var order []string
+
+	addrs := make([]netip.Addr, 0, len(responders))
+	for addr := range responders {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Less(addrs[j]) })
+
+	for _, addr := range addrs {
+		mac, ok := macs[addr]
+		if !ok {
+			continue
+		}
+		key := mac.String()
+		result, ok := byMAC[key]
+		if !ok {
+			result = &NDPResult{
+				MAC:                 key,
+				Vendor:              lookupVendor(mac),
+				LocallyAdministered: IsLocallyAdministered(mac),
+			}
+			byMAC[key] = result
+			order = append(order, key)
+		}
+		result.Addresses = append(result.Addresses, addr.WithZone("").String())
+	}
+
+	results := make([]NDPResult, 0, len(order))
+	for _, key := range order {
+		results = append(results, *byMAC[key])
+	}
+	return results
+}
+
+// sourceAddresses returns the interface's IPv6 addresses, link-local first
+func (s *NDPScanner) sourceAddresses() ([]netip.Addr, error) {
+	addrs, err := s.iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get addresses of %s: %w", s.iface.Name, err)
+	}
+
+	var linkLocal, other []netip.Addr
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() != nil {
+			continue
+		}
+		ip, ok := netip.AddrFromSlice(ipNet.IP)
+		if !ok {
+			continue
+		}
+		if ip.IsLinkLocalUnicast() {
+			linkLocal = append(linkLocal, ip)
+		} else if ip.IsGlobalUnicast() {
+			other = append(other, ip)
+		}
+	}
+	if len(linkLocal) == 0 {
+		return nil, fmt.Errorf("interface %s has no IPv6 link-local address", s.iface.Name)
+	}
+	return append(linkLocal, other...), nil
+}
+
+// send writes an ICMPv6 message from a source address of the interface.
+// The kernel fills in the checksum.
+func (s *NDPScanner) send(pc *ipv6.PacketConn, msg icmp.Message, source, destination netip.Addr) error {
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	cm := &ipv6.ControlMessage{Src: source.AsSlice(), IfIndex: s.iface.Index, HopLimit: 255}
+	_, err = pc.WriteTo(data, cm, &net.IPAddr{IP: destination.AsSlice(), Zone: s.iface.Name})
+	return err
+}
+
+// solicitedNode returns the solicited-node multicast group of an address
+func solicitedNode(addr netip.Addr) netip.Addr {
+	a := addr.As16()
+	group := [16]byte{0xff, 0x02, 11: 0x01, 12: 0xff, 13: a[13], 14: a[14], 15: a[15]}
+	return netip.AddrFrom16(group)
+}
+
+// neighborSolicitation builds a Neighbor Solicitation for target carrying
+// our link-layer address, so the answer can be sent straight back
+func neighborSolicitation(target netip.Addr, mac net.HardwareAddr) icmp.Message {
+	t := target.As16()
+	data := make([]byte, 4, 4+16+2+len(mac))
+	data = append(data, t[:]...)
+	if len(mac) == 6 {
+		data = append(data, 1, 1) // source link-layer address, 8 bytes
+		data = append(data, mac...)
+	}
+	return icmp.Message{Type: ipv6.ICMPTypeNeighborSolicitation, Body: &icmp.RawBody{Data: data}}
+}
+
+// parseNeighborAdvertisement returns the target address and target
+// link-layer address option of a Neighbor Advertisement body
+func parseNeighborAdvertisement(data []byte) (netip.Addr, net.HardwareAddr, bool) {
+	if len(data) < 20 {
+		return netip.Addr{}, nil, false
+	}
+	target := netip.AddrFrom16([16]byte(data[4:20]))
+
+	for options := data[20:]; len(options) >= 8; {
+		length := int(options[1]) * 8
+		if length == 0 || length > len(options) {
+			break
+		}
+		if options[0] == 2 && length >= 8 {
+			mac := make(net.HardwareAddr, 6)
+			copy(mac, options[2:8])
+			return target, mac, true
+		}
+		options = options[length:]
+	}
+	return netip.Addr{}, nil, false
+}
@@ -0,0 +1,139 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+func TestIPv6Scope(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"fe80::1", ScopeLinkLocal},
+		{"fd12:3456::1", ScopeUniqueLocal},
+		{"2001:db8::1", ScopeGlobal},
+	}
+	for _, tt := range tests {
+		if got := IPv6Scope(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("IPv6Scope(%s) = %s, want %s", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestSolicitedNode(t *testing.T) {
+	got := solicitedNode(netip.MustParseAddr("fe80::2aa:ff:fe28:9c5a"))
+	if want := netip.MustParseAddr("ff02::1:ff28:9c5a"); got != want {
+		t.Errorf("solicitedNode = %s, want %s", got, want)
+	}
+}
+
+func TestNeighborSolicitation(t *testing.T) {
+	target := netip.MustParseAddr("fe80::1")
+	mac, _ := net.ParseMAC("aa:bb:cc:00:00:01")
+
+	solicitation := neighborSolicitation(target, mac)
+	data, err := solicitation.Marshal(nil)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	msg, err := icmp.ParseMessage(ipv6.ICMPTypeNeighborSolicitation.Protocol(), data)
+	if err != nil || msg.Type != ipv6.ICMPTypeNeighborSolicitation {
+		t.Fatalf("ParseMessage = %+v, %v", msg, err)
+	}
+	body := msg.Body.(*icmp.RawBody).Data
+	if len(body) != 28 || !bytes.Equal(body[4:20], target.AsSlice()) {
+		t.Fatalf("body = %x, want the target address and one 8-byte option", body)
+	}
+	if body[20] != 1 || body[21] != 1 || !bytes.Equal(body[22:], mac) {
+		t.Errorf("option = %x, want our link-layer address", body[20:])
+	}
+}
+
+// neighborAdvertisement builds a Neighbor Advertisement body for target
+// with the given options
+func neighborAdvertisement(target string, options ...[]byte) []byte {
+	data := make([]byte, 4)
+	data[0] = 0x60 // solicited, override
+	data = append(data, netip.MustParseAddr(target).AsSlice()...)
+	for _, option := range options {
+		data = append(data, option...)
+	}
+	return data
+}
+
+func TestParseNeighborAdvertisement(t *testing.T) {
+	mac := []byte{0xaa, 0xbb, 0xcc, 0x00, 0x00, 0x01}
+	targetLinkLayer := append([]byte{2, 1}, mac...)
+	sourceLinkLayer := append([]byte{1, 1}, 0, 0, 0, 0, 0, 0)
+
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"target link-layer address", neighborAdvertisement("fe80::1", targetLinkLayer), true},
+		{"after another option", neighborAdvertisement("fe80::1", sourceLinkLayer, targetLinkLayer), true},
+		{"no options", neighborAdvertisement("fe80::1"), false},
+		{"only a source link-layer address", neighborAdvertisement("fe80::1", sourceLinkLayer), false},
+		{"zero-length option", neighborAdvertisement("fe80::1", []byte{1, 0, 0, 0, 0, 0, 0, 0}, targetLinkLayer), false},
+		{"truncated option", neighborAdvertisement("fe80::1", []byte{2, 2, 0, 0, 0, 0, 0, 0}), false},
+		{"truncated body", neighborAdvertisement("fe80::1")[:12], false},
+	}
+	for _, tt := range tests {
+		target, got, ok := parseNeighborAdvertisement(tt.data)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && (target != netip.MustParseAddr("fe80::1") || !bytes.Equal(got, mac)) {
+			t.Errorf("%s: = %s %s", tt.name, target, got)
+		}
+	}
+}
+
+func TestNDPCollect(t *testing.T) {
+	first, _ := net.ParseMAC("aa:bb:cc:00:00:01")
+	second, _ := net.ParseMAC("02:00:00:00:00:02")
+	linkLocal := netip.MustParseAddr("fe80::1").WithZone("eth0")
+	global := netip.MustParseAddr("2001:db8::1")
+	other := netip.MustParseAddr("fe80::2").WithZone("eth0")
+	unresolved := netip.MustParseAddr("fe80::3").WithZone("eth0")
+
+	results := (&NDPScanner{}).collect(
+		map[netip.Addr]bool{global: true, linkLocal: true, other: true, unresolved: true},
+		map[netip.Addr]net.HardwareAddr{linkLocal: first, global: first, other: second},
+	)
+	if len(results) != 2 {
+		t.Fatalf("collect returned %d hosts, want 2: %+v", len(results), results)
+	}
+	if results[0].MAC != first.String() || !slices.Equal(results[0].Addresses, []string{"2001:db8::1", "fe80::1"}) {
+		t.Errorf("first host = %+v, want both addresses without a zone", results[0])
+	}
+	if results[1].MAC != second.String() || !results[1].LocallyAdministered {
+		t.Errorf("second host = %+v, want a locally administered MAC", results[1])
+	}
+}
+
+func TestKeepsIPv4(t *testing.T) {
+	tests := []struct {
+		current, candidate string
+		keep               bool
+	}{
+		{"10.0.0.5", "fe80::1", true},
+		{"10.0.0.5", "10.0.0.6", false},
+		{"fe80::1", "10.0.0.5", false},
+		{"", "fe80::1", false},
+	}
+	for _, tt := range tests {
+		if got := KeepsIPv4(tt.current, tt.candidate); got != tt.keep {
+			t.Errorf("KeepsIPv4(%q, %q) = %v, want %v", tt.current, tt.candidate, got, tt.keep)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package network
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// OUIRegistry identifies an IEEE assignment block
+type OUIRegistry string
+
+const (
+	// RegistryMAL is a 24-bit MA-L (OUI) assignment
+	RegistryMAL OUIRegistry = "MA-L"
+	// RegistryMAM is a 28-bit MA-M assignment
+	RegistryMAM OUIRegistry = "MA-M"
+	// RegistryMAS is a 36-bit MA-S (OUI-36) assignment
+	RegistryMAS OUIRegistry = "MA-S"
+)
+
+// prefixLength returns the assignment length in hex digits
+func (r OUIRegistry) prefixLength() int {
+	switch r {
+	case RegistryMAL:
+		return 6
+	case RegistryMAM:
+		return 7
+	case RegistryMAS:
+		return 9
+	}
+	return 0
+}
+
+// OUIEntry is a single vendor assignment from the IEEE registry
+type OUIEntry struct {
+	Registry     OUIRegistry
+	Assignment   string // upper-case hex digits, 6, 7 or 9 long
+	Organization string
+}
+
+// VendorDatabase resolves MAC addresses to vendors by longest-prefix match
+// over the MA-S, MA-M and MA-L assignments
+type VendorDatabase struct {
+	prefixes map[int]map[string]string // hex digits -> assignment -> organization
+	entries  int
+}
+
+// embeddedOUIRegistry is the registry built into the binary. The copy in the
+// repository is a seed of common MA-L assignments only; cmd/ouiupdate
+// replaces it with the IEEE exports.
+//
+//go:embed oui_registry.csv
+var embeddedOUIRegistry []byte
+
+var (
+	vendorDB     *VendorDatabase
+	vendorDBOnce sync.Once
+)
+
+// defaultVendorDatabase returns the vendor database built from the embedded registry
+func defaultVendorDatabase() *VendorDatabase {
+	vendorDBOnce.Do(func() {
+		entries, err := ParseOUIRegistry(bytes.NewReader(embeddedOUIRegistry))
+		if err != nil {
+			log.Printf("Warning: failed to load embedded OUI registry: %v", err)
+		}
+		vendorDB = NewVendorDatabase(entries)
+	})
+	return vendorDB
+}
+
+// NewVendorDatabase builds a vendor database from registry entries
+func NewVendorDatabase(entries []OUIEntry) *VendorDatabase {
+	db := &VendorDatabase{prefixes: make(map[int]map[string]string)}
+	for _, entry := range entries {
+		length := len(entry.Assignment)
+		if db.prefixes[length] == nil {
+			db.prefixes[length] = make(map[string]string)
+		}
+		db.prefixes[length][entry.Assignment] = entry.Organization
+		db.entries++
+	}
+	return db
+}
+
+// Len returns the number of assignments in the database
+func (db *VendorDatabase) Len() int {
+	return db.entries
+}
+
+// Lookup returns the organization the MAC address is assigned to, preferring
+// the most specific (MA-S, then MA-M, then MA-L) assignment. Locally
+// administered addresses are never assigned and always return "".
+func (db *VendorDatabase) Lookup(mac net.HardwareAddr) string {
+	if len(mac) < 6 || IsLocallyAdministered(mac) {
+		return ""
+	}
+
+	digits := strings.ToUpper(strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac.String()))
+	for _, length := range []int{9, 7, 6} {
+		if vendor, ok := db.prefixes[length][digits[:length]]; ok {
+			return vendor
+		}
+	}
+	return ""
+}
+
+// IsLocallyAdministered reports whether the MAC address has the U/L bit set.
+// Such addresses are not vendor-assigned; they are typically randomized
+// privacy addresses or virtual interfaces.
+func IsLocallyAdministered(mac net.HardwareAddr) bool {
+	return len(mac) > 0 && mac[0]&0x02 != 0
+}
+
+// lookupVendor returns the vendor name for a MAC address
+func lookupVendor(mac net.HardwareAddr) string {
+	return defaultVendorDatabase().Lookup(mac)
+}
+
+// ParseOUIRegistry reads registry entries from an IEEE CSV export
+// (oui.csv, mam.csv or oui36.csv) or from the embedded registry file; both
+// start with "Registry,Assignment,Organization Name" columns.
+func ParseOUIRegistry(r io.Reader) ([]OUIEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	var entries []OUIEntry
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "Registry") {
+			continue // header
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("line %d: expected at least 3 columns, got %d", line, len(record))
+		}
+
+		registry := OUIRegistry(strings.TrimSpace(record[0]))
+		assignment := strings.ToUpper(strings.TrimSpace(record[1]))
+		if registry.prefixLength() == 0 {
+			return nil, fmt.Errorf("line %d: unknown registry %q", line, registry)
+		}
+		if len(assignment) != registry.prefixLength() || !isHex(assignment) {
+			return nil, fmt.Errorf("line %d: invalid %s assignment %q", line, registry, assignment)
+		}
+
+		entries = append(entries, OUIEntry{
+			Registry:     registry,
+			Assignment:   assignment,
+			Organization: strings.TrimSpace(record[2]),
+		})
+	}
+
+	return entries, nil
+}
+
+// ReadOUIRegistryFile reads registry entries from an IEEE CSV file
+func ReadOUIRegistryFile(filePath string) ([]OUIEntry, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	entries, err := ParseOUIRegistry(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+	return entries, nil
+}
+
+// WriteOUIRegistry writes registry entries in the embedded registry format,
+// sorted by registry and assignment
+func WriteOUIRegistry(w io.Writer, entries []OUIEntry) error {
+	sorted := make([]OUIEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Registry != sorted[j].Registry {
+			return sorted[i].Registry < sorted[j].Registry
+		}
+		return sorted[i].Assignment < sorted[j].Assignment
+	})
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"Registry", "Assignment", "Organization Name"}); err != nil {
+		return err
+	}
+	for _, entry := range sorted {
+		if err := writer.Write([]string{string(entry.Registry), entry.Assignment, entry.Organization}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789ABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseOUIRegistry(t *testing.T) {
+	input := `Registry,Assignment,Organization Name,Organization Address
+MA-L,00000c,"Cisco Systems, Inc",170 West Tasman Dr. San Jose CA US 95134
+MA-M,70B3D51,Example MA-M Co,Somewhere
+MA-S,70B3D5123,Example MA-S Co,Somewhere
+`
+	entries, err := ParseOUIRegistry(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOUIRegistry: %v", err)
+	}
+	want := []OUIEntry{
+		{RegistryMAL, "00000C", "Cisco Systems, Inc"},
+		{RegistryMAM, "70B3D51", "Example MA-M Co"},
+		{RegistryMAS, "70B3D5123", "Example MA-S Co"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %+v, want %+v", entries, want)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+}
+
+func TestParseOUIRegistryErrors(t *testing.T) {
+	tests := []string{
+		"MA-X,000000,Unknown registry\n",
+		"MA-L,00000,Too short\n",
+		"MA-M,000000,MA-L length\n",
+		"MA-L,00000G,Not hex\n",
+		"MA-L,000000\n",
+	}
+	for _, input := range tests {
+		if _, err := ParseOUIRegistry(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseOUIRegistry(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+func TestVendorDatabaseLookup(t *testing.T) {
+	db := NewVendorDatabase([]OUIEntry{
+		{RegistryMAL, "70B3D5", "IEEE Registration Authority"},
+		{RegistryMAM, "70B3D51", "MA-M Vendor"},
+		{RegistryMAS, "70B3D5123", "MA-S Vendor"},
+		{RegistryMAL, "B827EB", "Raspberry Pi Foundation"},
+	})
+	if db.Len() != 4 {
+		t.Errorf("Len = %d, want 4", db.Len())
+	}
+
+	tests := []struct {
+		mac  string
+		want string
+	}{
+		{"70:b3:d5:12:34:56", "MA-S Vendor"},
+		{"70:b3:d5:1f:00:00", "MA-M Vendor"},
+		{"70:b3:d5:20:00:00", "IEEE Registration Authority"},
+		{"b8-27-eb-01-02-03", "Raspberry Pi Foundation"},
+		{"00:11:22:33:44:55", ""},
+		{"02:00:00:00:00:01", ""}, // locally administered
+	}
+	for _, tt := range tests {
+		mac, err := net.ParseMAC(tt.mac)
+		if err != nil {
+			t.Fatalf("ParseMAC(%s): %v", tt.mac, err)
+		}
+		if got := db.Lookup(mac); got != tt.want {
+			t.Errorf("Lookup(%s) = %q, want %q", tt.mac, got, tt.want)
+		}
+	}
+}
+
+func TestWriteOUIRegistryRoundTrip(t *testing.T) {
+	entries := []OUIEntry{
+		{RegistryMAS, "70B3D5123", "MA-S Vendor"},
+		{RegistryMAL, "B827EB", "Raspberry Pi Foundation"},
+		{RegistryMAL, "00000C", "Cisco Systems, Inc"},
+	}
+	var buf bytes.Buffer
+	if err := WriteOUIRegistry(&buf, entries); err != nil {
+		t.Fatalf("WriteOUIRegistry: %v", err)
+	}
+	parsed, err := ParseOUIRegistry(&buf)
+	if err != nil {
+		t.Fatalf("ParseOUIRegistry: %v", err)
+	}
+	if len(parsed) != 3 || parsed[0].Assignment != "00000C" || parsed[2].Registry != RegistryMAS {
+		t.Errorf("round trip = %+v, want sorted by registry and assignment", parsed)
+	}
+}
+
+func TestEmbeddedRegistry(t *testing.T) {
+	entries, err := ParseOUIRegistry(bytes.NewReader(embeddedOUIRegistry))
+	if err != nil {
+		t.Fatalf("embedded registry: %v", err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("embedded registry is empty")
+	}
+	mac, _ := net.ParseMAC("00:00:0c:01:02:03")
+	if vendor := lookupVendor(mac); !strings.Contains(vendor, "Cisco") {
+		t.Errorf("lookupVendor(%s) = %q, want Cisco", mac, vendor)
+	}
+}
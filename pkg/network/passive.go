@@ -0,0 +1,263 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/mdlayher/arp"
+	"golang.org/x/net/ipv4"
+)
+
+// Sources of a passive sighting
+const (
+	SightingARP           = "arp"
+	SightingGratuitousARP = "gratuitous_arp"
+	SightingDHCP          = "dhcp"
+)
+
+// DHCP ports, message types and options the listener reads
+const (
+	dhcpServerPort = 67
+	dhcpClientPort = 68
+
+	dhcpOpRequest = 1
+	dhcpOpReply   = 2
+	dhcpAck       = 5
+
+	dhcpOptionPad         = 0
+	dhcpOptionHostname    = 12
+	dhcpOptionMessageType = 53
+	dhcpOptionEnd         = 255
+
+	// dhcpHeaderLength is the fixed BOOTP header followed by the magic cookie
+	dhcpHeaderLength = 240
+
+	// maxPendingHostnames bounds the hostnames remembered from client
+	// messages until the server acknowledges a lease
+	maxPendingHostnames = 1024
+)
+
+// dhcpMagicCookie starts the options field of a DHCP message
+var dhcpMagicCookie = []byte{99, 130, 83, 99}
+
+// Sighting is a device heard talking on the local network
+type Sighting struct {
+	IP                  string
+	MAC                 string
+	Vendor              string
+	LocallyAdministered bool
+	Hostname            string
+	Source              string
+	Time                time.Time
+}
+
+// PassiveListener watches ARP and DHCP traffic on an interface and reports
+// every device it hears, without sending anything. ARP requests, replies
+// and gratuitous announcements give a device's IP and MAC address; DHCP
+// acknowledgements give the address a device has just leased, with the
+// hostname the client asked with. Traffic between other hosts is only seen
+// when it is broadcast, as ARP requests and most DHCP messages are.
+type PassiveListener struct {
+	iface *net.Interface
+
+	mu        sync.Mutex
+	hostnames map[string]string
+}
+
+// NewPassiveListener creates a passive listener for an interface
+func NewPassiveListener(interfaceName string) (*PassiveListener, error) {
+	iface, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interface %s: %w", interfaceName, err)
+	}
+	return &PassiveListener{iface: iface, hostnames: make(map[string]string)}, nil
+}
+
+// Listen reports sightings to handle until ctx is done. handle is called
+// from several goroutines. The sockets need the same privileges as an ARP
+// sweep.
+func (l *PassiveListener) Listen(ctx context.Context, handle func(Sighting)) error {
+	arpClient, err := arp.Dial(l.iface)
+	if err != nil {
+		return fmt.Errorf("failed to create ARP listener: %w", err)
+	}
+	defer arpClient.Close()
+
+	conn, err := net.ListenPacket("ip4:udp", "0.0.0.0")
+	if err != nil {
+		return fmt.Errorf("failed to create DHCP listener: %w", err)
+	}
+	defer conn.Close()
+
+	dhcpConn := ipv4.NewPacketConn(conn)
+	if err := dhcpConn.SetControlMessage(ipv4.FlagInterface, true); err != nil {
+		return fmt.Errorf("failed to create DHCP listener: %w", err)
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- l.listenARP(arpClient, handle) }()
+	go func() { errs <- l.listenDHCP(dhcpConn, handle) }()
+
+	select {
+	case <-ctx.Done():
+		// Closing the sockets ends both readers
+		arpClient.Close()
+		conn.Close()
+		<-errs
+		<-errs
+		return nil
+	case err := <-errs:
+		arpClient.Close()
+		conn.Close()
+		<-errs
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+}
+
+// listenARP reports the sender of every ARP packet on the interface.
+// Probes, which are sent from 0.0.0.0 while a device checks an address is
+// free, and the packets this host sends are skipped.
+func (l *PassiveListener) listenARP(client *arp.Client, handle func(Sighting)) error {
+	for {
+		packet, _, err := client.Read()
+		if err != nil {
+			// Malformed packets fail to parse; only socket errors end the listener
+			if _, ok := err.(net.Error); !ok {
+				continue
+			}
+			return fmt.Errorf("ARP listener failed: %w", err)
+		}
+
+		ip := packet.SenderIP
+		if !ip.Is4() || ip.IsUnspecified() || bytes.Equal(packet.SenderHardwareAddr, l.iface.HardwareAddr) {
+			continue
+		}
+
+		source := SightingARP
+		if packet.SenderIP == packet.TargetIP {
+			source = SightingGratuitousARP
+		}
+		handle(l.sighting(ip, packet.SenderHardwareAddr, "", source))
+	}
+}
+
+// listenDHCP reports the devices that DHCP servers on the interface's
+// network acknowledge a lease for. Renewing clients already hold their
+// address and are reported from their own request.
+func (l *PassiveListener) listenDHCP(conn *ipv4.PacketConn, handle func(Sighting)) error {
+	buf := make([]byte, 1500)
+	for {
+		n, cm, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return fmt.Errorf("DHCP listener failed: %w", err)
+		}
+		if cm != nil && cm.IfIndex != l.iface.Index {
+			continue
+		}
+
+		if sighting, ok := l.parseDHCP(buf[:n]); ok {
+			handle(sighting)
+		}
+	}
+}
+
+// parseDHCP reads a DHCP message from a UDP datagram. Client messages only
+// carry an address when the client is renewing; their hostname is kept for
+// the server's acknowledgement.
+func (l *PassiveListener) parseDHCP(datagram []byte) (Sighting, bool) {
+	if len(datagram) < 8+dhcpHeaderLength {
+		return Sighting{}, false
+	}
+	dstPort := binary.BigEndian.Uint16(datagram[2:4])
+	if dstPort != dhcpServerPort && dstPort != dhcpClientPort {
+		return Sighting{}, false
+	}
+
+	msg := datagram[8:]
+	op, htype, hlen := msg[0], msg[1], msg[2]
+	if htype != 1 || hlen != 6 || !bytes.Equal(msg[236:240], dhcpMagicCookie) {
+		return Sighting{}, false
+	}
+	mac := net.HardwareAddr(bytes.Clone(msg[28:34]))
+	if bytes.Equal(mac, l.iface.HardwareAddr) {
+		return Sighting{}, false
+	}
+	ciaddr := netip.AddrFrom4([4]byte(msg[12:16]))
+	yiaddr := netip.AddrFrom4([4]byte(msg[16:20]))
+
+	var messageType byte
+	var hostname string
+	for options := msg[dhcpHeaderLength:]; len(options) > 0; {
+		code := options[0]
+		if code == dhcpOptionEnd {
+			break
+		}
+		if code == dhcpOptionPad {
+			options = options[1:]
+			continue
+		}
+		if len(options) < 2 || len(options) < 2+int(options[1]) {
+			break
+		}
+		value := options[2 : 2+int(options[1])]
+		switch code {
+		case dhcpOptionMessageType:
+			if len(value) == 1 {
+				messageType = value[0]
+			}
+		case dhcpOptionHostname:
+			hostname = string(value)
+		}
+		options = options[2+len(value):]
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case op == dhcpOpRequest:
+		if hostname != "" {
+			if len(l.hostnames) >= maxPendingHostnames {
+				clear(l.hostnames)
+			}
+			l.hostnames[mac.String()] = hostname
+		}
+		if ciaddr.IsUnspecified() {
+			return Sighting{}, false
+		}
+		return l.sighting(ciaddr, mac, hostname, SightingDHCP), true
+
+	case op == dhcpOpReply && messageType == dhcpAck:
+		if yiaddr.IsUnspecified() {
+			return Sighting{}, false
+		}
+		if hostname == "" {
+			hostname = l.hostnames[mac.String()]
+		}
+		delete(l.hostnames, mac.String())
+		return l.sighting(yiaddr, mac, hostname, SightingDHCP), true
+	}
+	return Sighting{}, false
+}
+
+// sighting describes a device heard at an address
+func (l *PassiveListener) sighting(ip netip.Addr, mac net.HardwareAddr, hostname, source string) Sighting {
+	return Sighting{
+		IP:                  ip.String(),
+		MAC:                 mac.String(),
+		Vendor:              lookupVendor(mac),
+		LocallyAdministered: IsLocallyAdministered(mac),
+		Hostname:            hostname,
+		Source:              source,
+		Time:                time.Now(),
+	}
+}
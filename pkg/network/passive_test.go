@@ -0,0 +1,103 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// dhcpDatagram builds the UDP datagram of a DHCP message from mac. Options
+// are given as code and value pairs.
+func dhcpDatagram(dstPort uint16, op byte, mac string, ciaddr, yiaddr string, options ...[]byte) []byte {
+	msg := make([]byte, dhcpHeaderLength)
+	msg[0], msg[1], msg[2] = op, 1, 6
+	copy(msg[12:16], net.ParseIP(ciaddr).To4())
+	copy(msg[16:20], net.ParseIP(yiaddr).To4())
+	hw, _ := net.ParseMAC(mac)
+	copy(msg[28:34], hw)
+	copy(msg[236:240], dhcpMagicCookie)
+	for _, option := range options {
+		msg = append(msg, option[0], byte(len(option)-1))
+		msg = append(msg, option[1:]...)
+	}
+	msg = append(msg, dhcpOptionPad, dhcpOptionEnd)
+
+	datagram := make([]byte, 8, 8+len(msg))
+	binary.BigEndian.PutUint16(datagram[2:4], dstPort)
+	return append(datagram, msg...)
+}
+
+func messageType(t byte) []byte { return []byte{dhcpOptionMessageType, t} }
+
+func hostnameOption(name string) []byte {
+	return append([]byte{dhcpOptionHostname}, name...)
+}
+
+func TestParseDHCP(t *testing.T) {
+	ours, _ := net.ParseMAC("aa:bb:cc:ff:ff:ff")
+	l := &PassiveListener{iface: &net.Interface{HardwareAddr: ours}, hostnames: make(map[string]string)}
+	const client = "aa:bb:cc:00:00:01"
+
+	tests := []struct {
+		name     string
+		datagram []byte
+		ip       string
+		hostname string
+	}{
+		{"discover", dhcpDatagram(dhcpServerPort, dhcpOpRequest, client, "0.0.0.0", "0.0.0.0", messageType(1), hostnameOption("printer")), "", ""},
+		{"ack takes the hostname of the discover", dhcpDatagram(dhcpClientPort, dhcpOpReply, client, "0.0.0.0", "10.0.0.20", messageType(dhcpAck)), "10.0.0.20", "printer"},
+		{"second ack has no hostname left", dhcpDatagram(dhcpClientPort, dhcpOpReply, client, "0.0.0.0", "10.0.0.20", messageType(dhcpAck)), "10.0.0.20", ""},
+		{"renewing request", dhcpDatagram(dhcpServerPort, dhcpOpRequest, client, "10.0.0.20", "0.0.0.0", messageType(3), hostnameOption("printer")), "10.0.0.20", "printer"},
+		{"nak", dhcpDatagram(dhcpClientPort, dhcpOpReply, client, "0.0.0.0", "10.0.0.20", messageType(6)), "", ""},
+		{"ack without an address", dhcpDatagram(dhcpClientPort, dhcpOpReply, client, "0.0.0.0", "0.0.0.0", messageType(dhcpAck)), "", ""},
+		{"our own request", dhcpDatagram(dhcpServerPort, dhcpOpRequest, ours.String(), "10.0.0.2", "0.0.0.0", messageType(3)), "", ""},
+		{"other port", dhcpDatagram(53, dhcpOpRequest, client, "10.0.0.20", "0.0.0.0", messageType(3)), "", ""},
+		{"truncated", dhcpDatagram(dhcpServerPort, dhcpOpRequest, client, "10.0.0.20", "0.0.0.0")[:200], "", ""},
+		{"truncated option", dhcpDatagram(dhcpServerPort, dhcpOpRequest, client, "10.0.0.20", "0.0.0.0", []byte{dhcpOptionHostname})[:8+dhcpHeaderLength+2], "10.0.0.20", ""},
+	}
+	for _, tt := range tests {
+		sighting, ok := l.parseDHCP(tt.datagram)
+		if ok != (tt.ip != "") {
+			t.Errorf("%s: sighting reported = %v, want %v", tt.name, ok, tt.ip != "")
+			continue
+		}
+		if ok && (sighting.IP != tt.ip || sighting.MAC != client || sighting.Hostname != tt.hostname || sighting.Source != SightingDHCP) {
+			t.Errorf("%s: sighting = %+v, want %s with hostname %q", tt.name, sighting, tt.ip, tt.hostname)
+		}
+	}
+
+	// A bad magic cookie is not DHCP
+	datagram := dhcpDatagram(dhcpServerPort, dhcpOpRequest, client, "10.0.0.20", "0.0.0.0", messageType(3))
+	datagram[8+236] = 0
+	if _, ok := l.parseDHCP(datagram); ok {
+		t.Error("message without the magic cookie parsed")
+	}
+}
+
+func TestHeardAssets(t *testing.T) {
+	d := &AssetDiscovery{assets: make(map[string]*Asset), heard: make(map[string]bool)}
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	d.recordSighting(Sighting{IP: "10.0.0.20", MAC: "aa:bb:cc:00:00:01", Source: SightingARP, Time: at})
+	d.recordSighting(Sighting{IP: "10.0.0.21", MAC: "aa:bb:cc:00:00:01", Hostname: "printer", Source: SightingDHCP, Time: at.Add(time.Minute)})
+	d.recordSighting(Sighting{IP: "10.0.0.30", MAC: "aa:bb:cc:00:00:02", Source: SightingGratuitousARP, Time: at})
+
+	heard, err := d.HeardAssets(context.Background(), false, nil)
+	if err != nil {
+		t.Fatalf("HeardAssets: %v", err)
+	}
+	if len(heard) != 2 {
+		t.Fatalf("heard %d assets, want 2: %+v", len(heard), heard)
+	}
+	for _, asset := range heard {
+		if asset.MAC == "aa:bb:cc:00:00:01" && (asset.IP != "10.0.0.21" || asset.Hostname != "printer" || len(asset.Addresses) != 2) {
+			t.Errorf("moved device = %+v, want its latest address and hostname", asset)
+		}
+	}
+
+	if again, _ := d.HeardAssets(context.Background(), false, nil); len(again) != 0 {
+		t.Errorf("heard %d assets again without new sightings", len(again))
+	}
+}
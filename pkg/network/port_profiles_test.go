@@ -0,0 +1,92 @@
+package network
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNamedPortProfiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		tcp, udp int
+	}{
+		{ProfileTop100, 100, 18},
+		{ProfileTop1000, 1000, 100},
+		{ProfileAll, 65535, 100},
+	}
+	for _, tt := range tests {
+		profile, err := ParsePortProfile(tt.name)
+		if err != nil {
+			t.Fatalf("ParsePortProfile(%q): %v", tt.name, err)
+		}
+		if len(profile.TCP) != tt.tcp || len(profile.UDP) != tt.udp {
+			t.Errorf("%s has %d TCP and %d UDP ports, want %d and %d", tt.name, len(profile.TCP), len(profile.UDP), tt.tcp, tt.udp)
+		}
+	}
+
+	top100, _ := ParsePortProfile(ProfileTop100)
+	top1000, _ := ParsePortProfile(ProfileTop1000)
+	for _, port := range top100.TCP {
+		if _, found := slices.BinarySearch(top1000.TCP, port); !found {
+			t.Errorf("top-100 port %d missing from top-1000", port)
+		}
+	}
+
+	if DefaultPortProfile().Name != ProfileDefault || len(GetCommonTCPPorts()) == 0 {
+		t.Error("default profile is empty")
+	}
+	if empty, _ := ParsePortProfile(" "); !slices.Equal(empty.TCP, GetCommonTCPPorts()) {
+		t.Error("empty specification is not the default profile")
+	}
+}
+
+func TestParsePortProfile(t *testing.T) {
+	tests := []struct {
+		spec     string
+		tcp, udp []int
+	}{
+		{"22,80", []int{22, 80}, []int{}},
+		{"8000-8002, 443", []int{443, 8000, 8001, 8002}, []int{}},
+		{"T:22,80,U:53,161", []int{22, 80}, []int{53, 161}},
+		{"u:53,t:22", []int{22}, []int{53}},
+		{"80,80,79-80", []int{79, 80}, []int{}},
+	}
+	for _, tt := range tests {
+		profile, err := ParsePortProfile(tt.spec)
+		if err != nil {
+			t.Errorf("ParsePortProfile(%q): %v", tt.spec, err)
+			continue
+		}
+		if !slices.Equal(profile.TCP, tt.tcp) || !slices.Equal(profile.UDP, tt.udp) {
+			t.Errorf("ParsePortProfile(%q) = TCP %v UDP %v, want %v %v", tt.spec, profile.TCP, profile.UDP, tt.tcp, tt.udp)
+		}
+	}
+
+	mixed, err := ParsePortProfile("TOP-100,U:5353")
+	if err != nil {
+		t.Fatalf("ParsePortProfile: %v", err)
+	}
+	if len(mixed.TCP) != 100 || !slices.Contains(mixed.UDP, 5353) {
+		t.Errorf("top-100 with an extra UDP port = %d TCP, UDP %v", len(mixed.TCP), mixed.UDP)
+	}
+
+	for _, spec := range []string{"0", "65536", "100-90", "ssh", "80-", "U:top-ten"} {
+		if _, err := ParsePortProfile(spec); err == nil {
+			t.Errorf("ParsePortProfile(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestPortSpecRoundTrip(t *testing.T) {
+	spec := PortSpec([]int{22, 80}, []int{53})
+	if spec != "T:22,80,U:53" {
+		t.Fatalf("PortSpec = %q", spec)
+	}
+	profile, err := ParsePortProfile(spec)
+	if err != nil {
+		t.Fatalf("ParsePortProfile(%q): %v", spec, err)
+	}
+	if !slices.Equal(profile.TCP, []int{22, 80}) || !slices.Equal(profile.UDP, []int{53}) {
+		t.Errorf("round trip = TCP %v UDP %v", profile.TCP, profile.UDP)
+	}
+}
@@ -1,7 +1,9 @@
 package network
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net"
 	"os"
 	"strconv"
@@ -32,14 +34,31 @@ const (
 	ScanUDP ScanType = "udp"
 )
 
+// TCPScanMode selects how TCP ports are probed
+type TCPScanMode string
+
+const (
+	// TCPScanConnect completes a full connection to each port and reads a banner
+	TCPScanConnect TCPScanMode = "connect"
+	// TCPScanSYN sends a raw SYN and never completes the handshake
+	TCPScanSYN TCPScanMode = "syn"
+)
+
 // PortScanResult represents the result of a port scan
 type PortScanResult struct {
-	IP       string    `json:"ip"`
-	Port     int       `json:"port"`
-	Protocol ScanType  `json:"protocol"`
-	State    PortState `json:"state"`
-	Service  string    `json:"service"`
-	Banner   string    `json:"banner,omitempty"`
+	IP         string      `json:"ip"`
+	Port       int         `json:"port"`
+	Protocol   ScanType    `json:"protocol"`
+	State      PortState   `json:"state"`
+	Service    string      `json:"service"`
+	Product    string      `json:"product,omitempty"`
+	Version    string      `json:"version,omitempty"`
+	CPE        string      `json:"cpe,omitempty"`
+	Banner     string      `json:"banner,omitempty"`
+	TLS        *TLSInfo    `json:"tls,omitempty"`
+	HTTP       *HTTPInfo   `json:"http,omitempty"`
+	Screenshot *Screenshot `json:"screenshot,omitempty"`
+	Findings   []Finding   `json:"findings,omitempty"`
 }
 
 // PortScanner represents a port scanner
@@ -47,6 +66,14 @@ type PortScanner struct {
 	timeout     time.Duration
 	concurrency int
 	retries     int
+	syn         *SYNScanner
+	detector    *ServiceDetector
+	probeTCP    bool
+	tls         *TLSInspector
+	http        *HTTPEnumerator
+	screenshots *Screenshotter
+	audit       *CredentialAuditor
+	limit       *RateLimiter
 }
 
 // NewPortScanner creates a new port scanner
@@ -61,23 +88,159 @@ func NewPortScanner(timeout time.Duration, concurrency int, retries int) *PortSc
 		timeout:     timeout,
 		concurrency: concurrency,
 		retries:     retries,
+		detector:    NewServiceDetector(timeout),
+	}
+}
+
+// SetServiceDetection turns active service probing of open TCP ports on or
+// off. Banners and UDP responses are matched against the service probes
+// either way; probing sends further payloads to ports whose banner does
+// not identify the service, and to every open port found by a SYN scan.
+func (s *PortScanner) SetServiceDetection(enabled bool) {
+	s.probeTCP = enabled
+}
+
+// SetTCPScanMode selects connect or SYN scanning for TCP ports and returns
+// the mode in effect. SYN scanning needs raw socket privileges; without
+// them the scanner falls back to connect scanning.
+func (s *PortScanner) SetTCPScanMode(mode TCPScanMode) TCPScanMode {
+	if s.syn != nil {
+		s.syn.Close()
+		s.syn = nil
+	}
+	if mode != TCPScanSYN {
+		return TCPScanConnect
+	}
+
+	syn, err := NewSYNScanner()
+	if err != nil {
+		log.Printf("SYN scan unavailable, falling back to connect scan: %v", err)
+		return TCPScanConnect
+	}
+	s.syn = syn
+	return TCPScanSYN
+}
+
+// Close releases the raw socket of the SYN scan engine, if one is open
+func (s *PortScanner) Close() error {
+	if s.syn == nil {
+		return nil
 	}
+	err := s.syn.Close()
+	s.syn = nil
+	return err
+}
+
+// SetTLSInspector sets the inspector recording the TLS configuration of
+// open ports that speak TLS. nil turns TLS inspection off.
+func (s *PortScanner) SetTLSInspector(inspector *TLSInspector) {
+	s.tls = inspector
+}
+
+// SetHTTPEnumerator sets the enumerator recording what the web services
+// among open ports serve. nil turns HTTP enumeration off.
+func (s *PortScanner) SetHTTPEnumerator(enumerator *HTTPEnumerator) {
+	s.http = enumerator
+}
+
+// SetScreenshotter sets the screenshotter capturing the web services found
+// by HTTP enumeration. nil turns screenshots off.
+func (s *PortScanner) SetScreenshotter(screenshotter *Screenshotter) {
+	s.screenshots = screenshotter
+}
+
+// SetCredentialAuditor sets the auditor trying default credentials against
+// the open ports of allow-listed hosts. nil turns the audit off.
+func (s *PortScanner) SetCredentialAuditor(auditor *CredentialAuditor) {
+	s.audit = auditor
 }
 
-// ScanPort scans a single port
-func (s *PortScanner) ScanPort(ip string, port int, protocol ScanType) (*PortScanResult, error) {
+// SetRateLimiter sets the limiter pacing the scanner's probes, shared with
+// the rest of the scan. nil leaves them unpaced.
+func (s *PortScanner) SetRateLimiter(limit *RateLimiter) {
+	s.limit = limit
+}
+
+// ScanPort scans a single port. A probe cut short by ctx reports the port
+// as filtered.
+func (s *PortScanner) ScanPort(ctx context.Context, ip string, port int, protocol ScanType) (*PortScanResult, error) {
 	switch protocol {
 	case ScanTCP:
-		return s.scanTCPPort(ip, port)
+		return s.scanTCPPort(ctx, ip, port)
 	case ScanUDP:
-		return s.scanUDPPort(ip, port)
+		return s.scanUDPPort(ctx, ip, port)
 	default:
 		return nil, fmt.Errorf("unsupported protocol: %s", protocol)
 	}
 }
 
-// scanTCPPort scans a single TCP port using SYN packets
-func (s *PortScanner) scanTCPPort(ip string, port int) (*PortScanResult, error) {
+// scanTCPPort scans a single TCP port with a SYN probe when the SYN engine
+// is enabled, and a full connect otherwise
+func (s *PortScanner) scanTCPPort(ctx context.Context, ip string, port int) (*PortScanResult, error) {
+	if results, ok := s.synScan(ctx, ip, []int{port}); ok {
+		return &results[0], nil
+	}
+	return s.scanTCPConnect(ctx, ip, port)
+}
+
+// synScan probes TCP ports with the SYN engine. It reports false when the
+// engine is disabled or cannot scan the host, so the caller can fall back
+// to connect scanning. Ports left unanswered when ctx is done are reported
+// as filtered.
+func (s *PortScanner) synScan(ctx context.Context, ip string, ports []int) ([]PortScanResult, bool) {
+	if s.syn == nil || len(ports) == 0 {
+		return nil, false
+	}
+
+	states, err := s.syn.ScanPorts(ctx, ip, ports, s.timeout, s.retries, s.limit)
+	if err != nil && !Interrupted(err) {
+		return nil, false
+	}
+
+	results := make([]PortScanResult, 0, len(ports))
+	for _, port := range ports {
+		results = append(results, PortScanResult{
+			IP:       ip,
+			Port:     port,
+			Protocol: ScanTCP,
+			State:    states[port],
+			Service:  lookupService(port, ScanTCP),
+		})
+	}
+
+	if s.probeTCP {
+		s.probeOpenPorts(ctx, results)
+	}
+	return results, true
+}
+
+// probeOpenPorts identifies the services on the open ports of SYN scan
+// results, up to the scanner's concurrency at a time, until ctx is done
+func (s *PortScanner) probeOpenPorts(ctx context.Context, results []PortScanResult) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.concurrency)
+
+	for i := range results {
+		if results[i].State != PortOpen {
+			continue
+		}
+		if !acquire(ctx, sem) {
+			break
+		}
+		wg.Add(1)
+
+		go func(result *PortScanResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result.applyFingerprint(s.detector.ProbeTCP(ctx, result.IP, result.Port, nil))
+		}(&results[i])
+	}
+	wg.Wait()
+}
+
+// scanTCPConnect scans a single TCP port by completing a connection, and
+// reads the service banner of open ports
+func (s *PortScanner) scanTCPConnect(ctx context.Context, ip string, port int) (*PortScanResult, error) {
 	target := net.JoinHostPort(ip, strconv.Itoa(port))
 
 	// Create a TCP dialer with the appropriate timeout
@@ -85,11 +248,6 @@ func (s *PortScanner) scanTCPPort(ip string, port int) (*PortScanResult, error)
 		Timeout: s.timeout,
 	}
 
-	// This is a half-open SYN scan using the system's TCP stack
-	// For a true SYN scan without completing the handshake, we would need raw sockets
-	// which requires admin privileges and is more complex
-	conn, err := dialer.Dial("tcp", target)
-
 	result := &PortScanResult{
 		IP:       ip,
 		Port:     port,
@@ -97,16 +255,25 @@ func (s *PortScanner) scanTCPPort(ip string, port int) (*PortScanResult, error)
 		Service:  lookupService(port, ScanTCP),
 	}
 
+	if !s.limit.Wait(ctx, ip) {
+		result.State = PortFiltered
+		return result, nil
+	}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", target)
+
 	if err != nil {
 		// Check the error type to determine if the port is closed or filtered
 		if opErr, ok := err.(*net.OpError); ok {
 			// Connection refused means the port is closed but reachable
 			if syscallErr, ok := opErr.Err.(*os.SyscallError); ok && syscallErr.Err == syscall.ECONNREFUSED {
+				s.limit.Observe(time.Since(start), false)
 				result.State = PortClosed
 				return result, nil
 			}
 			// Timeout means the port is likely filtered
 			if opErr.Timeout() {
+				s.limit.Observe(0, true)
 				result.State = PortFiltered
 				return result, nil
 			}
@@ -116,6 +283,7 @@ func (s *PortScanner) scanTCPPort(ip string, port int) (*PortScanResult, error)
 	}
 
 	// If we get here, the port is open
+	s.limit.Observe(time.Since(start), false)
 	result.State = PortOpen
 
 	// Try to get a banner
@@ -131,17 +299,30 @@ func (s *PortScanner) scanTCPPort(ip string, port int) (*PortScanResult, error)
 		if err == nil && n > 0 {
 			result.Banner = string(banner[:n])
 		}
+
+		// Identify the service from the banner, probing further if allowed
+		if s.probeTCP {
+			conn.Close()
+			result.applyFingerprint(s.detector.ProbeTCP(ctx, ip, port, banner[:n]))
+		} else {
+			result.applyFingerprint(s.detector.MatchBanner(banner[:n]))
+		}
 	}
 
 	return result, nil
 }
 
 // scanUDPPort scans a single UDP port
-func (s *PortScanner) scanUDPPort(ip string, port int) (*PortScanResult, error) {
+func (s *PortScanner) scanUDPPort(ctx context.Context, ip string, port int) (*PortScanResult, error) {
 	target := net.JoinHostPort(ip, strconv.Itoa(port))
 
 	// Create a UDP connection
-	conn, err := net.DialTimeout("udp", target, s.timeout)
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "udp", target)
+	if err == nil && !s.limit.Wait(ctx, ip) {
+		conn.Close()
+		err = ctx.Err()
+	}
 	if err != nil {
 		return &PortScanResult{
 			IP:       ip,
@@ -152,8 +333,13 @@ func (s *PortScanner) scanUDPPort(ip string, port int) (*PortScanResult, error)
 		}, nil
 	}
 
-	// Try to send something
-	_, err = conn.Write([]byte("Hello\n"))
+	// Send the service probe for the port, or something generic
+	payload := s.detector.UDPPayload(port)
+	if payload == nil {
+		payload = []byte("Hello\n")
+	}
+	start := time.Now()
+	_, err = conn.Write(payload)
 	if err != nil {
 		conn.Close()
 		return &PortScanResult{
@@ -184,20 +370,29 @@ func (s *PortScanner) scanUDPPort(ip string, port int) (*PortScanResult, error)
 
 	// If we got a response, the port is open
 	if err == nil && n > 0 {
+		s.limit.Observe(time.Since(start), false)
 		result.State = PortOpen
 		result.Banner = string(buf[:n])
+		result.applyFingerprint(s.detector.MatchUDP(port, buf[:n]))
 		return result, nil
 	}
 
 	// For UDP, no response could mean the port is open but not responding,
 	// or it could be filtered. It's harder to tell with UDP.
 	// We'll mark it as filtered for now.
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		s.limit.Observe(0, true)
+	} else {
+		s.limit.Observe(time.Since(start), false)
+	}
 	result.State = PortFiltered
 	return result, nil
 }
 
-// ScanPorts scans multiple ports on a single host
-func (s *PortScanner) ScanPorts(ip string, startPort, endPort int, protocol ScanType) ([]PortScanResult, error) {
+// ScanPorts scans multiple ports on a single host. When ctx is done no
+// further ports are probed, and the ports scanned so far are returned with
+// ctx's error.
+func (s *PortScanner) ScanPorts(ctx context.Context, ip string, startPort, endPort int, protocol ScanType) ([]PortScanResult, error) {
 	var results []PortScanResult
 	var wg sync.WaitGroup
 	resultChan := make(chan PortScanResult, endPort-startPort+1)
@@ -207,14 +402,16 @@ func (s *PortScanner) ScanPorts(ip string, startPort, endPort int, protocol Scan
 
 	// Scan ports
 	for port := startPort; port <= endPort; port++ {
+		if !acquire(ctx, sem) {
+			break
+		}
 		wg.Add(1)
-		sem <- struct{}{} // Acquire semaphore
 
 		go func(p int) {
 			defer wg.Done()
 			defer func() { <-sem }() // Release semaphore
 
-			result, err := s.ScanPort(ip, p, protocol)
+			result, err := s.ScanPort(ctx, ip, p, protocol)
 			if err == nil && result != nil {
 				resultChan <- *result
 			}
@@ -232,52 +429,66 @@ func (s *PortScanner) ScanPorts(ip string, startPort, endPort int, protocol Scan
 		results = append(results, result)
 	}
 
-	return results, nil
+	return results, ctx.Err()
 }
 
-// ScanHost scans common ports on a host
-func (s *PortScanner) ScanHost(ip string) ([]PortScanResult, error) {
-	// Common TCP ports to scan
-	commonTCPPorts := []int{
-		20, 21, 22, 23, 25, 53, 80, 110, 111, 135, 139, 143, 443,
-		445, 993, 995, 1723, 3306, 3389, 5900, 8080,
-	}
-
-	// Common UDP ports to scan
-	commonUDPPorts := []int{
-		53, 67, 68, 69, 123, 135, 137, 138, 161, 162, 445, 514, 631, 1900,
-	}
+// ScanHost scans the ports of the default profile on a host
+func (s *PortScanner) ScanHost(ctx context.Context, ip string) ([]PortScanResult, error) {
+	profile := DefaultPortProfile()
+	return s.ScanHostPorts(ctx, ip, profile.TCP, profile.UDP)
+}
 
+// ScanHostPorts scans the given TCP and UDP ports on a host, up to the
+// scanner's concurrency at a time. When ctx is done no further ports or
+// inspections are started, and what was found so far is returned with
+// ctx's error.
+func (s *PortScanner) ScanHostPorts(ctx context.Context, ip string, tcpPorts, udpPorts []int) ([]PortScanResult, error) {
 	var results []PortScanResult
 	var wg sync.WaitGroup
-	resultChan := make(chan PortScanResult, len(commonTCPPorts)+len(commonUDPPorts))
+	resultChan := make(chan PortScanResult, s.concurrency)
 
-	// Scan TCP ports
-	wg.Add(1)
-	go func() {
+	// Create a semaphore to limit concurrency
+	sem := make(chan struct{}, s.concurrency)
+
+	scan := func(ports []int, protocol ScanType) {
 		defer wg.Done()
 
-		for _, port := range commonTCPPorts {
-			result, err := s.ScanPort(ip, port, ScanTCP)
-			if err == nil && result != nil {
-				resultChan <- *result
+		for _, port := range ports {
+			if !acquire(ctx, sem) {
+				return
 			}
+			wg.Add(1)
+
+			go func(p int) {
+				defer wg.Done()
+				defer func() { <-sem }() // Release semaphore
+
+				result, err := s.ScanPort(ctx, ip, p, protocol)
+				if err == nil && result != nil {
+					resultChan <- *result
+				}
+			}(port)
 		}
-	}()
+	}
 
-	// Scan UDP ports
+	// Scan TCP ports
 	wg.Add(1)
 	go func() {
-		defer wg.Done()
-
-		for _, port := range commonUDPPorts {
-			result, err := s.ScanPort(ip, port, ScanUDP)
-			if err == nil && result != nil {
-				resultChan <- *result
+		// The SYN engine probes every port of the host at once
+		if synResults, ok := s.synScan(ctx, ip, tcpPorts); ok {
+			defer wg.Done()
+			for _, result := range synResults {
+				resultChan <- result
 			}
+			return
 		}
+		scan(tcpPorts, ScanTCP)
 	}()
 
+	// Scan UDP ports
+	wg.Add(1)
+	go scan(udpPorts, ScanUDP)
+
 	// Wait for all scans to complete
 	go func() {
 		wg.Wait()
@@ -289,7 +500,20 @@ func (s *PortScanner) ScanHost(ip string) ([]PortScanResult, error) {
 		results = append(results, result)
 	}
 
-	return results, nil
+	if s.tls != nil {
+		s.tls.inspectTLS(ctx, results, "", s.concurrency)
+	}
+	if s.http != nil {
+		s.http.enumerateHTTP(ctx, results, "", s.concurrency)
+	}
+	if s.http != nil && s.screenshots != nil {
+		s.screenshots.captureScreenshots(ctx, results, "")
+	}
+	if s.audit != nil {
+		s.audit.auditPorts(ctx, ip, results, "")
+	}
+
+	return results, ctx.Err()
 }
 
 // lookupService returns the service name for a port
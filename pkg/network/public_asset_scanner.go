@@ -1,12 +1,9 @@
 package network
 
 import (
-	"bufio"
-	"fmt"
+	"context"
 	"log"
 	"net"
-	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,19 +19,21 @@ type PublicAsset struct {
 	FirstSeen    time.Time        `json:"first_seen"`
 	PingReply    bool             `json:"ping_reply"`
 	ResponseTime time.Duration    `json:"response_time"`
+	TTL          int              `json:"ttl,omitempty"`
 }
 
 // ToAsset converts a PublicAsset to an Asset for integration with the main asset management system
 func (pa *PublicAsset) ToAsset() Asset {
 	return Asset{
-		IP:          pa.IP,
-		MAC:         "", // Public assets don't have MAC addresses
-		Vendor:      "", // Public assets don't have vendor info
-		OpenPorts:   pa.OpenPorts,
-		LastSeen:    pa.LastSeen,
-		FirstSeen:   pa.FirstSeen,
-		Hostname:    pa.Hostname,
-		ARPResponse: false, // Public assets don't respond to ARP
+		IP:           pa.IP,
+		MAC:          "", // Public assets don't have MAC addresses
+		Vendor:       "", // Public assets don't have vendor info
+		OpenPorts:    pa.OpenPorts,
+		LastSeen:     pa.LastSeen,
+		FirstSeen:    pa.FirstSeen,
+		Hostname:     pa.Hostname,
+		ARPResponse:  false, // Public assets don't respond to ARP
+		ResponseTime: pa.ResponseTime,
 	}
 }
 
@@ -43,6 +42,16 @@ type PublicAssetScanner struct {
 	timeout     time.Duration
 	concurrency int
 	retries     int
+	pingCount   int
+	skipPing    bool
+	detector    *ServiceDetector
+	probeTCP    bool
+	tls         *TLSInspector
+	http        *HTTPEnumerator
+	screenshots *Screenshotter
+	audit       *CredentialAuditor
+	limit       *RateLimiter
+	random      bool
 	mu          sync.RWMutex
 	assets      map[string]*PublicAsset
 }
@@ -59,53 +68,163 @@ func NewPublicAssetScanner(timeout time.Duration, concurrency int, retries int)
 		timeout:     timeout,
 		concurrency: concurrency,
 		retries:     retries,
+		pingCount:   1,
+		detector:    NewServiceDetector(timeout),
 		assets:      make(map[string]*PublicAsset),
 	}
 }
 
-// ScanPublicAssets performs comprehensive scanning on public targets
-func (p *PublicAssetScanner) ScanPublicAssets(targets []string, tcpPorts []int, udpPorts []int) ([]*PublicAsset, error) {
-	log.Printf("Starting public asset scan on %d targets", len(targets))
+// SetRateLimiter sets the limiter pacing the echo requests, connection
+// attempts and UDP probes of a scan. nil leaves them unpaced.
+func (p *PublicAssetScanner) SetRateLimiter(limit *RateLimiter) {
+	p.limit = limit
+}
+
+// SetRandomOrder scans the targets in random order rather than address
+// order
+func (p *PublicAssetScanner) SetRandomOrder(random bool) {
+	p.random = random
+}
+
+// SetServiceDetection turns active service probing of open TCP ports on or
+// off. Banners and UDP responses are matched against the service probes
+// either way.
+func (p *PublicAssetScanner) SetServiceDetection(enabled bool) {
+	p.probeTCP = enabled
+}
+
+// SetPingCount sets the number of echo requests sent to each target. A
+// target is live when any of them is answered.
+func (p *PublicAssetScanner) SetPingCount(count int) {
+	if count > 0 {
+		p.pingCount = count
+	}
+}
+
+// SetPingEnabled turns ping host discovery on or off. With ping off, every
+// target is treated as live and port scanned.
+func (p *PublicAssetScanner) SetPingEnabled(enabled bool) {
+	p.skipPing = !enabled
+}
+
+// SetTLSInspector sets the inspector recording the TLS configuration of
+// open ports that speak TLS. nil turns TLS inspection off.
+func (p *PublicAssetScanner) SetTLSInspector(inspector *TLSInspector) {
+	p.tls = inspector
+}
+
+// SetHTTPEnumerator sets the enumerator recording what the web services
+// among open ports serve. nil turns HTTP enumeration off.
+func (p *PublicAssetScanner) SetHTTPEnumerator(enumerator *HTTPEnumerator) {
+	p.http = enumerator
+}
+
+// SetScreenshotter sets the screenshotter capturing the web services found
+// by HTTP enumeration. nil turns screenshots off.
+func (p *PublicAssetScanner) SetScreenshotter(screenshotter *Screenshotter) {
+	p.screenshots = screenshotter
+}
+
+// SetCredentialAuditor sets the auditor trying default credentials against
+// the open ports of allow-listed targets. nil turns the audit off.
+func (p *PublicAssetScanner) SetCredentialAuditor(auditor *CredentialAuditor) {
+	p.audit = auditor
+}
+
+// ScanPublicAssets performs comprehensive scanning on public targets. The
+// targets are streamed to the workers, so a set of any size can be
+// scanned. Without ping a target counts as live once one of its ports
+// answers. When ctx is done the phase in progress starts no further
+// probes, the phases after it are skipped, and the hosts found so far are
+// returned with ctx's error.
+func (p *PublicAssetScanner) ScanPublicAssets(ctx context.Context, targets *AddressSet, tcpPorts []int, udpPorts []int) ([]*PublicAsset, error) {
+	log.Printf("Starting public asset scan on %d targets", targets.Size())
 
 	// Step 1: Ping scan to identify live hosts
 	log.Println("Phase 1: Host discovery (Ping scan)")
-	liveHosts := p.performPingScan(targets)
-	log.Printf("Found %d live hosts", len(liveHosts))
-
-	if len(liveHosts) == 0 {
-		return []*PublicAsset{}, nil
+	liveHosts, pinged := p.performPingScan(ctx, targets)
+	if pinged {
+		log.Printf("Found %d live hosts", len(liveHosts))
+		if len(liveHosts) == 0 {
+			return []*PublicAsset{}, ctx.Err()
+		}
 	}
 
-	// Extract live host IPs
+	// The later phases probe the live hosts, or every target when none
+	// were pinged
 	var liveIPs []string
 	for ip := range liveHosts {
 		liveIPs = append(liveIPs, ip)
 	}
+	live := func() <-chan string {
+		if !pinged {
+			return targets.Stream(ctx, p.random)
+		}
+		ips := make(chan string)
+		go func() {
+			defer close(ips)
+			for _, ip := range liveIPs {
+				select {
+				case ips <- ip:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return ips
+	}
+	addPorts := func(results map[string][]PortScanResult) {
+		for ip, ports := range results {
+			asset, exists := liveHosts[ip]
+			if !exists {
+				asset = p.newPublicAsset(ip)
+				liveHosts[ip] = asset
+			}
+			asset.OpenPorts = append(asset.OpenPorts, ports...)
+		}
+	}
 
 	// Step 2: TCP SYN scan on live hosts
-	if len(tcpPorts) > 0 {
+	if len(tcpPorts) > 0 && ctx.Err() == nil {
 		log.Printf("Phase 2: TCP SYN scan on %d ports", len(tcpPorts))
-		tcpResults := p.performTCPScan(liveIPs, tcpPorts)
-
-		// Add TCP results to assets
-		for ip, ports := range tcpResults {
-			if asset, exists := liveHosts[ip]; exists {
-				asset.OpenPorts = append(asset.OpenPorts, ports...)
+		addPorts(p.performTCPScan(ctx, live(), tcpPorts))
+
+		// Certificates are checked against, and web pages requested by,
+		// the name the host resolves to. The browser taking screenshots is
+		// kept running across all hosts.
+		shots := p.http != nil && p.screenshots != nil
+		if shots {
+			if err := p.screenshots.acquire(); err != nil {
+				log.Printf("Screenshots skipped: %v", err)
+				shots = false
+			}
+		}
+		for _, asset := range liveHosts {
+			if ctx.Err() != nil {
+				break
+			}
+			if p.tls != nil {
+				p.tls.inspectTLS(ctx, asset.OpenPorts, asset.Hostname, p.concurrency)
+			}
+			if p.http != nil {
+				p.http.enumerateHTTP(ctx, asset.OpenPorts, asset.Hostname, p.concurrency)
+			}
+			if shots {
+				p.screenshots.captureScreenshots(ctx, asset.OpenPorts, asset.Hostname)
+			}
+			if p.audit != nil {
+				p.audit.auditPorts(ctx, asset.IP, asset.OpenPorts, asset.Hostname)
 			}
 		}
+		if shots {
+			p.screenshots.release()
+		}
 	}
 
 	// Step 3: UDP scan on live hosts
-	if len(udpPorts) > 0 {
+	if len(udpPorts) > 0 && ctx.Err() == nil {
 		log.Printf("Phase 3: UDP scan on %d ports", len(udpPorts))
-		udpResults := p.performUDPScan(liveIPs, udpPorts)
-
-		// Add UDP results to assets
-		for ip, ports := range udpResults {
-			if asset, exists := liveHosts[ip]; exists {
-				asset.OpenPorts = append(asset.OpenPorts, ports...)
-			}
-		}
+		addPorts(p.performUDPScan(ctx, live(), udpPorts, !pinged))
 	}
 
 	// Convert map to slice
@@ -114,18 +233,36 @@ func (p *PublicAssetScanner) ScanPublicAssets(targets []string, tcpPorts []int,
 		results = append(results, asset)
 	}
 
+	if err := ctx.Err(); err != nil {
+		log.Printf("Scan stopped early (%v). Found %d live hosts with %d total open ports",
+			err, len(results), p.countTotalOpenPorts(results))
+		return results, err
+	}
 	log.Printf("Scan completed. Found %d live hosts with %d total open ports",
 		len(results), p.countTotalOpenPorts(results))
 
 	return results, nil
 }
 
-// performPingScan performs ICMP ping scan on targets
-func (p *PublicAssetScanner) performPingScan(targets []string) map[string]*PublicAsset {
+// performPingScan performs ICMP ping scan on targets until ctx is done. It
+// reports false when ping is disabled, or no ICMP socket can be opened, and
+// no target was pinged.
+func (p *PublicAssetScanner) performPingScan(ctx context.Context, targets *AddressSet) (map[string]*PublicAsset, bool) {
 	results := make(map[string]*PublicAsset)
-	var mu sync.Mutex
+	if p.skipPing {
+		return results, false
+	}
+
+	pinger, err := NewPinger()
+	if err != nil {
+		log.Printf("Warning: ICMP is unavailable (%v); probing the ports of all %d targets", err, targets.Size())
+		return results, false
+	}
+	defer pinger.Close()
+	pinger.SetRateLimiter(p.limit)
 
-	jobs := make(chan string, len(targets))
+	var mu sync.Mutex
+	jobs := targets.Stream(ctx, p.random)
 	var wg sync.WaitGroup
 
 	// Start workers
@@ -134,8 +271,10 @@ func (p *PublicAssetScanner) performPingScan(targets []string) map[string]*Publi
 		go func() {
 			defer wg.Done()
 			for target := range jobs {
-				asset := p.pingHost(target)
-				if asset != nil {
+				if ctx.Err() != nil {
+					continue
+				}
+				if asset := p.pingHost(ctx, pinger, target); asset != nil {
 					mu.Lock()
 					results[target] = asset
 					mu.Unlock()
@@ -144,44 +283,39 @@ func (p *PublicAssetScanner) performPingScan(targets []string) map[string]*Publi
 		}()
 	}
 
-	// Send jobs
-	for _, target := range targets {
-		jobs <- target
-	}
-	close(jobs)
 	wg.Wait()
 
-	return results
+	return results, true
 }
 
 // pingHost performs ping on a single host
-func (p *PublicAssetScanner) pingHost(target string) *PublicAsset {
-	start := time.Now()
-
-	// Use system ping command for reliability
-	cmd := exec.Command("ping", "-c", "1", "-W", strconv.Itoa(int(p.timeout.Seconds())), target)
-	err := cmd.Run()
-
-	if err == nil {
-		duration := time.Since(start)
-		hostname := p.resolveHostname(target)
-
-		return &PublicAsset{
-			IP:           target,
-			Hostname:     hostname,
-			PingReply:    true,
-			ResponseTime: duration,
-			FirstSeen:    time.Now(),
-			LastSeen:     time.Now(),
-			OpenPorts:    make([]PortScanResult, 0),
-		}
+func (p *PublicAssetScanner) pingHost(ctx context.Context, pinger *Pinger, target string) *PublicAsset {
+	result, err := pinger.Ping(ctx, target, p.pingCount, p.timeout)
+	if err != nil || !result.Alive() {
+		return nil
 	}
 
-	return nil
+	asset := p.newPublicAsset(target)
+	asset.PingReply = true
+	asset.ResponseTime = result.AvgRTT
+	asset.TTL = result.TTL
+	return asset
+}
+
+// newPublicAsset creates the record of a live target
+func (p *PublicAssetScanner) newPublicAsset(target string) *PublicAsset {
+	now := time.Now()
+	return &PublicAsset{
+		IP:        target,
+		Hostname:  p.resolveHostname(target),
+		FirstSeen: now,
+		LastSeen:  now,
+		OpenPorts: make([]PortScanResult, 0),
+	}
 }
 
-// performTCPScan performs TCP SYN scan on targets and ports
-func (p *PublicAssetScanner) performTCPScan(targets []string, ports []int) map[string][]PortScanResult {
+// performTCPScan performs TCP SYN scan on targets and ports until ctx is done
+func (p *PublicAssetScanner) performTCPScan(ctx context.Context, targets <-chan string, ports []int) map[string][]PortScanResult {
 	results := make(map[string][]PortScanResult)
 	var mu sync.Mutex
 
@@ -190,7 +324,7 @@ func (p *PublicAssetScanner) performTCPScan(targets []string, ports []int) map[s
 		port   int
 	}
 
-	jobs := make(chan scanJob, len(targets)*len(ports))
+	jobs := make(chan scanJob, p.concurrency)
 	var wg sync.WaitGroup
 
 	// Start workers
@@ -199,7 +333,10 @@ func (p *PublicAssetScanner) performTCPScan(targets []string, ports []int) map[s
 		go func() {
 			defer wg.Done()
 			for job := range jobs {
-				result := p.scanTCPPort(job.target, job.port)
+				if ctx.Err() != nil {
+					continue
+				}
+				result := p.scanTCPPort(ctx, job.target, job.port)
 				if result != nil && result.State == PortOpen {
 					mu.Lock()
 					results[job.target] = append(results[job.target], *result)
@@ -210,9 +347,14 @@ func (p *PublicAssetScanner) performTCPScan(targets []string, ports []int) map[s
 	}
 
 	// Send jobs
-	for _, target := range targets {
+send:
+	for target := range targets {
 		for _, port := range ports {
-			jobs <- scanJob{target: target, port: port}
+			select {
+			case jobs <- scanJob{target: target, port: port}:
+			case <-ctx.Done():
+				break send
+			}
 		}
 	}
 	close(jobs)
@@ -222,30 +364,52 @@ func (p *PublicAssetScanner) performTCPScan(targets []string, ports []int) map[s
 }
 
 // scanTCPPort scans a single TCP port
-func (p *PublicAssetScanner) scanTCPPort(target string, port int) *PortScanResult {
-	address := fmt.Sprintf("%s:%d", target, port)
+func (p *PublicAssetScanner) scanTCPPort(ctx context.Context, target string, port int) *PortScanResult {
+	address := net.JoinHostPort(target, strconv.Itoa(port))
 
-	conn, err := net.DialTimeout("tcp", address, p.timeout)
+	if !p.limit.Wait(ctx, target) {
+		return nil
+	}
+	start := time.Now()
+	dialer := net.Dialer{Timeout: p.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
 	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			p.limit.Observe(0, true)
+		} else {
+			p.limit.Observe(time.Since(start), false)
+		}
 		return nil // Only return open ports for public scans
 	}
 	defer conn.Close()
+	p.limit.Observe(time.Since(start), false)
 
 	// Try to grab banner
 	banner := p.grabBanner(conn)
 
-	return &PortScanResult{
+	result := &PortScanResult{
 		IP:       target,
 		Port:     port,
 		Protocol: ScanTCP,
 		State:    PortOpen,
 		Service:  lookupService(port, ScanTCP),
-		Banner:   banner,
+		Banner:   cleanBanner(banner),
+	}
+
+	// Identify the service from the banner, probing further if allowed
+	if p.probeTCP {
+		conn.Close()
+		result.applyFingerprint(p.detector.ProbeTCP(ctx, target, port, banner))
+	} else {
+		result.applyFingerprint(p.detector.MatchBanner(banner))
 	}
+	return result
 }
 
-// performUDPScan performs UDP scan on targets and ports
-func (p *PublicAssetScanner) performUDPScan(targets []string, ports []int) map[string][]PortScanResult {
+// performUDPScan performs UDP scan on targets and ports until ctx is done.
+// openOnly keeps only the ports that answered, for targets not known to be
+// live.
+func (p *PublicAssetScanner) performUDPScan(ctx context.Context, targets <-chan string, ports []int, openOnly bool) map[string][]PortScanResult {
 	results := make(map[string][]PortScanResult)
 	var mu sync.Mutex
 
@@ -254,7 +418,7 @@ func (p *PublicAssetScanner) performUDPScan(targets []string, ports []int) map[s
 		port   int
 	}
 
-	jobs := make(chan scanJob, len(targets)*len(ports))
+	jobs := make(chan scanJob, p.concurrency)
 	var wg sync.WaitGroup
 
 	// Start workers
@@ -263,8 +427,11 @@ func (p *PublicAssetScanner) performUDPScan(targets []string, ports []int) map[s
 		go func() {
 			defer wg.Done()
 			for job := range jobs {
-				result := p.scanUDPPort(job.target, job.port)
-				if result != nil {
+				if ctx.Err() != nil {
+					continue
+				}
+				result := p.scanUDPPort(ctx, job.target, job.port)
+				if result != nil && (!openOnly || result.State == PortOpen) {
 					mu.Lock()
 					results[job.target] = append(results[job.target], *result)
 					mu.Unlock()
@@ -274,9 +441,14 @@ func (p *PublicAssetScanner) performUDPScan(targets []string, ports []int) map[s
 	}
 
 	// Send jobs
-	for _, target := range targets {
+send:
+	for target := range targets {
 		for _, port := range ports {
-			jobs <- scanJob{target: target, port: port}
+			select {
+			case jobs <- scanJob{target: target, port: port}:
+			case <-ctx.Done():
+				break send
+			}
 		}
 	}
 	close(jobs)
@@ -286,18 +458,22 @@ func (p *PublicAssetScanner) performUDPScan(targets []string, ports []int) map[s
 }
 
 // scanUDPPort scans a single UDP port
-func (p *PublicAssetScanner) scanUDPPort(target string, port int) *PortScanResult {
-	address := fmt.Sprintf("%s:%d", target, port)
+func (p *PublicAssetScanner) scanUDPPort(ctx context.Context, target string, port int) *PortScanResult {
+	address := net.JoinHostPort(target, strconv.Itoa(port))
 
-	conn, err := net.DialTimeout("udp", address, p.timeout)
+	dialer := net.Dialer{Timeout: p.timeout}
+	conn, err := dialer.DialContext(ctx, "udp", address)
 	if err != nil {
 		return nil
 	}
 	defer conn.Close()
 
-	// Send UDP probe packet
-	probe := p.getUDPProbe(port)
-	_, err = conn.Write(probe)
+	// Send the service probe for the port, or an empty packet
+	if !p.limit.Wait(ctx, target) {
+		return nil
+	}
+	start := time.Now()
+	_, err = conn.Write(p.detector.UDPPayload(port))
 	if err != nil {
 		return nil
 	}
@@ -309,53 +485,42 @@ func (p *PublicAssetScanner) scanUDPPort(target string, port int) *PortScanResul
 	buffer := make([]byte, 1024)
 	n, err := conn.Read(buffer)
 
-	state := PortFiltered
-	banner := ""
-
-	if err == nil && n > 0 {
-		// Got a response, port is likely open
-		state = PortOpen
-		banner = strings.TrimSpace(string(buffer[:n]))
-	}
-
 	// Return result even if filtered for UDP (helps with inventory)
-	return &PortScanResult{
+	result := &PortScanResult{
 		IP:       target,
 		Port:     port,
 		Protocol: ScanUDP,
-		State:    state,
+		State:    PortFiltered,
 		Service:  lookupService(port, ScanUDP),
-		Banner:   banner,
 	}
-}
 
-// getUDPProbe returns appropriate UDP probe packet for specific ports
-func (p *PublicAssetScanner) getUDPProbe(port int) []byte {
-	switch port {
-	case 53: // DNS
-		// DNS query for google.com
-		return []byte{0x00, 0x01, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x03, 0x63, 0x6f, 0x6d, 0x00, 0x00, 0x01, 0x00, 0x01}
-	case 123: // NTP
-		// NTP request packet
-		return []byte{0x1b, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
-	case 161: // SNMP
-		// SNMP GetRequest
-		return []byte{0x30, 0x29, 0x02, 0x01, 0x00, 0x04, 0x06, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0xa0, 0x1c, 0x02, 0x01, 0x01, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00, 0x30, 0x11, 0x30, 0x0f, 0x06, 0x0b, 0x2b, 0x06, 0x01, 0x04, 0x01, 0x94, 0x78, 0x01, 0x02, 0x07, 0x03, 0x05, 0x00}
-	default:
-		// Generic empty UDP packet
-		return []byte{}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		p.limit.Observe(0, true)
+	} else {
+		p.limit.Observe(time.Since(start), false)
 	}
+
+	if err == nil && n > 0 {
+		// Got a response, port is likely open
+		result.State = PortOpen
+		result.Banner = strings.TrimSpace(string(buffer[:n]))
+		result.applyFingerprint(p.detector.MatchUDP(port, buffer[:n]))
+	}
+
+	return result
 }
 
 // grabBanner attempts to grab service banner
-func (p *PublicAssetScanner) grabBanner(conn net.Conn) string {
+func (p *PublicAssetScanner) grabBanner(conn net.Conn) []byte {
 	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
 	buffer := make([]byte, 1024)
-	n, err := conn.Read(buffer)
-	if err != nil {
-		return ""
-	}
-	banner := strings.TrimSpace(string(buffer[:n]))
+	n, _ := conn.Read(buffer)
+	return buffer[:n]
+}
+
+// cleanBanner shortens a banner for the port record
+func cleanBanner(raw []byte) string {
+	banner := strings.TrimSpace(string(raw))
 	// Clean up binary data
 	if len(banner) > 100 {
 		banner = banner[:100] + "..."
@@ -385,21 +550,6 @@ func (p *PublicAssetScanner) countTotalOpenPorts(assets []*PublicAsset) int {
 	return total
 }
 
-// GetCommonTCPPorts returns commonly scanned TCP ports
-func GetCommonTCPPorts() []int {
-	return []int{
-		21, 22, 23, 25, 53, 80, 110, 111, 135, 139, 143, 443, 445, 993, 995,
-		1723, 3306, 3389, 5432, 5900, 8080, 8443, 8888,
-	}
-}
-
-// GetCommonUDPPorts returns commonly scanned UDP ports
-func GetCommonUDPPorts() []int {
-	return []int{
-		53, 67, 68, 69, 123, 135, 137, 138, 161, 162, 445, 500, 514, 520, 1194, 4500,
-	}
-}
-
 // Close cleans up the scanner resources
 func (p *PublicAssetScanner) Close() error {
 	p.mu.Lock()
@@ -407,83 +557,3 @@ func (p *PublicAssetScanner) Close() error {
 	p.assets = nil
 	return nil
 }
-
-// ReadTargetsFromFile reads IP addresses and CIDR ranges from a file for public scanning
-func ReadTargetsFromFile(filePath string) ([]string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
-	}
-	defer file.Close()
-
-	var targets []string
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		// Check if it's a CIDR range
-		if strings.Contains(line, "/") {
-			// Parse CIDR and expand to individual IPs
-			ips, err := expandCIDRToIPs(line)
-			if err != nil {
-				log.Printf("Warning: Failed to parse CIDR %s: %v", line, err)
-				continue
-			}
-			targets = append(targets, ips...)
-		} else {
-			// Single IP address
-			if net.ParseIP(line) != nil {
-				targets = append(targets, line)
-			} else {
-				log.Printf("Warning: Invalid IP address: %s", line)
-			}
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file %s: %w", filePath, err)
-	}
-
-	return targets, nil
-}
-
-// expandCIDRToIPs expands a CIDR range to individual IP addresses
-func expandCIDRToIPs(cidr string) ([]string, error) {
-	ip, ipnet, err := net.ParseCIDR(cidr)
-	if err != nil {
-		return nil, err
-	}
-
-	var ips []string
-	for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); incIP(ip) {
-		ips = append(ips, ip.String())
-	}
-
-	// Remove network and broadcast addresses for IPv4
-	if len(ips) > 2 {
-		ips = ips[1 : len(ips)-1]
-	}
-
-	// Limit to reasonable number of IPs for public scanning
-	if len(ips) > 254 {
-		log.Printf("Warning: CIDR %s expands to %d IPs, limiting to first 254", cidr, len(ips))
-		ips = ips[:254]
-	}
-
-	return ips, nil
-}
-
-// incIP increments an IP address
-func incIP(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
-		}
-	}
-}
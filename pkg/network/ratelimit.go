@@ -0,0 +1,226 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timing bounds how fast a scan sends its probes: ARP requests, SYN
+// segments, connection attempts, UDP probes and echo requests alike
+type Timing struct {
+	// Rate is the probes a second across the whole scan; 0 is unlimited
+	Rate float64
+	// SubnetRate is the probes a second to any one /24, or /64 for IPv6;
+	// 0 is unlimited
+	SubnetRate float64
+	// Adaptive slows the scan down when probes start timing out and
+	// speeds it back up, never past the rates above, when replies are fast
+	Adaptive bool
+}
+
+// timingTemplates are the timing presets, from paranoid (T0) to insane
+// (T5), after nmap's
+var timingTemplates = []struct {
+	name   string
+	timing Timing
+}{
+	{"paranoid", Timing{Rate: 1.0 / 300, SubnetRate: 1.0 / 300, Adaptive: true}},
+	{"sneaky", Timing{Rate: 1.0 / 15, SubnetRate: 1.0 / 15, Adaptive: true}},
+	{"polite", Timing{Rate: 2.5, SubnetRate: 2.5, Adaptive: true}},
+	{"normal", Timing{Rate: 1000, Adaptive: true}},
+	{"aggressive", Timing{Rate: 5000, Adaptive: true}},
+	{"insane", Timing{Rate: 20000}},
+}
+
+// TimingTemplate returns a timing preset, named T0 to T5, 0 to 5 or
+// paranoid, sneaky, polite, normal, aggressive or insane
+func TimingTemplate(name string) (Timing, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	for i, template := range timingTemplates {
+		if key == template.name || key == fmt.Sprintf("t%d", i) || key == fmt.Sprintf("%d", i) {
+			return template.timing, nil
+		}
+	}
+	return Timing{}, fmt.Errorf("unknown timing template %q", name)
+}
+
+const (
+	// adaptWindow is how many probe outcomes the adaptive timing judges at once
+	adaptWindow = 32
+
+	// timeoutSpike is how far the share of probes timing out in a window
+	// may rise above its recent average before the scan slows down
+	timeoutSpike = 0.2
+
+	// minRateScale is the furthest the adaptive timing slows a scan down
+	minRateScale = 1.0 / 32
+)
+
+// RateLimiter is a token bucket shared by every probe of a scan, with a
+// bucket of its own for each target subnet. A nil RateLimiter lets every
+// probe through at once.
+type RateLimiter struct {
+	timing Timing
+
+	mu      sync.Mutex
+	global  tokenBucket
+	subnets map[netip.Prefix]*tokenBucket
+
+	// scale is the share of the configured rates currently allowed
+	scale    float64
+	window   probeWindow
+	primed   bool
+	baseline float64       // average share of probes timing out
+	fastest  time.Duration // lowest average reply time of a window
+}
+
+// tokenBucket holds the tokens of one bucket. Tokens go negative when
+// probes are waiting for them.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// probeWindow counts the outcomes of the probes since the last adjustment
+type probeWindow struct {
+	probes   int
+	timeouts int
+	rtt      time.Duration
+}
+
+// NewRateLimiter creates a rate limiter for one scan
+func NewRateLimiter(timing Timing) *RateLimiter {
+	return &RateLimiter{
+		timing:  timing,
+		subnets: make(map[netip.Prefix]*tokenBucket),
+		scale:   1,
+	}
+}
+
+// Wait blocks until a probe to target may be sent. It returns false when
+// ctx is done first.
+func (l *RateLimiter) Wait(ctx context.Context, target string) bool {
+	if l == nil {
+		return ctx.Err() == nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	var wait time.Duration
+	if l.timing.Rate > 0 {
+		wait = l.global.take(now, l.timing.Rate*l.scale)
+	}
+	if l.timing.SubnetRate > 0 {
+		if subnet, ok := targetSubnet(target); ok {
+			bucket, ok := l.subnets[subnet]
+			if !ok {
+				bucket = &tokenBucket{}
+				l.subnets[subnet] = bucket
+			}
+			wait = max(wait, bucket.take(now, l.timing.SubnetRate*l.scale))
+		}
+	}
+	l.mu.Unlock()
+
+	return sleep(ctx, wait)
+}
+
+// take takes a token from a bucket filling at rate, and returns how long
+// until the token is due. A bucket holds up to 50ms worth of tokens, and
+// at least one.
+func (b *tokenBucket) take(now time.Time, rate float64) time.Duration {
+	burst := max(rate/20, 1)
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// targetSubnet returns the /24 or /64 a target address belongs to
+func targetSubnet(target string) (netip.Prefix, bool) {
+	addr, err := netip.ParseAddr(target)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	bits := 64
+	if addr.Is4() {
+		bits = 24
+	}
+	subnet, err := addr.WithZone("").Prefix(bits)
+	return subnet, err == nil
+}
+
+// Observe records the outcome of a probe: how long its reply took, or that
+// it timed out. Every window of probes the adaptive timing halves the rate
+// when the share of probes timing out jumps above its recent average, and
+// raises it again while replies are as fast as they have been. Scans of
+// sparse networks, where most probes time out, keep their rate.
+func (l *RateLimiter) Observe(rtt time.Duration, timedOut bool) {
+	if l == nil || !l.timing.Adaptive {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.window.probes++
+	if timedOut {
+		l.window.timeouts++
+	} else {
+		l.window.rtt += rtt
+	}
+	if l.window.probes < adaptWindow {
+		return
+	}
+
+	window := l.window
+	l.window = probeWindow{}
+	lost := float64(window.timeouts) / float64(window.probes)
+	var mean time.Duration
+	if replies := window.probes - window.timeouts; replies > 0 {
+		mean = window.rtt / time.Duration(replies)
+	}
+
+	if !l.primed {
+		l.primed = true
+		l.baseline = lost
+		l.fastest = mean
+		return
+	}
+
+	switch {
+	case lost > l.baseline+timeoutSpike:
+		l.scale = max(l.scale/2, minRateScale)
+	case mean > 0 && mean <= 2*l.fastest:
+		l.scale = min(l.scale*1.25, 1)
+	}
+
+	l.baseline = 0.8*l.baseline + 0.2*lost
+	if mean > 0 && (l.fastest == 0 || mean < l.fastest) {
+		l.fastest = mean
+	}
+}
+
+// Rate returns the probes a second the scan is currently allowed, 0 when
+// unlimited
+func (l *RateLimiter) Rate() float64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.timing.Rate * l.scale
+}
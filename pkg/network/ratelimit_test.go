@@ -0,0 +1,156 @@
+package network
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestTimingTemplate(t *testing.T) {
+	for _, name := range []string{"T3", "3", " normal ", "NORMAL"} {
+		timing, err := TimingTemplate(name)
+		if err != nil || timing.Rate != 1000 || !timing.Adaptive {
+			t.Errorf("TimingTemplate(%q) = %+v, %v; want the normal preset", name, timing, err)
+		}
+	}
+	if insane, _ := TimingTemplate("T5"); insane.Adaptive || insane.Rate != 20000 {
+		t.Errorf("T5 = %+v", insane)
+	}
+	if polite, _ := TimingTemplate("polite"); polite.SubnetRate == 0 {
+		t.Errorf("polite = %+v, want a subnet rate", polite)
+	}
+	for _, name := range []string{"T6", "fast", ""} {
+		if _, err := TimingTemplate(name); err == nil {
+			t.Errorf("TimingTemplate(%q) succeeded", name)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	var bucket tokenBucket
+
+	// 100 probes a second hold a burst of 5
+	for i := 0; i < 5; i++ {
+		if wait := bucket.take(now, 100); wait != 0 {
+			t.Fatalf("probe %d of the burst waits %v", i, wait)
+		}
+	}
+	if wait := bucket.take(now, 100); wait != 10*time.Millisecond {
+		t.Errorf("probe after the burst waits %v, want 10ms", wait)
+	}
+	if wait := bucket.take(now, 100); wait != 20*time.Millisecond {
+		t.Errorf("second probe after the burst waits %v, want 20ms", wait)
+	}
+
+	// A second later the bucket is full again, but no fuller
+	later := now.Add(time.Second)
+	for i := 0; i < 5; i++ {
+		if wait := bucket.take(later, 100); wait != 0 {
+			t.Fatalf("probe %d after refilling waits %v", i, wait)
+		}
+	}
+	if wait := bucket.take(later, 100); wait == 0 {
+		t.Error("bucket held more than its burst")
+	}
+
+	// Slow rates still hold one token
+	var slow tokenBucket
+	if slow.take(now, 1.0/300) != 0 || slow.take(now, 1.0/300) != 300*time.Second {
+		t.Error("a paranoid bucket does not allow one probe then wait five minutes")
+	}
+}
+
+func TestTargetSubnet(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"10.0.0.200", "10.0.0.0/24"},
+		{"::ffff:10.0.0.200", "10.0.0.0/24"},
+		{"2001:db8::1", "2001:db8::/64"},
+		{"fe80::1%eth0", "fe80::/64"},
+	}
+	for _, tt := range tests {
+		subnet, ok := targetSubnet(tt.target)
+		if !ok || subnet != netip.MustParsePrefix(tt.want) {
+			t.Errorf("targetSubnet(%s) = %s, %v; want %s", tt.target, subnet, ok, tt.want)
+		}
+	}
+	if _, ok := targetSubnet("www.example.com"); ok {
+		t.Error("a hostname has a subnet")
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	var unlimited *RateLimiter
+	if !unlimited.Wait(context.Background(), "10.0.0.1") || unlimited.Rate() != 0 {
+		t.Error("a nil limiter held a probe back")
+	}
+	unlimited.Observe(time.Millisecond, true)
+
+	// One probe a second to a subnet: the second probe to 10.0.0.0/24
+	// waits, one to another subnet does not
+	limit := NewRateLimiter(Timing{SubnetRate: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if !limit.Wait(ctx, "10.0.0.1") || !limit.Wait(ctx, "10.0.1.1") {
+		t.Fatal("first probes to two subnets were held back")
+	}
+	if limit.Wait(ctx, "10.0.0.2") {
+		t.Error("second probe to a subnet was let through within the second")
+	}
+
+	cancelled, stop := context.WithCancel(context.Background())
+	stop()
+	if NewRateLimiter(Timing{}).Wait(cancelled, "10.0.0.1") || unlimited.Wait(cancelled, "10.0.0.1") {
+		t.Error("Wait succeeded after the context was done")
+	}
+}
+
+// observeWindow records a window of probes, lost of which time out and the
+// rest reply after rtt
+func observeWindow(l *RateLimiter, lost int, rtt time.Duration) {
+	for i := 0; i < adaptWindow; i++ {
+		l.Observe(rtt, i < lost)
+	}
+}
+
+func TestRateLimiterAdapts(t *testing.T) {
+	limit := NewRateLimiter(Timing{Rate: 1000, Adaptive: true})
+
+	observeWindow(limit, 0, 10*time.Millisecond) // sets the baseline
+	observeWindow(limit, adaptWindow/2, 10*time.Millisecond)
+	if rate := limit.Rate(); rate != 500 {
+		t.Fatalf("rate after a spike of timeouts = %v, want 500", rate)
+	}
+	observeWindow(limit, adaptWindow, 0)
+	if rate := limit.Rate(); rate != 250 {
+		t.Fatalf("rate after every probe timed out = %v, want 250", rate)
+	}
+
+	for i := 0; i < 20; i++ {
+		observeWindow(limit, 0, 10*time.Millisecond)
+	}
+	if rate := limit.Rate(); rate != 1000 {
+		t.Errorf("rate after fast replies = %v, want back to 1000 and no higher", rate)
+	}
+
+	// A sparse network, where most probes always time out, keeps its rate
+	sparse := NewRateLimiter(Timing{Rate: 1000, Adaptive: true})
+	for i := 0; i < 10; i++ {
+		observeWindow(sparse, adaptWindow-2, 10*time.Millisecond)
+	}
+	if rate := sparse.Rate(); rate != 1000 {
+		t.Errorf("rate of a sparse scan = %v, want 1000", rate)
+	}
+
+	// Without adaptive timing the rate never changes
+	fixed := NewRateLimiter(Timing{Rate: 1000})
+	observeWindow(fixed, 0, time.Millisecond)
+	observeWindow(fixed, adaptWindow, 0)
+	if rate := fixed.Rate(); rate != 1000 {
+		t.Errorf("rate without adaptive timing = %v", rate)
+	}
+}
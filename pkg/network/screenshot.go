@@ -0,0 +1,281 @@
+package network
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// screenshotSettle is how long a page is given to render after it loads
+const screenshotSettle = 2 * time.Second
+
+// Screenshot is a capture of the page a web service serves. The image is
+// stored once per distinct content, named by its SHA-256.
+type Screenshot struct {
+	URL        string    `json:"url"`
+	SHA256     string    `json:"sha256"`
+	Size       int       `json:"size"`
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	CapturedAt time.Time `json:"captured_at"`
+}
+
+// Screenshotter captures web services with a headless browser. One browser
+// is started while captures are running and shut down when the last
+// finishes; at most tabs pages are open in it at a time.
+type Screenshotter struct {
+	dir         string
+	timeout     time.Duration
+	width       int
+	height      int
+	browserPath string
+	tabs        chan struct{}
+
+	mu      sync.Mutex
+	users   int
+	browser context.Context
+	cancel  context.CancelFunc
+}
+
+// NewScreenshotter creates a screenshotter storing PNG captures under dir,
+// with up to tabs pages open at once and each capture bounded by timeout
+func NewScreenshotter(dir string, tabs int, timeout time.Duration, width, height int) *Screenshotter {
+	if tabs <= 0 {
+		tabs = 4
+	}
+	if width <= 0 || height <= 0 {
+		width, height = 1280, 800
+	}
+	return &Screenshotter{
+		dir:     dir,
+		timeout: timeout,
+		width:   width,
+		height:  height,
+		tabs:    make(chan struct{}, tabs),
+	}
+}
+
+// SetBrowserPath sets the Chrome or Chromium binary to run. By default the
+// browser is looked up by its usual names on the PATH.
+func (s *Screenshotter) SetBrowserPath(path string) {
+	s.browserPath = path
+}
+
+// ScreenshotPath returns where the capture with the given SHA-256 is stored
+// under dir. Captures are spread over subdirectories named by the first two
+// hex digits of the hash.
+func ScreenshotPath(dir, sum string) (string, error) {
+	if len(sum) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid screenshot hash %q", sum)
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return "", fmt.Errorf("invalid screenshot hash %q", sum)
+	}
+	return filepath.Join(dir, sum[:2], sum+".png"), nil
+}
+
+// acquire starts the browser if no capture is using it yet
+func (s *Screenshotter) acquire() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.users == 0 {
+		opts := append(chromedp.DefaultExecAllocatorOptions[:],
+			chromedp.Flag("ignore-certificate-errors", true),
+			chromedp.WindowSize(s.width, s.height),
+		)
+		if s.browserPath != "" {
+			opts = append(opts, chromedp.ExecPath(s.browserPath))
+		}
+		if os.Geteuid() == 0 {
+			// Chrome refuses to run its sandbox as root, which raw socket
+			// scanning usually requires
+			opts = append(opts, chromedp.NoSandbox)
+		}
+
+		allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+		browser, browserCancel := chromedp.NewContext(allocCtx)
+		if err := chromedp.Run(browser); err != nil {
+			browserCancel()
+			allocCancel()
+			return fmt.Errorf("failed to start browser: %w", err)
+		}
+
+		s.browser = browser
+		s.cancel = func() {
+			browserCancel()
+			allocCancel()
+		}
+	}
+	s.users++
+	return nil
+}
+
+// release shuts the browser down once no capture is using it
+func (s *Screenshotter) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users--
+	if s.users == 0 {
+		s.cancel()
+		s.browser, s.cancel = nil, nil
+	}
+}
+
+// Capture loads a URL in a new tab, waits for it to render and stores a
+// screenshot of the viewport. The page load is abandoned when ctx is done.
+func (s *Screenshotter) Capture(ctx context.Context, target string) (*Screenshot, error) {
+	if err := s.acquire(); err != nil {
+		return nil, err
+	}
+	defer s.release()
+
+	if !acquire(ctx, s.tabs) {
+		return nil, ctx.Err()
+	}
+	defer func() { <-s.tabs }()
+
+	s.mu.Lock()
+	tab, closeTab := chromedp.NewContext(s.browser)
+	s.mu.Unlock()
+	defer closeTab()
+
+	capture, cancel := context.WithTimeout(tab, s.timeout)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	var image []byte
+	err := chromedp.Run(capture,
+		chromedp.EmulateViewport(int64(s.width), int64(s.height)),
+		chromedp.Navigate(target),
+		chromedp.Sleep(screenshotSettle),
+		chromedp.CaptureScreenshot(&image),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture %s: %w", target, err)
+	}
+
+	sum, err := s.store(image)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Screenshot{
+		URL:        target,
+		SHA256:     sum,
+		Size:       len(image),
+		Width:      s.width,
+		Height:     s.height,
+		CapturedAt: time.Now(),
+	}, nil
+}
+
+// store writes an image under its SHA-256 unless the same image is already
+// stored, and returns the hash
+func (s *Screenshotter) store(image []byte) (string, error) {
+	digest := sha256.Sum256(image)
+	sum := hex.EncodeToString(digest[:])
+
+	path, err := ScreenshotPath(s.dir, sum)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return sum, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create screenshot directory: %w", err)
+	}
+
+	// Written to a temporary file first so a reader never sees a partial image
+	tmp, err := os.CreateTemp(filepath.Dir(path), sum+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to store screenshot: %w", err)
+	}
+	if _, err := tmp.Write(image); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to store screenshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to store screenshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to store screenshot: %w", err)
+	}
+	return sum, nil
+}
+
+// screenshotURL is the address a browser should load for an enumerated web
+// service: the hostname it was reached by when known, so virtual hosts
+// render as they would for a user
+func screenshotURL(result *PortScanResult, hostname string) string {
+	scheme := "http"
+	if result.HTTP != nil {
+		if parsed, err := url.Parse(result.HTTP.URL); err == nil {
+			scheme = parsed.Scheme
+		}
+	}
+	host := result.IP
+	if hostname != "" {
+		host = hostname
+	}
+	target := &url.URL{Scheme: scheme, Host: net.JoinHostPort(host, strconv.Itoa(result.Port)), Path: "/"}
+	return target.String()
+}
+
+// captureScreenshots captures the web services among port results that HTTP
+// enumeration found, until ctx is done. The browser is kept running across
+// the batch.
+func (s *Screenshotter) captureScreenshots(ctx context.Context, results []PortScanResult, hostname string) {
+	var pending []*PortScanResult
+	for i := range results {
+		if results[i].HTTP != nil {
+			pending = append(pending, &results[i])
+		}
+	}
+	if len(pending) == 0 || ctx.Err() != nil {
+		return
+	}
+
+	if err := s.acquire(); err != nil {
+		log.Printf("Screenshots skipped: %v", err)
+		return
+	}
+	defer s.release()
+
+	var wg sync.WaitGroup
+	for _, result := range pending {
+		wg.Add(1)
+		go func(result *PortScanResult) {
+			defer wg.Done()
+
+			shot, err := s.Capture(ctx, screenshotURL(result, hostname))
+			if Interrupted(err) {
+				return
+			}
+			if err != nil {
+				log.Printf("Screenshot of %s:%d failed: %v", result.IP, result.Port, err)
+				return
+			}
+			result.Screenshot = shot
+		}(result)
+	}
+	wg.Wait()
+}
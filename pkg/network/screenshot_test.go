@@ -0,0 +1,64 @@
+package network
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScreenshotStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewScreenshotter(dir, 1, 0, 0, 0)
+	image := []byte("\x89PNG test image")
+
+	sum, err := s.store(image)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	path, err := ScreenshotPath(dir, sum)
+	if err != nil {
+		t.Fatalf("ScreenshotPath: %v", err)
+	}
+	if filepath.Dir(path) != filepath.Join(dir, sum[:2]) {
+		t.Errorf("stored at %s, want under the hash prefix directory", path)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, image) {
+		t.Fatalf("stored image = %q, %v", data, err)
+	}
+
+	// The same image is stored once
+	again, err := s.store(image)
+	if err != nil || again != sum {
+		t.Errorf("storing again = %s, %v; want %s", again, err, sum)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("directory holds %d entries, %v; want the one image and no temporary files", len(entries), err)
+	}
+}
+
+func TestScreenshotPath(t *testing.T) {
+	for _, sum := range []string{"", "abc", "../../../../etc/passwd", "zz" + string(bytes.Repeat([]byte("0"), 62))} {
+		if _, err := ScreenshotPath("shots", sum); err == nil {
+			t.Errorf("ScreenshotPath(%q) succeeded, want an error", sum)
+		}
+	}
+}
+
+func TestScreenshotURL(t *testing.T) {
+	tests := []struct {
+		result   PortScanResult
+		hostname string
+		want     string
+	}{
+		{PortScanResult{IP: "10.0.0.1", Port: 8080, HTTP: &HTTPInfo{URL: "http://10.0.0.1:8080/"}}, "", "http://10.0.0.1:8080/"},
+		{PortScanResult{IP: "10.0.0.1", Port: 8443, HTTP: &HTTPInfo{URL: "https://10.0.0.1:8443/"}}, "intranet.example.com", "https://intranet.example.com:8443/"},
+		{PortScanResult{IP: "2001:db8::1", Port: 80}, "", "http://[2001:db8::1]:80/"},
+	}
+	for _, tt := range tests {
+		if got := screenshotURL(&tt.result, tt.hostname); got != tt.want {
+			t.Errorf("screenshotURL(%s:%d, %q) = %s, want %s", tt.result.IP, tt.result.Port, tt.hostname, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,558 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServiceProbe is a payload sent to a port to draw a response from the
+// service behind it, with the patterns that identify services by that
+// response
+type ServiceProbe struct {
+	Protocol ScanType
+	Name     string
+	Payload  []byte
+	Ports    map[int]bool
+	Wait     time.Duration
+	Matches  []ServiceMatch
+}
+
+// ServiceMatch identifies a service by a pattern in a probe response. The
+// product, version and CPE templates may refer to the capture groups of the
+// pattern as $1 to $9.
+type ServiceMatch struct {
+	Service string
+	Pattern *regexp.Regexp
+	Product string
+	Version string
+	CPE     string
+	Soft    bool
+}
+
+// ServiceFingerprint is the service identified on a port
+type ServiceFingerprint struct {
+	Service string
+	Product string
+	Version string
+	CPE     string
+	Probe   string
+	Soft    bool
+}
+
+// ServiceProbeDatabase holds the probes of a service probes file in file
+// order. The NULL probe, which sends nothing and matches banners, is kept
+// apart because its matches apply to every TCP response.
+type ServiceProbeDatabase struct {
+	null   *ServiceProbe
+	probes []*ServiceProbe
+}
+
+// nullProbeName names the probe that only reads the connection banner
+const nullProbeName = "NULL"
+
+//go:embed service_probes.txt
+var embeddedServiceProbes []byte
+
+var (
+	serviceProbeDB     *ServiceProbeDatabase
+	serviceProbeDBOnce sync.Once
+)
+
+// defaultServiceProbes returns the probe database built from the embedded
+// probes file
+func defaultServiceProbes() *ServiceProbeDatabase {
+	serviceProbeDBOnce.Do(func() {
+		db, err := ParseServiceProbes(bytes.NewReader(embeddedServiceProbes))
+		if err != nil {
+			log.Printf("Warning: failed to load embedded service probes: %v", err)
+			db = &ServiceProbeDatabase{}
+		}
+		serviceProbeDB = db
+	})
+	return serviceProbeDB
+}
+
+// ParseServiceProbes reads a service probes file. The format is a subset of
+// nmap-service-probes; see service_probes.txt for a description.
+func ParseServiceProbes(r io.Reader) (*ServiceProbeDatabase, error) {
+	db := &ServiceProbeDatabase{}
+	var probe *ServiceProbe
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		directive, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+
+		if directive != "Probe" && probe == nil {
+			return nil, fmt.Errorf("line %d: %s before the first Probe", lineNumber, directive)
+		}
+
+		var err error
+		switch directive {
+		case "Probe":
+			probe, err = parseProbeLine(rest)
+			if err == nil {
+				if probe.Protocol == ScanTCP && probe.Name == nullProbeName {
+					db.null = probe
+				} else {
+					db.probes = append(db.probes, probe)
+				}
+			}
+		case "ports":
+			probe.Ports = make(map[int]bool)
+			err = parsePortSpec(rest, probe.Ports, probe.Ports, 0)
+		case "totalwaitms":
+			var ms int
+			if ms, err = strconv.Atoi(rest); err == nil && ms > 0 {
+				probe.Wait = time.Duration(ms) * time.Millisecond
+			} else if err == nil {
+				err = fmt.Errorf("invalid wait %q", rest)
+			}
+		case "match", "softmatch":
+			var match ServiceMatch
+			if match, err = parseMatchLine(rest, directive == "softmatch"); err == nil {
+				probe.Matches = append(probe.Matches, match)
+			}
+		default:
+			err = fmt.Errorf("unknown directive %q", directive)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// parseProbeLine parses "<TCP|UDP> <name> q|<payload>|"
+func parseProbeLine(rest string) (*ServiceProbe, error) {
+	fields := strings.SplitN(rest, " ", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("probe needs a protocol, a name and a payload")
+	}
+
+	probe := &ServiceProbe{Name: fields[1]}
+	switch fields[0] {
+	case "TCP":
+		probe.Protocol = ScanTCP
+	case "UDP":
+		probe.Protocol = ScanUDP
+	default:
+		return nil, fmt.Errorf("invalid probe protocol %q", fields[0])
+	}
+
+	if !strings.HasPrefix(fields[2], "q") {
+		return nil, fmt.Errorf("probe payload must be given as q|...|")
+	}
+	payload, tail, err := splitDelimited(fields[2][1:])
+	if err != nil {
+		return nil, fmt.Errorf("probe payload: %w", err)
+	}
+	if strings.TrimSpace(tail) != "" {
+		return nil, fmt.Errorf("unexpected text after probe payload: %q", tail)
+	}
+	if probe.Payload, err = unescapePayload(payload); err != nil {
+		return nil, err
+	}
+
+	return probe, nil
+}
+
+// parseMatchLine parses "<service> m|<regex>|[flags] [p/../] [v/../] [cpe:/../]"
+func parseMatchLine(rest string, soft bool) (ServiceMatch, error) {
+	match := ServiceMatch{Soft: soft}
+
+	service, rest, _ := strings.Cut(rest, " ")
+	if service == "" {
+		return match, fmt.Errorf("match needs a service name")
+	}
+	match.Service = service
+
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(rest, "m") {
+		return match, fmt.Errorf("match pattern must be given as m|...|")
+	}
+	pattern, rest, err := splitDelimited(rest[1:])
+	if err != nil {
+		return match, fmt.Errorf("match pattern: %w", err)
+	}
+
+	flags := "(?"
+	for len(rest) > 0 && rest[0] != ' ' {
+		switch rest[0] {
+		case 'i':
+			flags += "i"
+		case 's':
+			flags += "s"
+		default:
+			return match, fmt.Errorf("unknown pattern flag %q", rest[0])
+		}
+		rest = rest[1:]
+	}
+	if flags != "(?" {
+		pattern = flags + ")" + pattern
+	}
+	if match.Pattern, err = regexp.Compile(pattern); err != nil {
+		return match, err
+	}
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		var field *string
+		switch {
+		case strings.HasPrefix(rest, "cpe:"):
+			field, rest = &match.CPE, rest[len("cpe:"):]
+		case rest[0] == 'p':
+			field, rest = &match.Product, rest[1:]
+		case rest[0] == 'v':
+			field, rest = &match.Version, rest[1:]
+		default:
+			return match, fmt.Errorf("unknown match field %q", rest)
+		}
+		if *field, rest, err = splitDelimited(rest); err != nil {
+			return match, err
+		}
+	}
+	if match.CPE != "" {
+		match.CPE = "cpe:/" + match.CPE
+	}
+
+	if soft && (match.Product != "" || match.Version != "" || match.CPE != "") {
+		return match, fmt.Errorf("softmatch cannot set product, version or cpe")
+	}
+	return match, nil
+}
+
+// splitDelimited splits text that starts with a delimiter character into the
+// part up to the next occurrence of that character and the remainder
+func splitDelimited(text string) (string, string, error) {
+	if text == "" {
+		return "", "", fmt.Errorf("missing delimiter")
+	}
+	delim := text[0]
+	end := strings.IndexByte(text[1:], delim)
+	if end < 0 {
+		return "", "", fmt.Errorf("unterminated %q", text)
+	}
+	return text[1 : end+1], text[end+2:], nil
+}
+
+// unescapePayload resolves the \r \n \t \0 \\ and \xHH escapes of a payload
+func unescapePayload(text string) ([]byte, error) {
+	var payload []byte
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			payload = append(payload, text[i])
+			continue
+		}
+		if i+1 >= len(text) {
+			return nil, fmt.Errorf("payload ends with a backslash")
+		}
+		i++
+		switch text[i] {
+		case 'r':
+			payload = append(payload, '\r')
+		case 'n':
+			payload = append(payload, '\n')
+		case 't':
+			payload = append(payload, '\t')
+		case '0':
+			payload = append(payload, 0)
+		case '\\':
+			payload = append(payload, '\\')
+		case 'x':
+			if i+2 >= len(text) {
+				return nil, fmt.Errorf("short \\x escape in payload")
+			}
+			b, err := strconv.ParseUint(text[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid \\x escape in payload: %q", text[i-1:i+3])
+			}
+			payload = append(payload, byte(b))
+			i += 2
+		default:
+			return nil, fmt.Errorf("unknown escape \\%c in payload", text[i])
+		}
+	}
+	return payload, nil
+}
+
+// latin1 maps each byte of a response to the character with the same code,
+// so patterns can match arbitrary bytes with \xHH
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// expandTemplate substitutes the capture groups of a match into a template
+func expandTemplate(template string, groups []string) string {
+	if !strings.Contains(template, "$") {
+		return template
+	}
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] == '$' && i+1 < len(template) && template[i+1] >= '1' && template[i+1] <= '9' {
+			if n := int(template[i+1] - '0'); n < len(groups) {
+				b.WriteString(strings.TrimSpace(groups[n]))
+			}
+			i++
+			continue
+		}
+		b.WriteByte(template[i])
+	}
+	return b.String()
+}
+
+// Match tries the matches of a probe against its response, followed by the
+// NULL probe's matches for TCP. A soft service limits the matches tried to
+// that service. The first hard match wins; failing that, the first soft
+// match is returned.
+func (db *ServiceProbeDatabase) Match(probe *ServiceProbe, response []byte, softService string) *ServiceFingerprint {
+	if len(response) == 0 {
+		return nil
+	}
+	text := latin1(response)
+
+	matchSets := []*ServiceProbe{probe}
+	if probe.Protocol == ScanTCP && db.null != nil && probe != db.null {
+		matchSets = append(matchSets, db.null)
+	}
+
+	var soft *ServiceFingerprint
+	for _, set := range matchSets {
+		for _, match := range set.Matches {
+			if softService != "" && match.Service != softService {
+				continue
+			}
+			if soft != nil && match.Soft {
+				continue
+			}
+			groups := match.Pattern.FindStringSubmatch(text)
+			if groups == nil {
+				continue
+			}
+			fingerprint := &ServiceFingerprint{
+				Service: match.Service,
+				Product: expandTemplate(match.Product, groups),
+				Version: expandTemplate(match.Version, groups),
+				CPE:     expandTemplate(match.CPE, groups),
+				Probe:   probe.Name,
+				Soft:    match.Soft,
+			}
+			if !match.Soft {
+				return fingerprint
+			}
+			soft = fingerprint
+		}
+	}
+	return soft
+}
+
+// UDPProbe returns the UDP probe for a port, or nil when there is none
+func (db *ServiceProbeDatabase) UDPProbe(port int) *ServiceProbe {
+	for _, probe := range db.probes {
+		if probe.Protocol == ScanUDP && probe.Ports[port] {
+			return probe
+		}
+	}
+	return nil
+}
+
+// tcpProbes returns the TCP probes to send to a port after the NULL probe:
+// those listing the port first, then the rest, in file order
+func (db *ServiceProbeDatabase) tcpProbes(port int) []*ServiceProbe {
+	var listed, others []*ServiceProbe
+	for _, probe := range db.probes {
+		if probe.Protocol != ScanTCP {
+			continue
+		}
+		if probe.Ports[port] {
+			listed = append(listed, probe)
+		} else {
+			others = append(others, probe)
+		}
+	}
+	return append(listed, others...)
+}
+
+// matchesService reports whether a probe, or the NULL probe it falls back
+// to, has any match for a service
+func (db *ServiceProbeDatabase) matchesService(probe *ServiceProbe, service string) bool {
+	for _, set := range []*ServiceProbe{probe, db.null} {
+		if set == nil {
+			continue
+		}
+		for _, match := range set.Matches {
+			if match.Service == service && !match.Soft {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServiceDetector identifies the services on open ports by sending the
+// probes of a probe database and matching the responses
+type ServiceDetector struct {
+	db      *ServiceProbeDatabase
+	timeout time.Duration
+}
+
+// NewServiceDetector creates a detector using the embedded probe database.
+// timeout bounds connecting and the wait for each probe's response.
+func NewServiceDetector(timeout time.Duration) *ServiceDetector {
+	return &ServiceDetector{db: defaultServiceProbes(), timeout: timeout}
+}
+
+// MatchBanner identifies a TCP service by the banner it sent on connect
+func (d *ServiceDetector) MatchBanner(banner []byte) *ServiceFingerprint {
+	if d.db.null == nil {
+		return nil
+	}
+	return d.db.Match(d.db.null, banner, "")
+}
+
+// MatchUDP identifies a UDP service by its answer to the probe payload
+// UDPPayload returned for the port
+func (d *ServiceDetector) MatchUDP(port int, response []byte) *ServiceFingerprint {
+	probe := d.db.UDPProbe(port)
+	if probe == nil {
+		return nil
+	}
+	return d.db.Match(probe, response, "")
+}
+
+// UDPPayload returns the probe payload for a UDP port, or nil when no probe
+// lists the port
+func (d *ServiceDetector) UDPPayload(port int) []byte {
+	if probe := d.db.UDPProbe(port); probe != nil {
+		return probe.Payload
+	}
+	return nil
+}
+
+// ProbeTCP identifies the service on an open TCP port. banner is what the
+// port sent on connect, if the caller already read it; with a nil banner
+// the NULL probe reads it first. Each probe then runs on a new connection
+// until one matches, or only the soft-matched service is left to confirm
+// and no remaining probe can. No further probes are sent once ctx is done.
+func (d *ServiceDetector) ProbeTCP(ctx context.Context, ip string, port int, banner []byte) *ServiceFingerprint {
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	var best *ServiceFingerprint
+	if d.db.null != nil {
+		if banner == nil {
+			banner = d.exchange(ctx, address, d.db.null)
+		}
+		if fingerprint := d.db.Match(d.db.null, banner, ""); fingerprint != nil {
+			if !fingerprint.Soft {
+				return fingerprint
+			}
+			best = fingerprint
+		}
+	}
+
+	for _, probe := range d.db.tcpProbes(port) {
+		if ctx.Err() != nil {
+			break
+		}
+		softService := ""
+		if best != nil {
+			softService = best.Service
+			if !d.db.matchesService(probe, softService) {
+				continue
+			}
+		}
+
+		response := d.exchange(ctx, address, probe)
+		fingerprint := d.db.Match(probe, response, softService)
+		if fingerprint == nil {
+			continue
+		}
+		if !fingerprint.Soft {
+			return fingerprint
+		}
+		if best == nil {
+			best = fingerprint
+		}
+	}
+
+	return best
+}
+
+// exchange sends a probe over a new connection and reads the response until
+// the probe's wait runs out, the service closes the connection, or the
+// response matches a service outright
+func (d *ServiceDetector) exchange(ctx context.Context, address string, probe *ServiceProbe) []byte {
+	dialer := net.Dialer{Timeout: d.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	if len(probe.Payload) > 0 {
+		conn.SetWriteDeadline(time.Now().Add(d.timeout))
+		if _, err := conn.Write(probe.Payload); err != nil {
+			return nil
+		}
+	}
+
+	wait := probe.Wait
+	if wait == 0 || wait > d.timeout {
+		wait = d.timeout
+	}
+	conn.SetReadDeadline(time.Now().Add(wait))
+
+	var response []byte
+	buf := make([]byte, 4096)
+	for len(response) < 16384 {
+		n, err := conn.Read(buf)
+		response = append(response, buf[:n]...)
+		if err != nil {
+			break
+		}
+		if fingerprint := d.db.Match(probe, response, ""); fingerprint != nil && !fingerprint.Soft {
+			break
+		}
+	}
+	return response
+}
+
+// applyFingerprint records an identified service on a port result
+func (r *PortScanResult) applyFingerprint(fingerprint *ServiceFingerprint) {
+	if fingerprint == nil {
+		return
+	}
+	// A port already named for a TLS service says more than a bare SSL
+	if fingerprint.Soft && fingerprint.Service == "SSL" && tlsServices[r.Service] {
+		return
+	}
+	r.Service = fingerprint.Service
+	r.Product = fingerprint.Product
+	r.Version = fingerprint.Version
+	r.CPE = fingerprint.CPE
+}
@@ -0,0 +1,162 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmbeddedServiceProbesParse(t *testing.T) {
+	db, err := ParseServiceProbes(bytes.NewReader(embeddedServiceProbes))
+	if err != nil {
+		t.Fatalf("embedded service probes: %v", err)
+	}
+	if db.null == nil || len(db.null.Matches) == 0 {
+		t.Fatal("embedded probes have no NULL probe matches")
+	}
+	for _, port := range []int{53, 123, 161} {
+		if db.UDPProbe(port) == nil {
+			t.Errorf("no UDP probe for port %d", port)
+		}
+	}
+	if probes := db.tcpProbes(8080); len(probes) == 0 || probes[0].Name != "GetRequest" {
+		t.Errorf("first TCP probe for 8080 is not GetRequest: %v", probes)
+	}
+}
+
+func TestParseServiceProbes(t *testing.T) {
+	const probes = `# test probes
+Probe TCP NULL q||
+match SSH m|^SSH-([\d.]+)-OpenSSH[_-]([\w.]+)| p/OpenSSH/ v/$2/ cpe:/a:openbsd:openssh:$2/
+softmatch FTP m|^220 .*ftp|i
+
+Probe TCP Hello q|HELO\r\n\x00|
+ports 25,2525-2526
+totalwaitms 250
+match SMTP m|^250 ([\w.]+)| p/Test smtpd/ v/$1/
+
+Probe UDP Echo q|ping|
+ports 7
+match echo m|^ping$|
+`
+	db, err := ParseServiceProbes(strings.NewReader(probes))
+	if err != nil {
+		t.Fatalf("ParseServiceProbes: %v", err)
+	}
+	if len(db.probes) != 2 {
+		t.Fatalf("parsed %d probes besides NULL, want 2", len(db.probes))
+	}
+
+	hello := db.probes[0]
+	if string(hello.Payload) != "HELO\r\n\x00" || hello.Wait != 250*time.Millisecond || !hello.Ports[2526] || hello.Ports[80] {
+		t.Errorf("Hello probe = payload %q, wait %v, ports %v", hello.Payload, hello.Wait, hello.Ports)
+	}
+
+	tests := []struct {
+		probe    *ServiceProbe
+		response string
+		service  string
+		version  string
+		soft     bool
+	}{
+		{db.null, "SSH-2.0-OpenSSH_9.6p1 Ubuntu\r\n", "SSH", "9.6p1", false},
+		{db.null, "220 Welcome to the FTP server\r\n", "FTP", "", true},
+		{hello, "250 mail.example.com\r\n", "SMTP", "mail.example.com", false},
+		{hello, "SSH-2.0-OpenSSH_8.0\r\n", "SSH", "8.0", false},
+		{db.UDPProbe(7), "ping", "echo", "", false},
+		{db.null, "nothing to see", "", "", false},
+	}
+	for _, tt := range tests {
+		fingerprint := db.Match(tt.probe, []byte(tt.response), "")
+		if tt.service == "" {
+			if fingerprint != nil {
+				t.Errorf("Match(%q) = %+v, want none", tt.response, fingerprint)
+			}
+			continue
+		}
+		if fingerprint == nil || fingerprint.Service != tt.service || fingerprint.Version != tt.version || fingerprint.Soft != tt.soft {
+			t.Errorf("Match(%q) = %+v, want %s %q (soft %v)", tt.response, fingerprint, tt.service, tt.version, tt.soft)
+		}
+	}
+
+	// A soft match limits the matches tried to its service
+	if fingerprint := db.Match(hello, []byte("250 ok\r\n"), "FTP"); fingerprint != nil {
+		t.Errorf("match limited to FTP = %+v, want none", fingerprint)
+	}
+}
+
+func TestParseServiceProbesErrors(t *testing.T) {
+	for _, probes := range []string{
+		"match SSH m|^SSH-|",
+		"Probe ICMP Echo q||",
+		"Probe TCP Broken q|unterminated",
+		"Probe TCP Hello q|HELO|\nports 0",
+		"Probe TCP Hello q|HELO|\ntotalwaitms -5",
+		"Probe TCP Hello q|HELO|\nmatch SMTP m|^250 (|",
+		"Probe TCP Hello q|HELO|\nfallback GetRequest",
+	} {
+		if _, err := ParseServiceProbes(strings.NewReader(probes)); err == nil {
+			t.Errorf("ParseServiceProbes(%q) succeeded, want an error", probes)
+		}
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25.3")
+	}))
+	defer server.Close()
+	host, port := splitServerURL(t, server.URL)
+
+	detector := NewServiceDetector(time.Second)
+	fingerprint := detector.ProbeTCP(context.Background(), host, port, nil)
+	if fingerprint == nil || fingerprint.Service != "HTTP" || fingerprint.Product != "nginx" || fingerprint.Version != "1.25.3" {
+		t.Errorf("ProbeTCP of an nginx server = %+v", fingerprint)
+	}
+
+	// A banner sent on connect identifies the service without probing
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			w := bufio.NewWriter(conn)
+			w.WriteString("SSH-2.0-OpenSSH_9.6\r\n")
+			w.Flush()
+			conn.Close()
+		}
+	}()
+	sshPort := listener.Addr().(*net.TCPAddr).Port
+	fingerprint = detector.ProbeTCP(context.Background(), "127.0.0.1", sshPort, nil)
+	if fingerprint == nil || fingerprint.Service != "SSH" || fingerprint.Product != "OpenSSH" || fingerprint.Probe != nullProbeName {
+		t.Errorf("ProbeTCP of an SSH banner = %+v", fingerprint)
+	}
+}
+
+// splitServerURL returns the host and port of a test server
+func splitServerURL(t *testing.T, rawURL string) (string, int) {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", rawURL, err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatalf("port of %s: %v", rawURL, err)
+	}
+	return u.Hostname(), port
+}
@@ -0,0 +1,329 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// TCP header flags used by the SYN scanner
+const (
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagACK = 0x10
+)
+
+// synKey identifies an outstanding SYN probe by target address and port
+type synKey struct {
+	ip   string
+	port uint16
+}
+
+// synProbe is a SYN sent to one port, waiting for its answer
+type synProbe struct {
+	src      net.IP
+	seq      uint32
+	answered bool
+	replies  chan<- synReply
+}
+
+// synReply is the classification of the answer to a SYN probe
+type synReply struct {
+	port  int
+	state PortState
+}
+
+// SYNScanner performs half-open TCP scans over a raw IPv4 socket. A SYN is
+// sent to each port; a SYN/ACK marks the port open and is answered with a
+// RST so the connection is never completed, a RST marks it closed, and
+// silence after every retransmission marks it filtered. One receive loop
+// reads the answers for every target being scanned.
+type SYNScanner struct {
+	conn    net.PacketConn
+	srcPort uint16
+	mu      sync.Mutex
+	pending map[synKey]*synProbe
+	sources map[string]net.IP
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewSYNScanner opens the raw socket. This requires root or CAP_NET_RAW.
+func NewSYNScanner() (*SYNScanner, error) {
+	conn, err := net.ListenPacket("ip4:tcp", "0.0.0.0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open raw TCP socket: %w", err)
+	}
+
+	s := &SYNScanner{
+		conn:    conn,
+		srcPort: uint16(40000 + rand.Intn(20000)),
+		pending: make(map[synKey]*synProbe),
+		sources: make(map[string]net.IP),
+		done:    make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.receive()
+
+	return s, nil
+}
+
+// Close closes the raw socket and waits for the receive loop to stop
+func (s *SYNScanner) Close() error {
+	close(s.done)
+	err := s.conn.Close()
+	s.wg.Wait()
+	return err
+}
+
+// ScanPorts probes TCP ports on an IPv4 host. Every port is probed at once;
+// unanswered ports are retransmitted up to retries times, with the timeout
+// split evenly across the attempts. Segments are paced by limit, when set.
+// When ctx is done the scan stops waiting; the ports without an answer yet
+// are reported as filtered, with ctx's error.
+func (s *SYNScanner) ScanPorts(ctx context.Context, ip string, ports []int, timeout time.Duration, retries int, limit *RateLimiter) (map[int]PortState, error) {
+	dst := net.ParseIP(ip).To4()
+	if dst == nil {
+		return nil, fmt.Errorf("SYN scan supports IPv4 targets only: %s", ip)
+	}
+
+	src, err := s.sourceFor(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make(chan synReply, len(ports))
+	probes, err := s.register(dst.String(), src, ports, replies)
+	if err != nil {
+		return nil, err
+	}
+	defer s.unregister(dst.String(), probes)
+
+	attempts := retries + 1
+	if attempts < 1 {
+		attempts = 1
+	}
+	wait := timeout / time.Duration(attempts)
+
+	states := make(map[int]PortState, len(ports))
+	sent := make(map[int]time.Time, len(ports))
+	for attempt := 0; attempt < attempts && len(states) < len(probes) && ctx.Err() == nil; attempt++ {
+		for port, probe := range probes {
+			if _, ok := states[port]; ok {
+				continue
+			}
+			if !limit.Wait(ctx, ip) {
+				break
+			}
+			segment := buildTCPSegment(src, dst, s.srcPort, uint16(port), probe.seq, 0, tcpFlagSYN)
+			if _, err := s.conn.WriteTo(segment, &net.IPAddr{IP: dst}); err != nil {
+				return nil, fmt.Errorf("failed to send SYN to %s:%d: %w", ip, port, err)
+			}
+			sent[port] = time.Now()
+		}
+
+		timer := time.NewTimer(wait)
+	collect:
+		for len(states) < len(probes) {
+			select {
+			case reply := <-replies:
+				if _, ok := states[reply.port]; !ok {
+					limit.Observe(time.Since(sent[reply.port]), false)
+				}
+				states[reply.port] = reply.state
+			case <-timer.C:
+				break collect
+			case <-ctx.Done():
+				break collect
+			case <-s.done:
+				timer.Stop()
+				return nil, fmt.Errorf("SYN scanner closed")
+			}
+		}
+		timer.Stop()
+	}
+
+	for port := range probes {
+		if _, ok := states[port]; !ok {
+			if ctx.Err() == nil {
+				limit.Observe(0, true)
+			}
+			states[port] = PortFiltered
+		}
+	}
+	return states, ctx.Err()
+}
+
+// register records a probe for each port of a target. A port already being
+// probed by another scan fails the whole registration.
+func (s *SYNScanner) register(ip string, src net.IP, ports []int, replies chan<- synReply) (map[int]*synProbe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	probes := make(map[int]*synProbe, len(ports))
+	for _, port := range ports {
+		if _, dup := probes[port]; dup {
+			continue
+		}
+		key := synKey{ip: ip, port: uint16(port)}
+		if _, busy := s.pending[key]; busy {
+			for registered := range probes {
+				delete(s.pending, synKey{ip: ip, port: uint16(registered)})
+			}
+			return nil, fmt.Errorf("port %s:%d is already being scanned", ip, port)
+		}
+		probe := &synProbe{src: src, seq: rand.Uint32(), replies: replies}
+		s.pending[key] = probe
+		probes[port] = probe
+	}
+	return probes, nil
+}
+
+func (s *SYNScanner) unregister(ip string, probes map[int]*synProbe) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for port := range probes {
+		delete(s.pending, synKey{ip: ip, port: uint16(port)})
+	}
+}
+
+// sourceFor returns the local address the kernel routes traffic to dst from,
+// which the TCP checksum has to cover
+func (s *SYNScanner) sourceFor(dst net.IP) (net.IP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if src, ok := s.sources[dst.String()]; ok {
+		return src, nil
+	}
+
+	conn, err := net.Dial("udp4", net.JoinHostPort(dst.String(), "9"))
+	if err != nil {
+		return nil, fmt.Errorf("no route to %s: %w", dst, err)
+	}
+	defer conn.Close()
+
+	src := conn.LocalAddr().(*net.UDPAddr).IP.To4()
+	s.sources[dst.String()] = src
+	return src, nil
+}
+
+// receive reads TCP segments from the raw socket and classifies those that
+// answer an outstanding probe, until the socket is closed
+func (s *SYNScanner) receive() {
+	defer s.wg.Done()
+
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			log.Printf("SYN scan receive error: %v", err)
+			return
+		}
+		if n < 20 {
+			continue
+		}
+
+		segment := buf[:n]
+		srcPort := binary.BigEndian.Uint16(segment[0:2])
+		dstPort := binary.BigEndian.Uint16(segment[2:4])
+		seq := binary.BigEndian.Uint32(segment[4:8])
+		ack := binary.BigEndian.Uint32(segment[8:12])
+		flags := segment[13]
+		if dstPort != s.srcPort {
+			continue
+		}
+
+		ipAddr, ok := addr.(*net.IPAddr)
+		if !ok {
+			continue
+		}
+		src := ipAddr.IP.To4()
+
+		s.mu.Lock()
+		probe, ok := s.pending[synKey{ip: src.String(), port: srcPort}]
+		if !ok || probe.answered || ack != probe.seq+1 {
+			s.mu.Unlock()
+			continue
+		}
+
+		var state PortState
+		switch {
+		case flags&(tcpFlagSYN|tcpFlagACK) == tcpFlagSYN|tcpFlagACK:
+			state = PortOpen
+		case flags&tcpFlagRST != 0:
+			state = PortClosed
+		default:
+			s.mu.Unlock()
+			continue
+		}
+		probe.answered = true
+		s.mu.Unlock()
+
+		if state == PortOpen {
+			// Tear down the half-open connection
+			rst := buildTCPSegment(probe.src, src, s.srcPort, srcPort, ack, seq+1, tcpFlagRST|tcpFlagACK)
+			s.conn.WriteTo(rst, &net.IPAddr{IP: src})
+		}
+
+		probe.replies <- synReply{port: int(srcPort), state: state}
+	}
+}
+
+// buildTCPSegment builds a TCP header with its checksum. SYN segments carry
+// an MSS option so the probe looks like an ordinary connection attempt.
+func buildTCPSegment(src, dst net.IP, srcPort, dstPort uint16, seq, ack uint32, flags byte) []byte {
+	var options []byte
+	if flags&tcpFlagSYN != 0 {
+		options = []byte{0x02, 0x04, 0x05, 0xb4} // MSS 1460
+	}
+
+	segment := make([]byte, 20+len(options))
+	binary.BigEndian.PutUint16(segment[0:2], srcPort)
+	binary.BigEndian.PutUint16(segment[2:4], dstPort)
+	binary.BigEndian.PutUint32(segment[4:8], seq)
+	binary.BigEndian.PutUint32(segment[8:12], ack)
+	segment[12] = byte(len(segment)/4) << 4
+	segment[13] = flags
+	binary.BigEndian.PutUint16(segment[14:16], 64240) // window
+	copy(segment[20:], options)
+
+	binary.BigEndian.PutUint16(segment[16:18], tcpChecksum(src, dst, segment))
+	return segment
+}
+
+// tcpChecksum computes the TCP checksum over the IPv4 pseudo-header and segment
+func tcpChecksum(src, dst net.IP, segment []byte) uint16 {
+	pseudo := make([]byte, 12, 12+len(segment))
+	copy(pseudo[0:4], src.To4())
+	copy(pseudo[4:8], dst.To4())
+	pseudo[9] = 6 // protocol TCP
+	binary.BigEndian.PutUint16(pseudo[10:12], uint16(len(segment)))
+	data := append(pseudo, segment...)
+
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,104 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBuildTCPSegment(t *testing.T) {
+	src, dst := net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")
+
+	syn := buildTCPSegment(src, dst, 40000, 443, 1000, 0, tcpFlagSYN)
+	if len(syn) != 24 || syn[12]>>4 != 6 {
+		t.Fatalf("SYN segment is %d bytes with data offset %d, want 24 and 6 words", len(syn), syn[12]>>4)
+	}
+	if got := binary.BigEndian.Uint16(syn[0:2]); got != 40000 {
+		t.Errorf("source port %d, want 40000", got)
+	}
+	if got := binary.BigEndian.Uint16(syn[2:4]); got != 443 {
+		t.Errorf("destination port %d, want 443", got)
+	}
+	if got := binary.BigEndian.Uint32(syn[4:8]); got != 1000 {
+		t.Errorf("sequence number %d, want 1000", got)
+	}
+	if syn[13] != tcpFlagSYN || syn[20] != 0x02 {
+		t.Errorf("flags %#x, option kind %d; want SYN with an MSS option", syn[13], syn[20])
+	}
+	// Summing a segment with its checksum in place gives zero
+	if sum := tcpChecksum(src, dst, syn); sum != 0 {
+		t.Errorf("checksum does not verify: %#x", sum)
+	}
+
+	rst := buildTCPSegment(src, dst, 40000, 443, 1001, 5001, tcpFlagRST|tcpFlagACK)
+	if len(rst) != 20 || binary.BigEndian.Uint32(rst[8:12]) != 5001 {
+		t.Errorf("RST segment is %d bytes acknowledging %d, want 20 and 5001", len(rst), binary.BigEndian.Uint32(rst[8:12]))
+	}
+	if sum := tcpChecksum(src, dst, rst); sum != 0 {
+		t.Errorf("RST checksum does not verify: %#x", sum)
+	}
+}
+
+// closedPort returns a loopback port nothing listens on: one just released
+func closedPort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return port
+}
+
+func TestSYNScanLoopback(t *testing.T) {
+	scanner, err := NewSYNScanner()
+	if err != nil {
+		t.Skipf("no raw socket: %v", err)
+	}
+	defer scanner.Close()
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+	open := listener.Addr().(*net.TCPAddr).Port
+
+	closed := closedPort(t)
+
+	states, err := scanner.ScanPorts(context.Background(), "127.0.0.1", []int{open, closed}, 2*time.Second, 1, nil)
+	if err != nil {
+		t.Fatalf("ScanPorts: %v", err)
+	}
+	if states[open] != PortOpen || states[closed] != PortClosed {
+		t.Errorf("states = %v, want %d open and %d closed", states, open, closed)
+	}
+
+	if _, err := scanner.ScanPorts(context.Background(), "::1", []int{open}, time.Second, 0, nil); err == nil {
+		t.Error("SYN scan of an IPv6 target succeeded")
+	}
+}
+
+func TestSYNScannerRegister(t *testing.T) {
+	s := &SYNScanner{pending: make(map[synKey]*synProbe)}
+	replies := make(chan synReply, 2)
+
+	probes, err := s.register("10.0.0.1", net.ParseIP("10.0.0.9"), []int{22, 80, 22}, replies)
+	if err != nil || len(probes) != 2 {
+		t.Fatalf("register = %d probes, %v; want 2 with the duplicate port dropped", len(probes), err)
+	}
+	if _, err := s.register("10.0.0.1", nil, []int{443, 80}, replies); err == nil {
+		t.Error("registering a port already being scanned succeeded")
+	}
+	if len(s.pending) != 2 {
+		t.Errorf("failed registration left %d probes pending, want 2", len(s.pending))
+	}
+
+	s.unregister("10.0.0.1", probes)
+	if len(s.pending) != 0 {
+		t.Errorf("%d probes pending after unregister", len(s.pending))
+	}
+}
@@ -0,0 +1,189 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Target is one target of a target file: an address, prefix, range or
+// hostname, with the options given after it on its line
+type Target struct {
+	// Spec is the target as written
+	Spec string
+	// Hostname is set for a target given by name; it is resolved when the
+	// target is scanned
+	Hostname string
+	// Ports is the port profile of this target, empty for the scan's own
+	Ports string
+	// Tags are added to every asset found at the target
+	Tags []string
+	// Line is the line of the file the target is on
+	Line int
+}
+
+// TargetFile is the contents of a target file
+type TargetFile struct {
+	Targets []Target
+	// Exclude lists the addresses, prefixes and ranges no target covers
+	Exclude []string
+}
+
+// ReadTargetsFromFile reads a target file. Each line holds a target,
+// optionally followed by options, or an exclusion:
+//
+//	# comments and blank lines are skipped
+//	203.0.113.10
+//	10.2.0.0/24 ports=top-100 tags=dmz
+//	10.3.0.5-10.3.0.80 tags=lab,printers
+//	www.example.com ports=web
+//	!10.2.0.1
+//
+// A target is an address, prefix, range or hostname. The options are
+// ports, a port profile (see ParsePortProfile), and tags, a comma-separated
+// list. A line starting with ! excludes an address, prefix or range from
+// every target. Every invalid line is reported, by line number.
+func ReadTargetsFromFile(filePath string) (*TargetFile, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	return ParseTargets(file, filePath)
+}
+
+// ParseTargets parses a target file read from r; name is used in errors
+func ParseTargets(r io.Reader, name string) (*TargetFile, error) {
+	targets := &TargetFile{}
+	var errs []error
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if exclude, ok := strings.CutPrefix(fields[0], "!"); ok {
+			if len(fields) > 1 {
+				errs = append(errs, fmt.Errorf("%s:%d: exclusions take no options", name, n))
+				continue
+			}
+			if _, err := parseAddressRange(exclude, false); err != nil {
+				errs = append(errs, fmt.Errorf("%s:%d: %w", name, n, err))
+				continue
+			}
+			targets.Exclude = append(targets.Exclude, exclude)
+			continue
+		}
+
+		target, err := parseTarget(fields)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", name, n, err))
+			continue
+		}
+		target.Line = n
+		targets.Targets = append(targets.Targets, target)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", name, err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return targets, nil
+}
+
+// parseTarget parses the fields of a target line
+func parseTarget(fields []string) (Target, error) {
+	target := Target{Spec: fields[0]}
+	if _, err := parseAddressRange(target.Spec, true); err != nil {
+		if !isHostname(target.Spec) {
+			return Target{}, err
+		}
+		target.Hostname = strings.ToLower(strings.TrimSuffix(target.Spec, "."))
+	}
+
+	seen := make(map[string]bool)
+	for _, option := range fields[1:] {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok || value == "" {
+			return Target{}, fmt.Errorf("option %q is not key=value", option)
+		}
+		if seen[key] {
+			return Target{}, fmt.Errorf("option %q given twice", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "ports":
+			if _, err := ParsePortProfile(value); err != nil {
+				return Target{}, err
+			}
+			target.Ports = value
+		case "tags":
+			for _, tag := range strings.Split(value, ",") {
+				if tag == "" {
+					return Target{}, fmt.Errorf("empty tag in %q", option)
+				}
+				if !slices.Contains(target.Tags, tag) {
+					target.Tags = append(target.Tags, tag)
+				}
+			}
+		default:
+			return Target{}, fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return target, nil
+}
+
+// isHostname reports whether s is a DNS name. A name whose last label is
+// numeric, such as 10.0.0.300, is a mistyped address rather than a name.
+func isHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	labels := strings.Split(s, ".")
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return strings.Trim(labels[len(labels)-1], "0123456789") != ""
+}
+
+// Resolve returns the addresses, prefixes and ranges the target covers,
+// looking up the addresses of a hostname
+func (t Target) Resolve(ctx context.Context) ([]string, error) {
+	if t.Hostname == "" {
+		return []string{t.Spec}, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", t.Hostname)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", t.Hostname, err)
+	}
+	specs := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		specs = append(specs, addr.Unmap().WithZone("").String())
+	}
+	return specs, nil
+}
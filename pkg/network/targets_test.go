@@ -0,0 +1,146 @@
+package network
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseTargets(t *testing.T) {
+	const file = `# scan targets
+203.0.113.10
+10.2.0.0/24 ports=top-100 tags=dmz   # the DMZ
+10.3.0.5-10.3.0.80	tags=lab,printers,lab
+
+WWW.Example.com. ports=T:80,443
+!10.2.0.1
+!10.3.0.64/28
+`
+	targets, err := ParseTargets(strings.NewReader(file), "list.txt")
+	if err != nil {
+		t.Fatalf("ParseTargets: %v", err)
+	}
+
+	want := []Target{
+		{Spec: "203.0.113.10", Line: 2},
+		{Spec: "10.2.0.0/24", Ports: "top-100", Tags: []string{"dmz"}, Line: 3},
+		{Spec: "10.3.0.5-10.3.0.80", Tags: []string{"lab", "printers"}, Line: 4},
+		{Spec: "WWW.Example.com.", Hostname: "www.example.com", Ports: "T:80,443", Line: 6},
+	}
+	if len(targets.Targets) != len(want) {
+		t.Fatalf("parsed %d targets, want %d: %+v", len(targets.Targets), len(want), targets.Targets)
+	}
+	for i, target := range targets.Targets {
+		w := want[i]
+		if target.Spec != w.Spec || target.Hostname != w.Hostname || target.Ports != w.Ports || !slices.Equal(target.Tags, w.Tags) || target.Line != w.Line {
+			t.Errorf("target %d = %+v, want %+v", i, target, w)
+		}
+	}
+	if !slices.Equal(targets.Exclude, []string{"10.2.0.1", "10.3.0.64/28"}) {
+		t.Errorf("exclusions = %v", targets.Exclude)
+	}
+}
+
+func TestParseTargetsErrors(t *testing.T) {
+	tests := []struct {
+		line  string
+		error string
+	}{
+		{"10.0.0.300", "invalid target"},
+		{"10.0.0.0/24 ports", "not key=value"},
+		{"10.0.0.0/24 ports=", "not key=value"},
+		{"10.0.0.0/24 ports=ssh", "ssh"},
+		{"10.0.0.0/24 tags=a tags=b", "given twice"},
+		{"10.0.0.0/24 tags=a,,b", "empty tag"},
+		{"10.0.0.0/24 speed=fast", "unknown option"},
+		{"!10.0.0.1 tags=x", "exclusions take no options"},
+		{"!www.example.com", "invalid target"},
+		{"under_score.example.com", "invalid target"},
+	}
+	for _, tt := range tests {
+		_, err := ParseTargets(strings.NewReader("# header\n"+tt.line), "list.txt")
+		if err == nil || !strings.Contains(err.Error(), "list.txt:2: ") || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("ParseTargets(%q) = %v, want a %q error on list.txt:2", tt.line, err, tt.error)
+		}
+	}
+
+	// Every invalid line is reported
+	_, err := ParseTargets(strings.NewReader("10.0.0.300\n10.0.0.1\n10.0.0.0/24 bogus\n"), "list.txt")
+	if err == nil || !strings.Contains(err.Error(), "list.txt:1: ") || !strings.Contains(err.Error(), "list.txt:3: ") {
+		t.Errorf("ParseTargets = %v, want errors on lines 1 and 3", err)
+	}
+}
+
+func TestIsHostname(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"www.example.com", true},
+		{"www.example.com.", true},
+		{"router", true},
+		{"host-1.example.com", true},
+		{"1.example.com", true},
+		{"10.0.0.300", false},
+		{"-bad.example.com", false},
+		{"bad-.example.com", false},
+		{"a..example.com", false},
+		{"", false},
+		{".", false},
+		{strings.Repeat("a", 64) + ".com", false},
+		{"under_score.com", false},
+	}
+	for _, tt := range tests {
+		if got := isHostname(tt.s); got != tt.want {
+			t.Errorf("isHostname(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTargetResolve(t *testing.T) {
+	specs, err := Target{Spec: "10.0.0.0/24"}.Resolve(context.Background())
+	if err != nil || !slices.Equal(specs, []string{"10.0.0.0/24"}) {
+		t.Errorf("Resolve of a prefix = %v, %v", specs, err)
+	}
+
+	specs, err = Target{Spec: "localhost", Hostname: "localhost"}.Resolve(context.Background())
+	if err != nil {
+		t.Skipf("localhost does not resolve: %v", err)
+	}
+	if !slices.Contains(specs, "127.0.0.1") && !slices.Contains(specs, "::1") {
+		t.Errorf("localhost resolved to %v", specs)
+	}
+}
+
+func TestScanCIDRFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(path, []byte("10.0.0.0/29\n10.0.1.5\n!10.0.0.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner, client := newFakeARPScanner()
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.1.5"} {
+		client.hosts[netip.MustParseAddr(ip)], _ = net.ParseMAC("aa:bb:cc:00:00:01")
+	}
+
+	results, err := scanner.ScanCIDRFiles(context.Background(), path)
+	if err != nil {
+		t.Fatalf("ScanCIDRFiles: %v", err)
+	}
+	var ips []string
+	for _, result := range results {
+		ips = append(ips, result.IP)
+	}
+	if !slices.Equal(ips, []string{"10.0.0.1", "10.0.1.5"}) {
+		t.Errorf("found %v, want the excluded address left out", ips)
+	}
+
+	if _, err := scanner.ScanCIDRFiles(context.Background(), filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("scanning a missing target file succeeded")
+	}
+}
@@ -0,0 +1,423 @@
+package network
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLS finding types
+const (
+	FindingCertificateExpired     = "certificate_expired"
+	FindingCertificateExpiring    = "certificate_expiring"
+	FindingCertificateNotYetValid = "certificate_not_yet_valid"
+	FindingSelfSignedCertificate  = "self_signed_certificate"
+	FindingUntrustedCertificate   = "untrusted_certificate"
+	FindingHostnameMismatch       = "hostname_mismatch"
+	FindingWeakKey                = "weak_key"
+	FindingWeakSignature          = "weak_signature"
+	FindingLegacyProtocol         = "legacy_protocol"
+	FindingWeakCipher             = "weak_cipher"
+)
+
+// tlsPorts are the ports inspected for TLS whatever service was detected
+var tlsPorts = map[int]bool{
+	443: true, 465: true, 563: true, 636: true, 853: true, 990: true,
+	992: true, 993: true, 994: true, 995: true, 2376: true, 3269: true,
+	5061: true, 5986: true, 6443: true, 8443: true, 9443: true,
+}
+
+// tlsServices are detected service names that imply TLS on any port
+var tlsServices = map[string]bool{
+	"SSL": true, "HTTPS": true, "IMAP-SSL": true, "POP3-SSL": true,
+}
+
+// tlsVersions are the protocol versions tried, newest first
+var tlsVersions = []uint16{tls.VersionTLS13, tls.VersionTLS12, tls.VersionTLS11, tls.VersionTLS10}
+
+// CertificateInfo describes one certificate of a served chain
+type CertificateInfo struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	SANs               []string  `json:"sans,omitempty"`
+	SerialNumber       string    `json:"serial_number"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	KeyType            string    `json:"key_type"`
+	KeySize            int       `json:"key_size"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	SHA256Fingerprint  string    `json:"sha256_fingerprint"`
+	SelfSigned         bool      `json:"self_signed,omitempty"`
+}
+
+// TLSCipherSuite is a cipher suite a port accepts with a protocol version
+type TLSCipherSuite struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	Weak    bool   `json:"weak,omitempty"`
+}
+
+// TLSInfo is the TLS configuration of a port: the protocol versions and
+// cipher suites it accepts, in the server's order of preference, and the
+// certificate chain it serves, leaf first
+type TLSInfo struct {
+	ServerName   string            `json:"server_name,omitempty"`
+	Versions     []string          `json:"versions"`
+	CipherSuites []TLSCipherSuite  `json:"cipher_suites"`
+	Certificates []CertificateInfo `json:"certificates"`
+	Trusted      bool              `json:"trusted"`
+	Findings     []Finding         `json:"findings,omitempty"`
+	InspectedAt  time.Time         `json:"inspected_at"`
+}
+
+// TLSInspector records the TLS configuration and certificates of open ports.
+// Versions are tried one at a time, and the cipher suites of each version
+// are enumerated by offering the ones not yet accepted until the server
+// refuses them all.
+type TLSInspector struct {
+	timeout     time.Duration
+	expiryAlert time.Duration
+}
+
+// NewTLSInspector creates an inspector. Certificates expiring within
+// expiryAlert are reported with a certificate_expiring finding.
+func NewTLSInspector(timeout, expiryAlert time.Duration) *TLSInspector {
+	return &TLSInspector{timeout: timeout, expiryAlert: expiryAlert}
+}
+
+// Inspect performs the TLS handshakes with a port. serverName, when known,
+// is sent as SNI and checked against the certificate. An error means the
+// port did not complete any handshake. When ctx is done no further
+// handshakes are attempted.
+func (t *TLSInspector) Inspect(ctx context.Context, ip string, port int, serverName string) (*TLSInfo, error) {
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	state, err := t.handshake(ctx, address, serverName, tls.VersionTLS10, tls.VersionTLS13, allCipherSuites())
+	if err != nil {
+		return nil, err
+	}
+
+	info := &TLSInfo{
+		ServerName:   serverName,
+		Versions:     []string{},
+		CipherSuites: []TLSCipherSuite{},
+		InspectedAt:  time.Now(),
+	}
+	for _, cert := range state.PeerCertificates {
+		info.Certificates = append(info.Certificates, describeCertificate(cert))
+	}
+
+	for _, version := range tlsVersions {
+		for _, suite := range t.enumerateSuites(ctx, address, serverName, version) {
+			info.CipherSuites = append(info.CipherSuites, TLSCipherSuite{
+				Version: tls.VersionName(version),
+				Name:    tls.CipherSuiteName(suite),
+				Weak:    isWeakCipherSuite(suite),
+			})
+		}
+		for _, suite := range info.CipherSuites {
+			if suite.Version == tls.VersionName(version) {
+				info.Versions = append(info.Versions, suite.Version)
+				break
+			}
+		}
+	}
+
+	info.Trusted = t.assess(info, state.PeerCertificates)
+	return info, nil
+}
+
+// handshake connects to a port and completes a TLS handshake limited to the
+// given versions and cipher suites. The chain is not verified here; see
+// assess.
+func (t *TLSInspector) handshake(ctx context.Context, address, serverName string, minVersion, maxVersion uint16, suites []uint16) (*tls.ConnectionState, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: t.timeout},
+		Config: &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: true,
+			MinVersion:         minVersion,
+			MaxVersion:         maxVersion,
+			CipherSuites:       suites,
+		},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	return &state, nil
+}
+
+// enumerateSuites returns the cipher suites a port accepts with a protocol
+// version, in the order the server prefers them. TLS 1.3 suites cannot be
+// chosen by the client, so only the one negotiated is reported for it.
+func (t *TLSInspector) enumerateSuites(ctx context.Context, address, serverName string, version uint16) []uint16 {
+	if version == tls.VersionTLS13 {
+		state, err := t.handshake(ctx, address, serverName, version, version, nil)
+		if err != nil {
+			return nil
+		}
+		return []uint16{state.CipherSuite}
+	}
+
+	var accepted []uint16
+	remaining := allCipherSuites()
+	for len(remaining) > 0 {
+		state, err := t.handshake(ctx, address, serverName, version, version, remaining)
+		if err != nil {
+			break
+		}
+		accepted = append(accepted, state.CipherSuite)
+
+		offered := remaining
+		remaining = remaining[:0:0]
+		for _, suite := range offered {
+			if suite != state.CipherSuite {
+				remaining = append(remaining, suite)
+			}
+		}
+		if len(remaining) == len(offered) {
+			break // the server chose a suite that was not offered
+		}
+	}
+	return accepted
+}
+
+// assess verifies the chain and records the findings of a port's TLS
+// configuration. It reports whether the chain is trusted by the system.
+func (t *TLSInspector) assess(info *TLSInfo, chain []*x509.Certificate) bool {
+	now := time.Now()
+	add := func(findingType string, severity Severity, format string, args ...interface{}) {
+		info.Findings = append(info.Findings, Finding{
+			Type:     findingType,
+			Severity: severity,
+			Detail:   fmt.Sprintf(format, args...),
+		})
+	}
+
+	for i, cert := range chain {
+		name := certificateName(cert)
+		switch {
+		case now.After(cert.NotAfter):
+			add(FindingCertificateExpired, SeverityHigh, "%s expired on %s", name, cert.NotAfter.Format("2006-01-02"))
+		case t.expiryAlert > 0 && now.Add(t.expiryAlert).After(cert.NotAfter):
+			days := int(cert.NotAfter.Sub(now).Hours() / 24)
+			add(FindingCertificateExpiring, SeverityMedium, "%s expires on %s (%d days)", name, cert.NotAfter.Format("2006-01-02"), days)
+		case now.Before(cert.NotBefore):
+			add(FindingCertificateNotYetValid, SeverityMedium, "%s is not valid before %s", name, cert.NotBefore.Format("2006-01-02"))
+		}
+
+		if keyType, size := publicKeyInfo(cert); isWeakKey(keyType, size) {
+			add(FindingWeakKey, SeverityHigh, "%s has a %d-bit %s key", name, size, keyType)
+		}
+
+		// The signature on a self-signed root is never checked
+		if i == 0 || !isSelfSigned(cert) {
+			switch cert.SignatureAlgorithm {
+			case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+				add(FindingWeakSignature, SeverityMedium, "%s is signed with %s", name, cert.SignatureAlgorithm)
+			}
+		}
+	}
+
+	trusted := false
+	if len(chain) > 0 {
+		leaf := chain[0]
+		intermediates := x509.NewCertPool()
+		for _, cert := range chain[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates})
+		trusted = err == nil
+
+		switch {
+		case isSelfSigned(leaf):
+			add(FindingSelfSignedCertificate, SeverityMedium, "%s is self-signed", certificateName(leaf))
+		case !trusted:
+			add(FindingUntrustedCertificate, SeverityMedium, "%s does not chain to a trusted root: %v", certificateName(leaf), err)
+		}
+
+		if info.ServerName != "" {
+			if err := leaf.VerifyHostname(info.ServerName); err != nil {
+				add(FindingHostnameMismatch, SeverityMedium, "%s is not valid for %s", certificateName(leaf), info.ServerName)
+			}
+		}
+	}
+
+	var legacy []string
+	for _, version := range info.Versions {
+		if version == tls.VersionName(tls.VersionTLS10) || version == tls.VersionName(tls.VersionTLS11) {
+			legacy = append(legacy, version)
+		}
+	}
+	if len(legacy) > 0 {
+		add(FindingLegacyProtocol, SeverityMedium, "accepts %s", strings.Join(legacy, ", "))
+	}
+
+	weak := make(map[string][]string)
+	for _, suite := range info.CipherSuites {
+		if suite.Weak {
+			weak[suite.Version] = append(weak[suite.Version], suite.Name)
+		}
+	}
+	for _, version := range info.Versions {
+		if suites := weak[version]; len(suites) > 0 {
+			add(FindingWeakCipher, SeverityMedium, "accepts %s with %s", strings.Join(suites, ", "), version)
+		}
+	}
+
+	return trusted
+}
+
+// describeCertificate extracts the inventory fields of a certificate
+func describeCertificate(cert *x509.Certificate) CertificateInfo {
+	keyType, size := publicKeyInfo(cert)
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	return CertificateInfo{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SANs:               sans,
+		SerialNumber:       cert.SerialNumber.Text(16),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		KeyType:            keyType,
+		KeySize:            size,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		SHA256Fingerprint:  hex.EncodeToString(fingerprint[:]),
+		SelfSigned:         isSelfSigned(cert),
+	}
+}
+
+// publicKeyInfo returns the algorithm and size in bits of a certificate's key
+func publicKeyInfo(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	}
+	return cert.PublicKeyAlgorithm.String(), 0
+}
+
+// isWeakKey reports keys below 2048-bit RSA or 224-bit elliptic curve strength
+func isWeakKey(keyType string, size int) bool {
+	switch keyType {
+	case "RSA", "DSA":
+		return size < 2048
+	case "ECDSA":
+		return size < 224
+	}
+	return false
+}
+
+// isSelfSigned reports whether a certificate is signed by its own key. The
+// signature is checked directly rather than with CheckSignatureFrom, which
+// refuses a leaf that is not marked as a CA.
+func isSelfSigned(cert *x509.Certificate) bool {
+	return cert.Subject.String() == cert.Issuer.String() &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// certificateName names a certificate in findings by its common name, or
+// its full subject when it has none
+func certificateName(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return "certificate " + strconv.Quote(cert.Subject.CommonName)
+	}
+	return "certificate " + strconv.Quote(cert.Subject.String())
+}
+
+// allCipherSuites returns every TLS 1.0-1.2 cipher suite the client can
+// offer, including the insecure ones
+func allCipherSuites() []uint16 {
+	var suites []uint16
+	for _, suite := range tls.CipherSuites() {
+		suites = append(suites, suite.ID)
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		suites = append(suites, suite.ID)
+	}
+	return suites
+}
+
+// isWeakCipherSuite reports suites with known weaknesses: RC4, 3DES, CBC
+// with SHA-256 and suites without forward secrecy
+func isWeakCipherSuite(id uint16) bool {
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.ID == id {
+			return true
+		}
+	}
+	return strings.HasPrefix(tls.CipherSuiteName(id), "TLS_RSA_")
+}
+
+// isTLSCandidate reports whether a port result should be inspected for TLS:
+// an open TCP port on a well-known TLS port or where TLS was detected
+func isTLSCandidate(result *PortScanResult) bool {
+	if result.Protocol != ScanTCP || result.State != PortOpen {
+		return false
+	}
+	return tlsPorts[result.Port] || tlsServices[result.Service]
+}
+
+// inspectTLS inspects the TLS candidates among port results, up to
+// concurrency at a time until ctx is done, and records what it finds on them
+func (t *TLSInspector) inspectTLS(ctx context.Context, results []PortScanResult, serverName string, concurrency int) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for i := range results {
+		if !isTLSCandidate(&results[i]) {
+			continue
+		}
+		if !acquire(ctx, sem) {
+			break
+		}
+		wg.Add(1)
+
+		go func(result *PortScanResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			info, err := t.Inspect(ctx, result.IP, result.Port, serverName)
+			if err != nil {
+				return
+			}
+			result.TLS = info
+			if result.Service == "unknown" {
+				result.Service = "SSL"
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+}
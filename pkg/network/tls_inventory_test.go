@@ -0,0 +1,182 @@
+package network
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// findingTypes returns the types of a TLS inspection's findings
+func findingTypes(info *TLSInfo) []string {
+	var types []string
+	for _, finding := range info.Findings {
+		types = append(types, finding.Type)
+	}
+	return types
+}
+
+func TestInspectTLSServer(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// Refused versions and suites are logged by the server otherwise
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	host, port := splitServerURL(t, server.URL)
+
+	inspector := NewTLSInspector(2*time.Second, 30*24*time.Hour)
+	info, err := inspector.Inspect(context.Background(), host, port, "www.example.org")
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+
+	if !slices.Contains(info.Versions, "TLS 1.3") || !slices.Contains(info.Versions, "TLS 1.2") {
+		t.Errorf("versions = %v, want TLS 1.3 and 1.2", info.Versions)
+	}
+	if slices.Contains(info.Versions, "TLS 1.0") {
+		t.Errorf("versions = %v, but the server refuses TLS 1.0", info.Versions)
+	}
+	if len(info.CipherSuites) < 2 {
+		t.Errorf("cipher suites = %v, want every suite the server accepts", info.CipherSuites)
+	}
+	if len(info.Certificates) == 0 || info.Certificates[0].SHA256Fingerprint == "" {
+		t.Fatalf("certificates = %+v", info.Certificates)
+	}
+
+	// The test certificate is issued for example.com by an untrusted CA
+	types := findingTypes(info)
+	if info.Trusted || !slices.Contains(types, FindingHostnameMismatch) {
+		t.Errorf("trusted %v, findings %v; want an untrusted chain and a hostname mismatch", info.Trusted, types)
+	}
+
+	if _, err := inspector.Inspect(context.Background(), host, closedPort(t), ""); err == nil {
+		t.Error("Inspect of a closed port succeeded")
+	}
+}
+
+// testCertificate creates a self-signed certificate
+func testCertificate(t *testing.T, template *x509.Certificate, key any, public any) *x509.Certificate {
+	t.Helper()
+	der, err := x509.CreateCertificate(rand.Reader, template, template, public, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return cert
+}
+
+func TestAssessTLS(t *testing.T) {
+	now := time.Now()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	expiring := testCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "expiring.example.com"},
+		DNSNames:     []string{"expiring.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(10 * 24 * time.Hour),
+	}, ecKey, &ecKey.PublicKey)
+	weak := testCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "old.example.com"},
+		NotBefore:    now.Add(-48 * time.Hour),
+		NotAfter:     now.Add(-24 * time.Hour),
+	}, rsaKey, &rsaKey.PublicKey)
+
+	inspector := NewTLSInspector(time.Second, 30*24*time.Hour)
+	tests := []struct {
+		name  string
+		info  TLSInfo
+		chain []*x509.Certificate
+		want  []string
+	}{
+		{
+			name:  "expiring",
+			info:  TLSInfo{ServerName: "expiring.example.com", Versions: []string{"TLS 1.3"}},
+			chain: []*x509.Certificate{expiring},
+			want:  []string{FindingCertificateExpiring, FindingSelfSignedCertificate},
+		},
+		{
+			name: "expired weak key on legacy protocols",
+			info: TLSInfo{
+				ServerName: "www.example.com",
+				Versions:   []string{"TLS 1.2", "TLS 1.0"},
+				CipherSuites: []TLSCipherSuite{
+					{Version: "TLS 1.0", Name: "TLS_RSA_WITH_AES_128_CBC_SHA", Weak: true},
+				},
+			},
+			chain: []*x509.Certificate{weak},
+			want:  []string{FindingCertificateExpired, FindingWeakKey, FindingSelfSignedCertificate, FindingHostnameMismatch, FindingLegacyProtocol, FindingWeakCipher},
+		},
+	}
+	for _, tt := range tests {
+		info := tt.info
+		if inspector.assess(&info, tt.chain) {
+			t.Errorf("%s: self-signed chain trusted", tt.name)
+		}
+		if types := findingTypes(&info); !slices.Equal(types, tt.want) {
+			t.Errorf("%s: findings %v, want %v", tt.name, types, tt.want)
+		}
+	}
+
+	described := describeCertificate(weak)
+	if described.KeyType != "RSA" || described.KeySize != 1024 || !described.SelfSigned {
+		t.Errorf("describeCertificate = %+v", described)
+	}
+}
+
+func TestIsWeakCipherSuite(t *testing.T) {
+	tests := []struct {
+		suite uint16
+		weak  bool
+	}{
+		{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, false},
+		{tls.TLS_RSA_WITH_AES_128_GCM_SHA256, true},
+		{tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA, true},
+	}
+	for _, tt := range tests {
+		if got := isWeakCipherSuite(tt.suite); got != tt.weak {
+			t.Errorf("isWeakCipherSuite(%s) = %v, want %v", tls.CipherSuiteName(tt.suite), got, tt.weak)
+		}
+	}
+}
+
+func TestIsTLSCandidate(t *testing.T) {
+	tests := []struct {
+		result PortScanResult
+		want   bool
+	}{
+		{PortScanResult{Port: 443, Protocol: ScanTCP, State: PortOpen}, true},
+		{PortScanResult{Port: 4443, Protocol: ScanTCP, State: PortOpen, Service: "HTTPS"}, true},
+		{PortScanResult{Port: 80, Protocol: ScanTCP, State: PortOpen, Service: "HTTP"}, false},
+		{PortScanResult{Port: 443, Protocol: ScanTCP, State: PortFiltered}, false},
+		{PortScanResult{Port: 443, Protocol: ScanUDP, State: PortOpen}, false},
+	}
+	for _, tt := range tests {
+		if got := isTLSCandidate(&tt.result); got != tt.want {
+			t.Errorf("isTLSCandidate(%+v) = %v, want %v", tt.result, got, tt.want)
+		}
+	}
+}
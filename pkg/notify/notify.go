@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"assetmanager/pkg/store"
+)
+
+// EventFindings is the event sent when a scan raises network findings
+const EventFindings = "network_findings"
+
+// Event is the JSON body posted to the webhook
+type Event struct {
+	Event     string                `json:"event"`
+	Timestamp time.Time             `json:"timestamp"`
+	Findings  []store.StoredFinding `json:"findings"`
+}
+
+// Notifier posts events to a webhook, such as a chat integration or an
+// incident tool accepting JSON
+type Notifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a notifier posting to url, each delivery bounded by timeout
+func NewWebhook(url string, timeout time.Duration) *Notifier {
+	return &Notifier{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// NotifyFindings posts the findings a scan raised. Nothing is sent when
+// there are none.
+func (n *Notifier) NotifyFindings(findings []store.StoredFinding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(Event{
+		Event:     EventFindings,
+		Timestamp: time.Now(),
+		Findings:  findings,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notification webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"assetmanager/pkg/network"
+	"assetmanager/pkg/store"
+)
+
+func TestNotifyFindings(t *testing.T) {
+	received := make(chan Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&event) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	notifier := NewWebhook(server.URL, time.Second)
+	if err := notifier.NotifyFindings(nil); err != nil {
+		t.Fatalf("NotifyFindings of none: %v", err)
+	}
+	select {
+	case <-received:
+		t.Fatal("a notification was sent without findings")
+	default:
+	}
+
+	finding := store.StoredFinding{ID: 7, ScanID: 3}
+	finding.Type = network.FindingIPConflict
+	if err := notifier.NotifyFindings([]store.StoredFinding{finding}); err != nil {
+		t.Fatalf("NotifyFindings: %v", err)
+	}
+	event := <-received
+	if event.Event != EventFindings || len(event.Findings) != 1 || event.Findings[0].ID != 7 || event.Findings[0].Type != network.FindingIPConflict {
+		t.Errorf("event = %+v", event)
+	}
+}
+
+func TestNotifyFindingsFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	findings := []store.StoredFinding{{ID: 1}}
+	if err := NewWebhook(server.URL, time.Second).NotifyFindings(findings); err == nil {
+		t.Error("a webhook answering 503 reported success")
+	}
+	server.Close()
+	if err := NewWebhook(server.URL, time.Second).NotifyFindings(findings); err == nil {
+		t.Error("an unreachable webhook reported success")
+	}
+}
@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// State is what the scheduler is currently doing
+type State string
+
+const (
+	// StateIdle indicates the scheduler is waiting for the next run
+	StateIdle State = "idle"
+	// StateRunning indicates a scan is in progress
+	StateRunning State = "running"
+	// StateStopped indicates the scheduler has shut down
+	StateStopped State = "stopped"
+)
+
+// Status is a snapshot of the scheduler state
+type Status struct {
+	State            State      `json:"state"`
+	Interval         string     `json:"interval"`
+	NextRun          *time.Time `json:"next_run,omitempty"`
+	LastRunStarted   *time.Time `json:"last_run_started,omitempty"`
+	LastRunCompleted *time.Time `json:"last_run_completed,omitempty"`
+	LastDuration     string     `json:"last_duration,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+	RunCount         int        `json:"run_count"`
+}
+
+// Scheduler runs a scan function immediately and then at a fixed interval,
+// keeping track of when it last ran and when it will run next
+type Scheduler struct {
+	interval time.Duration
+	scan     func(ctx context.Context) error
+	mu       sync.RWMutex
+	status   Status
+}
+
+// New creates a scheduler that calls scan every interval. The context scan
+// is given is cancelled when the scheduler is stopped.
+func New(interval time.Duration, scan func(ctx context.Context) error) *Scheduler {
+	return &Scheduler{
+		interval: interval,
+		scan:     scan,
+		status: Status{
+			State:    StateIdle,
+			Interval: interval.String(),
+		},
+	}
+}
+
+// Run performs a scan right away and then one per interval until ctx is
+// cancelled. A scan in progress is cancelled with it, and Run returns once
+// the scan has stopped.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.runOnce(ctx)
+
+	for {
+		select {
+		case <-ticker.C:
+			s.runOnce(ctx)
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.status.State = StateStopped
+			s.status.NextRun = nil
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// runOnce performs a single scan and records its outcome
+func (s *Scheduler) runOnce(ctx context.Context) {
+	started := time.Now()
+	s.mu.Lock()
+	s.status.State = StateRunning
+	s.status.LastRunStarted = &started
+	s.status.NextRun = nil
+	s.mu.Unlock()
+
+	err := s.scan(ctx)
+
+	completed := time.Now()
+	next := started.Add(s.interval)
+	if next.Before(completed) {
+		// The scan overran the interval; the ticker fires right away
+		next = completed
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.State = StateIdle
+	s.status.LastRunCompleted = &completed
+	s.status.LastDuration = completed.Sub(started).String()
+	s.status.NextRun = &next
+	s.status.RunCount++
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+}
+
+// Status returns a snapshot of the scheduler state
+func (s *Scheduler) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan int, 10)
+	count := 0
+	s := New(10*time.Millisecond, func(ctx context.Context) error {
+		count++
+		runs <- count
+		if count == 2 {
+			return errors.New("sweep failed")
+		}
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	// The first scan runs right away, the next ones every interval
+	for want := 1; want <= 3; want++ {
+		select {
+		case got := <-runs:
+			if got != want {
+				t.Fatalf("run %d, want %d", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("scan %d did not run", want)
+		}
+	}
+	cancel()
+	<-done
+
+	status := s.Status()
+	if status.State != StateStopped || status.NextRun != nil {
+		t.Errorf("stopped scheduler is %s, next run %v", status.State, status.NextRun)
+	}
+	if status.RunCount < 3 || status.LastRunCompleted == nil || status.Interval != "10ms" {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestRunOnceRecordsOutcome(t *testing.T) {
+	fail := true
+	s := New(time.Hour, func(ctx context.Context) error {
+		if fail {
+			return errors.New("sweep failed")
+		}
+		return nil
+	})
+
+	s.runOnce(context.Background())
+	status := s.Status()
+	if status.State != StateIdle || status.LastError != "sweep failed" || status.RunCount != 1 {
+		t.Errorf("after a failed scan, status = %+v", status)
+	}
+	if status.NextRun == nil || !status.NextRun.Equal(status.LastRunStarted.Add(time.Hour)) {
+		t.Errorf("next run %v, want an interval after %v", status.NextRun, status.LastRunStarted)
+	}
+
+	fail = false
+	s.runOnce(context.Background())
+	if status := s.Status(); status.LastError != "" || status.RunCount != 2 {
+		t.Errorf("a successful scan left status %+v", status)
+	}
+}
+
+func TestRunOnceWhileRunning(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := New(time.Hour, func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	go s.runOnce(context.Background())
+	<-started
+	if status := s.Status(); status.State != StateRunning || status.LastRunStarted == nil || status.NextRun != nil {
+		t.Errorf("status during a scan = %+v", status)
+	}
+	close(release)
+}
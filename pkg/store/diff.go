@@ -0,0 +1,367 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"assetmanager/pkg/network"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// FieldDelta is the old and new value of a changed asset field
+type FieldDelta struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// AssetDelta describes how a single asset differs between two scans
+type AssetDelta struct {
+	AssetID     string      `json:"asset_id"`
+	IP          string      `json:"ip"`
+	IPChange    *FieldDelta `json:"ip_change,omitempty"`
+	MAC         *FieldDelta `json:"mac,omitempty"`
+	Hostname    *FieldDelta `json:"hostname,omitempty"`
+	OpenedPorts []string    `json:"opened_ports,omitempty"`
+	ClosedPorts []string    `json:"closed_ports,omitempty"`
+}
+
+// ScanDiff is the difference between the asset snapshots of two scan runs
+type ScanDiff struct {
+	FromScan          ScanRun         `json:"from_scan"`
+	ToScan            ScanRun         `json:"to_scan"`
+	NewAssets         []network.Asset `json:"new_assets"`
+	DisappearedAssets []network.Asset `json:"disappeared_assets"`
+	ChangedAssets     []AssetDelta    `json:"changed_assets"`
+}
+
+// putSnapshot stores the assets found by a scan run
+func putSnapshot(tx *bolt.Tx, scanID uint64, assets []network.Asset) error {
+	data, err := json.Marshal(assets)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot of scan %d: %w", scanID, err)
+	}
+	if err := tx.Bucket(snapshotsBucket).Put(itob(scanID), data); err != nil {
+		return fmt.Errorf("failed to store snapshot of scan %d: %w", scanID, err)
+	}
+	return nil
+}
+
+// GetScan returns a recorded scan run by ID
+func (s *Store) GetScan(id uint64) (*ScanRun, bool, error) {
+	var run *ScanRun
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(scansBucket)
+		if b == nil {
+			return nil
+		}
+		data := b.Get(itob(id))
+		if data == nil {
+			return nil
+		}
+		run = &ScanRun{}
+		return json.Unmarshal(data, run)
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read scan %d: %w", id, err)
+	}
+
+	return run, run != nil, nil
+}
+
+// FindScanBefore returns the latest scan run completed at or before t
+func (s *Store) FindScanBefore(t time.Time) (*ScanRun, bool, error) {
+	scans, err := s.GetScans(0)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, run := range scans {
+		if !run.CompletedAt.After(t) {
+			return &run, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// GetSnapshot returns the assets recorded by a scan run
+func (s *Store) GetSnapshot(scanID uint64) ([]network.Asset, error) {
+	var assets []network.Asset
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(snapshotsBucket)
+		if b == nil {
+			return fmt.Errorf("no snapshot recorded for scan %d", scanID)
+		}
+		data := b.Get(itob(scanID))
+		if data == nil {
+			return fmt.Errorf("no snapshot recorded for scan %d", scanID)
+		}
+		return json.Unmarshal(data, &assets)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return assets, nil
+}
+
+// DiffScans compares the asset snapshots of two recorded scan runs
+func (s *Store) DiffScans(fromID, toID uint64) (*ScanDiff, error) {
+	runs := make([]ScanRun, 2)
+	snapshots := make([][]network.Asset, 2)
+
+	for i, id := range []uint64{fromID, toID} {
+		run, ok, err := s.GetScan(id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("scan %d not found", id)
+		}
+		runs[i] = *run
+
+		snapshots[i], err = s.GetSnapshot(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	diff := DiffSnapshots(snapshots[0], snapshots[1])
+	diff.FromScan = runs[0]
+	diff.ToScan = runs[1]
+	return diff, nil
+}
+
+// ResolveScanRange picks the two scan runs to compare. from and to are scan
+// IDs; since is a date ("2006-01-02") or timestamp (RFC 3339 or
+// "2006-01-02 15:04:05") selecting the last scan at or before that time as
+// the starting point. An empty to means the latest scan, and when neither
+// from nor since is given the scan preceding to is used.
+func (s *Store) ResolveScanRange(from, to, since string) (uint64, uint64, error) {
+	if from != "" && since != "" {
+		return 0, 0, fmt.Errorf("from and since are mutually exclusive")
+	}
+
+	var toID uint64
+	if to != "" {
+		id, err := strconv.ParseUint(to, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid scan ID %q", to)
+		}
+		toID = id
+	} else {
+		latest, ok, err := s.GetLatestScan()
+		if err != nil {
+			return 0, 0, err
+		}
+		if !ok {
+			return 0, 0, fmt.Errorf("no scans have been recorded yet")
+		}
+		toID = latest.ID
+	}
+
+	switch {
+	case from != "":
+		id, err := strconv.ParseUint(from, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid scan ID %q", from)
+		}
+		return id, toID, nil
+
+	case since != "":
+		t, err := parseSince(since)
+		if err != nil {
+			return 0, 0, err
+		}
+		run, ok, err := s.FindScanBefore(t)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !ok {
+			return 0, 0, fmt.Errorf("no scan recorded at or before %s", since)
+		}
+		return run.ID, toID, nil
+
+	default:
+		if toID <= 1 {
+			return 0, 0, fmt.Errorf("no scan recorded before scan %d", toID)
+		}
+		return toID - 1, toID, nil
+	}
+}
+
+// parseSince parses the since argument of ResolveScanRange in local time
+func parseSince(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			if layout == "2006-01-02" {
+				// A bare date means "as of the end of that day"
+				t = t.Add(24*time.Hour - time.Nanosecond)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid since value %q", value)
+}
+
+// DiffSnapshots compares two asset snapshots. Each snapshot is deduplicated
+// first, so duplicate entries for the same asset are merged before comparing.
+func DiffSnapshots(from, to []network.Asset) *ScanDiff {
+	before := indexAssets(network.DeduplicateAssets(from))
+	after := indexAssets(network.DeduplicateAssets(to))
+
+	diff := &ScanDiff{
+		NewAssets:         []network.Asset{},
+		DisappearedAssets: []network.Asset{},
+		ChangedAssets:     []AssetDelta{},
+	}
+
+	for _, id := range sortedAssetIDs(after) {
+		asset := after[id]
+		old, ok := before[id]
+		if !ok {
+			diff.NewAssets = append(diff.NewAssets, asset)
+			continue
+		}
+		if delta, changed := diffAsset(old, asset); changed {
+			diff.ChangedAssets = append(diff.ChangedAssets, delta)
+		}
+	}
+
+	for _, id := range sortedAssetIDs(before) {
+		if _, ok := after[id]; !ok {
+			diff.DisappearedAssets = append(diff.DisappearedAssets, before[id])
+		}
+	}
+
+	return diff
+}
+
+// diffAsset reports the IP, port, MAC and hostname differences of one asset
+func diffAsset(before, after network.Asset) (AssetDelta, bool) {
+	delta := AssetDelta{AssetID: after.AssetID(), IP: after.IP}
+	changed := false
+
+	if before.IP != after.IP {
+		delta.IPChange = &FieldDelta{Old: before.IP, New: after.IP}
+		changed = true
+	}
+	if !strings.EqualFold(before.MAC, after.MAC) {
+		delta.MAC = &FieldDelta{Old: before.MAC, New: after.MAC}
+		changed = true
+	}
+	if before.Hostname != after.Hostname {
+		delta.Hostname = &FieldDelta{Old: before.Hostname, New: after.Hostname}
+		changed = true
+	}
+
+	for _, change := range diffPorts(before.OpenPorts, after.OpenPorts) {
+		switch change.Type {
+		case ChangePortOpened:
+			delta.OpenedPorts = append(delta.OpenedPorts, change.NewValue)
+		case ChangePortClosed:
+			delta.ClosedPorts = append(delta.ClosedPorts, change.OldValue)
+		}
+		changed = true
+	}
+
+	return delta, changed
+}
+
+func indexAssets(assets []network.Asset) map[string]network.Asset {
+	index := make(map[string]network.Asset, len(assets))
+	for _, asset := range assets {
+		index[asset.AssetID()] = asset
+	}
+	return index
+}
+
+func sortedAssetIDs(index map[string]network.Asset) []string {
+	ids := make([]string, 0, len(index))
+	for id := range index {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// WriteText writes the diff as a human-readable report
+func (d *ScanDiff) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Scan diff: #%d (%s) -> #%d (%s)\n",
+		d.FromScan.ID, d.FromScan.CompletedAt.Format("2006-01-02 15:04:05"),
+		d.ToScan.ID, d.ToScan.CompletedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "%d new, %d disappeared, %d changed\n",
+		len(d.NewAssets), len(d.DisappearedAssets), len(d.ChangedAssets))
+
+	if len(d.NewAssets) > 0 {
+		fmt.Fprintf(&b, "\nNew assets:\n")
+		for _, asset := range d.NewAssets {
+			fmt.Fprintf(&b, "  + %s\n", describeAsset(asset))
+		}
+	}
+
+	if len(d.DisappearedAssets) > 0 {
+		fmt.Fprintf(&b, "\nDisappeared assets:\n")
+		for _, asset := range d.DisappearedAssets {
+			fmt.Fprintf(&b, "  - %s\n", describeAsset(asset))
+		}
+	}
+
+	if len(d.ChangedAssets) > 0 {
+		fmt.Fprintf(&b, "\nChanged assets:\n")
+		for _, delta := range d.ChangedAssets {
+			fmt.Fprintf(&b, "  ~ %s\n", delta.IP)
+			if delta.IPChange != nil {
+				fmt.Fprintf(&b, "      IP: %s -> %s\n", orNone(delta.IPChange.Old), orNone(delta.IPChange.New))
+			}
+			if delta.MAC != nil {
+				fmt.Fprintf(&b, "      MAC: %s -> %s\n", orNone(delta.MAC.Old), orNone(delta.MAC.New))
+			}
+			if delta.Hostname != nil {
+				fmt.Fprintf(&b, "      hostname: %s -> %s\n", orNone(delta.Hostname.Old), orNone(delta.Hostname.New))
+			}
+			if len(delta.OpenedPorts) > 0 {
+				fmt.Fprintf(&b, "      ports opened: %s\n", strings.Join(delta.OpenedPorts, ", "))
+			}
+			if len(delta.ClosedPorts) > 0 {
+				fmt.Fprintf(&b, "      ports closed: %s\n", strings.Join(delta.ClosedPorts, ", "))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// describeAsset formats an asset as a single report line
+func describeAsset(asset network.Asset) string {
+	parts := []string{asset.IP}
+	if asset.MAC != "" {
+		parts = append(parts, asset.MAC)
+	}
+	if asset.Hostname != "" {
+		parts = append(parts, asset.Hostname)
+	}
+	if ports := sortedKeys(openPortSet(asset.OpenPorts)); len(ports) > 0 {
+		parts = append(parts, "ports "+strings.Join(ports, ","))
+	}
+	return strings.Join(parts, "  ")
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
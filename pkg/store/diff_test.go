@@ -0,0 +1,113 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"assetmanager/pkg/network"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	from := []network.Asset{
+		{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01", Hostname: "a.local", OpenPorts: []network.PortScanResult{openPort(22)}},
+		{IP: "10.0.0.2", MAC: "aa:bb:cc:00:00:02"},
+		{IP: "10.0.0.3", MAC: "aa:bb:cc:00:00:03"},
+	}
+	to := []network.Asset{
+		{IP: "10.0.0.9", MAC: "aa:bb:cc:00:00:01", Hostname: "b.local", OpenPorts: []network.PortScanResult{openPort(80)}},
+		{IP: "10.0.0.3", MAC: "AA:BB:CC:00:00:03"},
+		{IP: "10.0.0.4", MAC: "aa:bb:cc:00:00:04"},
+		{IP: "10.0.0.4", MAC: "aa:bb:cc:00:00:04"},
+	}
+
+	diff := DiffSnapshots(from, to)
+	if len(diff.NewAssets) != 1 || diff.NewAssets[0].IP != "10.0.0.4" {
+		t.Errorf("new assets = %+v, want 10.0.0.4 once", diff.NewAssets)
+	}
+	if len(diff.DisappearedAssets) != 1 || diff.DisappearedAssets[0].IP != "10.0.0.2" {
+		t.Errorf("disappeared assets = %+v, want 10.0.0.2", diff.DisappearedAssets)
+	}
+	if len(diff.ChangedAssets) != 1 {
+		t.Fatalf("changed assets = %+v, want only aa:bb:cc:00:00:01", diff.ChangedAssets)
+	}
+
+	delta := diff.ChangedAssets[0]
+	if delta.AssetID != "aa:bb:cc:00:00:01" || delta.IPChange == nil || delta.IPChange.Old != "10.0.0.1" || delta.IPChange.New != "10.0.0.9" {
+		t.Errorf("delta = %+v, want an IP change 10.0.0.1 -> 10.0.0.9", delta)
+	}
+	if delta.Hostname == nil || delta.MAC != nil {
+		t.Errorf("delta hostname %v, MAC %v; want a hostname change only", delta.Hostname, delta.MAC)
+	}
+	if strings.Join(delta.OpenedPorts, ",") != "80/tcp" || strings.Join(delta.ClosedPorts, ",") != "22/tcp" {
+		t.Errorf("opened %v, closed %v; want 80/tcp opened, 22/tcp closed", delta.OpenedPorts, delta.ClosedPorts)
+	}
+}
+
+func TestDiffScans(t *testing.T) {
+	s := openTestStore(t)
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.Local)
+	recordScan(t, s, at, network.Asset{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"})
+	recordScan(t, s, at.Add(24*time.Hour), network.Asset{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"}, network.Asset{IP: "10.0.0.2", MAC: "aa:bb:cc:00:00:02"})
+	recordScan(t, s, at.Add(48*time.Hour), network.Asset{IP: "10.0.0.2", MAC: "aa:bb:cc:00:00:02"})
+
+	diff, err := s.DiffScans(1, 3)
+	if err != nil {
+		t.Fatalf("DiffScans: %v", err)
+	}
+	if diff.FromScan.ID != 1 || diff.ToScan.ID != 3 || len(diff.NewAssets) != 1 || len(diff.DisappearedAssets) != 1 {
+		t.Errorf("DiffScans(1, 3) = %+v", diff)
+	}
+
+	var report strings.Builder
+	if err := diff.WriteText(&report); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	for _, want := range []string{"#1", "#3", "1 new, 1 disappeared, 0 changed", "+ 10.0.0.2", "- 10.0.0.1"} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, report.String())
+		}
+	}
+
+	if _, err := s.DiffScans(1, 9); err == nil {
+		t.Error("DiffScans with an unknown scan succeeded")
+	}
+}
+
+func TestResolveScanRange(t *testing.T) {
+	s := openTestStore(t)
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		recordScan(t, s, at.Add(time.Duration(i)*24*time.Hour))
+	}
+
+	tests := []struct {
+		from, to, since string
+		wantFrom        uint64
+		wantTo          uint64
+		wantErr         bool
+	}{
+		{wantFrom: 2, wantTo: 3},
+		{to: "2", wantFrom: 1, wantTo: 2},
+		{from: "1", wantFrom: 1, wantTo: 3},
+		{since: "2025-07-02", wantFrom: 2, wantTo: 3},
+		{since: "2025-07-02 09:00:00", wantFrom: 1, wantTo: 3},
+		{to: "1", wantErr: true},
+		{from: "1", since: "2025-07-02", wantErr: true},
+		{from: "x", wantErr: true},
+		{since: "yesterday", wantErr: true},
+		{since: "2025-06-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		from, to, err := s.ResolveScanRange(tt.from, tt.to, tt.since)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ResolveScanRange(%q, %q, %q) = %d, %d; want an error", tt.from, tt.to, tt.since, from, to)
+			}
+			continue
+		}
+		if err != nil || from != tt.wantFrom || to != tt.wantTo {
+			t.Errorf("ResolveScanRange(%q, %q, %q) = %d, %d, %v; want %d, %d", tt.from, tt.to, tt.since, from, to, err, tt.wantFrom, tt.wantTo)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"assetmanager/pkg/network"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// StoredFinding is a network finding recorded with the scan that raised it
+type StoredFinding struct {
+	ID        uint64    `json:"id"`
+	ScanID    uint64    `json:"scan_id"`
+	Timestamp time.Time `json:"timestamp"`
+	network.NetworkFinding
+}
+
+// FindingQuery selects recorded findings. Zero values match everything; a
+// Limit of zero or less returns every match.
+type FindingQuery struct {
+	Since    time.Time
+	Type     string
+	Severity network.Severity
+	Limit    int
+}
+
+// RecordFindings appends the findings raised by a scan run and returns them
+// as stored, with their IDs
+func (s *Store) RecordFindings(run ScanRun, findings []network.NetworkFinding) ([]StoredFinding, error) {
+	stored := make([]StoredFinding, 0, len(findings))
+	if len(findings) == 0 {
+		return stored, nil
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(findingsBucket)
+		for _, finding := range findings {
+			id, err := b.NextSequence()
+			if err != nil {
+				return fmt.Errorf("failed to allocate finding ID: %w", err)
+			}
+			record := StoredFinding{
+				ID:             id,
+				ScanID:         run.ID,
+				Timestamp:      run.CompletedAt,
+				NetworkFinding: finding,
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("failed to encode finding %d: %w", id, err)
+			}
+			if err := b.Put(itob(id), data); err != nil {
+				return fmt.Errorf("failed to store finding %d: %w", id, err)
+			}
+			stored = append(stored, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// GetFindings returns the recorded findings matching a query, newest first
+func (s *Store) GetFindings(query FindingQuery) ([]StoredFinding, error) {
+	findings := []StoredFinding{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(findingsBucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if query.Limit > 0 && len(findings) >= query.Limit {
+				break
+			}
+			var finding StoredFinding
+			if err := json.Unmarshal(v, &finding); err != nil {
+				return fmt.Errorf("failed to decode finding: %w", err)
+			}
+			if !query.Since.IsZero() && finding.Timestamp.Before(query.Since) {
+				break
+			}
+			if query.Type != "" && finding.Type != query.Type {
+				continue
+			}
+			if query.Severity != "" && finding.Severity != query.Severity {
+				continue
+			}
+			findings = append(findings, finding)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return findings, nil
+}
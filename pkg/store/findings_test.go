@@ -0,0 +1,79 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"assetmanager/pkg/network"
+)
+
+func networkFinding(findingType string, severity network.Severity) network.NetworkFinding {
+	return network.NetworkFinding{
+		Finding: network.Finding{Type: findingType, Severity: severity, Detail: findingType},
+		IPs:     []string{"10.0.0.1"},
+		MACs:    []string{"aa:bb:cc:00:00:01"},
+	}
+}
+
+func TestFindings(t *testing.T) {
+	s := openTestStore(t)
+	first := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	if stored, err := s.RecordFindings(ScanRun{ID: 1, CompletedAt: first}, nil); err != nil || len(stored) != 0 {
+		t.Fatalf("RecordFindings of none = %v, %v", stored, err)
+	}
+	stored, err := s.RecordFindings(ScanRun{ID: 1, CompletedAt: first}, []network.NetworkFinding{
+		networkFinding(network.FindingIPConflict, network.SeverityHigh),
+		networkFinding(network.FindingIPMACChanged, network.SeverityMedium),
+	})
+	if err != nil {
+		t.Fatalf("RecordFindings: %v", err)
+	}
+	if len(stored) != 2 || stored[0].ID != 1 || stored[1].ID != 2 || stored[1].ScanID != 1 || !stored[1].Timestamp.Equal(first) {
+		t.Fatalf("stored = %+v, want IDs 1 and 2 from scan 1", stored)
+	}
+	if _, err := s.RecordFindings(ScanRun{ID: 2, CompletedAt: second}, []network.NetworkFinding{
+		networkFinding(network.FindingIPConflict, network.SeverityHigh),
+	}); err != nil {
+		t.Fatalf("RecordFindings: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query FindingQuery
+		ids   []uint64
+	}{
+		{"all, newest first", FindingQuery{}, []uint64{3, 2, 1}},
+		{"by type", FindingQuery{Type: network.FindingIPConflict}, []uint64{3, 1}},
+		{"by severity", FindingQuery{Severity: network.SeverityMedium}, []uint64{2}},
+		{"since", FindingQuery{Since: second}, []uint64{3}},
+		{"limit", FindingQuery{Limit: 2}, []uint64{3, 2}},
+		{"no match", FindingQuery{Type: network.FindingGatewayMACChanged}, []uint64{}},
+	}
+	for _, tt := range tests {
+		findings, err := s.GetFindings(tt.query)
+		if err != nil {
+			t.Fatalf("%s: GetFindings: %v", tt.name, err)
+		}
+		ids := []uint64{}
+		for _, finding := range findings {
+			ids = append(ids, finding.ID)
+		}
+		if len(ids) != len(tt.ids) {
+			t.Errorf("%s: IDs %v, want %v", tt.name, ids, tt.ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.ids[i] {
+				t.Errorf("%s: IDs %v, want %v", tt.name, ids, tt.ids)
+				break
+			}
+		}
+	}
+
+	findings, _ := s.GetFindings(FindingQuery{Limit: 1})
+	if len(findings) != 1 || findings[0].IPs[0] != "10.0.0.1" || findings[0].MACs[0] != "aa:bb:cc:00:00:01" {
+		t.Errorf("finding = %+v, want its addresses kept", findings)
+	}
+}
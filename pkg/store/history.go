@@ -0,0 +1,187 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ChangeType identifies the kind of change recorded on an asset timeline
+type ChangeType string
+
+const (
+	// ChangeDiscovered marks the first time an asset was seen
+	ChangeDiscovered ChangeType = "discovered"
+	// ChangePortOpened marks a port that is newly open
+	ChangePortOpened ChangeType = "port_opened"
+	// ChangePortClosed marks a previously open port that is no longer open
+	ChangePortClosed ChangeType = "port_closed"
+	// ChangeMAC marks a change of the asset's MAC address
+	ChangeMAC ChangeType = "mac_changed"
+	// ChangeIP marks a device seen at a different IP address
+	ChangeIP ChangeType = "ip_changed"
+	// ChangeHostname marks a change of the asset's hostname
+	ChangeHostname ChangeType = "hostname_changed"
+	// ChangeOffline marks an asset that was not seen by a scan
+	ChangeOffline ChangeType = "offline"
+	// ChangeOnline marks an offline asset that was seen again
+	ChangeOnline ChangeType = "online"
+)
+
+// ScanSummary describes a completed scan
+type ScanSummary struct {
+	Timestamp   string `json:"timestamp"`
+	TotalHosts  int    `json:"total_hosts"`
+	ScanTime    string `json:"scan_time"`
+	LocalNet    string `json:"local_network"`
+	FileTargets int    `json:"file_targets"`
+}
+
+// ScanRun is a completed scan recorded in the inventory. A partial run was
+// cancelled or had phases cut short by their deadline; Interrupted lists
+// those phases with the reason.
+type ScanRun struct {
+	ID          uint64    `json:"id"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	PortScan    bool      `json:"port_scan"`
+	Partial     bool      `json:"partial,omitempty"`
+	Interrupted []string  `json:"interrupted,omitempty"`
+	ScanSummary
+	NewAssets     int `json:"new_assets"`
+	OfflineAssets int `json:"offline_assets"`
+	Changes       int `json:"changes"`
+}
+
+// AssetChange is a single entry on an asset's change timeline
+type AssetChange struct {
+	ScanID    uint64     `json:"scan_id"`
+	Timestamp time.Time  `json:"timestamp"`
+	Type      ChangeType `json:"type"`
+	Field     string     `json:"field,omitempty"`
+	OldValue  string     `json:"old_value,omitempty"`
+	NewValue  string     `json:"new_value,omitempty"`
+}
+
+// itob encodes a sequence number as a sortable bucket key
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// putScanRun stores a scan run under its ID
+func putScanRun(tx *bolt.Tx, run ScanRun) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to encode scan %d: %w", run.ID, err)
+	}
+	if err := tx.Bucket(scansBucket).Put(itob(run.ID), data); err != nil {
+		return fmt.Errorf("failed to store scan %d: %w", run.ID, err)
+	}
+	return nil
+}
+
+// appendChanges adds changes observed by a scan to an asset's timeline
+func appendChanges(tx *bolt.Tx, assetID string, run ScanRun, changes []AssetChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	b, err := tx.Bucket(historyBucket).CreateBucketIfNotExists([]byte(assetID))
+	if err != nil {
+		return fmt.Errorf("failed to create history for %s: %w", assetID, err)
+	}
+
+	for _, change := range changes {
+		change.ScanID = run.ID
+		change.Timestamp = run.CompletedAt
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("failed to allocate history entry for %s: %w", assetID, err)
+		}
+		data, err := json.Marshal(change)
+		if err != nil {
+			return fmt.Errorf("failed to encode history entry for %s: %w", assetID, err)
+		}
+		if err := b.Put(itob(seq), data); err != nil {
+			return fmt.Errorf("failed to store history entry for %s: %w", assetID, err)
+		}
+	}
+	return nil
+}
+
+// GetScans returns recorded scan runs, newest first. A limit of zero or less
+// returns every run.
+func (s *Store) GetScans(limit int) ([]ScanRun, error) {
+	scans := []ScanRun{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(scansBucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if limit > 0 && len(scans) >= limit {
+				break
+			}
+			var run ScanRun
+			if err := json.Unmarshal(v, &run); err != nil {
+				return fmt.Errorf("failed to decode scan %d: %w", binary.BigEndian.Uint64(k), err)
+			}
+			scans = append(scans, run)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return scans, nil
+}
+
+// GetLatestScan returns the most recently recorded scan run, if any
+func (s *Store) GetLatestScan() (*ScanRun, bool, error) {
+	scans, err := s.GetScans(1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(scans) == 0 {
+		return nil, false, nil
+	}
+	return &scans[0], true, nil
+}
+
+// GetAssetHistory returns the change timeline of an asset, oldest first
+func (s *Store) GetAssetHistory(assetID string) ([]AssetChange, error) {
+	history := []AssetChange{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(historyBucket)
+		if root == nil {
+			return nil
+		}
+		b := root.Bucket([]byte(assetID))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var change AssetChange
+			if err := json.Unmarshal(v, &change); err != nil {
+				return fmt.Errorf("failed to decode history entry for %s: %w", assetID, err)
+			}
+			history = append(history, change)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"assetmanager/pkg/network"
+)
+
+func TestGetScans(t *testing.T) {
+	s := openTestStore(t)
+	start := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		recordScan(t, s, start.Add(time.Duration(i)*time.Hour), network.Asset{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"})
+	}
+
+	scans, err := s.GetScans(0)
+	if err != nil {
+		t.Fatalf("GetScans: %v", err)
+	}
+	if len(scans) != 3 || scans[0].ID != 3 || scans[2].ID != 1 {
+		t.Fatalf("GetScans(0) = %+v, want scans 3, 2, 1", scans)
+	}
+
+	scans, err = s.GetScans(2)
+	if err != nil {
+		t.Fatalf("GetScans: %v", err)
+	}
+	if len(scans) != 2 || scans[0].ID != 3 {
+		t.Errorf("GetScans(2) = %d scans starting at %d, want 2 starting at 3", len(scans), scans[0].ID)
+	}
+
+	latest, ok, err := s.GetLatestScan()
+	if err != nil || !ok || latest.ID != 3 {
+		t.Errorf("GetLatestScan = %v, %v, %v; want scan 3", latest, ok, err)
+	}
+}
+
+func TestGetLatestScanEmpty(t *testing.T) {
+	s := openTestStore(t)
+	if _, ok, err := s.GetLatestScan(); ok || err != nil {
+		t.Errorf("GetLatestScan on an empty store = %v, %v; want nothing", ok, err)
+	}
+}
+
+func TestGetAssetHistory(t *testing.T) {
+	s := openTestStore(t)
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	id := "aa:bb:cc:00:00:01"
+
+	recordScan(t, s, at, network.Asset{IP: "10.0.0.1", MAC: id, OpenPorts: []network.PortScanResult{openPort(22)}})
+	recordScan(t, s, at.Add(time.Hour), network.Asset{IP: "10.0.0.2", MAC: id, OpenPorts: []network.PortScanResult{openPort(80)}})
+	recordScan(t, s, at.Add(2*time.Hour))
+	recordScan(t, s, at.Add(3*time.Hour), network.Asset{IP: "10.0.0.2", MAC: id, OpenPorts: []network.PortScanResult{openPort(80)}})
+
+	history, err := s.GetAssetHistory(id)
+	if err != nil {
+		t.Fatalf("GetAssetHistory: %v", err)
+	}
+
+	want := []struct {
+		scan   uint64
+		change ChangeType
+		value  string
+	}{
+		{1, ChangeDiscovered, "10.0.0.1"},
+		{2, ChangeIP, "10.0.0.2"},
+		{2, ChangePortOpened, "80/tcp"},
+		{2, ChangePortClosed, ""},
+		{3, ChangeOffline, ""},
+		{4, ChangeOnline, ""},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history = %+v, want %d entries", history, len(want))
+	}
+	for i, w := range want {
+		got := history[i]
+		if got.ScanID != w.scan || got.Type != w.change || got.NewValue != w.value {
+			t.Errorf("history[%d] = scan %d %s %q, want scan %d %s %q", i, got.ScanID, got.Type, got.NewValue, w.scan, w.change, w.value)
+		}
+		if got.Timestamp.IsZero() {
+			t.Errorf("history[%d] has no timestamp", i)
+		}
+	}
+
+	if history, err := s.GetAssetHistory("unknown"); err != nil || len(history) != 0 {
+		t.Errorf("GetAssetHistory(unknown) = %v, %v; want empty", history, err)
+	}
+}
@@ -0,0 +1,248 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"assetmanager/pkg/network"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// assetIndex maps each IP address to the stored assets currently at it.
+// It is kept up to date as a scan is recorded, so later assets of the same
+// scan match the records written by earlier ones.
+type assetIndex struct {
+	keys map[string][]string
+	ips  map[string]string
+}
+
+// addressIndex builds the address index of the stored assets
+func addressIndex(b *bolt.Bucket) (*assetIndex, error) {
+	index := &assetIndex{keys: make(map[string][]string), ips: make(map[string]string)}
+	err := b.ForEach(func(k, v []byte) error {
+		var asset network.Asset
+		if err := json.Unmarshal(v, &asset); err != nil {
+			return fmt.Errorf("failed to decode asset %s: %w", k, err)
+		}
+		index.put(string(k), asset.IP)
+		return nil
+	})
+	return index, err
+}
+
+// at returns the keys of the assets at an IP address
+func (x *assetIndex) at(ip string) []string {
+	return x.keys[ip]
+}
+
+// put records that the asset stored under key is at ip
+func (x *assetIndex) put(key, ip string) {
+	x.remove(key)
+	x.keys[ip] = append(x.keys[ip], key)
+	x.ips[key] = ip
+}
+
+// remove drops an asset that is no longer stored under key
+func (x *assetIndex) remove(key string) {
+	ip, ok := x.ips[key]
+	if !ok {
+		return
+	}
+	keys := x.keys[ip]
+	for i, k := range keys {
+		if k == key {
+			keys = append(keys[:i:i], keys[i+1:]...)
+			break
+		}
+	}
+	if len(keys) == 0 {
+		delete(x.keys, ip)
+	} else {
+		x.keys[ip] = keys
+	}
+	delete(x.ips, key)
+}
+
+// matchAsset finds the stored record a scanned asset belongs to: the one
+// with its asset ID or, failing that, a record at the same IP address that
+// was identified with less information (no MAC address, or no hostname).
+// Records already claimed by this scan are skipped.
+func matchAsset(b *bolt.Bucket, index *assetIndex, claimed map[string]bool, scanned network.Asset) (string, *network.Asset, bool, error) {
+	key := scanned.AssetID()
+	existing, ok, err := getAsset(b, key)
+	if err != nil || ok {
+		return key, existing, ok, err
+	}
+
+	var bestKey string
+	var best *network.Asset
+	for _, candidateKey := range index.at(scanned.IP) {
+		if claimed[candidateKey] {
+			continue
+		}
+		candidate, ok, err := getAsset(b, candidateKey)
+		if err != nil {
+			return "", nil, false, err
+		}
+		if !ok || !sameDevice(*candidate, scanned) {
+			continue
+		}
+		if best == nil || preferRecord(*candidate, *best) {
+			bestKey, best = candidateKey, candidate
+		}
+	}
+
+	if best == nil {
+		return key, nil, false, nil
+	}
+	return bestKey, best, true, nil
+}
+
+// preferRecord reports whether record a is a better match than record b:
+// one with a MAC address wins, then the most recently seen
+func preferRecord(a, b network.Asset) bool {
+	if (a.MAC != "") != (b.MAC != "") {
+		return a.MAC != ""
+	}
+	return a.LastSeen.After(b.LastSeen)
+}
+
+// sameDevice reports whether a stored record at the scanned asset's IP
+// address, whose asset ID differs from it, is the same device. A scan
+// that learned a MAC address claims a record that had none; a scan without
+// one is attributed to the host at that address unless both carry
+// different hostnames.
+func sameDevice(stored, scanned network.Asset) bool {
+	if scanned.MAC != "" {
+		return stored.MAC == ""
+	}
+	if stored.MAC != "" {
+		return true
+	}
+	return stored.Hostname == "" || scanned.Hostname == ""
+}
+
+// rekeyAsset moves an asset record and its timeline from one asset ID to
+// another. A record already stored under the new ID is merged with it.
+func rekeyAsset(tx *bolt.Tx, from string, asset network.Asset) (network.Asset, error) {
+	b := tx.Bucket(assetsBucket)
+	to := asset.AssetID()
+
+	if existing, ok, err := getAsset(b, to); err != nil {
+		return asset, err
+	} else if ok {
+		seenCount := existing.SeenCount + asset.SeenCount
+		online := existing.Online || asset.Online
+		network.MergeAsset(existing, asset)
+		existing.SeenCount = seenCount
+		existing.Online = online
+		asset = *existing
+	}
+
+	if err := b.Delete([]byte(from)); err != nil {
+		return asset, fmt.Errorf("failed to remove asset %s: %w", from, err)
+	}
+	if err := putAsset(b, asset); err != nil {
+		return asset, err
+	}
+	return asset, moveHistory(tx, from, to)
+}
+
+// moveHistory folds the timeline of one asset ID into another, keeping the
+// entries in chronological order
+func moveHistory(tx *bolt.Tx, from, to string) error {
+	history := tx.Bucket(historyBucket)
+	if history.Bucket([]byte(from)) == nil {
+		return nil
+	}
+
+	var entries []AssetChange
+	for _, id := range []string{to, from} {
+		b := history.Bucket([]byte(id))
+		if b == nil {
+			continue
+		}
+		err := b.ForEach(func(k, v []byte) error {
+			var change AssetChange
+			if err := json.Unmarshal(v, &change); err != nil {
+				return fmt.Errorf("failed to decode history entry of %s: %w", id, err)
+			}
+			entries = append(entries, change)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := history.DeleteBucket([]byte(id)); err != nil {
+			return fmt.Errorf("failed to remove history of %s: %w", id, err)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].ScanID != entries[j].ScanID {
+			return entries[i].ScanID < entries[j].ScanID
+		}
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+
+	b, err := history.CreateBucket([]byte(to))
+	if err != nil {
+		return fmt.Errorf("failed to create history for %s: %w", to, err)
+	}
+	for _, change := range entries {
+		seq, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("failed to allocate history entry for %s: %w", to, err)
+		}
+		data, err := json.Marshal(change)
+		if err != nil {
+			return fmt.Errorf("failed to encode history entry for %s: %w", to, err)
+		}
+		if err := b.Put(itob(seq), data); err != nil {
+			return fmt.Errorf("failed to store history entry for %s: %w", to, err)
+		}
+	}
+	return nil
+}
+
+// migrateAssetIDs rekeys assets stored under an older form of their asset
+// ID, such as ARP-discovered hosts recorded by IP before MAC addresses
+// identified them, and fills in the address history of each asset
+func migrateAssetIDs(tx *bolt.Tx) error {
+	b := tx.Bucket(assetsBucket)
+
+	type storedAsset struct {
+		key   string
+		asset network.Asset
+	}
+	var stale []storedAsset
+	err := b.ForEach(func(k, v []byte) error {
+		var asset network.Asset
+		if err := json.Unmarshal(v, &asset); err != nil {
+			return fmt.Errorf("failed to decode asset %s: %w", k, err)
+		}
+		if string(k) != asset.AssetID() || len(asset.Addresses) == 0 {
+			stale = append(stale, storedAsset{string(k), asset})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range stale {
+		item.asset.Addresses = item.asset.AddressHistory()
+		if item.key == item.asset.AssetID() {
+			if err := putAsset(b, item.asset); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := rekeyAsset(tx, item.key, item.asset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"assetmanager/pkg/network"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestRecordScanFollowsMACAcrossAddresses(t *testing.T) {
+	s := openTestStore(t)
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	recordScan(t, s, at,
+		network.Asset{IP: "10.0.0.5", MAC: "aa:bb:cc:00:00:01"},
+		network.Asset{IP: "10.0.0.6", MAC: "aa:bb:cc:00:00:02"},
+	)
+	// The laptop moves to a new lease, and the other two devices swap
+	recordScan(t, s, at.Add(time.Hour),
+		network.Asset{IP: "10.0.0.7", MAC: "aa:bb:cc:00:00:01"},
+		network.Asset{IP: "10.0.0.5", MAC: "aa:bb:cc:00:00:02"},
+	)
+
+	assets, err := s.GetAssets()
+	if err != nil {
+		t.Fatalf("GetAssets: %v", err)
+	}
+	if len(assets) != 2 {
+		t.Fatalf("inventory holds %d assets, want 2", len(assets))
+	}
+
+	moved := mustGetAsset(t, s, "aa:bb:cc:00:00:01")
+	if moved.IP != "10.0.0.7" || len(moved.Addresses) != 2 || moved.Addresses[0].IP != "10.0.0.7" {
+		t.Errorf("moved asset at %s with addresses %v, want 10.0.0.7 then 10.0.0.5", moved.IP, moved.Addresses)
+	}
+	if !moved.Online || moved.SeenCount != 2 {
+		t.Errorf("moved asset online %v, seen count %d; want true, 2", moved.Online, moved.SeenCount)
+	}
+
+	history, err := s.GetAssetHistory("aa:bb:cc:00:00:01")
+	if err != nil {
+		t.Fatalf("GetAssetHistory: %v", err)
+	}
+	if len(history) != 2 || history[1].Type != ChangeIP || history[1].NewValue != "10.0.0.7" {
+		t.Errorf("history = %+v, want discovered then ip_changed to 10.0.0.7", history)
+	}
+}
+
+func TestRecordScanAttributesPortOnlyResults(t *testing.T) {
+	s := openTestStore(t)
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	recordScan(t, s, at, network.Asset{IP: "10.0.0.5", MAC: "aa:bb:cc:00:00:01"})
+	run := recordScan(t, s, at.Add(time.Hour), network.Asset{IP: "10.0.0.5", OpenPorts: []network.PortScanResult{openPort(22)}})
+
+	if run.NewAssets != 0 {
+		t.Errorf("port-only result created %d assets, want it attributed to the MAC host", run.NewAssets)
+	}
+	if asset := mustGetAsset(t, s, "aa:bb:cc:00:00:01"); len(asset.OpenPorts) != 1 || asset.MAC == "" {
+		t.Errorf("MAC host = %+v, want port 22 and its MAC address", asset)
+	}
+}
+
+func TestRecordScanRekeysOnMAC(t *testing.T) {
+	s := openTestStore(t)
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	recordScan(t, s, at, network.Asset{IP: "10.0.0.5", OpenPorts: []network.PortScanResult{openPort(80)}})
+	run := recordScan(t, s, at.Add(time.Hour), network.Asset{IP: "10.0.0.5", MAC: "aa:bb:cc:00:00:01", OpenPorts: []network.PortScanResult{openPort(80)}})
+	if run.NewAssets != 0 {
+		t.Errorf("learning the MAC address created %d assets, want the record rekeyed", run.NewAssets)
+	}
+
+	if _, ok, _ := s.GetAsset("10.0.0.5"); ok {
+		t.Error("record still stored under its IP address")
+	}
+	asset := mustGetAsset(t, s, "aa:bb:cc:00:00:01")
+	if !asset.FirstSeen.Equal(at) || asset.SeenCount != 2 {
+		t.Errorf("rekeyed asset first seen %v, seen count %d; want %v, 2", asset.FirstSeen, asset.SeenCount, at)
+	}
+
+	history, err := s.GetAssetHistory("aa:bb:cc:00:00:01")
+	if err != nil {
+		t.Fatalf("GetAssetHistory: %v", err)
+	}
+	if len(history) == 0 || history[0].Type != ChangeDiscovered || history[0].ScanID != 1 {
+		t.Errorf("history = %+v, want the timeline moved over starting with discovery in scan 1", history)
+	}
+}
+
+func TestRecordScanSharedAddressInOneScan(t *testing.T) {
+	s := openTestStore(t)
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	recordScan(t, s, at, network.Asset{IP: "10.0.0.5", OpenPorts: []network.PortScanResult{openPort(80)}})
+	// The first result rekeys the stored record to its MAC address; the
+	// second, at the same address, is a different named host
+	run := recordScan(t, s, at.Add(time.Hour),
+		network.Asset{IP: "10.0.0.5", MAC: "aa:bb:cc:00:00:01", OpenPorts: []network.PortScanResult{openPort(80)}},
+		network.Asset{IP: "10.0.0.5", Hostname: "printer.local"},
+	)
+	if run.NewAssets != 1 || run.OfflineAssets != 0 {
+		t.Errorf("run = %d new, %d offline; want 1 new, 0 offline", run.NewAssets, run.OfflineAssets)
+	}
+
+	assets, err := s.GetAssets()
+	if err != nil {
+		t.Fatalf("GetAssets: %v", err)
+	}
+	if len(assets) != 2 || assets[0].AssetID() != "aa:bb:cc:00:00:01" || assets[1].AssetID() != "printer.local@10.0.0.5" {
+		t.Errorf("inventory = %+v, want the MAC host and the named host", assets)
+	}
+}
+
+func TestAssetIndex(t *testing.T) {
+	index := &assetIndex{keys: make(map[string][]string), ips: make(map[string]string)}
+	index.put("10.0.0.5", "10.0.0.5")
+	index.put("aa:bb:cc:00:00:01", "10.0.0.5")
+	index.put("aa:bb:cc:00:00:01", "10.0.0.7")
+	index.remove("10.0.0.5")
+	index.remove("unknown")
+
+	if keys := index.at("10.0.0.5"); len(keys) != 0 {
+		t.Errorf("10.0.0.5 holds %v, want nothing after the move and removal", keys)
+	}
+	if keys := index.at("10.0.0.7"); len(keys) != 1 || keys[0] != "aa:bb:cc:00:00:01" {
+		t.Errorf("10.0.0.7 holds %v, want the moved asset", keys)
+	}
+}
+
+func TestOpenMigratesAssetIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "assets.db")
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	// An inventory written before assets were identified by MAC address
+	db, err := bolt.Open(path, 0644, nil)
+	if err != nil {
+		t.Fatalf("bolt.Open: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(assetsBucket)
+		if err != nil {
+			return err
+		}
+		data := `{"ip":"10.0.0.5","mac":"aa:bb:cc:00:00:01","first_seen":"2025-07-01T10:00:00Z","last_seen":"2025-07-01T10:00:00Z","seen_count":1,"online":true}`
+		return b.Put([]byte("10.0.0.5"), []byte(data))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatalf("writing legacy record: %v", err)
+	}
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	if _, ok, _ := s.GetAsset("10.0.0.5"); ok {
+		t.Error("legacy record still stored under its IP address")
+	}
+	asset := mustGetAsset(t, s, "aa:bb:cc:00:00:01")
+	if len(asset.Addresses) != 1 || asset.Addresses[0].IP != "10.0.0.5" || !asset.Addresses[0].FirstSeen.Equal(at) {
+		t.Errorf("migrated addresses = %+v, want 10.0.0.5 since %v", asset.Addresses, at)
+	}
+}
@@ -0,0 +1,382 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"assetmanager/pkg/network"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	assetsBucket    = []byte("assets")
+	scansBucket     = []byte("scans")
+	historyBucket   = []byte("history")
+	snapshotsBucket = []byte("snapshots")
+	findingsBucket  = []byte("findings")
+)
+
+// lockTimeout bounds how long Open waits for another process holding the database
+const lockTimeout = 5 * time.Second
+
+// Store is an embedded on-disk asset inventory backed by BoltDB
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (or creates) the inventory database at path for reading and writing
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inventory database %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{assetsBucket, scansBucket, historyBucket, snapshotsBucket, findingsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("failed to create bucket %s: %w", name, err)
+			}
+		}
+		return migrateAssetIDs(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// OpenReadOnly opens an existing inventory database without taking the write lock
+func OpenReadOnly(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: lockTimeout, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inventory database %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// RecordScan stores a completed scan run, a snapshot of the assets it found,
+// and upserts those assets into the inventory. Assets are matched to stored
+// records by asset ID, or by IP address when the record was identified
+// with less information (see matchAsset).
+// Assets already in the inventory keep their original FirstSeen and have
+// their SeenCount bumped; assets missing from this scan are kept but marked
+// offline. A partial run marks nothing offline, and hosts it found no open
+// ports on keep their stored ports. Field-level changes are appended to
+// each asset's timeline. The stored run, with its assigned ID and change
+// counts, is returned.
+func (s *Store) RecordScan(run ScanRun, assets []network.Asset) (*ScanRun, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(assetsBucket)
+
+		id, err := tx.Bucket(scansBucket).NextSequence()
+		if err != nil {
+			return fmt.Errorf("failed to allocate scan ID: %w", err)
+		}
+		run.ID = id
+
+		index, err := addressIndex(b)
+		if err != nil {
+			return err
+		}
+
+		seen := make(map[string]bool, len(assets))
+		snapshot := make([]network.Asset, 0, len(assets))
+		for _, asset := range assets {
+			key, existing, ok, err := matchAsset(b, index, seen, asset)
+			if err != nil {
+				return err
+			}
+
+			var changes []AssetChange
+			if ok {
+				asset, changes = mergeAsset(*existing, asset, run.PortScan && !run.Partial)
+			} else {
+				asset.Addresses = asset.AddressHistory()
+				asset.SeenCount = 1
+				asset.Online = true
+				changes = []AssetChange{{Type: ChangeDiscovered, NewValue: asset.IP}}
+				run.NewAssets++
+			}
+
+			// Learning a MAC address or hostname can change the asset ID
+			if ok && key != asset.AssetID() {
+				if asset, err = rekeyAsset(tx, key, asset); err != nil {
+					return err
+				}
+				index.remove(key)
+				key = asset.AssetID()
+			}
+			index.put(key, asset.IP)
+
+			if err := putAsset(b, asset); err != nil {
+				return err
+			}
+			if err := appendChanges(tx, key, run, changes); err != nil {
+				return err
+			}
+			seen[key] = true
+			run.Changes += len(changes)
+			snapshot = append(snapshot, asset)
+		}
+
+		if err := putSnapshot(tx, run.ID, snapshot); err != nil {
+			return err
+		}
+
+		// Anything in the inventory this scan did not see has gone offline,
+		// unless the scan stopped before it could have seen it
+		var offline []network.Asset
+		err = b.ForEach(func(k, v []byte) error {
+			if seen[string(k)] || run.Partial {
+				return nil
+			}
+			var asset network.Asset
+			if err := json.Unmarshal(v, &asset); err != nil {
+				return fmt.Errorf("failed to decode asset %s: %w", k, err)
+			}
+			if asset.Online {
+				offline = append(offline, asset)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, asset := range offline {
+			asset.Online = false
+			if err := putAsset(b, asset); err != nil {
+				return err
+			}
+			changes := []AssetChange{{Type: ChangeOffline}}
+			if err := appendChanges(tx, asset.AssetID(), run, changes); err != nil {
+				return err
+			}
+			run.Changes++
+			run.OfflineAssets++
+		}
+
+		return putScanRun(tx, run)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// mergeAsset folds a freshly scanned asset into its stored record and
+// reports the field-level changes between the two. When portsScanned is
+// false and the scan found no ports, the stored ports are kept as they are.
+func mergeAsset(existing, scanned network.Asset, portsScanned bool) (network.Asset, []AssetChange) {
+	var changes []AssetChange
+
+	if !existing.FirstSeen.IsZero() && existing.FirstSeen.Before(scanned.FirstSeen) {
+		scanned.FirstSeen = existing.FirstSeen
+	}
+	if scanned.LastSeen.Before(existing.LastSeen) {
+		scanned.LastSeen = existing.LastSeen
+	}
+
+	if !existing.Online {
+		changes = append(changes, AssetChange{Type: ChangeOnline})
+	}
+
+	scanned.Addresses = network.MergeAddresses(existing.AddressHistory(), scanned.AddressHistory())
+	if network.KeepsIPv4(existing.IP, scanned.IP) {
+		scanned.IP = existing.IP
+	}
+	if existing.IP != "" && existing.IP != scanned.IP {
+		changes = append(changes, AssetChange{Type: ChangeIP, Field: "ip", OldValue: existing.IP, NewValue: scanned.IP})
+	}
+
+	// Keep previously learned identity details when this scan could not see them
+	if scanned.MAC == "" {
+		scanned.MAC = existing.MAC
+		scanned.LocallyAdministered = existing.LocallyAdministered
+	} else if existing.MAC != "" && !strings.EqualFold(existing.MAC, scanned.MAC) {
+		changes = append(changes, AssetChange{Type: ChangeMAC, Field: "mac", OldValue: existing.MAC, NewValue: scanned.MAC})
+	}
+	if scanned.Vendor == "" {
+		scanned.Vendor = existing.Vendor
+	}
+	if len(scanned.Tags) == 0 {
+		scanned.Tags = existing.Tags
+	}
+	if scanned.Hostname == "" {
+		scanned.Hostname = existing.Hostname
+	} else if existing.Hostname != "" && existing.Hostname != scanned.Hostname {
+		changes = append(changes, AssetChange{Type: ChangeHostname, Field: "hostname", OldValue: existing.Hostname, NewValue: scanned.Hostname})
+	}
+
+	if !portsScanned && len(scanned.OpenPorts) == 0 {
+		scanned.OpenPorts = existing.OpenPorts
+	} else {
+		changes = append(changes, diffPorts(existing.OpenPorts, scanned.OpenPorts)...)
+	}
+
+	scanned.SeenCount = existing.SeenCount + 1
+	scanned.Online = true
+	return scanned, changes
+}
+
+// diffPorts reports ports that opened or closed between two port lists
+func diffPorts(before, after []network.PortScanResult) []AssetChange {
+	was := openPortSet(before)
+	now := openPortSet(after)
+
+	var changes []AssetChange
+	for _, key := range sortedKeys(now) {
+		if !was[key] {
+			changes = append(changes, AssetChange{Type: ChangePortOpened, Field: "port", NewValue: key})
+		}
+	}
+	for _, key := range sortedKeys(was) {
+		if !now[key] {
+			changes = append(changes, AssetChange{Type: ChangePortClosed, Field: "port", OldValue: key})
+		}
+	}
+	return changes
+}
+
+// openPortSet returns the open ports of a list keyed as "port/protocol"
+func openPortSet(ports []network.PortScanResult) map[string]bool {
+	set := make(map[string]bool)
+	for _, port := range ports {
+		if port.State == network.PortOpen {
+			set[fmt.Sprintf("%d/%s", port.Port, port.Protocol)] = true
+		}
+	}
+	return set
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// getAsset decodes a single asset from the assets bucket
+func getAsset(b *bolt.Bucket, id string) (*network.Asset, bool, error) {
+	data := b.Get([]byte(id))
+	if data == nil {
+		return nil, false, nil
+	}
+	var asset network.Asset
+	if err := json.Unmarshal(data, &asset); err != nil {
+		return nil, false, fmt.Errorf("failed to decode asset %s: %w", id, err)
+	}
+	return &asset, true, nil
+}
+
+// putAsset encodes an asset into the assets bucket under its asset ID
+func putAsset(b *bolt.Bucket, asset network.Asset) error {
+	key := asset.AssetID()
+	data, err := json.Marshal(asset)
+	if err != nil {
+		return fmt.Errorf("failed to encode asset %s: %w", key, err)
+	}
+	if err := b.Put([]byte(key), data); err != nil {
+		return fmt.Errorf("failed to store asset %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetAssets returns every asset in the inventory ordered by asset ID
+func (s *Store) GetAssets() ([]network.Asset, error) {
+	assets := []network.Asset{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(assetsBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var asset network.Asset
+			if err := json.Unmarshal(v, &asset); err != nil {
+				return fmt.Errorf("failed to decode asset %s: %w", k, err)
+			}
+			assets = append(assets, asset)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].AssetID() < assets[j].AssetID()
+	})
+	return assets, nil
+}
+
+// GetAsset returns a single asset by its asset ID
+func (s *Store) GetAsset(id string) (*network.Asset, bool, error) {
+	var asset *network.Asset
+	var ok bool
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(assetsBucket)
+		if b == nil {
+			return nil
+		}
+		var err error
+		asset, ok, err = getAsset(b, id)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return asset, ok, nil
+}
+
+// FindAssets resolves a reference to assets: an asset ID or IP address, a
+// MAC address in any common notation, or a hostname (case-insensitive,
+// with or without the trailing dot). An asset ID match is returned on its
+// own; otherwise every asset matching the reference is returned.
+func (s *Store) FindAssets(ref string) ([]network.Asset, error) {
+	if asset, ok, err := s.GetAsset(ref); err != nil || ok {
+		if ok {
+			return []network.Asset{*asset}, nil
+		}
+		return nil, err
+	}
+
+	assets, err := s.GetAssets()
+	if err != nil {
+		return nil, err
+	}
+
+	mac, macErr := net.ParseMAC(ref)
+	hostname := strings.TrimSuffix(strings.ToLower(ref), ".")
+
+	var matches []network.Asset
+	for _, asset := range assets {
+		switch {
+		case asset.IP == ref:
+		case macErr == nil && strings.EqualFold(asset.MAC, mac.String()):
+		case hostname != "" && strings.TrimSuffix(strings.ToLower(asset.Hostname), ".") == hostname:
+		default:
+			continue
+		}
+		matches = append(matches, asset)
+	}
+
+	return matches, nil
+}
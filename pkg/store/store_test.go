@@ -0,0 +1,169 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"assetmanager/pkg/network"
+)
+
+// openTestStore opens an inventory in a temporary directory, closed when
+// the test ends
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "assets.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// recordScan records a completed, port-scanned run at the given time
+func recordScan(t *testing.T, s *Store, at time.Time, assets ...network.Asset) *ScanRun {
+	t.Helper()
+	for i := range assets {
+		if assets[i].FirstSeen.IsZero() {
+			assets[i].FirstSeen = at
+		}
+		if assets[i].LastSeen.IsZero() {
+			assets[i].LastSeen = at
+		}
+	}
+	run, err := s.RecordScan(ScanRun{StartedAt: at, CompletedAt: at, PortScan: true}, assets)
+	if err != nil {
+		t.Fatalf("RecordScan: %v", err)
+	}
+	return run
+}
+
+func openPort(port int) network.PortScanResult {
+	return network.PortScanResult{Port: port, Protocol: "tcp", State: network.PortOpen}
+}
+
+func mustGetAsset(t *testing.T, s *Store, id string) network.Asset {
+	t.Helper()
+	asset, ok, err := s.GetAsset(id)
+	if err != nil {
+		t.Fatalf("GetAsset(%s): %v", id, err)
+	}
+	if !ok {
+		t.Fatalf("GetAsset(%s): not found", id)
+	}
+	return *asset
+}
+
+func TestRecordScanUpsertsAssets(t *testing.T) {
+	s := openTestStore(t)
+	first := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	run := recordScan(t, s, first,
+		network.Asset{IP: "192.168.1.10", MAC: "aa:bb:cc:00:00:01", Vendor: "Acme"},
+		network.Asset{IP: "192.168.1.11", MAC: "aa:bb:cc:00:00:02"},
+	)
+	if run.ID != 1 || run.NewAssets != 2 {
+		t.Fatalf("first run = ID %d, %d new; want ID 1, 2 new", run.ID, run.NewAssets)
+	}
+
+	run = recordScan(t, s, second, network.Asset{IP: "192.168.1.10", MAC: "aa:bb:cc:00:00:01"})
+	if run.ID != 2 || run.NewAssets != 0 || run.OfflineAssets != 1 {
+		t.Fatalf("second run = ID %d, %d new, %d offline; want ID 2, 0 new, 1 offline", run.ID, run.NewAssets, run.OfflineAssets)
+	}
+
+	seen := mustGetAsset(t, s, "aa:bb:cc:00:00:01")
+	if !seen.FirstSeen.Equal(first) || !seen.LastSeen.Equal(second) {
+		t.Errorf("first/last seen = %v/%v, want %v/%v", seen.FirstSeen, seen.LastSeen, first, second)
+	}
+	if seen.SeenCount != 2 || !seen.Online {
+		t.Errorf("seen count %d, online %v; want 2, true", seen.SeenCount, seen.Online)
+	}
+	if seen.Vendor != "Acme" {
+		t.Errorf("vendor = %q, want the stored %q kept", seen.Vendor, "Acme")
+	}
+
+	gone := mustGetAsset(t, s, "aa:bb:cc:00:00:02")
+	if gone.Online || gone.SeenCount != 1 {
+		t.Errorf("missing asset online %v, seen count %d; want false, 1", gone.Online, gone.SeenCount)
+	}
+
+	assets, err := s.GetAssets()
+	if err != nil {
+		t.Fatalf("GetAssets: %v", err)
+	}
+	if len(assets) != 2 || assets[0].AssetID() != "aa:bb:cc:00:00:01" {
+		t.Errorf("GetAssets = %d assets starting %v, want 2 ordered by ID", len(assets), assets)
+	}
+}
+
+func TestMergeAsset(t *testing.T) {
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	existing := network.Asset{
+		IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01", Hostname: "old.local", Vendor: "Acme",
+		OpenPorts: []network.PortScanResult{openPort(22)},
+		FirstSeen: at, LastSeen: at, SeenCount: 3, Online: false,
+	}
+	scanned := network.Asset{
+		IP: "10.0.0.9", MAC: "aa:bb:cc:00:00:01", Hostname: "new.local",
+		OpenPorts: []network.PortScanResult{openPort(80)},
+		FirstSeen: at.Add(time.Hour), LastSeen: at.Add(time.Hour),
+	}
+
+	merged, changes := mergeAsset(existing, scanned, true)
+	if !merged.FirstSeen.Equal(at) || merged.SeenCount != 4 || !merged.Online {
+		t.Errorf("merged first seen %v, seen count %d, online %v", merged.FirstSeen, merged.SeenCount, merged.Online)
+	}
+	if merged.Vendor != "Acme" {
+		t.Errorf("merged vendor %q, want the stored value kept", merged.Vendor)
+	}
+
+	want := map[ChangeType]bool{ChangeOnline: true, ChangeIP: true, ChangeHostname: true, ChangePortOpened: true, ChangePortClosed: true}
+	for _, change := range changes {
+		if !want[change.Type] {
+			t.Errorf("unexpected change %+v", change)
+		}
+		delete(want, change.Type)
+	}
+	for missing := range want {
+		t.Errorf("missing %s change", missing)
+	}
+
+	merged, _ = mergeAsset(existing, network.Asset{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"}, false)
+	if len(merged.OpenPorts) != 1 || merged.OpenPorts[0].Port != 22 {
+		t.Errorf("ports without a port scan = %v, want the stored ports", merged.OpenPorts)
+	}
+}
+
+func TestFindAssets(t *testing.T) {
+	s := openTestStore(t)
+	recordScan(t, s, time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC),
+		network.Asset{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01", Hostname: "Router.local."},
+		network.Asset{IP: "203.0.113.5", Hostname: "www.example.com"},
+	)
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"aa:bb:cc:00:00:01", "aa:bb:cc:00:00:01"},
+		{"AA-BB-CC-00-00-01", "aa:bb:cc:00:00:01"},
+		{"10.0.0.1", "aa:bb:cc:00:00:01"},
+		{"router.local", "aa:bb:cc:00:00:01"},
+		{"www.example.com@203.0.113.5", "www.example.com@203.0.113.5"},
+		{"203.0.113.5", "www.example.com@203.0.113.5"},
+		{"10.9.9.9", ""},
+	}
+	for _, tt := range tests {
+		matches, err := s.FindAssets(tt.ref)
+		if err != nil {
+			t.Fatalf("FindAssets(%q): %v", tt.ref, err)
+		}
+		switch {
+		case tt.want == "" && len(matches) != 0:
+			t.Errorf("FindAssets(%q) = %d matches, want none", tt.ref, len(matches))
+		case tt.want != "" && (len(matches) != 1 || matches[0].AssetID() != tt.want):
+			t.Errorf("FindAssets(%q) = %v, want %s", tt.ref, matches, tt.want)
+		}
+	}
+}